@@ -0,0 +1,144 @@
+// Package acdb looks up the registration, type and operator of aircraft
+// by ICAO address, from an aircraft database file.
+package acdb
+
+import (
+	"encoding/csv"
+	"fmt"
+	"go1090/mode_s"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Record of an aircraft in the database. Unknown fields are empty.
+type Record struct {
+	Registration string // e.g. "PH-BXA"
+	TypeCode     string // ICAO type designator, e.g. "B738"
+	Operator     string
+}
+
+// DB is an aircraft database, read-only once loaded.
+type DB struct {
+	records map[uint32]Record
+}
+
+// Column names of the CSV files, lower case, by field. The first names
+// are the ones of the OpenSky aircraft database, then of BaseStation
+// exports.
+var csvColumns = map[string][]string{
+	"icao":         {"icao24", "icao", "hex", "modes", "mode_s"},
+	"registration": {"registration", "reg", "r"},
+	"typecode":     {"typecode", "icaotypecode", "icao_type", "type", "t"},
+	"operator":     {"operator", "registeredowners", "owner", "ownop"},
+}
+
+// Load function.
+// Reads a BaseStation.sqb (SQLite) file, by its .sqb, .sqlite or .db
+// extension, or else a CSV file with a header line.
+func Load(path string) (*DB, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".sqb", ".sqlite", ".db":
+		return loadBaseStation(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("aircraft database error: %s", err.Error())
+	}
+	defer f.Close()
+
+	return LoadCSV(f)
+}
+
+// LoadCSV function.
+// Reads a CSV database, e.g. aircraftDatabase.csv of OpenSky. The header
+// line names the columns, see csvColumns: only the ICAO address is
+// required.
+func LoadCSV(r io.Reader) (*DB, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("aircraft database error: %s", err.Error())
+	}
+	columns := make(map[string]int)
+	for field, names := range csvColumns {
+		columns[field] = -1
+	found:
+		for _, name := range names {
+			for i, h := range header {
+				if strings.EqualFold(strings.TrimSpace(h), name) {
+					columns[field] = i
+					break found
+				}
+			}
+		}
+	}
+	if columns["icao"] < 0 {
+		return nil, fmt.Errorf("aircraft database error: no ICAO address column")
+	}
+
+	db := &DB{records: make(map[uint32]Record)}
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("aircraft database error: %s", err.Error())
+		}
+
+		get := func(field string) string {
+			if i := columns[field]; i >= 0 && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		db.add(get("icao"), Record{
+			Registration: get("registration"),
+			TypeCode:     strings.ToUpper(get("typecode")),
+			Operator:     get("operator"),
+		})
+	}
+	return db, nil
+}
+
+// Add a record, ignoring invalid addresses and empty records.
+func (db *DB) add(hex string, rec Record) {
+	addr, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || addr > 0xffffff || rec == (Record{}) {
+		return
+	}
+	db.records[uint32(addr)] = rec
+}
+
+// Len returns the number of aircraft of the database.
+func (db *DB) Len() int {
+	return len(db.records)
+}
+
+// Lookup function.
+func (db *DB) Lookup(addr uint32) (Record, bool) {
+	rec, ok := db.records[addr]
+	return rec, ok
+}
+
+// Enricher returns an enricher filling the registration, type and
+// operator of the aircraft found in the database.
+func (db *DB) Enricher() mode_s.Enricher {
+	return func(ac *mode_s.Aircraft) {
+		if ac.Addr&mode_s.MODES_NON_ICAO_ADDRESS != 0 {
+			return
+		}
+		if rec, ok := db.records[ac.Addr]; ok {
+			ac.Registration = rec.Registration
+			ac.TypeCode = rec.TypeCode
+			ac.Operator = rec.Operator
+		}
+	}
+}
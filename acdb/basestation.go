@@ -0,0 +1,42 @@
+//go:build sqlite
+
+package acdb
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Read the Aircraft table of a BaseStation.sqb file.
+func loadBaseStation(path string) (*DB, error) {
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("aircraft database error: %s", err.Error())
+	}
+	defer conn.Close()
+
+	rows, err := conn.Query(`SELECT ModeS, Registration, ICAOTypeCode, RegisteredOwners FROM Aircraft`)
+	if err != nil {
+		return nil, fmt.Errorf("aircraft database error: %s", err.Error())
+	}
+	defer rows.Close()
+
+	db := &DB{records: make(map[uint32]Record)}
+	for rows.Next() {
+		var hex, reg, typeCode, operator sql.NullString
+		if err := rows.Scan(&hex, &reg, &typeCode, &operator); err != nil {
+			return nil, fmt.Errorf("aircraft database error: %s", err.Error())
+		}
+		db.add(hex.String, Record{
+			Registration: reg.String,
+			TypeCode:     typeCode.String,
+			Operator:     operator.String,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("aircraft database error: %s", err.Error())
+	}
+	return db, nil
+}
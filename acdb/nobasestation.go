@@ -0,0 +1,11 @@
+//go:build !sqlite
+
+package acdb
+
+import "errors"
+
+// SQLite needs cgo, so BaseStation.sqb files are only read by the builds
+// with the sqlite tag: go build -tags sqlite
+func loadBaseStation(path string) (*DB, error) {
+	return nil, errors.New("aircraft database error: BaseStation files need a build with -tags sqlite, or export the Aircraft table to CSV")
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go1090/output"
+	"go1090/web"
+	"net/http"
+	"strings"
+)
+
+// The admin API controls a running receiver, e.g. a headless one managed
+// remotely. Every endpoint requires "Authorization: Bearer <-admin-token>":
+//
+//	GET  /admin/sinks                       output sinks and raw servers
+//	POST /admin/sinks/<name>/enable|disable
+//	GET  /admin/decoder                     error correction and CRC check
+//	POST /admin/decoder                     {"fix_errors": true, "aggressive": false, "check_crc": true}
+//	POST /admin/save                        write the state of the -store
+//	POST /admin/stats/reset
+//	GET  /admin/sources                     input sources
+//	POST /admin/reload                      reload the -config file
+
+// An input source, as listed by the admin API.
+type sourceInfo struct {
+	Name string `json:"name"` /* A or B */
+	Kind string `json:"kind"` /* rtl_adsb, ifile, replay or stdin */
+	Spec string `json:"spec"` /* command line or file */
+}
+
+// The input sources selected by the flags.
+func inputSources() []sourceInfo {
+	var list []sourceInfo
+	if *ifile != "" {
+		list = append(list, sourceInfo{Name: "A", Kind: "ifile", Spec: *ifile})
+	} else if *replay != "" {
+		list = append(list, sourceInfo{Name: "A", Kind: "replay", Spec: *replay})
+	} else if stdinFrames() {
+		list = append(list, sourceInfo{Name: "A", Kind: "stdin", Spec: "-"})
+	} else {
+		spec := strings.Join(append([]string{*rtlPath}, receiverArgs()...), " ")
+		list = append(list, sourceInfo{Name: "A", Kind: "rtl_adsb", Spec: spec})
+	}
+	if *sourceB != "" {
+		list = append(list, sourceInfo{Name: "B", Kind: "rtl_adsb", Spec: *sourceB})
+	}
+	return list
+}
+
+// Error correction mode and CRC check of the decoder.
+type decoderSettings struct {
+	FixErrors  bool `json:"fix_errors"`
+	Aggressive bool `json:"aggressive"`
+	CheckCRC   bool `json:"check_crc"`
+}
+
+func (ctx *Context) decoderSettings() decoderSettings {
+	fix, aggressive := ctx.decoder.ErrorCorrection()
+	return decoderSettings{FixErrors: fix, Aggressive: aggressive, CheckCRC: ctx.decoder.CheckCRC()}
+}
+
+// Register the admin API.
+func (ctx *Context) registerAdmin(srv *web.Server) {
+	srv.HandleAdmin("/admin/sinks", web.JSONHandler(func() interface{} {
+		return ctx.sinkStatus()
+	}))
+	srv.HandleAdmin("/admin/sinks/", http.HandlerFunc(ctx.handleSinkAction))
+	srv.HandleAdmin("/admin/decoder", http.HandlerFunc(ctx.handleDecoder))
+	srv.HandleAdmin("/admin/save", web.ActionHandler(ctx.saveState))
+	srv.HandleAdmin("/admin/stats/reset", web.ActionHandler(func() error {
+		ctx.stats.Reset()
+		return nil
+	}))
+	srv.HandleAdmin("/admin/sources", web.JSONHandler(func() interface{} {
+		return ctx.sources
+	}))
+	if *configFile != "" {
+		srv.HandleAdmin("/admin/reload", web.ActionHandler(ctx.reload))
+	}
+}
+
+// Name of the sink of a raw output server.
+func rawSinkName(s *output.RawServer) string {
+	return "raw:" + s.Addr().String()
+}
+
+// Status of the output sinks, then of the raw output servers.
+func (ctx *Context) sinkStatus() []output.SinkStatus {
+	list := ctx.outputs.Status()
+
+	ctx.mux.RLock()
+	defer ctx.mux.RUnlock()
+
+	if ctx.reloadable != nil {
+		for _, s := range ctx.reloadable.raw {
+			list = append(list, output.SinkStatus{Name: rawSinkName(s), Enabled: s.Enabled()})
+		}
+	}
+	return list
+}
+
+// Enable or disable a sink by name. Reloading the configuration enables
+// the sinks again.
+func (ctx *Context) setSinkEnabled(name string, enabled bool) error {
+	found := ctx.outputs.SetEnabled(name, enabled)
+
+	ctx.mux.RLock()
+	defer ctx.mux.RUnlock()
+
+	if ctx.reloadable != nil {
+		for _, s := range ctx.reloadable.raw {
+			if rawSinkName(s) == name {
+				s.SetEnabled(enabled)
+				found = true
+			}
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("admin error: unknown sink %q", name)
+	}
+	return nil
+}
+
+// POST /admin/sinks/<name>/enable or /disable.
+func (ctx *Context) handleSinkAction(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/admin/sinks/")
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	name, action := path[:i], path[i+1:]
+	if action != "enable" && action != "disable" {
+		http.NotFound(w, r)
+		return
+	}
+
+	web.ActionHandler(func() error {
+		return ctx.setSinkEnabled(name, action == "enable")
+	}).ServeHTTP(w, r)
+}
+
+// GET or POST /admin/decoder.
+func (ctx *Context) handleDecoder(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		web.JSONHandler(func() interface{} {
+			return ctx.decoderSettings()
+		}).ServeHTTP(w, r)
+		return
+	}
+
+	web.ActionHandler(func() error {
+		s := ctx.decoderSettings()
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			return fmt.Errorf("admin error: %s", err.Error())
+		}
+		ctx.decoder.SetErrorCorrection(s.FixErrors, s.Aggressive)
+		ctx.decoder.SetCheckCRC(s.CheckCRC)
+		return nil
+	}).ServeHTTP(w, r)
+}
+
+// Write out the state buffered by the store sinks.
+func (ctx *Context) saveState() error {
+	var err error
+	ctx.outputs.Each(func(name string, s output.Sink) {
+		if f, ok := s.(output.Flusher); ok && err == nil {
+			err = f.Flush()
+		}
+	})
+	return err
+}
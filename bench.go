@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"go1090/mode_s"
+	"go1090/sim"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Read a corpus of "*...;" frames, optionally preceded by a timestamp.
+func loadCorpus(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var frames [][]byte
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		frame := strings.TrimSuffix(strings.TrimPrefix(fields[len(fields)-1], "*"), ";")
+		msg, err := hex.DecodeString(frame)
+		if err != nil || (len(msg) != mode_s.MODES_SHORT_MSG_BYTES && len(msg) != mode_s.MODES_LONG_MSG_BYTES) {
+			continue
+		}
+
+		/* The decoder always reads a full long message. */
+		buf := make([]byte, mode_s.MODES_LONG_MSG_BYTES)
+		copy(buf, msg)
+		frames = append(frames, buf)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("%s: no frames", path)
+	}
+	return frames, nil
+}
+
+type benchResult struct {
+	frames    int
+	elapsed   time.Duration
+	mallocs   uint64
+	bytes     uint64
+	latencies []time.Duration
+
+	crcOk int    /* frames with a good CRC, possibly after correction */
+	fixed [3]int /* by number of corrected bits */
+}
+
+// Push the corpus through the decoder and the sky 'rounds' times.
+func benchRun(decoder *mode_s.Decoder, sky *mode_s.Sky, corpus [][]byte, n int) benchResult {
+	r := benchResult{latencies: make([]time.Duration, 0, n)}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		t := time.Now()
+
+		msg := mode_s.AcquireMessage()
+		decoder.DecodeModesMessage(msg, corpus[i%len(corpus)])
+		sky.UpdateData(msg)
+
+		r.latencies = append(r.latencies, time.Since(t))
+		if msg.CRCOk() {
+			r.crcOk++
+			r.fixed[msg.CorrectedBits()]++
+		}
+		mode_s.ReleaseMessage(msg)
+	}
+	r.elapsed = time.Since(start)
+
+	runtime.ReadMemStats(&after)
+	r.frames = n
+	r.mallocs = after.Mallocs - before.Mallocs
+	r.bytes = after.TotalAlloc - before.TotalAlloc
+	return r
+}
+
+func (r *benchResult) merge(o benchResult) {
+	r.frames += o.frames
+	r.elapsed += o.elapsed
+	r.mallocs += o.mallocs
+	r.bytes += o.bytes
+	r.latencies = append(r.latencies, o.latencies...)
+	r.crcOk += o.crcOk
+	for i := range r.fixed {
+		r.fixed[i] += o.fixed[i]
+	}
+}
+
+func (r benchResult) print() {
+	sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+	pct := func(p float64) time.Duration {
+		return r.latencies[int(float64(len(r.latencies)-1)*p)]
+	}
+
+	fmt.Printf("frames:       %d in %s\n", r.frames, r.elapsed)
+	fmt.Printf("throughput:   %.0f msgs/sec\n", float64(r.frames)/r.elapsed.Seconds())
+	fmt.Printf("allocations:  %.2f allocs/msg, %.1f bytes/msg\n",
+		float64(r.mallocs)/float64(r.frames), float64(r.bytes)/float64(r.frames))
+	fmt.Printf("latency:      p50 %s  p90 %s  p99 %s  p99.9 %s  max %s\n",
+		pct(0.5), pct(0.9), pct(0.99), pct(0.999), r.latencies[len(r.latencies)-1])
+	fmt.Printf("crc ok:       %.2f%%, fixed %d single bit and %d two bit errors\n",
+		float64(r.crcOk)*100/float64(r.frames), r.fixed[1], r.fixed[2])
+}
+
+// bench subcommand: measure decoding and tracking performance.
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	count := fs.Int("n", 1000000, "Number of frames to decode (not used by the soak test)")
+	corpusFile := fs.String("corpus", "", "File of '*...;' frames to use instead of generated traffic")
+	aircraft := fs.Int("aircraft", 300, "Number of simulated aircraft in the generated corpus")
+	duration := fs.Duration("duration", 0, "Soak test: keep running for this long, reporting periodically")
+	every := fs.Duration("report-every", 10*time.Second, "Soak test: interval between reports")
+	singleBit := fs.Float64("single-bit", 0, "Generated corpus: probability of a frame with one flipped bit")
+	twoBits := fs.Float64("two-bits", 0, "Generated corpus: probability of a frame with two flipped bits")
+	truncate := fs.Float64("truncate", 0, "Generated corpus: probability of a truncated frame")
+	aggressive := fs.Bool("aggressive", false, "Decode with aggressive (two bit) error correction")
+	fs.Parse(args)
+
+	var corpus [][]byte
+	if *corpusFile != "" {
+		var err error
+		if corpus, err = loadCorpus(*corpusFile); err != nil {
+			fmt.Fprintln(os.Stderr, "bench:", err)
+			return 1
+		}
+	} else {
+		gen := sim.NewGenerator(*aircraft, 37.5, 127.0, 1)
+		gen.SetNoise(sim.Noise{SingleBit: *singleBit, TwoBits: *twoBits, Truncate: *truncate})
+		corpus = gen.Corpus(*aircraft * 100)
+
+		n := gen.NoiseStats()
+		fmt.Printf("noise:        %d single bit, %d two bit errors, %d truncated of %d frames\n",
+			n.SingleBit, n.TwoBits, n.Truncated, n.Frames)
+	}
+
+	decoder := mode_s.NewDecoder(mode_s.WithAggressiveCorrection(*aggressive))
+	sky := mode_s.NewSky()
+
+	fmt.Printf("corpus:       %d frames\n", len(corpus))
+
+	if *duration <= 0 {
+		benchRun(decoder, sky, corpus, *count).print()
+		return 0
+	}
+
+	/* Soak test: run batches at full speed, reporting every interval
+	 * and watching memory and the number of tracked aircraft for leaks. */
+	const batch = 10000
+	end := time.Now().Add(*duration)
+	for time.Now().Before(end) {
+		var r benchResult
+		for next := time.Now().Add(*every); time.Now().Before(next); {
+			r.merge(benchRun(decoder, sky, corpus, batch))
+		}
+		sky.RemoveStaleAircrafts()
+
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+
+		fmt.Printf("--- %s\n", time.Now().Format("15:04:05"))
+		r.print()
+		fmt.Printf("heap:         %d KB in use, %d aircraft tracked\n",
+			mem.HeapInuse/1024, sky.AircraftCount())
+	}
+	return 0
+}
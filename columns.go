@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"go1090/i18n"
+	"go1090/mode_s"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// A column of the aircraft list.
+type listColumn struct {
+	header string                 // i18n key of the header.
+	width  func(ctx *Context) int // Negative when left aligned, 0 for the rest of the line.
+	value  func(ctx *Context, ac *mode_s.Aircraft) string
+}
+
+func fixedWidth(w int) func(ctx *Context) int {
+	return func(ctx *Context) int { return w }
+}
+
+// With -extrapolate, the coordinates start with '~' when estimated.
+func (ctx *Context) estimateWidth() int {
+	if ctx.deadReckoning() > 0 {
+		return 1
+	}
+	return 0
+}
+
+func (ctx *Context) estimateMark(estimated bool) string {
+	switch {
+	case estimated:
+		return "~"
+	case ctx.deadReckoning() > 0:
+		return " "
+	}
+	return ""
+}
+
+// Columns of the list by -columns name.
+var listColumns = map[string]listColumn{
+	"icao": {"ui.list.icao", fixedWidth(-7), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return ac.HexAddr
+	}},
+	"flight": {"ui.list.flight", fixedWidth(-8), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return strings.TrimRight(ac.Flight, " \x00")
+	}},
+	"reg": {"ui.list.reg", fixedWidth(-8), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return ac.Registration
+	}},
+	"type": {"ui.list.type", fixedWidth(-4), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return ac.TypeCode
+	}},
+	"country": {"ui.list.country", fixedWidth(-2), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return ac.CountryCode
+	}},
+	"alt": {"ui.list.alt", fixedWidth(6), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return fmt.Sprintf("%d%s", ac.AltitudeIn(ctx.decoder.Units()), climbString(ac))
+	}},
+	"galt": {"ui.list.galt", fixedWidth(6), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return altitudeGeomString(ac, ctx.decoder.Units())
+	}},
+	"vr": {"ui.list.vr", fixedWidth(6), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return vertRateString(ac, ctx.decoder.Units())
+	}},
+	"spd": {"ui.list.spd", fixedWidth(4), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return fmt.Sprint(ac.SpeedIn(ctx.decoder.Units()))
+	}},
+	"trk": {"ui.list.trk", fixedWidth(3), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return ctx.trackString(ac)
+	}},
+	"hdg": {"ui.list.hdg", fixedWidth(3), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return ctx.headingString(ac)
+	}},
+	"lat": {"ui.list.lat", func(ctx *Context) int { return ctx.positionFormat().LatitudeWidth() + ctx.estimateWidth() },
+		func(ctx *Context, ac *mode_s.Aircraft) string {
+			lat, _, estimated := ctx.shownPosition(ac)
+			return ctx.estimateMark(estimated) + ctx.positionFormat().Latitude(lat)
+		}},
+	"lon": {"ui.list.lon", func(ctx *Context) int { return ctx.positionFormat().LongitudeWidth() + ctx.estimateWidth() },
+		func(ctx *Context, ac *mode_s.Aircraft) string {
+			_, lon, estimated := ctx.shownPosition(ac)
+			return ctx.estimateMark(estimated) + ctx.positionFormat().Longitude(lon)
+		}},
+	"dist": {"ui.list.dist", fixedWidth(5), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return distanceString(ac)
+	}},
+	"brg": {"ui.list.brg", fixedWidth(3), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return bearingString(ac)
+	}},
+	"msgs": {"ui.list.msgs", fixedWidth(6), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return fmt.Sprint(ac.Messages)
+	}},
+	"rssi": {"ui.list.rssi", fixedWidth(5), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return rssiString(ac)
+	}},
+	"seen": {"ui.list.seen", fixedWidth(-8), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return ac.Seen.Format("15:04:05")
+	}},
+	"sqwk": {"ui.list.sqwk", fixedWidth(-4), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return squawkString(ac)
+	}},
+	"info": {"ui.list.info", fixedWidth(0), func(ctx *Context, ac *mode_s.Aircraft) string {
+		return squawkMeaning(ac)
+	}},
+}
+
+// Columns shown without -columns; the registration and type follow the
+// flight with -aircraft-db.
+const defaultColumns = "icao,flight,alt,spd,trk,lat,lon,dist,brg,seen,sqwk,info"
+
+// Parse the -columns list of names.
+func parseColumns(spec string) ([]listColumn, error) {
+	if spec == "" {
+		spec = defaultColumns
+		if *aircraftDB != "" {
+			spec = strings.Replace(spec, "flight,", "flight,reg,type,", 1)
+		}
+	}
+
+	var columns []listColumn
+	for _, name := range strings.Split(spec, ",") {
+		c, ok := listColumns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			names := make([]string, 0, len(listColumns))
+			for n := range listColumns {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("columns error: unknown column %q (%s)", name, strings.Join(names, ", "))
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// A cell of the list, padded or truncated to the width of its column.
+func columnCell(s string, width int) string {
+	switch {
+	case width == 0:
+		return s
+	case width < 0:
+		return runewidth.FillRight(runewidth.Truncate(s, -width, ""), -width)
+	}
+	return runewidth.FillLeft(runewidth.Truncate(s, width, ""), width)
+}
+
+// A line of the list, at most maxWidth wide: the cells of the columns,
+// separated by spaces. The columns that do not fit are left out rather
+// than cut, except a last column of width 0, truncated to the rest of the
+// line.
+func (ctx *Context) listLine(maxWidth int, cell func(c listColumn) string) string {
+	ctx.mux.RLock()
+	columns := ctx.columns
+	ctx.mux.RUnlock()
+
+	var b strings.Builder
+	used := 0
+	for _, c := range columns {
+		w := c.width(ctx)
+		if w == 0 {
+			if rest := maxWidth - used - 1; rest > 0 {
+				b.WriteByte(' ')
+				b.WriteString(runewidth.Truncate(cell(c), rest, "~"))
+			}
+			break
+		}
+		if w < 0 {
+			w = -w
+		}
+		if used+1+w > maxWidth {
+			break
+		}
+		used += 1 + w
+		b.WriteByte(' ')
+		b.WriteString(columnCell(cell(c), c.width(ctx)))
+	}
+	return b.String()
+}
+
+// Header line of the list.
+func (ctx *Context) listHeader(maxWidth int) string {
+	return ctx.listLine(maxWidth, func(c listColumn) string {
+		return i18n.S(c.header)
+	})
+}
+
+// Row of an aircraft.
+func (ctx *Context) listRow(ac *mode_s.Aircraft, maxWidth int) string {
+	return ctx.listLine(maxWidth, func(c listColumn) string {
+		return c.value(ctx, ac)
+	})
+}
+
+// Vertical rate in units, if known.
+func altitudeGeomString(ac *mode_s.Aircraft, units mode_s.Units) string {
+	if !ac.AltitudeGeomValid {
+		return ""
+	}
+	return fmt.Sprint(ac.AltitudeGeomIn(units))
+}
+
+func vertRateString(ac *mode_s.Aircraft, units mode_s.Units) string {
+	if !ac.VertRateValid {
+		return ""
+	}
+	return fmt.Sprintf("%+d", ac.VertRateIn(units))
+}
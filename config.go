@@ -0,0 +1,502 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go1090/mode_s"
+	"go1090/output"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// The configuration file is a JSON object of flag values, e.g.
+//
+//	{"squawk-region": "UK", "lat": 51.47, "lon": -0.46, "raw-out": [":30002"]}
+//
+// or the same in YAML (.yaml, .yml) or TOML (.toml). Values can be grouped
+// in sections, whose names are free and only the keys count, e.g.
+//
+//	[receiver]
+//	lat = 51.47
+//	lon = -0.46
+//
+// Flags given on the command line override the file. The file is read
+// again on SIGHUP or POST /admin/reload: the sky settings, the TTL and the
+// outputs are then applied without restarting reception, once all are
+// checked and the new outputs opened; a file with an error changes
+// nothing. Other settings (input source, HTTP address, ...) take effect at
+// the next start.
+
+// Settings applied at startup and again when the configuration file is
+// reloaded: those of the sky, of the user interface and of the reloadable
+// outputs. A reload reads them into new settings, checked and loaded
+// before any is applied, and never writes the flags of the command line,
+// which other goroutines read.
+type settings struct {
+	/* Decoder and sky */
+	fixErrors, noFix, aggressive bool
+	checkCRC                     bool
+	metric                       bool
+	squawkArea                   string
+	aircraftTTL                  ttlFlag
+	receiverLat, receiverLon     float64
+	maxRange                     float64
+	cprPairAge                   time.Duration
+	mergePolicy                  string
+	filterArea, filterExcl       string
+	filterAlt                    string
+	filterRange                  float64
+	watchFile, ignoreFile        string
+	zonesFile                    string
+
+	/* User interface, web and health */
+	headingRef   string
+	declination  float64
+	cardinal     bool
+	coordDigits  int
+	listCols     string
+	extrapolate  time.Duration
+	apiUnits     string
+	adminToken   string
+	healthMaxAge time.Duration
+	privDecimals int
+	privFuzz     float64
+	blockList    string
+
+	/* Reloadable outputs, see openOutputs */
+	cotAddr                             string
+	cotInterval                         time.Duration
+	mqttBroker, mqttTopic, mqttClientID string
+	mqttUser, mqttPassword, mqttUnits   string
+	mqttQoS                             int
+	mqttRetain                          bool
+	mqttInterval                        time.Duration
+	aprsServer, aprsCall, aprsPasscode  string
+	aprsInterval                        time.Duration
+	aprsRate                            int
+	csvFile, eventsFile, rawLogFile     string
+	beastFile                           string
+	webhooks                            rawOutputFlags
+	alertAlt                            int
+	alertRadius, alertRecord            float64
+	alertCool                           time.Duration
+	rawOutputs, beastFeeds              rawOutputFlags
+	archRotate, archMaxAge              time.Duration
+	archCompress                        string
+
+	/* Checked and loaded from the flags, see load. */
+	heading  output.HeadingFormat
+	filter   mode_s.TrafficFilter
+	watch    *mode_s.AircraftList
+	ignore   *mode_s.AircraftList
+	zones    []mode_s.Zone
+	privacy  *output.Privacy
+	position output.PositionFormat
+	columns  []listColumn
+	units    mode_s.Units /* Of the REST API. */
+}
+
+// The settings of the flags, of which readSettings copies those set on the
+// command line.
+var cmdline settings
+
+func init() {
+	cmdline.bind(flag.CommandLine)
+}
+
+// Define the flags of the settings, set to their default.
+func (s *settings) bind(fs *flag.FlagSet) {
+	fs.StringVar(&s.squawkArea, "squawk-region", "ICAO", "Region of special purpose squawk codes (ICAO, US, CA, AU, UK, DE)")
+	fs.DurationVar(&s.healthMaxAge, "health-max-age", 60*time.Second, "Report the input unhealthy when no message was received for this long")
+	fs.StringVar(&s.cotAddr, "cot", "", "Send Cursor-on-Target events to this UDP host:port (e.g. 239.2.3.1:6969), or to a TAK server at tcp://host:port")
+	fs.DurationVar(&s.cotInterval, "cot-interval", 5*time.Second, "Minimum interval between CoT events of one aircraft")
+	fs.StringVar(&s.headingRef, "heading-ref", "true", "North reference of tracks: true or magnetic")
+	fs.Float64Var(&s.declination, "declination", 0, "Magnetic declination at the receiver in degrees, east positive (with -heading-ref magnetic)")
+	fs.BoolVar(&s.cardinal, "cardinal", false, "Show tracks as compass points (N, NNE, ...)")
+	fs.StringVar(&s.mqttBroker, "mqtt", "", "Publish aircraft as JSON to this MQTT broker host[:port]")
+	fs.StringVar(&s.mqttTopic, "mqtt-topic", output.DefaultMQTTTopic, "MQTT topic template, {icao} and {callsign} are replaced")
+	fs.IntVar(&s.mqttQoS, "mqtt-qos", 0, "QoS of the MQTT messages (0 or 1)")
+	fs.BoolVar(&s.mqttRetain, "mqtt-retain", false, "Publish retained MQTT messages, kept by the broker for new subscribers")
+	fs.StringVar(&s.mqttClientID, "mqtt-client-id", "go1090", "MQTT client identifier")
+	fs.StringVar(&s.mqttUser, "mqtt-user", "", "MQTT user name")
+	fs.StringVar(&s.mqttPassword, "mqtt-password", "", "MQTT password")
+	fs.DurationVar(&s.mqttInterval, "mqtt-interval", time.Second, "Minimum interval between MQTT messages of one aircraft")
+	fs.StringVar(&s.apiUnits, "api-units", "", "Default units of the REST API (/api, ?units= overrides): aviation or metric; metric with -metric")
+	fs.StringVar(&s.mqttUnits, "mqtt-units", "", "Units of the MQTT messages: aviation (ft, kt, ft/min) or metric (m, km/h, m/s); metric with -metric")
+	fs.StringVar(&s.aprsServer, "aprs", "", "Publish aircraft as APRS objects to this APRS-IS server host[:port] (e.g. rotate.aprs2.net)")
+	fs.StringVar(&s.aprsCall, "aprs-call", "", "Callsign logging into APRS-IS and sending the objects")
+	fs.StringVar(&s.aprsPasscode, "aprs-passcode", "", "APRS-IS passcode of -aprs-call")
+	fs.DurationVar(&s.aprsInterval, "aprs-interval", 2*time.Minute, "Minimum interval between APRS objects of one aircraft")
+	fs.IntVar(&s.aprsRate, "aprs-rate", 30, "Maximum APRS packets per minute, all aircraft together (0 = no limit)")
+	fs.StringVar(&s.csvFile, "csv", "", "Append every new aircraft position to this CSV file (time, icao, callsign, lat, lon, alt, gs, track, vr, squawk)")
+	fs.IntVar(&s.alertAlt, "alert-alt", 0, "Alert the -webhook of the aircraft flying below this altitude in feet (0 = never), within -alert-radius")
+	fs.Float64Var(&s.alertRadius, "alert-radius", 0, "Distance from the receiver of the -alert-alt aircraft, km (0 = any, else needs -lat/-lon)")
+	fs.Float64Var(&s.alertRecord, "alert-record", 0, "Alert the -webhook of positions farther from the receiver than ever, from this range in km (0 = never, needs -lat/-lon)")
+	fs.DurationVar(&s.alertCool, "alert-cooldown", 10*time.Minute, "Minimum interval between two -webhook alerts of a kind for one aircraft")
+	fs.StringVar(&s.eventsFile, "events", "", "Append aircraft events (anomalies, emergency squawks) to this file as JSON lines")
+	fs.StringVar(&s.rawLogFile, "raw-log", "", "Append the received frames to this file as timestamped AVR lines (@<timestamp><hex>;), for MLAT research or other decoders")
+	fs.StringVar(&s.beastFile, "beast-file", "", "Append the received frames to this file in Beast binary format with their timestamps, for replay into readsb or dump1090")
+	fs.Float64Var(&s.receiverLat, "lat", 0, "Receiver latitude, decimal degrees (with -lon: positions from a single message)")
+	fs.Float64Var(&s.receiverLon, "lon", 0, "Receiver longitude, decimal degrees")
+	fs.Float64Var(&s.maxRange, "max-range", mode_s.MODES_DEFAULT_MAX_RANGE_KM, "Discard positions farther than this from the receiver, in km (0 = no limit)")
+	fs.DurationVar(&s.cprPairAge, "cpr-pair-age", mode_s.MODES_CPR_PAIR_MAX_AGE, "Max time between the even and the odd CPR message of a position decoded from the pair")
+	fs.StringVar(&s.filterArea, "filter-area", "", "Only show the aircraft in these boxes, lat1,lon1,lat2,lon2 separated by ';'")
+	fs.StringVar(&s.filterExcl, "filter-exclude", "", "Hide the aircraft in these boxes, lat1,lon1,lat2,lon2 separated by ';'")
+	fs.Float64Var(&s.filterRange, "filter-range", 0, "Only show the aircraft within this distance of the receiver, in km (0 = any, needs -lat/-lon)")
+	fs.DurationVar(&s.extrapolate, "extrapolate", 0, "Extrapolate the positions not updated from speed and track for this long at most, shown as estimated with '~' (e.g. 30s, 0 = never)")
+	fs.StringVar(&s.filterAlt, "filter-alt", "", "Only show the aircraft in this altitude band, min:max in feet (e.g. :10000 for below 10000 ft)")
+	fs.StringVar(&s.watchFile, "watch", "", "File of aircraft to highlight and raise a watched event for: hex addresses and callsign patterns (KLM*), one per line")
+	fs.StringVar(&s.zonesFile, "zones", "", "File of named zones, circles (name: lat,lon radius_km) or polygons (name: lat,lon; lat,lon; ...), raising events when aircraft enter or leave them")
+	fs.StringVar(&s.ignoreFile, "ignore", "", "File of aircraft whose messages are dropped, e.g. test transmitters, in the format of -watch")
+	fs.DurationVar(&s.archRotate, "archive-rotate", 0, "Start new -events, -csv, -beast-file, -raw-log and -store position files after this long (e.g. 24h, 0 = never)")
+	fs.StringVar(&s.archCompress, "archive-compress", "", "Compress the rotated archive files: none or gzip")
+	fs.DurationVar(&s.archMaxAge, "archive-max-age", 0, "Delete the rotated archive files older than this (e.g. 720h, 0 = never)")
+	fs.BoolVar(&s.fixErrors, "fix", true, "Fix single bit errors of the messages")
+	fs.BoolVar(&s.noFix, "no-fix", false, "Do not fix bit errors (same as -fix=false)")
+	fs.BoolVar(&s.aggressive, "aggressive", false, "Also fix two bit errors of DF17 and accept noisier frames")
+	fs.BoolVar(&s.metric, "metric", false, "Altitudes in meters, speeds in km/h and vertical rates in m/s in the list, the snapshots, MQTT and the REST API (unless set with -mqtt-units, -api-units)")
+	fs.StringVar(&s.listCols, "columns", "", "Columns of the list, comma separated, from icao, flight, reg, type, country, alt, galt, vr, spd, trk, hdg, lat, lon, dist, brg, msgs, rssi, seen, sqwk, info (default "+defaultColumns+", with reg,type after flight with -aircraft-db)")
+	fs.BoolVar(&s.checkCRC, "check-crc", true, "Drop messages with a bad CRC (false: deliver them to the raw outputs and statistics, flagged)")
+	fs.IntVar(&s.privDecimals, "privacy-decimals", -1, "Round the positions of the public outputs (web, CoT) to this many decimals (-1 = exact)")
+	fs.Float64Var(&s.privFuzz, "privacy-fuzz", 0, "Shift the positions of the public outputs by up to this many km, a fixed offset per aircraft")
+	fs.StringVar(&s.blockList, "block", "", "Comma separated hex addresses never shown on the public outputs (web, CoT, raw)")
+	fs.StringVar(&s.adminToken, "admin-token", "", "Enable the /admin API of -http, authenticated with this bearer token")
+	fs.IntVar(&s.coordDigits, "coord-decimals", output.DefaultCoordinateDecimals, "Decimals of the coordinates shown in the list (JSON outputs keep full precision)")
+	fs.StringVar(&s.mergePolicy, "merge", mode_s.MERGE_FRESHEST, "Position of an aircraft seen by several receivers: freshest or average (of the agreeing ones)")
+	fs.Var(&s.rawOutputs, "raw-out", "Serve raw frames (AVR format) over TCP on addr[?filter], e.g. :30002?df=17,18&crc=ok (repeatable)")
+	fs.Var(&s.beastFeeds, "beast-feed", "Connect to an aggregator at host:port and send it the frames in Beast format, reconnecting when needed (repeatable)")
+	fs.Var(&s.webhooks, "webhook", "POST alerts (watched aircraft, emergency squawks, -zones, -alert-alt, -alert-record) to this Discord, Slack or generic JSON webhook URL (repeatable)")
+	s.aircraftTTL = ttlFlag(mode_s.MODES_AIRCRAFT_TTL * time.Second)
+	fs.Var(&s.aircraftTTL, "ttl", "Time an aircraft is kept without receiving any message, in seconds or with a unit (e.g. 300, 5m, 10s)")
+}
+
+// Read the values of a configuration file, by flag name.
+func readConfig(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config error: %s", err.Error())
+	}
+
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		err = json.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config error: %s", err.Error())
+	}
+
+	values := make(map[string]interface{})
+	if err := flattenConfig(doc, values); err != nil {
+		return nil, err
+	}
+
+	for name := range values {
+		if name == "config" || flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("config error: unknown setting %q", name)
+		}
+	}
+	return values, nil
+}
+
+// Read the configuration file into the flags not set on the command line,
+// at startup. The settings are read again by readSettings.
+func loadConfig(path string) error {
+	values, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+
+	var setErr error
+	flag.VisitAll(func(f *flag.Flag) {
+		if v, ok := values[f.Name]; ok && setErr == nil && !isFlagSet(f.Name) {
+			setErr = setFlagValue(f, v)
+		}
+	})
+	return setErr
+}
+
+// Read the settings: the flags given on the command line, else the values
+// of the configuration file (if any), else the defaults. The settings are
+// checked and their files loaded.
+func readSettings(path string) (*settings, error) {
+	values := make(map[string]interface{})
+	if path != "" {
+		var err error
+		if values, err = readConfig(path); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &settings{}
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	s.bind(fs)
+
+	var setErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if setErr != nil {
+			return
+		}
+		if isFlagSet(f.Name) {
+			setErr = copyFlagValue(f, flag.Lookup(f.Name))
+		} else if v, ok := values[f.Name]; ok {
+			setErr = setFlagValue(f, v)
+		}
+	})
+	if setErr != nil {
+		return nil, setErr
+	}
+
+	/* -net: the raw output of dump1090 on its default port. */
+	if *netMode && len(s.rawOutputs) == 0 {
+		s.rawOutputs = append(s.rawOutputs, ":30002")
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Copy the value of a flag of the command line.
+func copyFlagValue(to, from *flag.Flag) error {
+	if list, ok := from.Value.(*rawOutputFlags); ok {
+		for _, v := range *list {
+			to.Value.Set(v)
+		}
+		return nil
+	}
+	return to.Value.Set(from.Value.String())
+}
+
+// Copy the values of the sections to values, by key.
+func flattenConfig(section map[string]interface{}, values map[string]interface{}) error {
+	for name, v := range section {
+		if sub, ok := v.(map[string]interface{}); ok {
+			if err := flattenConfig(sub, values); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := values[name]; ok {
+			return fmt.Errorf("config error: %q set twice", name)
+		}
+		values[name] = v
+	}
+	return nil
+}
+
+// Set a flag from a configuration value, every element of an array for repeatable
+// flags.
+func setFlagValue(f *flag.Flag, v interface{}) error {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case bool:
+		s = strconv.FormatBool(v)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		s = strconv.Itoa(v)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case []interface{}:
+		for _, e := range v {
+			if err := setFlagValue(f, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("config error: invalid value of %q", f.Name)
+	}
+
+	if err := f.Value.Set(s); err != nil {
+		return fmt.Errorf("config error: %s: %s", f.Name, err.Error())
+	}
+	return nil
+}
+
+// Traffic shown, from the -filter flags.
+func (s *settings) trafficFilter() (mode_s.TrafficFilter, error) {
+	var f mode_s.TrafficFilter
+	var err error
+	if f.Include, err = mode_s.ParseGeoBoxes(s.filterArea); err != nil {
+		return f, err
+	}
+	if f.Exclude, err = mode_s.ParseGeoBoxes(s.filterExcl); err != nil {
+		return f, err
+	}
+	if s.filterAlt != "" {
+		band, err := mode_s.ParseAltitudeBand(s.filterAlt)
+		if err != nil {
+			return f, err
+		}
+		f.Altitude = &band
+	}
+	if s.filterRange > 0 && s.receiverLat == 0 && s.receiverLon == 0 {
+		return f, fmt.Errorf("filter error: -filter-range needs the receiver location (-lat, -lon)")
+	}
+	f.MaxRangeKm = s.filterRange
+	return f, nil
+}
+
+// Read a -watch or -ignore list, nil without file.
+func aircraftList(path string) (*mode_s.AircraftList, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return mode_s.LoadAircraftList(path)
+}
+
+// Check the settings and load the files they name.
+func (s *settings) load() error {
+	if s.mergePolicy != mode_s.MERGE_FRESHEST && s.mergePolicy != mode_s.MERGE_AVERAGE {
+		return fmt.Errorf("merge error: unknown policy %q (freshest, average)", s.mergePolicy)
+	}
+
+	magnetic, err := output.ParseHeadingReference(s.headingRef)
+	if err != nil {
+		return err
+	}
+	s.heading = output.HeadingFormat{
+		Magnetic:    magnetic,
+		Declination: s.declination,
+		Cardinal:    s.cardinal,
+	}
+
+	if s.filter, err = s.trafficFilter(); err != nil {
+		return err
+	}
+	if s.watch, err = aircraftList(s.watchFile); err != nil {
+		return err
+	}
+	if s.ignore, err = aircraftList(s.ignoreFile); err != nil {
+		return err
+	}
+	if s.zonesFile != "" {
+		if s.zones, err = mode_s.LoadZones(s.zonesFile); err != nil {
+			return err
+		}
+	}
+
+	blocked, err := output.ParseAddressList(s.blockList)
+	if err != nil {
+		return err
+	}
+	s.privacy = output.NewPrivacy(s.privDecimals, s.privFuzz, blocked)
+
+	if s.position, err = output.NewPositionFormat(s.coordDigits); err != nil {
+		return err
+	}
+	if s.columns, err = parseColumns(s.listCols); err != nil {
+		return err
+	}
+	if s.units, err = s.outputUnits(s.apiUnits); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Apply the settings of the sky and of the user interface, checked by
+// readSettings.
+func (ctx *Context) applySettings(s *settings) {
+	ctx.decoder.SetErrorCorrection(s.fixErrors && !s.noFix, s.aggressive)
+	ctx.decoder.SetCheckCRC(s.checkCRC)
+	ctx.decoder.SetMetricUnits(s.metric)
+	ctx.sky.SetSquawkRegion(s.squawkArea)
+	ctx.sky.SetAircraftTTL(time.Duration(s.aircraftTTL))
+	ctx.sky.SetMaxRange(s.maxRange)
+	ctx.sky.SetCPRPairMaxAge(s.cprPairAge)
+	ctx.sky.SetMergePolicy(s.mergePolicy)
+	if s.receiverLat != 0 || s.receiverLon != 0 {
+		ctx.sky.SetReceiverLocation(s.receiverLat, s.receiverLon)
+	}
+	ctx.sky.SetFilter(s.filter)
+	ctx.sky.SetIgnoreList(s.ignore)
+	ctx.sky.SetWatchList(s.watch)
+	ctx.sky.SetZones(s.zones)
+
+	ctx.mux.Lock()
+	ctx.settings = s
+	ctx.heading = s.heading
+	ctx.position = s.position
+	ctx.reckon = s.extrapolate
+	ctx.columns = s.columns
+	ctx.privacy = s.privacy
+	ctx.apiUnits = s.units
+	ctx.mux.Unlock()
+
+	if ctx.web != nil {
+		ctx.web.SetHeadingFormat(s.heading)
+		ctx.web.SetPrivacy(s.privacy)
+		ctx.web.SetAdminToken(s.adminToken)
+		ctx.web.SetUnits(s.units)
+		ctx.web.SetDeadReckoning(s.extrapolate)
+	}
+	if ctx.rpc != nil {
+		ctx.rpc.SetPrivacy(s.privacy)
+	}
+}
+
+// Settings applied last.
+func (ctx *Context) currentSettings() *settings {
+	ctx.mux.RLock()
+	defer ctx.mux.RUnlock()
+
+	return ctx.settings
+}
+
+// Read the configuration file again and apply it. The new outputs are
+// opened first: on error, nothing is applied.
+func (ctx *Context) reload() error {
+	ctx.reloadMux.Lock()
+	defer ctx.reloadMux.Unlock()
+
+	s, err := readSettings(*configFile)
+	if err == nil {
+		err = ctx.reloadOutputs(s)
+	}
+	if err == nil {
+		ctx.applySettings(s)
+	}
+
+	if err != nil {
+		logConfig.Error("configuration not reloaded", "path", *configFile, "error", err)
+	} else {
+		logConfig.Info("configuration reloaded", "path", *configFile)
+	}
+
+	ctx.mux.Lock()
+	ctx.configErr = err
+	ctx.mux.Unlock()
+	return err
+}
+
+// Reload the configuration on SIGHUP, calling done after every reload.
+func (ctx *Context) watchReload(done func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+
+	go func() {
+		for range c {
+			ctx.reload()
+			done()
+		}
+	}()
+}
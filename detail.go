@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"go1090/i18n"
+	"go1090/mode_s"
+	"strings"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+	. "github.com/logrusorgru/aurora"
+)
+
+func (ctx *Context) toggleDetail(g *gocui.Gui) error {
+	ctx.list.detail = ctx.list.hasSel && !ctx.list.detail
+	return ctx.update(g)
+}
+
+// Show the detail pane of the selected aircraft, or remove it.
+func (ctx *Context) updateDetail(g *gocui.Gui, aircrafts map[uint32]*mode_s.Aircraft) error {
+	ac := aircrafts[ctx.list.selected]
+	if !ctx.list.detail || !ctx.list.hasSel || ac == nil {
+		ctx.list.detail = false
+		if err := g.DeleteView("detail"); err != nil && err != gocui.ErrUnknownView {
+			return err
+		}
+		return nil
+	}
+
+	maxX, maxY := g.Size()
+	v, err := g.SetView("detail", 4, 4, maxX-6, maxY-2, 0)
+	if err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	if _, err := g.SetViewOnTop("detail"); err != nil {
+		return err
+	}
+	v.Title = i18n.T("ui.detail.title", map[string]interface{}{"ICAO": ac.HexAddr})
+	v.Clear()
+	fmt.Fprint(v, ctx.detailText(ac, time.Now()))
+	return nil
+}
+
+// Everything known about an aircraft, a line per topic.
+func (ctx *Context) detailText(ac *mode_s.Aircraft, now time.Time) string {
+	var b strings.Builder
+	line := func(key, format string, args ...interface{}) {
+		fmt.Fprintf(&b, " %-14s %s\n", i18n.S(key), fmt.Sprintf(format, args...))
+	}
+	none := i18n.S("ui.detail.none")
+	orNone := func(s string) string {
+		if s == "" {
+			return none
+		}
+		return s
+	}
+
+	line("ui.detail.flight", "%s", orNone(strings.TrimRight(ac.Flight, " \x00")))
+	if ac.Registration != "" || ac.TypeCode != "" || ac.Operator != "" {
+		line("ui.detail.registration", "%s %s %s", orNone(ac.Registration), ac.TypeCode, ac.Operator)
+	}
+	line("ui.detail.country", "%s", orNone(ac.Country))
+	line("ui.detail.category", "%s  (%s)", orNone(ac.Category), ac.Source)
+	line("ui.detail.squawk", "%s  %s", orNone(squawkString(ac)), ac.SquawkMeaning)
+	if ac.FlightStatusValid {
+		line("ui.detail.flight_status", "%d  %s", ac.FlightStatus, mode_s.FlightStatusString(ac.FlightStatus))
+	} else {
+		line("ui.detail.flight_status", "%s", none)
+	}
+	units := ctx.decoder.Units()
+	line("ui.detail.altitude", "%d %s", ac.AltitudeIn(units), units.AltitudeSymbol())
+	switch {
+	case ac.AltitudeGeomValid && ac.GNSSBaroDiffValid:
+		line("ui.detail.altitude_geom", "%s %s  (%+d)", altitudeGeomString(ac, units), units.AltitudeSymbol(),
+			units.Altitude(ac.GNSSBaroDiff))
+	case ac.AltitudeGeomValid:
+		line("ui.detail.altitude_geom", "%s %s", altitudeGeomString(ac, units), units.AltitudeSymbol())
+	default:
+		line("ui.detail.altitude_geom", "%s", none)
+	}
+	if ac.VertRateValid {
+		line("ui.detail.vert_rate", "%s %s", vertRateString(ac, units), units.VertRateSymbol())
+	} else {
+		line("ui.detail.vert_rate", "%s", none)
+	}
+	line("ui.detail.speed", "%d %s  %s", ac.SpeedIn(units), units.SpeedSymbol(), orNone(ctx.trackString(ac)))
+	if ac.HeadingValid {
+		line("ui.detail.heading", "%s  (%d %s, %s)", ctx.headingString(ac), ac.Heading, ac.HeadingRef, ac.HeadingSource)
+	} else {
+		line("ui.detail.heading", "%s", none)
+	}
+	if ac.Ranged {
+		line("ui.detail.position", "%.5f %.5f  %s km %s", ac.Latitude, ac.Longitude, distanceString(ac), bearingString(ac))
+	} else if ac.Latitude != 0 || ac.Longitude != 0 {
+		line("ui.detail.position", "%.5f %.5f", ac.Latitude, ac.Longitude)
+	} else {
+		line("ui.detail.position", "%s", none)
+	}
+	if lat, lon, estimated := ctx.shownPosition(ac); estimated {
+		last, _ := ac.Trail.Last()
+		line("ui.detail.estimated", "%.5f %.5f  (+%.0fs)", lat, lon, now.Sub(last.Time).Seconds())
+	}
+	line("ui.detail.cpr_even", "%s", cprString(ac.EvenCprLat, ac.EvenCprLon, ac.EvenCprTime, now))
+	line("ui.detail.cpr_odd", "%s", cprString(ac.OddCprLat, ac.OddCprLon, ac.OddCprTime, now))
+	line("ui.detail.adsb", "v%d  NIC %s  NACp %s  NACv %s  SIL %s  NICbaro %s",
+		ac.ADSB.Version, qualityString(ac.ADSB.NIC), qualityString(ac.ADSB.NACp), qualityString(ac.ADSB.NACv),
+		qualityString(ac.ADSB.SIL), qualityString(ac.ADSB.NICBaro))
+	if ac.ACASValid {
+		line("ui.detail.acas", "SL %d  %s", ac.ACAS.SensitivityLevel, mode_s.ReplyInfoString(ac.ACAS.ReplyInfo))
+	} else {
+		line("ui.detail.acas", "%s", none)
+	}
+	line("ui.detail.seen", "%s - %s  (%s)",
+		ac.FirstSeen.Format("15:04:05"), ac.Seen.Format("15:04:05"),
+		now.Sub(ac.Seen).Truncate(time.Second))
+	line("ui.detail.messages", "%d  %s", ac.Messages, histogramString("DF", ac.DFCounts[:]))
+	c := ac.MsgCounts
+	line("ui.detail.msg_rate", "%.1f/s  pos %d  vel %d  ident %d  Comm-B %d  other %d",
+		ac.MessageRate(now), c.Positions, c.Velocities, c.Identification, c.CommB, c.Other)
+	if ac.RSSIValid {
+		line("ui.detail.rssi", "%s dBFS", rssiString(ac))
+	}
+
+	fmt.Fprintf(&b, " %s\n", i18n.S("ui.detail.frames"))
+	for i := len(ac.LastFrames) - 1; i >= 0; i-- {
+		f := ac.LastFrames[i]
+		fmt.Fprintf(&b, "   %s  %s\n", f.Time.Format("15:04:05.000"), Cyan(f.Hex()))
+	}
+	return b.String()
+}
+
+// Raw CPR coordinates and age of an even or odd position message.
+func cprString(lat, lon int, ms int64, now time.Time) string {
+	if ms == 0 {
+		return i18n.S("ui.detail.none")
+	}
+	age := now.Sub(time.Unix(0, ms*int64(time.Millisecond))).Truncate(time.Second)
+	return fmt.Sprintf("%6d %6d  (%s)", lat, lon, age)
+}
+
+// ADS-B quality field, -1 when unknown.
+func qualityString(v int) string {
+	if v < 0 {
+		return "-"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,55 @@
+// Alerting bot: print an alert when an aircraft squawks an emergency code
+// or an anomaly is detected. Frames are read from a raw output port.
+//
+//	go run ./examples/alerts -connect localhost:30002
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"strings"
+
+	"go1090/mode_s"
+	"go1090/rtl_adsb"
+)
+
+func main() {
+	connect := flag.String("connect", "localhost:30002", "Raw output port to read frames from")
+	flag.Parse()
+
+	conn, err := net.Dial("tcp", *connect)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	decoder := mode_s.NewDecoder()
+	sky := mode_s.NewSky()
+
+	sky.AddEventHandler(func(e mode_s.Event) {
+		switch e.Type {
+		case mode_s.EVENT_EMERGENCY_SQUAWK:
+			log.Printf("EMERGENCY %s %s squawk %04d %s",
+				e.Aircraft.HexAddr, callsign(e.Aircraft), e.Squawk, e.Aircraft.SquawkMeaning)
+		case mode_s.EVENT_ANOMALY:
+			log.Printf("ANOMALY %s %s: %s", e.Aircraft.HexAddr, callsign(e.Aircraft), e.Anomaly.Detail)
+		case mode_s.EVENT_ACAS_RA:
+			ra := e.Aircraft.LastRA
+			log.Printf("ACAS RA %s %s: ARA %014b RAC %04b terminated %v",
+				e.Aircraft.HexAddr, callsign(e.Aircraft), ra.ARA, ra.RAC, ra.Terminated)
+		}
+	})
+
+	rtl_adsb.ScanFrames(conn, func(f rtl_adsb.Frame) {
+		mm := mode_s.ModeSMessage{}
+		decoder.DecodeModesMessage(&mm, f.Msg[:])
+		if decoder.Accept(&mm) {
+			sky.UpdateData(&mm)
+		}
+	})
+}
+
+func callsign(ac *mode_s.Aircraft) string {
+	return strings.TrimRight(ac.Flight, " \x00")
+}
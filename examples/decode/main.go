@@ -0,0 +1,68 @@
+// Decode Mode S frames with the mode_s package only, without a receiver.
+//
+//	go run ./examples/decode
+//	go run ./examples/decode 8D4840D6202CC371C32CE0576098
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"go1090/mode_s"
+)
+
+// Identification, airborne position and velocity messages.
+var samples = []string{
+	"8D4840D6202CC371C32CE0576098",
+	"8D40621D58C382D690C8AC2863A7",
+	"8D485020994409940838175B284F",
+}
+
+func main() {
+	frames := samples
+	if len(os.Args) > 1 {
+		frames = os.Args[1:]
+	}
+
+	decoder := mode_s.NewDecoder(mode_s.WithAggressiveCorrection(true))
+
+	for _, frame := range frames {
+		msg, err := hex.DecodeString(strings.Trim(frame, "*;"))
+		if err != nil || len(msg) != mode_s.MODES_LONG_MSG_BYTES && len(msg) != mode_s.MODES_SHORT_MSG_BYTES {
+			fmt.Fprintf(os.Stderr, "%s: invalid frame\n", frame)
+			continue
+		}
+
+		mm := mode_s.ModeSMessage{}
+		decoder.DecodeModesMessage(&mm, msg)
+
+		fmt.Printf("%s DF%d ICAO %06X crc ok %v", frame, mm.DF(), mm.ICAO(), mm.CRCOk())
+		if mm.Corrected() {
+			fmt.Printf(" (%d bit fixed)", mm.CorrectedBits())
+		}
+		if tc := mm.TypeCode(); tc != 0 {
+			fmt.Printf(" TC %d", tc)
+		}
+		if callsign, ok := mm.Callsign(); ok {
+			fmt.Printf(" callsign %s", callsign)
+		}
+		if alt, ok := mm.Altitude(); ok {
+			fmt.Printf(" altitude %d ft", alt)
+		}
+		if lat, lon, odd, ok := mm.CPR(); ok {
+			fmt.Printf(" CPR %d/%d odd %v", lat, lon, odd)
+		}
+		if speed, track, ok := mm.Velocity(); ok {
+			fmt.Printf(" speed %d kt track %d", speed, track)
+		}
+		if rate, ok := mm.VerticalRate(); ok {
+			fmt.Printf(" vertical rate %d ft/min", rate)
+		}
+		if squawk, ok := mm.Squawk(); ok {
+			fmt.Printf(" squawk %04d", squawk)
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,53 @@
+// Read AVR frames from a raw output port (dump1090 30002, go1090 -raw-out,
+// ...) and serve the frames passing a filter on another port.
+//
+//	go run ./examples/feeder -connect localhost:30002 -listen :30003 -filter "df=17&crc=ok"
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+
+	"go1090/mode_s"
+	"go1090/output"
+	"go1090/rtl_adsb"
+)
+
+func main() {
+	connect := flag.String("connect", "localhost:30002", "Raw output port to read frames from")
+	listen := flag.String("listen", ":30003", "Address serving the filtered frames")
+	query := flag.String("filter", "crc=ok", "Frame filter, e.g. df=17,18&icao=4840d6")
+	flag.Parse()
+
+	filter, err := output.ParseFrameFilter(*query)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server, err := output.NewRawServer(*listen, filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", *connect)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	log.Printf("feeding %s to %s", *connect, server.Addr())
+
+	decoder := mode_s.NewDecoder()
+	rtl_adsb.ScanFrames(conn, func(f rtl_adsb.Frame) {
+		mm := mode_s.ModeSMessage{}
+		decoder.DecodeModesMessage(&mm, f.Msg[:])
+		mm.SetReceived(*connect, f.Received)
+		if decoder.Accept(&mm) {
+			server.Forward(&mm)
+		}
+	})
+
+	log.Printf("%s closed the connection", *connect)
+}
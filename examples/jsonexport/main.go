@@ -0,0 +1,62 @@
+// Track the aircraft of a frame log (or of simulated traffic) and export
+// them as JSON.
+//
+//	go run ./examples/jsonexport -in frames.txt > aircraft.json
+//	go run ./examples/jsonexport -aircraft 20
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"sort"
+
+	"go1090/mode_s"
+	"go1090/rtl_adsb"
+	"go1090/sim"
+)
+
+func main() {
+	in := flag.String("in", "", "File of '*...;' frames, simulated traffic if empty")
+	aircraft := flag.Int("aircraft", 10, "Number of simulated aircraft")
+	flag.Parse()
+
+	decoder := mode_s.NewDecoder()
+	sky := mode_s.NewSky()
+
+	decode := func(msg []byte) {
+		mm := mode_s.ModeSMessage{}
+		decoder.DecodeModesMessage(&mm, msg)
+		if decoder.Accept(&mm) {
+			sky.UpdateData(&mm)
+		}
+	}
+
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rtl_adsb.ScanFrames(f, func(frame rtl_adsb.Frame) {
+			decode(frame.Msg[:])
+		})
+		f.Close()
+	} else {
+		for _, msg := range sim.NewGenerator(*aircraft, 37.5, 127.0, 1).Corpus(*aircraft * 10) {
+			decode(msg)
+		}
+	}
+
+	var list []*mode_s.Aircraft
+	for _, ac := range sky.Aircrafts() {
+		list = append(list, ac)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Addr < list[j].Addr })
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(list); err != nil {
+		log.Fatal(err)
+	}
+}
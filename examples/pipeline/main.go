@@ -0,0 +1,76 @@
+// Decode a frame log with channels: a source stage, a decoding stage and
+// two consumers fed by a fan-out stage, stopped with Ctrl-C.
+//
+//	go run ./examples/pipeline -replay frames.log
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"go1090/mode_s"
+	"go1090/rtl_adsb"
+)
+
+func main() {
+	replay := flag.String("replay", "frames.log", "Log of timestamped '*...;' frames")
+	speed := flag.Float64("speed", 0, "Replay speed multiplier (0 = as fast as possible)")
+	flag.Parse()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	var source rtl_adsb.Source = rtl_adsb.ReplaySource{Path: *replay, Speed: *speed, Name: "log"}
+	frames, err := source.Frames(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	decoder := mode_s.NewDecoder()
+	messages := decoder.Stream(ctx, frames)
+
+	// Fan out every message to the two consumers.
+	toSky := make(chan *mode_s.ModeSMessage, 16)
+	toCount := make(chan *mode_s.ModeSMessage, 16)
+	go func() {
+		defer close(toSky)
+		defer close(toCount)
+		for mm := range messages {
+			toSky <- mm
+			toCount <- mm
+		}
+	}()
+
+	sky := mode_s.NewSky()
+	skyDone := make(chan struct{})
+	go func() {
+		defer close(skyDone)
+		for mm := range toSky {
+			sky.UpdateData(mm)
+		}
+	}()
+
+	counts := make(map[int]int)
+	for mm := range toCount {
+		counts[mm.DF()]++
+	}
+	<-skyDone
+
+	for df := 0; df < 32; df++ {
+		if n := counts[df]; n > 0 {
+			fmt.Printf("DF%-2d %d\n", df, n)
+		}
+	}
+	fmt.Printf("%d aircraft\n", len(sky.Aircrafts()))
+}
@@ -1,10 +1,18 @@
 module go1090
 
-go 1.14
+go 1.24
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/awesome-gocui/gocui v0.6.0
+	github.com/klauspost/compress v1.18.0
 	github.com/logrusorgru/aurora v2.0.3+incompatible
-	github.com/mattn/go-runewidth v0.0.9 // indirect
-	github.com/patrickmn/go-cache v2.1.0+incompatible
+	github.com/mattn/go-runewidth v0.0.9
+	github.com/mattn/go-sqlite3 v1.14.22
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/awesome-gocui/termbox-go v0.0.0-20190427202837-c0aef3d18bcc // indirect
+	github.com/go-errors/errors v1.0.1 // indirect
 )
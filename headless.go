@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go1090/mode_s"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// Formats of the messages printed with -no-interactive.
+const (
+	printCompact = "compact" // A line per message.
+	printVerbose = "verbose" // Fields of the message a line each, see ModeSMessage.WriteTo.
+	printJSON    = "json"    // A JSON object per line, see ModeSMessage.MarshalJSON.
+)
+
+// Printer of the decoded messages to w, by -print format.
+func newMessagePrinter(format string, w io.Writer) (func(mm *mode_s.ModeSMessage), error) {
+	switch format {
+	case printCompact:
+		return func(mm *mode_s.ModeSMessage) {
+			io.WriteString(w, compactMessage(mm)+"\n")
+		}, nil
+	case printVerbose:
+		return func(mm *mode_s.ModeSMessage) {
+			mm.WriteTo(w)
+		}, nil
+	case printJSON:
+		enc := json.NewEncoder(w)
+		return func(mm *mode_s.ModeSMessage) {
+			enc.Encode(mm)
+		}, nil
+	}
+	return nil, fmt.Errorf("print error: unknown format %q (%s, %s or %s)", format, printCompact, printVerbose, printJSON)
+}
+
+// Message on a line: time, DF, address and the decoded fields, e.g.
+// "12:00:00.000 DF17 4840D6 TC4 flight=KLM1023 cat=A3".
+func compactMessage(mm *mode_s.ModeSMessage) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s DF%d %06X", mm.Provenance().Received.Format("15:04:05.000"), mm.DF(), mm.ICAO())
+	if mm.IsExtendedSquitter() {
+		fmt.Fprintf(&b, " TC%d", mm.TypeCode())
+	}
+	if !mm.CRCOk() {
+		b.WriteString(" crc=bad")
+	} else if mm.Corrected() {
+		fmt.Fprintf(&b, " fixed=%d", mm.CorrectedBits())
+	}
+	if level, ok := mm.SignalLevel(); ok {
+		fmt.Fprintf(&b, " rssi=%.1f", 10*math.Log10(level))
+	}
+
+	if flight, ok := mm.Callsign(); ok {
+		fmt.Fprintf(&b, " flight=%s", flight)
+	}
+	if cat, ok := mm.Category(); ok {
+		fmt.Fprintf(&b, " cat=%s", cat)
+	}
+	if squawk, ok := mm.Squawk(); ok {
+		fmt.Fprintf(&b, " squawk=%04d", squawk)
+	}
+	if alt, ok := mm.Altitude(); ok {
+		fmt.Fprintf(&b, " alt=%d", alt)
+	}
+	if alt, ok := mm.GeometricAltitude(); ok {
+		fmt.Fprintf(&b, " alt_geom=%d", alt)
+	}
+	if lat, lon, odd, ok := mm.CPR(); ok {
+		fmt.Fprintf(&b, " cpr=%s/%d/%d", evenOdd(odd), lat, lon)
+	}
+	if speed, track, ok := mm.Velocity(); ok {
+		fmt.Fprintf(&b, " gs=%d track=%d", speed, track)
+	}
+	if hdg, ok := mm.Heading(); ok {
+		fmt.Fprintf(&b, " hdg=%d", hdg)
+	}
+	if vr, ok := mm.VerticalRate(); ok {
+		fmt.Fprintf(&b, " vr=%+d", vr)
+	}
+	if diff, ok := mm.GNSSBaroDiff(); ok {
+		fmt.Fprintf(&b, " gnss_diff=%+d", diff)
+	}
+	return b.String()
+}
+
+func evenOdd(odd bool) string {
+	if odd {
+		return "odd"
+	}
+	return "even"
+}
+
+// Run without the user interface until SIGINT or SIGTERM.
+func waitForSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+	signal.Stop(c)
+}
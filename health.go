@@ -0,0 +1,75 @@
+package main
+
+import (
+	"go1090/mode_s"
+	"go1090/web"
+	"sync/atomic"
+	"time"
+)
+
+// Record the reception of a message, for the health checks.
+func (ctx *Context) markMessage(msg *mode_s.ModeSMessage) {
+	atomic.StoreInt64(&ctx.lastMessage, time.Now().UnixNano())
+	ctx.stats.Count(msg)
+}
+
+// Age of the last received message. Before the first message, the time
+// since startup.
+func (ctx *Context) lastMessageAge() time.Duration {
+	last := atomic.LoadInt64(&ctx.lastMessage)
+	if last == 0 {
+		return time.Since(ctx.started)
+	}
+	return time.Since(time.Unix(0, last))
+}
+
+// Register the /healthz checks of the input source and the frame queue.
+func (ctx *Context) registerHealthChecks(srv *web.Server) {
+	srv.AddHealthCheck("input", func() (bool, map[string]interface{}) {
+		age := ctx.lastMessageAge()
+		maxAge := ctx.currentSettings().healthMaxAge
+		return age <= maxAge, map[string]interface{}{
+			"messages":         ctx.stats.Messages(),
+			"last_message_age": age.Seconds(),
+			"max_age":          maxAge.Seconds(),
+		}
+	})
+
+	srv.AddHealthCheck("receivers", func() (bool, map[string]interface{}) {
+		ok := true
+		info := make(map[string]interface{})
+		for source, st := range ctx.receiverStates() {
+			ok = ok && st.Running
+			info[source] = map[string]interface{}{
+				"running":  st.Running,
+				"restarts": st.Restarts,
+			}
+		}
+		return ok, info
+	})
+
+	srv.AddHealthCheck("outputs", func() (bool, map[string]interface{}) {
+		behind := ctx.outputs.Behind()
+		return len(behind) == 0, map[string]interface{}{
+			"behind": behind,
+		}
+	})
+
+	srv.AddHealthCheck("feeders", func() (bool, map[string]interface{}) {
+		ok := true
+		info := make(map[string]interface{})
+		for _, st := range ctx.feederStatus() {
+			ok = ok && st.Connected
+			info[st.Addr] = st
+		}
+		return ok, info
+	})
+
+	srv.AddHealthCheck("queue", func() (bool, map[string]interface{}) {
+		depth, capacity := len(ctx.frames), cap(ctx.frames)
+		return depth*10 < capacity*9, map[string]interface{}{
+			"depth":    depth,
+			"capacity": capacity,
+		}
+	})
+}
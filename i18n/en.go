@@ -0,0 +1,192 @@
+package i18n
+
+var english = map[string]string{
+	/* Capability table. */
+	"ca.0": "Level 1 (Survillance Only)",
+	"ca.1": "Level 2 (DF0,4,5,11)",
+	"ca.2": "Level 3 (DF0,4,5,11,20,21)",
+	"ca.3": "Level 4 (DF0,4,5,11,20,21,24)",
+	"ca.4": "Level 2+3+4 (DF0,4,5,11,20,21,24,code7 - is on ground)",
+	"ca.5": "Level 2+3+4 (DF0,4,5,11,20,21,24,code7 - is on airborne)",
+	"ca.6": "Level 2+3+4 (DF0,4,5,11,20,21,24,code7)",
+	"ca.7": "Level 7 ???",
+
+	/* Flight status table. */
+	"fs.0": "Normal, Airborne",
+	"fs.1": "Normal, On the ground",
+	"fs.2": "ALERT,  Airborne",
+	"fs.3": "ALERT,  On the ground",
+	"fs.4": "ALERT & Special Position Identification. Airborne or Ground",
+	"fs.5": "Special Position Identification. Airborne or Ground",
+	"fs.6": "Value 6 is not assigned",
+	"fs.7": "Value 7 is not assigned",
+
+	/* Downlink request table (DF4, DF5, DF20, DF21). */
+	"dr.0":          "No downlink request",
+	"dr.1":          "Request to send Comm-B message",
+	"dr.2":          "ACAS message available",
+	"dr.3":          "Comm-B message and ACAS message available",
+	"dr.4":          "Comm-B broadcast message 1 available",
+	"dr.5":          "Comm-B broadcast message 2 available",
+	"dr.6":          "Comm-B broadcast message 1 and ACAS message available",
+	"dr.7":          "Comm-B broadcast message 2 and ACAS message available",
+	"dr.unassigned": "Value {{.Value}} is not assigned",
+	"dr.elm":        "Request to send {{.Segments}} segment ELM",
+
+	/* Utility message (DF4, DF5, DF20, DF21): IIS and IDS subfields. */
+	"um":       "Interrogator {{.IIS}}: {{.Reservation}}",
+	"um.ids.0": "No reservation",
+	"um.ids.1": "Comm-B reservation",
+	"um.ids.2": "Comm-C reservation",
+	"um.ids.3": "Comm-D reservation",
+
+	/* Reply information table (DF0, DF16). */
+	"ri.0":  "No operating ACAS",
+	"ri.1":  "Value 1 is not assigned",
+	"ri.2":  "ACAS with resolution capability inhibited",
+	"ri.3":  "ACAS with vertical-only resolution capability",
+	"ri.4":  "ACAS with vertical and horizontal resolution capability",
+	"ri.5":  "Value 5 is not assigned",
+	"ri.6":  "Value 6 is not assigned",
+	"ri.7":  "Value 7 is not assigned",
+	"ri.8":  "No maximum airspeed data",
+	"ri.9":  "Maximum airspeed up to 75 kt",
+	"ri.10": "Maximum airspeed 75 to 150 kt",
+	"ri.11": "Maximum airspeed 150 to 300 kt",
+	"ri.12": "Maximum airspeed 300 to 600 kt",
+	"ri.13": "Maximum airspeed 600 to 1200 kt",
+	"ri.14": "Maximum airspeed over 1200 kt",
+	"ri.15": "Value 15 is not assigned",
+
+	/* Extended squitter message descriptions. */
+	"me.identification":    "Aircraft Identification and Category",
+	"me.surface_position":  "Surface Position",
+	"me.airborne_baro":     "Airborne Position (Baro Altitude)",
+	"me.airborne_velocity": "Airborne Velocity",
+	"me.airborne_gnss":     "Airborne Position (GNSS Height)",
+	"me.test":              "Test Message",
+	"me.surface_status":    "Surface System Status",
+	"me.status_emergency":  "Extended Squitter Aircraft Status (Emergency)",
+	"me.status_tcas_ra":    "Extended Squitter Aircraft Status (1090ES TCAS RA)",
+	"me.target_state":      "Target State and Status Message",
+	"me.operational":       "Aircraft Operational Status Message",
+	"me.unknown":           "Unknown",
+
+	/* Emergency/priority status (TC 28 subtype 1). */
+	"emergency.0": "No emergency",
+	"emergency.1": "General emergency",
+	"emergency.2": "Lifeguard / medical emergency",
+	"emergency.3": "Minimum fuel",
+	"emergency.4": "No communications",
+	"emergency.5": "Unlawful interference",
+	"emergency.6": "Downed aircraft",
+	"emergency.7": "Reserved",
+
+	/* Squawk codes */
+	"squawk.hijack":             "Hijack",
+	"squawk.radio_failure":      "Radio failure",
+	"squawk.emergency":          "Emergency",
+	"squawk.ifr_conspicuity":    "IFR conspicuity",
+	"squawk.no_code":            "No code assigned",
+	"squawk.vfr":                "VFR",
+	"squawk.vfr_high":           "VFR above 12500 ft",
+	"squawk.glider":             "Glider",
+	"squawk.firefighting":       "Firefighting",
+	"squawk.sar":                "Search and rescue",
+	"squawk.military_vfr":       "Military VFR",
+	"squawk.uas_lost_link":      "UAS lost link",
+	"squawk.interceptor":        "Military interceptor",
+	"squawk.hems":               "Air ambulance (HEMS)",
+	"squawk.parachute":          "Parachute dropping",
+	"squawk.military_low_level": "Military low level",
+	"squawk.aerobatics":         "Aerobatics and display",
+	"squawk.circuit":            "VFR aerodrome circuit",
+
+	/* Anomalies */
+	"anomaly.position_jump": "Position jumped {{.Km}} km in {{.Seconds}} s",
+	"anomaly.altitude_jump": "Altitude jumped from {{.From}} to {{.To}} ft",
+	"anomaly.speed":         "Implausible ground speed {{.Speed}} kt",
+	"anomaly.slow_at_alt":   "Ground speed {{.Speed}} kt too slow at {{.Altitude}} ft",
+	"anomaly.fast_at_low":   "Ground speed {{.Speed}} kt too fast at {{.Altitude}} ft",
+
+	/* TUI */
+	"ui.status.title":             " STATUS (?: help) ",
+	"ui.status.empty":             " A/C: --  LAST UPDATE: 0000-00-00 00:00:00",
+	"ui.status.clock":             "  CLOCK: {{.Drift}} ppm",
+	"ui.status.clock_bad":         "  CLOCK: UNUSABLE ({{.Reason}})",
+	"ui.status.compare":           "  A/B FIRST: {{.AFirst}}/{{.BFirst}}  ONLY: {{.AOnly}}/{{.BOnly}}",
+	"ui.status.receiver_down":     "  RTL_ADSB {{.Source}} DOWN: {{.Error}}, RETRY IN {{.Retry}}s",
+	"ui.status.receiver_restarts": "  RTL_ADSB {{.Source}} RESTARTS: {{.Count}}",
+	"ui.status.emergency":         "  SQUAWK ALERT: {{.Aircraft}}",
+	"ui.status.watched":           "  WATCHED: {{.Aircraft}}",
+	"ui.status.sinks_behind":      "  OUTPUT BEHIND: {{.Sinks}}",
+	"ui.status.history":           "  1H: {{.Sparkline}}",
+	"ui.status.config_error":      "  CONFIG: {{.Error}}",
+	"ui.status.line":              " A/C: {{.Count}}  LAST UPDATE: {{.Time}}",
+	"ui.status.paused":            "  PAUSED",
+	"ui.status.snapshot":          "  SAVED {{.Count}} A/C TO {{.File}}",
+	"ui.status.snapshot_error":    "  SNAPSHOT: {{.Error}}",
+	"ui.list.title":               " A/C ",
+	"ui.list.title_hidden":        " A/C ({{.Above}} above, {{.Below}} below) ",
+	"ui.list.icao":                "ICAO",
+	"ui.list.flight":              "FLIGHT",
+	"ui.list.reg":                 "REG",
+	"ui.list.type":                "TYPE",
+	"ui.list.country":             "CC",
+	"ui.list.alt":                 "ALT ",
+	"ui.list.galt":                "GALT",
+	"ui.list.vr":                  "V/S",
+	"ui.list.spd":                 "SPD",
+	"ui.list.trk":                 "TRK",
+	"ui.list.hdg":                 "HDG",
+	"ui.list.lat":                 "LAT",
+	"ui.list.lon":                 "LON",
+	"ui.list.dist":                "DIST",
+	"ui.list.brg":                 "BRG",
+	"ui.list.msgs":                "MSGS",
+	"ui.list.rssi":                "RSSI",
+	"ui.list.seen":                "SEEN",
+	"ui.list.sqwk":                "SQWK",
+	"ui.list.info":                "",
+	"ui.radar.title":              " RADAR ",
+	"ui.radar.title_range":        " RADAR {{.Range}} km ",
+	"ui.radar.no_location":        " Set the receiver location with -lat and -lon",
+	"ui.help.title":               " KEYS (Esc: close) ",
+	"ui.help.help":                "Show or hide this help",
+	"ui.help.up":                  "Select the previous aircraft",
+	"ui.help.down":                "Select the next aircraft",
+	"ui.help.page_up":             "Select a page above",
+	"ui.help.page_down":           "Select a page below",
+	"ui.help.home":                "Select the first aircraft",
+	"ui.help.end":                 "Select the last aircraft",
+	"ui.help.detail":              "Open or close the detail of the selection",
+	"ui.help.pause":               "Pause or resume the list (decoding goes on)",
+	"ui.help.radar":               "Show or hide the radar panel",
+	"ui.help.snapshot":            "Save the list to a timestamped -snapshot file",
+	"ui.help.close":               "Close the help or the detail",
+	"ui.help.quit":                "Quit",
+	"ui.detail.title":             " {{.ICAO}} (Esc: close) ",
+	"ui.detail.none":              "-",
+	"ui.detail.flight":            "Flight",
+	"ui.detail.registration":      "Registration",
+	"ui.detail.country":           "Country",
+	"ui.detail.category":          "Category",
+	"ui.detail.squawk":            "Squawk",
+	"ui.detail.altitude":          "Altitude",
+	"ui.detail.altitude_geom":     "GNSS altitude",
+	"ui.detail.vert_rate":         "Vertical rate",
+	"ui.detail.speed":             "Speed, track",
+	"ui.detail.heading":           "Heading",
+	"ui.detail.position":          "Position",
+	"ui.detail.estimated":         "Estimated",
+	"ui.detail.cpr_even":          "CPR even",
+	"ui.detail.cpr_odd":           "CPR odd",
+	"ui.detail.adsb":              "ADS-B",
+	"ui.detail.acas":              "ACAS",
+	"ui.detail.flight_status":     "Flight status",
+	"ui.detail.seen":              "First/last",
+	"ui.detail.messages":          "Messages",
+	"ui.detail.msg_rate":          "Msg rate",
+	"ui.detail.rssi":              "Signal",
+	"ui.detail.frames":            "Last frames:",
+}
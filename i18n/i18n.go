@@ -0,0 +1,183 @@
+// Package i18n is the message catalog for every human-readable string
+// shown by go1090 (description tables and TUI text).
+//
+// Each string is identified by a key and is a text/template, so
+// integrators can translate or customize the output by loading an
+// override file. Missing keys fall back to English, then to the key itself.
+package i18n
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"text/template"
+)
+
+// Catalog is a set of message templates for one locale.
+type Catalog struct {
+	locale    string
+	messages  map[string]string
+	templates map[string]*template.Template
+
+	mux sync.Mutex
+}
+
+/* Built-in locales. */
+var locales = map[string]map[string]string{
+	"en": english,
+	"ko": korean,
+}
+
+var (
+	current    = mustNew("en")
+	currentMux sync.RWMutex
+)
+
+func mustNew(locale string) *Catalog {
+	c, err := New(locale)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// New returns a catalog for one of the built-in locales.
+func New(locale string) (*Catalog, error) {
+	base, ok := locales[locale]
+	if !ok {
+		return nil, fmt.Errorf("unknown locale: %s", locale)
+	}
+
+	c := &Catalog{
+		locale:    locale,
+		messages:  make(map[string]string, len(base)),
+		templates: make(map[string]*template.Template),
+	}
+	for k, v := range base {
+		c.messages[k] = v
+	}
+	return c, nil
+}
+
+// Locales returns the names of the built-in locales.
+func Locales() []string {
+	names := make([]string, 0, len(locales))
+	for name := range locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Locale returns the locale name of the catalog.
+func (c *Catalog) Locale() string {
+	return c.locale
+}
+
+// Set overrides a single message template.
+func (c *Catalog) Set(key, text string) error {
+	tmpl, err := template.New(key).Parse(text)
+	if err != nil {
+		return fmt.Errorf("message %s: %s", key, err.Error())
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.messages[key] = text
+	c.templates[key] = tmpl
+	return nil
+}
+
+// LoadOverrides reads a JSON object of key/template pairs from file and
+// applies them on top of the catalog.
+func (c *Catalog) LoadOverrides(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	overrides := make(map[string]string)
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	for k, v := range overrides {
+		if err := c.Set(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Catalog) template(key string) *template.Template {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if tmpl, ok := c.templates[key]; ok {
+		return tmpl
+	}
+
+	text, ok := c.messages[key]
+	if !ok {
+		if text, ok = english[key]; !ok {
+			return nil
+		}
+	}
+
+	tmpl, err := template.New(key).Parse(text)
+	if err != nil {
+		return nil
+	}
+	c.templates[key] = tmpl
+	return tmpl
+}
+
+// T renders the message template 'key' with 'data'.
+// If the key is unknown, the key itself is returned.
+func (c *Catalog) T(key string, data interface{}) string {
+	tmpl := c.template(key)
+	if tmpl == nil {
+		return key
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return key
+	}
+	return buf.String()
+}
+
+// S returns the message 'key' without template data.
+func (c *Catalog) S(key string) string {
+	return c.T(key, nil)
+}
+
+// Use makes 'c' the catalog returned by Current.
+func Use(c *Catalog) {
+	currentMux.Lock()
+	defer currentMux.Unlock()
+
+	current = c
+}
+
+// Current returns the catalog in use (English by default).
+func Current() *Catalog {
+	currentMux.RLock()
+	defer currentMux.RUnlock()
+
+	return current
+}
+
+// T renders 'key' using the current catalog.
+func T(key string, data interface{}) string {
+	return Current().T(key, data)
+}
+
+// S returns 'key' from the current catalog.
+func S(key string) string {
+	return Current().S(key)
+}
@@ -0,0 +1,192 @@
+package i18n
+
+var korean = map[string]string{
+	/* Capability table. */
+	"ca.0": "레벨 1 (감시 전용)",
+	"ca.1": "레벨 2 (DF0,4,5,11)",
+	"ca.2": "레벨 3 (DF0,4,5,11,20,21)",
+	"ca.3": "레벨 4 (DF0,4,5,11,20,21,24)",
+	"ca.4": "레벨 2+3+4 (DF0,4,5,11,20,21,24,code7 - 지상)",
+	"ca.5": "레벨 2+3+4 (DF0,4,5,11,20,21,24,code7 - 비행 중)",
+	"ca.6": "레벨 2+3+4 (DF0,4,5,11,20,21,24,code7)",
+	"ca.7": "레벨 7 ???",
+
+	/* Flight status table. */
+	"fs.0": "정상, 비행 중",
+	"fs.1": "정상, 지상",
+	"fs.2": "경보, 비행 중",
+	"fs.3": "경보, 지상",
+	"fs.4": "경보 및 특수 위치 식별. 비행 중 또는 지상",
+	"fs.5": "특수 위치 식별. 비행 중 또는 지상",
+	"fs.6": "값 6은 할당되지 않음",
+	"fs.7": "값 7은 할당되지 않음",
+
+	/* Downlink request table (DF4, DF5, DF20, DF21). */
+	"dr.0":          "다운링크 요청 없음",
+	"dr.1":          "Comm-B 메시지 송신 요청",
+	"dr.2":          "ACAS 메시지 있음",
+	"dr.3":          "Comm-B 메시지 및 ACAS 메시지 있음",
+	"dr.4":          "Comm-B 방송 메시지 1 있음",
+	"dr.5":          "Comm-B 방송 메시지 2 있음",
+	"dr.6":          "Comm-B 방송 메시지 1 및 ACAS 메시지 있음",
+	"dr.7":          "Comm-B 방송 메시지 2 및 ACAS 메시지 있음",
+	"dr.unassigned": "값 {{.Value}}은 할당되지 않음",
+	"dr.elm":        "{{.Segments}} 세그먼트 ELM 송신 요청",
+
+	/* Utility message (DF4, DF5, DF20, DF21): IIS and IDS subfields. */
+	"um":       "질문기 {{.IIS}}: {{.Reservation}}",
+	"um.ids.0": "예약 없음",
+	"um.ids.1": "Comm-B 예약",
+	"um.ids.2": "Comm-C 예약",
+	"um.ids.3": "Comm-D 예약",
+
+	/* Reply information table (DF0, DF16). */
+	"ri.0":  "작동 중인 ACAS 없음",
+	"ri.1":  "값 1은 할당되지 않음",
+	"ri.2":  "회피 기능이 억제된 ACAS",
+	"ri.3":  "수직 회피 기능만 있는 ACAS",
+	"ri.4":  "수직 및 수평 회피 기능이 있는 ACAS",
+	"ri.5":  "값 5는 할당되지 않음",
+	"ri.6":  "값 6은 할당되지 않음",
+	"ri.7":  "값 7은 할당되지 않음",
+	"ri.8":  "최대 대기속도 정보 없음",
+	"ri.9":  "최대 대기속도 75 kt 이하",
+	"ri.10": "최대 대기속도 75~150 kt",
+	"ri.11": "최대 대기속도 150~300 kt",
+	"ri.12": "최대 대기속도 300~600 kt",
+	"ri.13": "최대 대기속도 600~1200 kt",
+	"ri.14": "최대 대기속도 1200 kt 초과",
+	"ri.15": "값 15는 할당되지 않음",
+
+	/* Extended squitter message descriptions. */
+	"me.identification":    "항공기 식별 및 분류",
+	"me.surface_position":  "지상 위치",
+	"me.airborne_baro":     "비행 위치 (기압 고도)",
+	"me.airborne_velocity": "비행 속도",
+	"me.airborne_gnss":     "비행 위치 (GNSS 고도)",
+	"me.test":              "시험 메시지",
+	"me.surface_status":    "지상 시스템 상태",
+	"me.status_emergency":  "확장 스퀴터 항공기 상태 (비상)",
+	"me.status_tcas_ra":    "확장 스퀴터 항공기 상태 (1090ES TCAS RA)",
+	"me.target_state":      "목표 상태 메시지",
+	"me.operational":       "항공기 운용 상태 메시지",
+	"me.unknown":           "알 수 없음",
+
+	/* Emergency/priority status (TC 28 subtype 1). */
+	"emergency.0": "비상 아님",
+	"emergency.1": "일반 비상",
+	"emergency.2": "구급 / 의료 비상",
+	"emergency.3": "최소 연료",
+	"emergency.4": "통신 두절",
+	"emergency.5": "불법 간섭",
+	"emergency.6": "추락 항공기",
+	"emergency.7": "예약됨",
+
+	/* Squawk codes */
+	"squawk.hijack":             "납치",
+	"squawk.radio_failure":      "무선 두절",
+	"squawk.emergency":          "비상",
+	"squawk.ifr_conspicuity":    "IFR 식별",
+	"squawk.no_code":            "코드 미할당",
+	"squawk.vfr":                "VFR",
+	"squawk.vfr_high":           "VFR 12500 ft 이상",
+	"squawk.glider":             "글라이더",
+	"squawk.firefighting":       "소방",
+	"squawk.sar":                "수색 구조",
+	"squawk.military_vfr":       "군용 VFR",
+	"squawk.uas_lost_link":      "무인기 통신 두절",
+	"squawk.interceptor":        "군 요격기",
+	"squawk.hems":               "응급 의료 헬기",
+	"squawk.parachute":          "낙하산 강하",
+	"squawk.military_low_level": "군 저고도 비행",
+	"squawk.aerobatics":         "곡예 비행",
+	"squawk.circuit":            "VFR 장주 비행",
+
+	/* Anomalies */
+	"anomaly.position_jump": "위치가 {{.Seconds}}초 만에 {{.Km}} km 이동",
+	"anomaly.altitude_jump": "고도가 {{.From}} ft에서 {{.To}} ft로 급변",
+	"anomaly.speed":         "비정상 대지 속도 {{.Speed}} kt",
+	"anomaly.slow_at_alt":   "고도 {{.Altitude}} ft에서 대지 속도 {{.Speed}} kt는 너무 느림",
+	"anomaly.fast_at_low":   "고도 {{.Altitude}} ft에서 대지 속도 {{.Speed}} kt는 너무 빠름",
+
+	/* TUI */
+	"ui.status.title":             " 상태 (?: 도움말) ",
+	"ui.status.empty":             " 항공기: --  최근 갱신: 0000-00-00 00:00:00",
+	"ui.status.clock":             "  시계: {{.Drift}} ppm",
+	"ui.status.clock_bad":         "  시계: 사용 불가 ({{.Reason}})",
+	"ui.status.compare":           "  A/B 선착: {{.AFirst}}/{{.BFirst}}  단독: {{.AOnly}}/{{.BOnly}}",
+	"ui.status.receiver_down":     "  rtl_adsb {{.Source}} 중단: {{.Error}}, {{.Retry}}초 후 재시작",
+	"ui.status.receiver_restarts": "  rtl_adsb {{.Source}} 재시작: {{.Count}}회",
+	"ui.status.emergency":         "  비상 스쿽: {{.Aircraft}}",
+	"ui.status.watched":           "  관심 항공기: {{.Aircraft}}",
+	"ui.status.sinks_behind":      "  출력 지연: {{.Sinks}}",
+	"ui.status.history":           "  1시간: {{.Sparkline}}",
+	"ui.status.config_error":      "  설정 오류: {{.Error}}",
+	"ui.status.line":              " 항공기: {{.Count}}  최근 갱신: {{.Time}}",
+	"ui.status.paused":            "  일시 정지",
+	"ui.status.snapshot":          "  항공기 {{.Count}}대 저장: {{.File}}",
+	"ui.status.snapshot_error":    "  스냅샷 오류: {{.Error}}",
+	"ui.list.title":               " 항공기 ",
+	"ui.list.icao":                "ICAO",
+	"ui.list.flight":              "편명",
+	"ui.list.reg":                 "등록",
+	"ui.list.type":                "기종",
+	"ui.list.country":             "CC",
+	"ui.list.alt":                 "고도 ",
+	"ui.list.galt":                "GNSS",
+	"ui.list.vr":                  "상승률",
+	"ui.list.spd":                 "속도",
+	"ui.list.trk":                 "진로",
+	"ui.list.hdg":                 "기수",
+	"ui.list.lat":                 "위도",
+	"ui.list.lon":                 "경도",
+	"ui.list.dist":                "거리",
+	"ui.list.brg":                 "방위",
+	"ui.list.msgs":                "메시지",
+	"ui.list.rssi":                "신호",
+	"ui.list.seen":                "수신",
+	"ui.list.sqwk":                "스쿽",
+	"ui.list.info":                "",
+	"ui.list.title_hidden":        " 항공기 (위 {{.Above}}, 아래 {{.Below}}) ",
+	"ui.radar.title":              " 레이더 ",
+	"ui.radar.title_range":        " 레이더 {{.Range}} km ",
+	"ui.radar.no_location":        " -lat, -lon 옵션으로 수신기 위치를 지정하세요",
+	"ui.help.title":               " 키 (Esc: 닫기) ",
+	"ui.help.help":                "도움말 보기/닫기",
+	"ui.help.up":                  "이전 항공기 선택",
+	"ui.help.down":                "다음 항공기 선택",
+	"ui.help.page_up":             "한 페이지 위 선택",
+	"ui.help.page_down":           "한 페이지 아래 선택",
+	"ui.help.home":                "첫 항공기 선택",
+	"ui.help.end":                 "마지막 항공기 선택",
+	"ui.help.detail":              "선택한 항공기 상세 정보 보기/닫기",
+	"ui.help.pause":               "목록 일시 정지/재개 (해독은 계속)",
+	"ui.help.radar":               "레이더 패널 보기/닫기",
+	"ui.help.snapshot":            "목록을 시각이 붙은 -snapshot 파일로 저장",
+	"ui.help.close":               "도움말 또는 상세 정보 닫기",
+	"ui.help.quit":                "종료",
+	"ui.detail.title":             " {{.ICAO}} (Esc: 닫기) ",
+	"ui.detail.none":              "-",
+	"ui.detail.flight":            "편명",
+	"ui.detail.registration":      "등록번호",
+	"ui.detail.country":           "국가",
+	"ui.detail.category":          "분류",
+	"ui.detail.squawk":            "스쿽",
+	"ui.detail.altitude":          "고도",
+	"ui.detail.altitude_geom":     "GNSS 고도",
+	"ui.detail.vert_rate":         "수직 속도",
+	"ui.detail.speed":             "속도, 진로",
+	"ui.detail.heading":           "기수 방향",
+	"ui.detail.position":          "위치",
+	"ui.detail.estimated":         "추정 위치",
+	"ui.detail.cpr_even":          "CPR 짝수",
+	"ui.detail.cpr_odd":           "CPR 홀수",
+	"ui.detail.adsb":              "ADS-B",
+	"ui.detail.acas":              "ACAS",
+	"ui.detail.flight_status":     "비행 상태",
+	"ui.detail.seen":              "최초/최근",
+	"ui.detail.messages":          "메시지",
+	"ui.detail.msg_rate":          "수신율",
+	"ui.detail.rssi":              "신호 세기",
+	"ui.detail.frames":            "최근 프레임:",
+}
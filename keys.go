@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"go1090/i18n"
+	"strings"
+
+	"github.com/awesome-gocui/gocui"
+)
+
+// A key of the user interface. Every key is registered from this table,
+// which is also the content of the help overlay: a new feature adds its
+// keys here.
+type keyBinding struct {
+	key     interface{} // gocui.Key or rune.
+	name    string      // As shown in the help, e.g. "Ctrl+C".
+	help    string      // i18n key of the description.
+	handler func(ctx *Context, g *gocui.Gui) error
+}
+
+// Move the selection by a number of rows computed at the key press.
+func moveBy(delta func(ctx *Context, g *gocui.Gui) int) func(ctx *Context, g *gocui.Gui) error {
+	return func(ctx *Context, g *gocui.Gui) error {
+		return ctx.moveSelection(g, delta(ctx, g))
+	}
+}
+
+// The keys, in the order of the help.
+func keyBindings() []keyBinding {
+	return []keyBinding{
+		{'?', "?", "ui.help.help", (*Context).toggleHelp},
+		{gocui.KeyArrowUp, "Up", "ui.help.up", moveBy(func(ctx *Context, g *gocui.Gui) int { return -1 })},
+		{gocui.KeyArrowDown, "Down", "ui.help.down", moveBy(func(ctx *Context, g *gocui.Gui) int { return 1 })},
+		{gocui.KeyPgup, "PgUp", "ui.help.page_up", moveBy(func(ctx *Context, g *gocui.Gui) int { return -listPage(g) })},
+		{gocui.KeyPgdn, "PgDn", "ui.help.page_down", moveBy(func(ctx *Context, g *gocui.Gui) int { return listPage(g) })},
+		{gocui.KeyHome, "Home", "ui.help.home", moveBy(func(ctx *Context, g *gocui.Gui) int { return -len(ctx.list.listed) })},
+		{gocui.KeyEnd, "End", "ui.help.end", moveBy(func(ctx *Context, g *gocui.Gui) int { return len(ctx.list.listed) })},
+		{gocui.KeyEnter, "Enter", "ui.help.detail", (*Context).toggleDetail},
+		{'p', "p", "ui.help.pause", (*Context).togglePause},
+		{'s', "s", "ui.help.snapshot", (*Context).saveSnapshot},
+		{'r', "r", "ui.help.radar", (*Context).toggleRadar},
+		{gocui.KeyEsc, "Esc", "ui.help.close", (*Context).closeOverlay},
+		{gocui.KeyCtrlC, "Ctrl+C", "ui.help.quit", func(ctx *Context, g *gocui.Gui) error { return gocui.ErrQuit }},
+	}
+}
+
+// Register the keys of keyBindings.
+func (ctx *Context) bindKeys(g *gocui.Gui) error {
+	for _, k := range keyBindings() {
+		handler := k.handler
+		f := func(g *gocui.Gui, v *gocui.View) error {
+			return handler(ctx, g)
+		}
+		if err := g.SetKeybinding("", k.key, gocui.ModNone, f); err != nil {
+			return fmt.Errorf("key %s: %s", k.name, err.Error())
+		}
+	}
+	return nil
+}
+
+func (ctx *Context) toggleHelp(g *gocui.Gui) error {
+	ctx.list.help = !ctx.list.help
+	return ctx.update(g)
+}
+
+// Close the help overlay, or else the detail pane.
+func (ctx *Context) closeOverlay(g *gocui.Gui) error {
+	if ctx.list.help {
+		ctx.list.help = false
+	} else {
+		ctx.list.detail = false
+	}
+	return ctx.update(g)
+}
+
+// Show the help overlay, or remove it.
+func (ctx *Context) updateHelp(g *gocui.Gui) error {
+	if !ctx.list.help {
+		if err := g.DeleteView("help"); err != nil && err != gocui.ErrUnknownView {
+			return err
+		}
+		return nil
+	}
+
+	maxX, maxY := g.Size()
+	width, height := 60, len(keyBindings())+1
+	x0, y0 := (maxX-width)/2, (maxY-height)/2
+	if x0 < 0 {
+		x0 = 0
+	}
+	if y0 < 0 {
+		y0 = 0
+	}
+	v, err := g.SetView("help", x0, y0, x0+width, y0+height+1, 0)
+	if err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	if _, err := g.SetViewOnTop("help"); err != nil {
+		return err
+	}
+	v.Title = i18n.S("ui.help.title")
+	v.Clear()
+	fmt.Fprint(v, helpText())
+	return nil
+}
+
+// A line per key of keyBindings.
+func helpText() string {
+	var b strings.Builder
+	for _, k := range keyBindings() {
+		fmt.Fprintf(&b, " %-8s %s\n", k.name, i18n.S(k.help))
+	}
+	return b.String()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"go1090/i18n"
+	"go1090/mode_s"
+	"go1090/output"
+	"time"
+
+	"github.com/awesome-gocui/gocui"
+	. "github.com/logrusorgru/aurora"
+)
+
+// Selection and scrolling of the aircraft list. The key handlers and
+// update run in the main loop of gocui, so no lock is needed.
+type listState struct {
+	listed   []uint32 // Addresses of the listed aircraft, in list order.
+	selected uint32
+	hasSel   bool
+	top      int  // Index in listed of the first aircraft shown.
+	detail   bool // Detail pane of the selected aircraft open.
+	help     bool // Help overlay open.
+	radar    bool // Radar panel shown.
+
+	paused bool                        // List frozen, decoding going on.
+	frozen map[uint32]*mode_s.Aircraft // Aircraft shown while paused.
+	notice string                      // Result of the last snapshot, for the status line.
+
+	width, height int // Size of the terminal at the last layout.
+}
+
+// Aircraft of the list: the frozen ones while paused.
+func (ctx *Context) shownAircrafts() map[uint32]*mode_s.Aircraft {
+	if ctx.list.paused {
+		return ctx.list.frozen
+	}
+	return ctx.sky.Aircrafts()
+}
+
+// Freeze the list, or let it follow the sky again.
+func (ctx *Context) togglePause(g *gocui.Gui) error {
+	ctx.list.paused = !ctx.list.paused
+	ctx.list.frozen = nil
+	if ctx.list.paused {
+		ctx.list.frozen = ctx.sky.Aircrafts()
+	}
+	return ctx.update(g)
+}
+
+// Save the aircraft of the list to a -snapshot file.
+func (ctx *Context) saveSnapshot(g *gocui.Gui) error {
+	shown := ctx.shownAircrafts()
+	aircraft := make([]*mode_s.Aircraft, 0, len(ctx.list.listed))
+	for _, addr := range ctx.list.listed {
+		if ac := shown[addr]; ac != nil {
+			aircraft = append(aircraft, ac)
+		}
+	}
+
+	name, err := output.WriteSnapshot(*snapshotFile, aircraft, time.Now(), ctx.decoder.Units())
+	if err != nil {
+		ctx.list.notice = i18n.T("ui.status.snapshot_error", map[string]interface{}{
+			"Error": Red(err.Error()),
+		})
+	} else {
+		ctx.list.notice = i18n.T("ui.status.snapshot", map[string]interface{}{
+			"File":  Green(name),
+			"Count": len(aircraft),
+		})
+	}
+	return ctx.update(g)
+}
+
+// Rows of a page of the list, for Page Up and Page Down.
+func listPage(g *gocui.Gui) int {
+	if v, err := g.View("list"); err == nil {
+		return listViewRows(v)
+	}
+	return 1
+}
+
+// Move the selection by delta rows, the first aircraft being selected
+// when there is no selection yet.
+func (ctx *Context) moveSelection(g *gocui.Gui, delta int) error {
+	l := &ctx.list
+	if len(l.listed) == 0 {
+		return nil
+	}
+
+	i := 0
+	if j := l.index(l.selected); l.hasSel && j >= 0 {
+		i = j + delta
+	}
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(l.listed) {
+		i = len(l.listed) - 1
+	}
+
+	l.selected, l.hasSel = l.listed[i], true
+	return ctx.update(g)
+}
+
+// Index of an aircraft in the list, -1 if it is not listed.
+func (l *listState) index(addr uint32) int {
+	for i, a := range l.listed {
+		if a == addr {
+			return i
+		}
+	}
+	return -1
+}
+
+// Rows of the list view left for the aircraft, below the header lines.
+func listViewRows(v *gocui.View) int {
+	_, h := v.Size()
+	if h -= 2; h < 1 {
+		return 1
+	}
+	return h
+}
+
+// Range of the listed aircraft shown in rows lines, scrolled so that the
+// selection is visible.
+func (l *listState) window(rows int) (from, to int) {
+	if i := l.index(l.selected); l.hasSel && i >= 0 {
+		if i < l.top {
+			l.top = i
+		}
+		if i >= l.top+rows {
+			l.top = i - rows + 1
+		}
+	}
+	if l.top > len(l.listed)-rows {
+		l.top = len(l.listed) - rows
+	}
+	if l.top < 0 {
+		l.top = 0
+	}
+
+	to = l.top + rows
+	if to > len(l.listed) {
+		to = len(l.listed)
+	}
+	return l.top, to
+}
+
+// Title of the list, with the number of aircraft hidden above and below.
+func listTitle(from, to, total int) string {
+	if from == 0 && to == total {
+		return i18n.S("ui.list.title")
+	}
+	return i18n.T("ui.list.title_hidden", map[string]interface{}{
+		"Above": from,
+		"Below": total - to,
+	})
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go1090/stats"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Loggers of the modules, each with its level (-log-level, -log-modules).
+// They discard everything until openLog.
+var (
+	logMain     = slog.New(slog.NewTextHandler(io.Discard, nil))
+	logReceiver = logMain // rtl_adsb and the other inputs.
+	logDecoder  = logMain // Every frame, at debug level.
+	logOutput   = logMain // Errors of the output sinks.
+	logConfig   = logMain // Configuration reloads.
+	logStats    = logMain // Periodic -stats-every blocks.
+)
+
+// Loggers by -log-modules name.
+var logModules = map[string]**slog.Logger{
+	"main":     &logMain,
+	"receiver": &logReceiver,
+	"decoder":  &logDecoder,
+	"output":   &logOutput,
+	"config":   &logConfig,
+	"stats":    &logStats,
+}
+
+// Handler dropping the records below the level of its module.
+type levelHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+func (h *levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{h.Handler.WithAttrs(attrs), h.level}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{h.Handler.WithGroup(name), h.level}
+}
+
+// Send the log to the -log file, as text or JSON lines. Without it, the
+// log is discarded, the user interface owning the terminal, or goes to
+// stderr with -no-interactive. The log package writes to the main module.
+func openLog() (func(), error) {
+	level, err := parseLogLevel(*logLevel)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := parseLogModules(*logModLevels)
+	if err != nil {
+		return nil, err
+	}
+
+	var w io.Writer = io.Discard
+	closeLog := func() {}
+	if *logFile != "" {
+		f, err := os.OpenFile(*logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("log error: %s", err.Error())
+		}
+		w, closeLog = f, func() { f.Close() }
+	} else if *noInteract {
+		w = os.Stderr
+	}
+
+	var h slog.Handler
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug} /* filtered by levelHandler */
+	switch *logFormat {
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		closeLog()
+		return nil, fmt.Errorf("log error: unknown format %q (text or json)", *logFormat)
+	}
+
+	for name, l := range logModules {
+		moduleLevel, ok := levels[name]
+		if !ok {
+			moduleLevel = level
+		}
+		*l = slog.New(&levelHandler{h.WithAttrs([]slog.Attr{slog.String("module", name)}), moduleLevel})
+	}
+	previous := slog.Default()
+	slog.SetDefault(logMain)
+
+	return func() {
+		slog.SetDefault(previous)
+		closeLog()
+	}, nil
+}
+
+// Parse a level name: debug, info, warn or error.
+func parseLogLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("log error: unknown level %q (debug, info, warn or error)", name)
+	}
+	return level, nil
+}
+
+// Parse the -log-modules levels, e.g. "decoder=debug,output=warn".
+func parseLogModules(spec string) (map[string]slog.Level, error) {
+	levels := make(map[string]slog.Level)
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if _, known := logModules[name]; !ok || !known {
+			names := make([]string, 0, len(logModules))
+			for n := range logModules {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("log error: invalid module level %q (<module>=<level>, modules %s)", item, strings.Join(names, ", "))
+		}
+		level, err := parseLogLevel(value)
+		if err != nil {
+			return nil, err
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
+
+// Log a startup error and stop, as log.Panicln: the deferred functions
+// restore the terminal.
+func fatal(v ...interface{}) {
+	msg := strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+	logMain.Error(msg)
+	panic(msg)
+}
+
+// Log a statistics block, with the maximum range so far.
+func logStatsBlock(c stats.Counters, coverage *stats.Coverage) {
+	if c.End.IsZero() {
+		c.End = time.Now()
+	}
+	maxRange := 0.0
+	if r := coverage.Snapshot().MaxRange; r != nil {
+		maxRange = r.DistanceKm
+	}
+	logStats.Info("statistics",
+		"start", c.Start,
+		"end", c.End,
+		"messages", c.Messages,
+		"rate", c.Rate(),
+		"bad_crc", c.BadCRC,
+		"single_bit_fixed", c.SingleBitFixed,
+		"two_bits_fixed", c.TwoBitsFixed,
+		"phase_corrected", c.PhaseCorrected,
+		"mode_ac", c.ModeAC,
+		"unique_aircraft", c.UniqueAircraft,
+		"max_range_km", maxRange,
+		"df", histogramString("DF", c.DF[:]),
+		"tc", histogramString("TC", c.TC[:]),
+		"ii", histogramString("II", c.II[:]),
+		"si", histogramString("SI", c.SI[:]),
+	)
+}
+
+// Non-zero counts of a histogram, e.g. "DF11:12 DF17:40".
+func histogramString(prefix string, counts []int64) string {
+	var parts []string
+	for i, n := range counts {
+		if n > 0 {
+			parts = append(parts, fmt.Sprintf("%s%d:%d", prefix, i, n))
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -1,26 +1,249 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"go1090/acdb"
+	"go1090/i18n"
 	"go1090/mode_s"
+	"go1090/output"
+	"go1090/rpc"
 	"go1090/rtl_adsb"
-	"log"
+	"go1090/stats"
+	"go1090/web"
+	"log/slog"
+	"os"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/awesome-gocui/gocui"
 	. "github.com/logrusorgru/aurora"
+	"github.com/mattn/go-runewidth"
 )
 
+var (
+	rtlPath      = flag.String("rtl-path", "rtl_adsb.exe", "Path of the rtl_adsb executable")
+	ifile        = flag.String("ifile", "", "Read 8-bit unsigned I/Q samples from file ('-' for stdin) instead of rtl_adsb")
+	replay       = flag.String("replay", "", "Replay a log of timestamped '*...;' frames instead of rtl_adsb")
+	record       = flag.String("record", "", "Record the received frames with their time to this file, for -replay (compressed when named .gz or .zst)")
+	replaySpeed  = flag.Float64("replay-speed", 1.0, "Replay speed multiplier (0 = as fast as possible)")
+	lang         = flag.String("lang", "en", "Language of the user interface (en, ko)")
+	stringsFile  = flag.String("strings", "", "JSON file of message template overrides")
+	links        = flag.Bool("links", false, "Attach photo, aircraft profile and registration URLs to aircraft")
+	netMode      = flag.Bool("net", false, "Enable the network outputs on the dump1090 ports unless set otherwise: -raw-out :30002 and -http :8080")
+	httpAddr     = flag.String("http", "", "Serve aircraft JSON over HTTP on this address (e.g. :8080)")
+	grpcAddr     = flag.String("grpc", "", "Serve the gRPC streams of messages and aircraft on this address (e.g. :50051, plaintext HTTP/2)")
+	modeAC       = flag.Bool("modeac", false, "Decode Mode A/C replies forwarded by the source and correlate them with Mode S aircraft")
+	aircraftDB   = flag.String("aircraft-db", "", "Aircraft database (CSV with a header line, or BaseStation.sqb) adding registrations, types and operators")
+	storeDir     = flag.String("store", "", "Save aircraft and positions in this directory, restoring the aircraft at startup")
+	storeEvery   = flag.Duration("store-every", time.Minute, "Save the -store aircraft state this often, with their trails (0 = only at exit and on POST /admin/save)")
+	icaoTTL      = flag.Duration("icao-cache-ttl", mode_s.MODES_ICAO_CACHE_TTL*time.Second, "Time an address seen in a DF11 or DF17 message is trusted to check the CRC of the other messages (set at start)")
+	decoders     = flag.Int("decoders", 1, "Number of goroutines decoding the received frames, for sources feeding more than a core decodes")
+	configFile   = flag.String("config", "", "Configuration file of flag values (JSON, or YAML or TOML by extension), reloaded on SIGHUP")
+	sourceB      = flag.String("source-b", "", "Second rtl_adsb executable (antenna B); compare its reception with rtl_adsb (antenna A)")
+	listRows     = flag.Int("interactive-rows", 0, "Maximum number of aircraft shown in the list (0 = as many as fit)")
+	radarPanel   = flag.Bool("radar", false, "Show the radar panel (r key) right of the list, with -lat and -lon")
+	radarRange   = flag.Float64("radar-range", 0, "Range of the radar panel, km (0 = fit the farthest aircraft)")
+	noInteract   = flag.Bool("no-interactive", false, "Print the decoded messages to stdout instead of showing the user interface, e.g. under systemd")
+	printFormat  = flag.String("print", printCompact, "Format of the messages printed with -no-interactive: compact (a line per message), verbose (as dump1090) or json (an object per line)")
+	snapshotFile = flag.String("snapshot", "snapshot.csv", "File of the aircraft list saved with the s key, the time being added to the name (JSON when named .json, else CSV)")
+	rtlDevice    = flag.Int("device", 0, "Device index of the rtl_adsb dongle")
+	rtlGain      = flag.Float64("gain", 0, "Tuner gain of rtl_adsb in dB (0 = automatic)")
+	rtlPPM       = flag.Int("ppm", 0, "Frequency correction of rtl_adsb in ppm")
+	rtlArgs      = flag.String("rtl-args", "", "More rtl_adsb command-line options, space separated")
+	statsEvery   = flag.Duration("stats-every", 0, "Write a statistics block to the -log file at this interval (e.g. 60s, 0 = never), and the totals at exit")
+	logFile      = flag.String("log", "", "Append the log (rtl_adsb exits and restarts, output errors, reloads) to this file")
+	logFormat    = flag.String("log-format", "text", "Format of the log: text (key=value) or json (a JSON object per line)")
+	logLevel     = flag.String("log-level", "info", "Level of the log: debug (every frame), info, warn or error")
+	logModLevels = flag.String("log-modules", "", "Levels of modules overriding -log-level, e.g. decoder=debug,output=warn (modules main, receiver, decoder, output, config, stats)")
+)
+
+// Raw output servers, "-raw-out addr[?filter]", repeatable.
+type rawOutputFlags []string
+
+func (r *rawOutputFlags) String() string {
+	return strings.Join(*r, " ")
+}
+
+func (r *rawOutputFlags) Set(v string) error {
+	*r = append(*r, v)
+	return nil
+}
+
+// External links, "-link name=template", repeatable: added to the ones of
+// -links, or replacing the one of the same name.
+type linkFlags map[string]string
+
+var linkTemplates = linkFlags{}
+
+func init() {
+	flag.Var(&linkTemplates, "link", "Attach a URL to aircraft, name=template with the aircraft as data, e.g. adsbx=https://globe.adsbexchange.com/?icao={{lower .HexAddr}} (repeatable, an empty template removes a link of -links)")
+}
+
+func (l *linkFlags) String() string {
+	list := make([]string, 0, len(*l))
+	for name, t := range *l {
+		list = append(list, name+"="+t)
+	}
+	sort.Strings(list)
+	return strings.Join(list, " ")
+}
+
+func (l *linkFlags) Set(v string) error {
+	name, t, ok := strings.Cut(v, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=template, got %q", v)
+	}
+	(*l)[name] = t
+	return nil
+}
+
+// A duration given in seconds ("120") or with a unit ("2m", "500ms").
+type ttlFlag time.Duration
+
+func (t *ttlFlag) String() string {
+	return time.Duration(*t).String()
+}
+
+func (t *ttlFlag) Set(v string) error {
+	if s, err := strconv.Atoi(v); err == nil {
+		*t = ttlFlag(time.Duration(s) * time.Second)
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	*t = ttlFlag(d)
+	return nil
+}
+
+// Number of received frames waiting to be decoded.
+const frameQueueLen = 1024
+
 type Context struct {
 	decoder *mode_s.Decoder
 	sky     *mode_s.Sky
+	outputs *output.Dispatcher
+	clock   *rtl_adsb.ClockDrift
+	frames  chan rtl_adsb.Frame
+	compare *rtl_adsb.Comparator /* nil unless comparing two sources */
+	stats   *stats.Stats
+	sources []sourceInfo
+
+	/* Reloadable settings, see config.go */
+	settings   *settings
+	reloadable *outputSet
+	heading    output.HeadingFormat
+	position   output.PositionFormat
+	reckon     time.Duration /* -extrapolate, 0 = off */
+	privacy    *output.Privacy
+	apiUnits   mode_s.Units
+	configErr  error                              /* Last configuration reload error. */
+	receivers  map[string]rtl_adsb.ReceiverStatus /* Supervised rtl_adsb, by source. */
+	web        *web.Server                        /* nil without -http */
+	rpc        *rpc.Server                        /* nil without -grpc */
+	db         *acdb.DB                           /* nil without -aircraft-db */
+	recorder   *rtl_adsb.Recorder                 /* nil without -record */
+	columns    []listColumn                       /* Of the list, see columns.go */
+	list       listState                          /* Selection and scrolling, see list.go */
+	ui         *gocui.Gui                         /* nil with -no-interactive */
+	print      func(mm *mode_s.ModeSMessage)      /* Messages printer, nil without -no-interactive */
+	mux        sync.RWMutex
+	reloadMux  sync.Mutex /* One reload at a time. */
+
+	/* Health */
+	started     time.Time
+	lastMessage int64 /* Unix nanoseconds, atomic */
 }
 
-func CreateContext() *Context {
+func CreateContext(s *settings) *Context {
 	return &Context{
-		decoder: &mode_s.Decoder{},
-		sky:     mode_s.NewSky(),
+		decoder: mode_s.NewDecoder(
+			mode_s.WithMetricUnits(s.metric),
+			mode_s.WithInteractiveRows(*listRows),
+			mode_s.WithICAOCacheTTL(*icaoTTL),
+		),
+		sky:     mode_s.NewSky(mode_s.WithAircraftTTL(time.Duration(s.aircraftTTL))),
+		outputs: output.NewDispatcher(),
+		clock:   rtl_adsb.NewClockDrift(),
+		frames:  make(chan rtl_adsb.Frame, frameQueueLen),
+		stats:   stats.New(),
+		started: time.Now(),
+	}
+}
+
+// Drift of the source MLAT clock, for the status bar.
+func (ctx *Context) clockStatus() string {
+	r := ctx.clock.Report()
+	if !r.Usable {
+		return i18n.T("ui.status.clock_bad", map[string]interface{}{
+			"Reason": Red(r.Reason),
+		})
+	}
+
+	return i18n.T("ui.status.clock", map[string]interface{}{
+		"Drift": Green(fmt.Sprintf("%+.1f", r.DriftPPM)),
+	})
+}
+
+// Antenna A/B comparison, for the status bar.
+func (ctx *Context) compareStatus() string {
+	r := ctx.compare.Report()
+	a, b := r.Sources["A"], r.Sources["B"]
+
+	percent := func(n int64) string {
+		if r.Common == 0 {
+			return "--"
+		}
+		return fmt.Sprintf("%d%%", n*100/r.Common)
+	}
+
+	return i18n.T("ui.status.compare", map[string]interface{}{
+		"AFirst": Green(percent(a.First)),
+		"BFirst": Green(percent(b.First)),
+		"AOnly":  Green(a.Exclusive),
+		"BOnly":  Green(b.Exclusive),
+	})
+}
+
+// Handle a message decoded from a received frame by the decode pool,
+// in the order of the frames.
+func (ctx *Context) handleFrame(msg *mode_s.ModeSMessage) {
+	if logDecoder.Enabled(context.Background(), slog.LevelDebug) {
+		logDecoder.Debug("frame", "source", msg.Provenance().Source, "hex", fmt.Sprintf("%X", msg.Bytes()),
+			"df", msg.DF(), "icao", fmt.Sprintf("%06X", msg.ICAO()), "crc_ok", msg.CRCOk())
+	}
+	if !ctx.decoder.Accept(msg) {
+		return
+	}
+
+	ctx.handleMessage(msg)
+	ctx.redraw()
+}
+
+// Update the sky with a decoded message and forward the aircraft to the
+// output sinks.
+func (ctx *Context) handleMessage(msg *mode_s.ModeSMessage) {
+	ctx.markMessage(msg)
+	if ctx.print != nil {
+		ctx.print(msg)
+	}
+
+	ctx.forwardRaw(msg)
+	if ctx.rpc != nil {
+		ctx.rpc.Forward(msg)
+	}
+
+	if ac := ctx.sky.UpdateData(msg); ac != nil {
+		if ac = ctx.sky.Aircraft(ac.Addr); ac != nil {
+			ctx.stats.Coverage().Observe(ac)
+			ctx.outputs.Update(ac)
+		}
 	}
 }
 
@@ -28,103 +251,620 @@ func (ctx *Context) update(g *gocui.Gui) error {
 	// update time and aircraft count
 	s, _ := g.View("status")
 	s.Clear()
-	fmt.Fprintf(s, " A/C: %02d  LAST UPDATE: %s\n",
-		Green(ctx.sky.AircraftCount()),
-		Bold(Green(time.Now().Format("2006-01-02 15:04:05"))))
+	aircrafts := ctx.shownAircrafts()
+	line := i18n.T("ui.status.line", map[string]interface{}{
+		"Count": Green(fmt.Sprintf("%02d", len(aircrafts))),
+		"Time":  Bold(Green(time.Now().Format("2006-01-02 15:04:05"))),
+	})
+	if ctx.list.paused {
+		line += i18n.S("ui.status.paused")
+	}
+	line += ctx.list.notice
+	if ctx.clock.Seen() {
+		line += ctx.clockStatus()
+	}
+	if ctx.compare != nil {
+		line += ctx.compareStatus()
+	}
+	line += ctx.receiverStatus()
+	ctx.mux.RLock()
+	configErr := ctx.configErr
+	ctx.mux.RUnlock()
+	if configErr != nil {
+		line += i18n.T("ui.status.config_error", map[string]interface{}{
+			"Error": Red(configErr.Error()),
+		})
+	}
+	if alert := emergencyAlert(aircrafts); alert != "" {
+		line += i18n.T("ui.status.emergency", map[string]interface{}{
+			"Aircraft": Bold(BgRed(White(alert))),
+		})
+	}
+	if watched := watchedAlert(aircrafts); watched != "" {
+		line += i18n.T("ui.status.watched", map[string]interface{}{
+			"Aircraft": Bold(BgBlue(White(watched))),
+		})
+	}
+	if behind := ctx.outputs.Behind(); len(behind) > 0 {
+		line += i18n.T("ui.status.sinks_behind", map[string]interface{}{
+			"Sinks": Red(strings.Join(behind, ", ")),
+		})
+	}
+	line += i18n.T("ui.status.history", map[string]interface{}{
+		"Sparkline": Cyan(stats.Sparkline(ctx.stats.History().AircraftCounts(), 15)),
+	})
+	fmt.Fprintln(s, line)
 
 	l, _ := g.View("list")
 	l.Clear()
 
 	// display aircraft list
-	fmt.Fprintln(l, " ICAO ADDR    FLIGHT     ALT    SPD    HDG     LAT     LON  SEEN")
-	fmt.Fprintln(l, " ===================================================================")
+	lineWidth, _ := l.Size()
+	header := ctx.listHeader(lineWidth)
+	fmt.Fprintln(l, header)
+	fmt.Fprintln(l, " "+strings.Repeat("=", runewidth.StringWidth(header)-1))
 
-	aircrafts := ctx.sky.Aircrafts()
 	addrs := make([]uint32, 0, len(aircrafts))
 	for addr := range aircrafts {
 		addrs = append(addrs, addr)
 	}
 	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
 
-	for _, addr := range addrs {
+	if rows := ctx.decoder.InteractiveRows(); rows > 0 && len(addrs) > rows {
+		addrs = addrs[:rows]
+	}
+	ctx.list.listed = addrs
+	from, to := ctx.list.window(listViewRows(l))
+	l.Title = listTitle(from, to, len(addrs))
+
+	for _, addr := range addrs[from:to] {
 		ac := aircrafts[addr]
-		fmt.Fprintln(l, Sprintf(Yellow(" %6s       %9s  %-5d  %-5d  %-3d  %6.2f  %6.2f  %s"),
-			ac.HexAddr,
-			ac.Flight,
-			ac.Altitude,
-			ac.Speed,
-			ac.Track,
-			ac.Latitude,
-			ac.Longitude,
-			ac.Seen.Format("15:04:05")))
+		row := rowFormat(ac, ctx.listRow(ac, lineWidth))
+		if ctx.list.hasSel && addr == ctx.list.selected {
+			row = row.Reverse()
+		}
+		fmt.Fprintln(l, row)
 	}
 
+	if err := ctx.updateRadar(g, aircrafts); err != nil {
+		return err
+	}
+	if err := ctx.updateDetail(g, aircrafts); err != nil {
+		return err
+	}
+	return ctx.updateHelp(g)
+}
+
+// Altitude above which an aircraft is in the high band of the list, feet.
+const highAltitude = 10000
+
+// Color of a row of the list: flashing red for emergencies, then by
+// altitude band (ground, low, high), dimmed without a position.
+func rowFormat(ac *mode_s.Aircraft, row string) Value {
+	if ac.Emergency {
+		return SlowBlink(Bold(BgRed(White(row))))
+	}
+
+	var v Value
+	switch {
+	case ac.OnGround:
+		v = Magenta(row)
+	case ac.Altitude == 0:
+		v = Yellow(row) /* unknown */
+	case ac.Altitude < highAltitude:
+		v = Green(row)
+	default:
+		v = Cyan(row)
+	}
+	if ac.Latitude == 0 && ac.Longitude == 0 {
+		v = v.Faint()
+	}
+	if ac.Watched {
+		v = v.Bold().BgBlue()
+	}
+	return v
+}
+
+// Format of the coordinates of the list.
+func (ctx *Context) positionFormat() output.PositionFormat {
+	ctx.mux.RLock()
+	defer ctx.mux.RUnlock()
+
+	return ctx.position
+}
+
+// Time positions are extrapolated for, 0 when they are not.
+func (ctx *Context) deadReckoning() time.Duration {
+	ctx.mux.RLock()
+	defer ctx.mux.RUnlock()
+
+	return ctx.reckon
+}
+
+// Position shown of an aircraft, extrapolated from its speed and track
+// with -extrapolate.
+func (ctx *Context) shownPosition(ac *mode_s.Aircraft) (lat, lon float64, estimated bool) {
+	lat, lon, estimated, _ = ac.EstimatedPosition(time.Now(), ctx.deadReckoning())
+	return lat, lon, estimated
+}
+
+// Demodulate I/Q samples from file instead of spawning rtl_adsb.
+func startIQFile(ctx *Context, path string) (func(), error) {
+	f := os.Stdin
+	if path != "-" {
+		var err error
+		if f, err = os.Open(path); err != nil {
+			return nil, fmt.Errorf("IQ file error: %s", err.Error())
+		}
+	}
+
+	go func() {
+		ctx.decoder.DecodeIQStream(f, func(msg *mode_s.ModeSMessage) {
+			msg.SetReceived("A", time.Now())
+			ctx.handleMessage(msg)
+			ctx.redraw()
+		})
+	}()
+
+	return func() {
+		f.Close()
+	}, nil
+}
+
+// With -net, serve the outputs of dump1090 on its default ports, unless
+// the addresses are given; the raw output is set by readSettings.
+func netDefaults() {
+	if *netMode && *httpAddr == "" {
+		*httpAddr = ":8080"
+	}
+}
+
+// Frames are read from stdin: "-" argument.
+func stdinFrames() bool {
+	return flag.Arg(0) == "-"
+}
+
+// Select the message catalog and apply user overrides.
+func initCatalog() error {
+	c, err := i18n.New(*lang)
+	if err != nil {
+		return err
+	}
+
+	if *stringsFile != "" {
+		if err := c.LoadOverrides(*stringsFile); err != nil {
+			return err
+		}
+	}
+
+	i18n.Use(c)
 	return nil
 }
 
 func main() {
-	// init ui
-	g, err := gocui.NewGui(gocui.OutputNormal, false)
-	if err != nil {
-		log.Panicln(err)
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		os.Exit(runBench(os.Args[2:]))
+	}
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [-]\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "  -\tread '*...;' frames from stdin instead of rtl_adsb (e.g. rtl_adsb | go1090 -)")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 1 || (flag.NArg() == 1 && flag.Arg(0) != "-") {
+		flag.Usage()
+		os.Exit(2)
 	}
 
-	defer g.Close()
+	if *configFile != "" {
+		if err := loadConfig(*configFile); err != nil {
+			fatal(err)
+		}
+	}
+	netDefaults()
 
-	g.SetManagerFunc(layout)
+	if err := initCatalog(); err != nil {
+		fatal(err)
+	}
 
-	if err := g.SetKeybinding("", gocui.KeyCtrlC, gocui.ModNone, quit); err != nil {
-		log.Panicln(err)
+	closeLog, err := openLog()
+	if err != nil {
+		fatal(err)
+	}
+	defer closeLog()
+
+	// load the aircraft database
+	var db *acdb.DB
+	if *aircraftDB != "" {
+		if db, err = acdb.Load(*aircraftDB); err != nil {
+			fatal(err)
+		}
+		logMain.Info("aircraft database loaded", "path", *aircraftDB, "aircraft", db.Len())
 	}
 
 	// init decoder and sky
-	ctx := CreateContext()
-	ctx.decoder.Init()
+	s, err := readSettings(*configFile)
+	if err != nil {
+		fatal(err)
+	}
+	ctx := CreateContext(s)
+	ctx.applySettings(s)
 
-	// start receive
-	handler := func(rcv rtl_adsb.ADSBMsg) {
-		msg := mode_s.ModeSMessage{}
-		ctx.decoder.DecodeModesMessage(&msg, rcv[:])
+	ctx.outputs.SetLogger(logOutput)
+
+	// init ui, or the printer of the messages
+	if *noInteract {
+		if ctx.print, err = newMessagePrinter(*printFormat, os.Stdout); err != nil {
+			fatal(err)
+		}
+	} else {
+		g, err := gocui.NewGui(gocui.OutputNormal, false)
+		if err != nil {
+			fatal(err)
+		}
+		defer g.Close()
+
+		ctx.ui = g
+		ctx.list.radar = *radarPanel
+		g.SetManagerFunc(ctx.layout)
+		if err := ctx.bindKeys(g); err != nil {
+			fatal(err)
+		}
+	}
+
+	if db != nil {
+		ctx.db = db
+		ctx.sky.AddEnricher(db.Enricher())
+	}
+	ctx.sky.AddEnricher(mode_s.CountryEnricher)
+
+	if *links || len(linkTemplates) > 0 {
+		templates := make(map[string]string)
+		if *links {
+			for name, t := range mode_s.DefaultLinkTemplates {
+				templates[name] = t
+			}
+		}
+		for name, t := range linkTemplates {
+			templates[name] = t
+		}
+		e, err := mode_s.NewLinkEnricher(templates)
+		if err != nil {
+			fatal(err)
+		}
+		ctx.sky.AddEnricher(e)
+	}
+
+	if err := ctx.initOutputs(); err != nil {
+		fatal(err)
+	}
+	defer ctx.closeOutputs()
+
+	if stdinFrames() && (*ifile != "" || *replay != "") {
+		fatal("error: - (stdin) is another source than -ifile and -replay")
+	}
+	if *sourceB != "" {
+		if *ifile != "" || *replay != "" || stdinFrames() {
+			fatal("error: -source-b compares two live rtl_adsb receivers")
+		}
+		ctx.compare = rtl_adsb.NewComparator("A", "B")
+	}
+	ctx.sources = inputSources()
+
+	if *record != "" {
+		r, err := rtl_adsb.NewRecorder(*record)
+		if err != nil {
+			fatal(err)
+		}
+		ctx.recorder = r
+		defer r.Close()
+	}
+
+	if *grpcAddr != "" {
+		srv, err := rpc.NewServer(*grpcAddr)
+		if err != nil {
+			fatal(err)
+		}
+		srv.SetPrivacy(ctx.privacy)
+		ctx.rpc = srv
+		ctx.outputs.Add("grpc", srv)
+	}
+
+	if *httpAddr != "" {
+		srv := web.NewServer(ctx.sky)
+		ctx.web = srv
+		srv.SetHeadingFormat(ctx.heading)
+		srv.SetPrivacy(ctx.privacy)
+		srv.SetUnits(ctx.apiUnits)
+		srv.SetDeadReckoning(ctx.deadReckoning())
+		ctx.registerHealthChecks(srv)
+		statsHandler := web.JSONHandler(func() interface{} {
+			return struct {
+				stats.Snapshot
+				Outputs []output.SinkStatus `json:"outputs"`
+			}{ctx.stats.Snapshot(), ctx.sinkStatus()}
+		})
+		srv.Handle("/data/stats.json", statsHandler)
+		srv.Handle("/api/stats", statsHandler)
+		srv.Handle("/data/coverage.geojson", web.JSONHandler(func() interface{} {
+			return output.CoverageGeoJSON(ctx.stats.Coverage().Snapshot())
+		}))
+		if s.adminToken != "" {
+			srv.SetAdminToken(s.adminToken)
+			ctx.registerAdmin(srv)
+		}
+		if ctx.compare != nil {
+			srv.Handle("/data/compare.json", web.JSONHandler(func() interface{} {
+				return struct {
+					rtl_adsb.CompareReport
+					Positions map[string]mode_s.SourceAgreement `json:"positions"`
+				}{ctx.compare.Report(), ctx.sky.SourceAgreement()}
+			}))
+		}
 
-		ctx.sky.UpdateData(&msg)
-		g.Update(ctx.update)
+		stopHTTP, err := srv.Start(*httpAddr)
+		if err != nil {
+			fatal(err)
+		}
+		defer stopHTTP()
 	}
 
-	stopFunc, e := rtl_adsb.StartReceive("rtl_adsb.exe", handler)
+	// decode received frames
+	pool := mode_s.NewDecodePool(ctx.decoder, *decoders, ctx.handleFrame)
+	go func() {
+		for rcv := range ctx.frames {
+			if ctx.compare != nil && !ctx.compare.Observe(rcv) {
+				continue /* already received by the other antenna */
+			}
+			if ctx.recorder != nil {
+				if err := ctx.recorder.Record(rcv); err != nil {
+					logReceiver.Error("recording stopped", "error", err)
+					ctx.recorder.Close()
+					ctx.recorder = nil
+				}
+			}
+			if rcv.Source != "B" {
+				ctx.clock.Observe(rcv)
+			}
+			if rcv.ModeAC && !*modeAC {
+				continue
+			}
+
+			pool.Decode(rcv)
+		}
+	}()
+
+	if *configFile != "" {
+		ctx.watchReload(ctx.redraw)
+	}
+
+	// start receive
+	handlerFor := func(source string) rtl_adsb.FrameHandler {
+		return func(rcv rtl_adsb.Frame) {
+			rcv.Source = source
+			ctx.frames <- rcv
+		}
+	}
+	handler := handlerFor("A")
+
+	var stopFunc func()
+	var e error
+	if *ifile != "" {
+		stopFunc, e = startIQFile(ctx, *ifile)
+	} else if *replay != "" {
+		stopFunc, e = rtl_adsb.StartReplayFrames(*replay, *replaySpeed, handler)
+	} else if stdinFrames() {
+		stopFunc, e = rtl_adsb.StartReaderFrames(os.Stdin, handler)
+	} else {
+		stopFunc, e = rtl_adsb.StartSupervisedFrames(*rtlPath, receiverArgs(), handler, ctx.watchReceiver("A"))
+	}
 
 	if e != nil {
-		log.Panicln("error: ", e)
+		fatal("error:", e)
+	}
+
+	if ctx.compare != nil {
+		stopB, err := rtl_adsb.StartSupervisedFrames(*sourceB, nil, handlerFor("B"), ctx.watchReceiver("B"))
+		if err != nil {
+			fatal("error:", err)
+		}
+		defer stopB()
 	}
 
 	//
 	go func() {
 		for ; ; <-time.Tick(time.Second * 1) {
 			ctx.sky.RemoveStaleAircrafts()
-			g.Update(ctx.update)
+			ctx.stats.Tick(time.Now(), ctx.sky.AircraftCount())
+			ctx.outputs.Tick()
+			ctx.redraw()
 		}
 	}()
 
-	if err := g.MainLoop(); err != nil && !gocui.IsQuit(err) {
-		log.Panicln(err)
+	if *storeDir != "" && *storeEvery > 0 {
+		go func() {
+			for range time.Tick(*storeEvery) {
+				if err := ctx.saveState(); err != nil {
+					logOutput.Error("saving the state failed", "err", err)
+				}
+			}
+		}()
+	}
+
+	if *statsEvery > 0 {
+		go func() {
+			for range time.Tick(*statsEvery) {
+				logStatsBlock(ctx.stats.TakePeriod(), ctx.stats.Coverage())
+			}
+		}()
+	}
+
+	if ctx.ui == nil {
+		waitForSignal()
+	} else if err := ctx.ui.MainLoop(); err != nil && !gocui.IsQuit(err) {
+		fatal(err)
+	}
+
+	if *statsEvery > 0 {
+		logStatsBlock(ctx.stats.Total(), ctx.stats.Coverage())
 	}
 
 	stopFunc()
 }
 
-func layout(g *gocui.Gui) error {
-	// layout
-	const maxX = 80
-	_, maxY := g.Size()
+// Redraw the user interface from its main loop, if shown.
+func (ctx *Context) redraw() {
+	if ctx.ui != nil {
+		ctx.ui.Update(ctx.update)
+	}
+}
 
-	v, _ := g.SetView("status", 0, 0, maxX-2, 2, 0)
-	v.Title = " STATUS "
-	fmt.Fprintln(v, " A/C: --  LAST UPDATE: 0000-00-00 00:00:00")
+// Track in the configured reference, as a compass point if selected, ""
+// if unknown.
+func (ctx *Context) trackString(ac *mode_s.Aircraft) string {
+	if !ac.TrackValid {
+		return ""
+	}
+	return ctx.directionString(ac.Track, false)
+}
 
-	v, _ = g.SetView("list", 0, 3, maxX-2, maxY-1, 0)
-	v.Title = " A/C "
-	return nil
+// Heading in the configured reference, as a compass point if selected,
+// "" if unknown.
+func (ctx *Context) headingString(ac *mode_s.Aircraft) string {
+	if !ac.HeadingValid {
+		return ""
+	}
+	return ctx.directionString(ac.Heading, ac.HeadingRef == mode_s.NORTH_MAGNETIC)
 }
 
-func quit(g *gocui.Gui, v *gocui.View) error {
-	return gocui.ErrQuit
+func (ctx *Context) directionString(deg int, magnetic bool) string {
+	ctx.mux.RLock()
+	h := ctx.heading
+	ctx.mux.RUnlock()
+
+	if h.Cardinal {
+		return output.Cardinal(h.DegreesFrom(deg, magnetic))
+	}
+	return fmt.Sprintf("%d", h.DegreesFrom(deg, magnetic))
+}
+
+// True if the flag was given on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// Distance from the receiver in km, if known.
+func distanceString(ac *mode_s.Aircraft) string {
+	if !ac.Ranged {
+		return ""
+	}
+	return fmt.Sprintf("%.1f", ac.DistanceKm)
+}
+
+// Climb/descend indicator.
+func climbString(ac *mode_s.Aircraft) string {
+	switch ac.Climb() {
+	case 1:
+		return "^"
+	case -1:
+		return "v"
+	}
+	return " "
+}
+
+// Bearing from the receiver, if known.
+func bearingString(ac *mode_s.Aircraft) string {
+	if !ac.Ranged {
+		return ""
+	}
+	return fmt.Sprintf("%.0f", ac.Bearing)
+}
+
+// The aircraft squawking an emergency code, e.g. "4840D6 7700", sorted.
+func emergencyAlert(aircrafts map[uint32]*mode_s.Aircraft) string {
+	var alerts []string
+	for _, ac := range aircrafts {
+		if mode_s.IsEmergencySquawk(ac.Squawk) {
+			alerts = append(alerts, ac.HexAddr+" "+squawkString(ac))
+		}
+	}
+	sort.Strings(alerts)
+	return strings.Join(alerts, ", ")
+}
+
+// The watched aircraft, by flight or address, sorted.
+func watchedAlert(aircrafts map[uint32]*mode_s.Aircraft) string {
+	var watched []string
+	for _, ac := range aircrafts {
+		if ac.Watched {
+			if flight := strings.TrimRight(ac.Flight, " \x00"); flight != "" {
+				watched = append(watched, flight)
+			} else {
+				watched = append(watched, ac.HexAddr)
+			}
+		}
+	}
+	sort.Strings(watched)
+	return strings.Join(watched, ", ")
+}
+
+// Signal level, e.g. "-12.3", empty if the source has none.
+func rssiString(ac *mode_s.Aircraft) string {
+	if !ac.RSSIValid {
+		return ""
+	}
+	return fmt.Sprintf("%.1f", ac.RSSI)
+}
+
+func squawkString(ac *mode_s.Aircraft) string {
+	if ac.Squawk == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%04d", ac.Squawk)
+}
+
+// Emergency status, the last anomaly, or the meaning of the squawk code.
+func squawkMeaning(ac *mode_s.Aircraft) string {
+	if ac.EmergencyState != 0 {
+		return ac.EmergencyStateDesc
+	}
+	if ac.Suspect {
+		return ac.Anomalies[len(ac.Anomalies)-1].Detail
+	}
+	return ac.SquawkMeaning
+}
+
+// Layout of the views, the whole terminal wide. When the terminal is
+// resized, the list is drawn again at the new width.
+func (ctx *Context) layout(g *gocui.Gui) error {
+	maxX, maxY := g.Size()
+	resized := maxX != ctx.list.width || maxY != ctx.list.height
+	ctx.list.width, ctx.list.height = maxX, maxY
+
+	v, _ := g.SetView("status", 0, 0, maxX-1, 2, 0)
+	v.Title = i18n.S("ui.status.title")
+	fmt.Fprintln(v, i18n.S("ui.status.empty"))
+
+	radarX := ctx.radarLeft(maxX, maxY)
+	if v, err := g.SetView("list", 0, 3, radarX-1, maxY-1, 0); err == gocui.ErrUnknownView {
+		v.Title = i18n.S("ui.list.title") /* then set by update */
+	}
+	if radarX < maxX {
+		if _, err := g.SetView("radar", radarX, 3, maxX-1, maxY-1, 0); err != nil && err != gocui.ErrUnknownView {
+			return err
+		}
+	} else if err := g.DeleteView("radar"); err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	if resized {
+		return ctx.update(g)
+	}
+	return nil
 }
@@ -0,0 +1,85 @@
+package mode_s
+
+import (
+	"fmt"
+	"go1090/i18n"
+	"time"
+)
+
+const ACAS_VDS_RA = 0x30 /* VDS of a DF16 MV field holding an RA (BDS 3,0). */
+
+/* A new RA identical to the last one raises an EVENT_ACAS_RA event again
+ * after this long. */
+const MODES_ACAS_RA_EVENT_GAP = 30 * time.Second
+
+/* ACAS fields of the DF0 and DF16 air-air surveillance replies. */
+type ACASStatus struct {
+	OnGround         bool `json:"on_ground"`         /* Vertical status (VS). */
+	SensitivityLevel int  `json:"sensitivity_level"` /* SL, 0 = ACAS inoperative. */
+	ReplyInfo        int  `json:"reply_info"`        /* RI, see ReplyInfoString. */
+}
+
+/* Description of the reply information: the ACAS capability (RI 0 to 4)
+ * or, in the replies to acquisition interrogations, the maximum cruising
+ * airspeed (RI 8 to 14). */
+func ReplyInfoString(ri int) string {
+	return i18n.S(fmt.Sprintf("ri.%d", ri&15))
+}
+
+/* An ACAS (TCAS) resolution advisory, as broadcast in DF17 TC 28
+ * subtype 2. */
+type ACASRA struct {
+	ARA             int       `json:"ara"`              /* Active resolution advisories (14 bits). */
+	RAC             int       `json:"rac"`              /* RA complements record (4 bits). */
+	Terminated      bool      `json:"terminated"`       /* RA terminated (RAT). */
+	MultipleThreats bool      `json:"multiple_threats"` /* Multiple threat encounter (MTE). */
+	ThreatType      int       `json:"threat_type"`      /* Threat type indicator (TTI). */
+	ThreatAddr      uint32    `json:"threat_addr"`      /* ICAO address of the threat if TTI = 1. */
+	Time            time.Time `json:"time"`             /* Time the RA was received. */
+}
+
+/* Decode the RA fields starting at message bit 'ara' (ARA, RAC, RAT,
+ * MTE, TTI, TID are always in this order). */
+func decodeACASRA(msg []byte, ara int) ACASRA {
+	bits := func(start, n int) int {
+		v := 0
+		for i := start; i < start+n; i++ {
+			v = v<<1 | int(msg[i/8]>>(7-uint(i%8)))&1
+		}
+		return v
+	}
+
+	ra := ACASRA{
+		ARA:             bits(ara, 14),
+		RAC:             bits(ara+14, 4),
+		Terminated:      bits(ara+18, 1) != 0,
+		MultipleThreats: bits(ara+19, 1) != 0,
+		ThreatType:      bits(ara+20, 2),
+	}
+
+	if ra.ThreatType == 1 {
+		/* The threat identity is its Mode S address. */
+		ra.ThreatAddr = uint32(bits(ara+22, 24))
+	}
+	return ra
+}
+
+/* Record a resolution advisory. An EVENT_ACAS_RA event is raised when it
+ * differs from the last one, or the last one is older than
+ * MODES_ACAS_RA_EVENT_GAP. */
+func (sky *Sky) setRA(a *Aircraft, ra ACASRA) {
+	ra.Time = a.Seen
+	last := a.LastRA
+	a.LastRA = ra
+
+	fresh := !last.Time.IsZero() && ra.Time.Sub(last.Time) <= MODES_ACAS_RA_EVENT_GAP
+	last.Time = ra.Time
+	if fresh && last == ra {
+		return
+	}
+	sky.emit(Event{
+		Type:     EVENT_ACAS_RA,
+		Time:     a.Seen,
+		Aircraft: a.Clone(),
+	})
+}
@@ -8,17 +8,114 @@ import (
 )
 
 const MODES_AIRCRAFT_TTL = 60 /* TTL before being removed */
+const MODES_TRAIL_LEN = 128   /* Max number of positions in a trail. */
+const MODES_LAST_FRAMES = 8   /* Raw frames kept per aircraft. */
+const MODES_SIGNAL_LEVELS = 8 /* Signal levels averaged in RSSI. */
+
+/* Local CPR decoding: max age of the last position used as reference,
+ * and max distance of a position from its reference. */
+const MODES_CPR_LOCAL_MAX_AGE = 60 * time.Second
+const MODES_CPR_LOCAL_MAX_RANGE_NM = 180
+
+/* Global CPR decoding: max time between the even and the odd message of
+ * a pair, see SetCPRPairMaxAge. */
+const MODES_CPR_PAIR_MAX_AGE = 10 * time.Second
+
+/* Vertical rates below this, in ft/min, are level flight. */
+const MODES_LEVEL_VERT_RATE = 128
+
+/* A decoded position of an aircraft. */
+type TrailPoint struct {
+	Latitude, Longitude float64
+	Altitude            int /* Feet, 0 if unknown. */
+	Speed               int /* Ground speed in knots, 0 if unknown. */
+	Time                time.Time
+	Provenance          Provenance /* Message completing the position. */
+	NIC                 int        /* Navigation integrity category, -1 if unknown. */
+}
+
+/* A raw frame received from an aircraft. */
+type RawFrame struct {
+	Time time.Time
+	Msg  [MODES_LONG_MSG_BYTES]byte
+	Len  int /* Bytes of Msg used: 7 or 14. */
+}
+
+/* Hexadecimal message, e.g. "8D4840D6202CC371C32CE0576098". */
+func (f RawFrame) Hex() string {
+	return fmt.Sprintf("%X", f.Msg[:f.Len])
+}
 
 /* Structure used to describe an aircraft in iteractive mode. */
 type Aircraft struct {
 	Addr     uint32    /* ICAO address */
 	HexAddr  string    /* Printable ICAO address */
-	Flight   string    /* Flight number */
+	Flight   string    /* Flight number, "" if unknown. */
 	Altitude int       /* Altitude */
 	Speed    int       /* Velocity computed from EW and NS components. */
-	Track    int       /* Angle of flight. */
+	Track    int       /* Ground track, degrees true, see TrackValid. */
 	Seen     time.Time /* Time at which the last packet was received. */
 	Messages int64     /* Number of Mode S messages received. */
+	Source   string    /* Best data source (SOURCE_ADSB_ICAO, SOURCE_TISB_ICAO, ...) */
+	Category string    /* ADS-B emitter category ("A1" to "D7"), "" if unknown. */
+	OnGround bool      /* Last air/ground state received, see ModeSMessage.OnGround. */
+
+	/* The track is the direction of the movement over the ground, the
+	 * heading where the nose points: they differ by the wind. */
+	TrackValid    bool
+	TrackSource   string /* DIRECTION_ADSB_VELOCITY, ... */
+	HeadingValid  bool
+	Heading       int    /* Degrees, in the HeadingRef north reference. */
+	HeadingRef    string /* NORTH_TRUE or NORTH_MAGNETIC. */
+	HeadingSource string /* DIRECTION_ADSB_AIRSPEED, ... */
+
+	FirstSeen  time.Time     /* Time at which the first packet was received. */
+	DFCounts   [32]int64     /* Messages received by downlink format. */
+	MsgCounts  MessageCounts /* Messages received by category. */
+	LastFrames []RawFrame    /* Last MODES_LAST_FRAMES frames, oldest first. */
+
+	/* From the aircraft database, see Sky.AddEnricher. */
+	Registration string
+	TypeCode     string /* ICAO type designator, e.g. "B738". */
+	Operator     string
+
+	/* Country of registry, from the ICAO address block, see CountryOf. */
+	Country     string
+	CountryCode string /* ISO 3166-1 alpha-2, e.g. "NL". */
+
+	VertRateValid bool /* VertRate was reported. */
+	VertRate      int  /* Vertical rate, ft/min, negative when descending. */
+
+	/* Geometric (GNSS) altitude, reported in TC 20-22 positions or
+	 * derived from Altitude and the difference of TC 19 messages. */
+	AltitudeGeomValid bool
+	AltitudeGeom      int  /* Feet. */
+	GNSSBaroDiffValid bool /* GNSSBaroDiff was reported. */
+	GNSSBaroDiff      int  /* GNSS minus barometric altitude, feet. */
+
+	ADSB ADSBQuality /* ADS-B version and quality fields. */
+
+	Provenance Provenance /* Last message applied to the aircraft. */
+
+	Squawk        int    /* Mode A code, decimal digits (7700 is "7700"). */
+	SquawkMeaning string /* Meaning of special purpose squawk codes. */
+
+	Emergency          bool   /* Emergency squawk or emergency status. */
+	EmergencyState     int    /* TC 28 emergency/priority status, 0 = none. */
+	EmergencyStateDesc string /* Description of EmergencyState. */
+	LastRA             ACASRA /* Last ACAS resolution advisory, zero Time if none. */
+
+	FlightStatusValid bool /* A DF4, 5, 20 or 21 reply was received. */
+	FlightStatus      int  /* Flight status of the last one, see FlightStatusString. */
+
+	ACASValid bool       /* A DF0 or DF16 reply was received. */
+	ACAS      ACASStatus /* ACAS fields of the last DF0 or DF16 reply. */
+
+	RSSIValid bool    /* The source gave the signal level of messages. */
+	RSSI      float64 /* Signal level of the last messages, dBFS (0 = full scale). */
+
+	ModeACount int64 /* Correlated Mode A replies (same squawk). */
+	ModeCCount int64 /* Correlated Mode C replies (same altitude). */
 
 	/* Encoded latitude and longitude as extracted by odd and even
 	 * CPR encoded messages. */
@@ -27,64 +124,387 @@ type Aircraft struct {
 	EvenCprLat int
 	EvenCprLon int
 
-	Latitude, Longitude     float64 /* Coordinated obtained from CPR encoded data. */
+	Latitude, Longitude float64 /* Coordinated obtained from CPR encoded data. */
+
+	/* Position relative to the receiver, set once the receiver location
+	 * is known and the aircraft has a position. */
+	Ranged                  bool
+	DistanceKm              float64 /* Great circle distance. */
+	Bearing                 float64 /* Initial bearing from the receiver, degrees true. */
 	OddCprTime, EvenCprTime int64
+
+	EHS EHSData /* Enhanced Surveillance data from Comm-B replies. */
+
+	Trail             Trail /* Last decoded positions. */
+	PositionsRejected int64 /* Implausible positions discarded. */
+
+	Links map[string]string /* External URLs (photo, registry, ...) */
+
+	Watched bool /* On the watch list, see Sky.SetWatchList. */
+
+	Zones []string /* Names of the zones the aircraft is in, see Sky.SetZones. */
+
+	Suspect   bool      /* An anomaly was detected, see Anomalies. */
+	Anomalies []Anomaly /* Last detected anomalies, oldest first. */
+
+	altitude_time     time.Time /* Time Altitude was reported. */
+	cpr_odd_last      bool      /* The last CPR message is the odd one. */
+	cpr_zone          cprZone   /* NL zone of the last position. */
+	flight_candidate  string    /* New callsign waiting for confirmation. */
+	kinematic_anomaly string    /* Current speed/altitude anomaly, "" if none. */
+
+	jump_confirmations int        /* Consecutive agreeing implausible positions. */
+	jump_position      TrailPoint /* Last implausible position. */
+	jump_rejected      time.Time  /* Time of the last rejected jump. */
+	jump_reported      time.Time  /* Time of the last reported jump, see reportJump. */
+
+	source_positions map[string]TrailPoint /* Last position by receiver, see merge.go. */
+
+	signal_levels [MODES_SIGNAL_LEVELS]float64 /* Ring buffer, see addSignal. */
+	signal_count  int                          /* Levels received, up to MODES_SIGNAL_LEVELS. */
+	signal_next   int
+
+	rate messageRate /* See MessageRate. */
+
+	version  uint64 /* Sky version of the last change, see Sky.touch. */
+	filtered bool   /* Outside the traffic of interest, see filter.go. */
 }
 
 /* Return a new aircraft structure for the interactive mode linked list
  * of aircrafts. */
 func NewAircraft(addr uint32) *Aircraft {
 	return &Aircraft{
-		Addr:    addr,
-		HexAddr: fmt.Sprintf("%06X", addr),
-		Seen:    time.Now(),
+		Addr:      addr,
+		HexAddr:   hexAddr(addr),
+		Seen:      time.Now(),
+		FirstSeen: time.Now(),
+		Source:    SOURCE_MODE_S,
+		ADSB:      newADSBQuality(),
 		// all other fields = 0
 	}
 }
 
+/* Keep the raw frame of a message, dropping the oldest one. */
+func (a *Aircraft) addFrame(mm *ModeSMessage, now time.Time) {
+	f := RawFrame{Time: now}
+	f.Len = copy(f.Msg[:], mm.Bytes())
+	if len(a.LastFrames) < MODES_LAST_FRAMES {
+		a.LastFrames = append(a.LastFrames, f)
+		return
+	}
+	copy(a.LastFrames, a.LastFrames[1:])
+	a.LastFrames[len(a.LastFrames)-1] = f
+}
+
+/* Average the signal level of a message with those of the last ones. */
+func (a *Aircraft) addSignal(level float64) {
+	a.signal_levels[a.signal_next] = level
+	a.signal_next = (a.signal_next + 1) % MODES_SIGNAL_LEVELS
+	if a.signal_count < MODES_SIGNAL_LEVELS {
+		a.signal_count++
+	}
+
+	sum := 0.0
+	for _, l := range a.signal_levels[:a.signal_count] {
+		sum += l
+	}
+	a.RSSIValid = true
+	a.RSSI = 10 * math.Log10(sum/float64(a.signal_count))
+}
+
+/* Printable address, non-ICAO addresses are prefixed with '~'. */
+func hexAddr(addr uint32) string {
+	if addr&MODES_NON_ICAO_ADDRESS != 0 {
+		return fmt.Sprintf("~%06X", addr&0xffffff)
+	}
+	return fmt.Sprintf("%06X", addr)
+}
+
 func (ac *Aircraft) Clone() *Aircraft {
 	clone := Aircraft{}
 	//deepcopier.Copy(ac).To(clone)
 	clone = *ac
 
+	clone.Trail = ac.Trail.clone()
+
+	if ac.LastFrames != nil {
+		clone.LastFrames = append([]RawFrame(nil), ac.LastFrames...)
+	}
+
+	if ac.Anomalies != nil {
+		clone.Anomalies = append([]Anomaly(nil), ac.Anomalies...)
+	}
+
+	if ac.Links != nil {
+		clone.Links = make(map[string]string, len(ac.Links))
+		for k, v := range ac.Links {
+			clone.Links[k] = v
+		}
+	}
+
 	return &clone
 }
 
 type Sky struct {
-	aircrafts    map[uint32]*Aircraft
-	aircraft_ttl int /* TTL before deletion. */
+	aircrafts     map[uint32]*Aircraft
+	modeac        map[int]*ModeACTarget /* Mode A/C replies by code. */
+	aircraft_ttl  time.Duration         /* TTL before deletion. */
+	squawk_region string
+
+	cpr_pair_max_age time.Duration /* See SetCPRPairMaxAge. */
+
+	/* Receiver location, reference of local CPR decoding. */
+	has_receiver               bool
+	receiver_lat, receiver_lon float64
+	max_range_km               float64 /* 0 = no range check. */
+	enrichers                  []Enricher
+
+	filter TrafficFilter /* Traffic shown, see filter.go. */
+	watch  *AircraftList /* Watched and ignored aircraft, see watch.go. */
+	ignore *AircraftList
+	zones  []Zone /* See zone.go. */
+
+	event_handlers []EventHandler
+	pending_events []Event
+
+	/* Multiple receivers, see merge.go */
+	merge_policy string
+	agreement    map[string]*SourceAgreement
+
+	/* Copies returned by Aircrafts, cloned again only once the aircraft
+	 * changed: version is bumped at every change of an aircraft. */
+	version      uint64
+	snapshots    map[uint32]*Aircraft
+	snapshot_mux sync.Mutex /* Aircrafts runs under the read lock. */
+
+	mux sync.RWMutex
+}
 
-	mux sync.Mutex
+/* SkyOption configures a Sky created with NewSky. */
+type SkyOption func(*Sky)
+
+/* Time aircraft are kept without receiving any message
+ * (MODES_AIRCRAFT_TTL seconds by default), see SetAircraftTTL. */
+func WithAircraftTTL(ttl time.Duration) SkyOption {
+	return func(sky *Sky) {
+		sky.aircraft_ttl = ttl
+	}
 }
 
-func NewSky() *Sky {
-	return &Sky{
-		aircrafts:    make(map[uint32]*Aircraft),
-		aircraft_ttl: MODES_AIRCRAFT_TTL,
+/* Max time between the even and the odd message of a CPR pair
+ * (MODES_CPR_PAIR_MAX_AGE by default), see SetCPRPairMaxAge. */
+func WithCPRPairMaxAge(age time.Duration) SkyOption {
+	return func(sky *Sky) {
+		sky.cpr_pair_max_age = age
 	}
 }
 
-// return copy of aircrafts data
-func (sky *Sky) Aircrafts() map[uint32]*Aircraft {
+func NewSky(opts ...SkyOption) *Sky {
+	sky := &Sky{
+		aircrafts:     make(map[uint32]*Aircraft),
+		snapshots:     make(map[uint32]*Aircraft),
+		modeac:        make(map[int]*ModeACTarget),
+		aircraft_ttl:  MODES_AIRCRAFT_TTL * time.Second,
+		squawk_region: "ICAO",
+		max_range_km:  MODES_DEFAULT_MAX_RANGE_KM,
+		merge_policy:  MERGE_FRESHEST,
+		agreement:     make(map[string]*SourceAgreement),
+
+		cpr_pair_max_age: MODES_CPR_PAIR_MAX_AGE,
+	}
+	for _, opt := range opts {
+		opt(sky)
+	}
+	return sky
+}
+
+/* Select the region of the conspicuity and special purpose squawk codes
+ * (see SquawkRegions()). */
+func (sky *Sky) SetSquawkRegion(region string) {
+	sky.mux.Lock()
+	defer sky.mux.Unlock()
+
+	sky.squawk_region = region
+}
+
+/* Set the time aircraft are kept without receiving any message,
+ * MODES_AIRCRAFT_TTL seconds by default. */
+func (sky *Sky) SetAircraftTTL(ttl time.Duration) {
+	sky.mux.Lock()
+	defer sky.mux.Unlock()
+
+	sky.aircraft_ttl = ttl
+}
+
+/* Return the aircraft TTL, see SetAircraftTTL. */
+func (sky *Sky) AircraftTTL() time.Duration {
+	sky.mux.RLock()
+	defer sky.mux.RUnlock()
+
+	return sky.aircraft_ttl
+}
+
+/* Set the max time between the even and the odd message of a CPR pair
+ * decoded together. An older pair is not decoded, the aircraft may have
+ * crossed a latitude zone since the first message; the position is then
+ * decoded relative to a known one. */
+func (sky *Sky) SetCPRPairMaxAge(age time.Duration) {
+	sky.mux.Lock()
+	defer sky.mux.Unlock()
+
+	sky.cpr_pair_max_age = age
+}
+
+/* Set the receiver location. Positions are then decoded from the first
+ * CPR message instead of waiting for an odd/even pair. */
+func (sky *Sky) SetReceiverLocation(lat, lon float64) {
+	sky.mux.Lock()
+	defer sky.mux.Unlock()
+
+	sky.has_receiver = true
+	sky.receiver_lat, sky.receiver_lon = lat, lon
+
+	for _, a := range sky.aircrafts {
+		if a.Trail.Len() > 0 {
+			sky.setRange(a)
+			sky.applyFilter(a)
+			sky.touch(a)
+		}
+	}
+}
+
+/* Return the receiver location, ok is false if not set. */
+func (sky *Sky) ReceiverLocation() (lat, lon float64, ok bool) {
+	sky.mux.RLock()
+	defer sky.mux.RUnlock()
+
+	return sky.receiver_lat, sky.receiver_lon, sky.has_receiver
+}
+
+/* Compute the distance and bearing of the aircraft position from the
+ * receiver. */
+func (sky *Sky) setRange(a *Aircraft) {
+	if !sky.has_receiver {
+		return
+	}
+
+	a.Ranged = true
+	a.DistanceKm = greatCircleKm(sky.receiver_lat, sky.receiver_lon, a.Latitude, a.Longitude)
+	a.Bearing = initialBearing(sky.receiver_lat, sky.receiver_lon, a.Latitude, a.Longitude)
+}
+
+/* Add previously saved aircraft that are not tracked yet, e.g. loaded
+ * from a store at startup. Aircraft older than the TTL are removed by
+ * the next RemoveStaleAircrafts. */
+func (sky *Sky) Restore(aircrafts []*Aircraft) {
 	sky.mux.Lock()
 	defer sky.mux.Unlock()
 
-	clone := make(map[uint32]*Aircraft)
+	for _, ac := range aircrafts {
+		if sky.aircrafts[ac.Addr] == nil {
+			a := ac.Clone()
+			sky.applyFilter(a)
+			sky.touch(a)
+			sky.aircrafts[ac.Addr] = a
+		}
+	}
+}
+
+/* Record a change of an aircraft, so that Aircrafts copies it again.
+ * Must be called with the lock held. */
+func (sky *Sky) touch(a *Aircraft) {
+	sky.version++
+	a.version = sky.version
+}
+
+// return copy of aircrafts data. The copies of the aircraft that did not
+// change since the last call are returned again, shared with the other
+// callers: they must not be modified (Clone them first).
+func (sky *Sky) Aircrafts() map[uint32]*Aircraft {
+	sky.mux.RLock()
+	defer sky.mux.RUnlock()
+	sky.snapshot_mux.Lock()
+	defer sky.snapshot_mux.Unlock()
+
+	clone := make(map[uint32]*Aircraft, len(sky.aircrafts))
 	for addr, ac := range sky.aircrafts {
-		clone[addr] = ac.Clone()
+		if ac.filtered {
+			continue
+		}
+		s := sky.snapshots[addr]
+		if s == nil || s.version != ac.version {
+			s = ac.Clone()
+			sky.snapshots[addr] = s
+		}
+		clone[addr] = s
 	}
 
 	return clone
 }
 
+/* Call fn with every aircraft, without copying them, until it returns
+ * false. The sky is read locked meanwhile: fn must neither modify nor
+ * keep the aircraft, nor call the other methods of the sky. */
+func (sky *Sky) ForEach(fn func(ac *Aircraft) bool) {
+	sky.mux.RLock()
+	defer sky.mux.RUnlock()
+
+	for _, ac := range sky.aircrafts {
+		if !ac.filtered && !fn(ac) {
+			return
+		}
+	}
+}
+
+/* Return the positions of an aircraft decoded after 'since', oldest
+ * first, nil if the aircraft is not tracked. */
+func (sky *Sky) Trail(addr uint32, since time.Time) []TrailPoint {
+	sky.mux.RLock()
+	defer sky.mux.RUnlock()
+
+	if ac := sky.aircrafts[addr]; ac != nil && !ac.filtered {
+		return ac.Trail.Since(since)
+	}
+	return nil
+}
+
+// return copy of one aircraft, nil if not tracked or filtered out
+func (sky *Sky) Aircraft(addr uint32) *Aircraft {
+	sky.mux.RLock()
+	defer sky.mux.RUnlock()
+
+	if ac := sky.aircrafts[addr]; ac != nil && !ac.filtered {
+		return ac.Clone()
+	}
+	return nil
+}
+
 func (sky *Sky) AircraftCount() int {
-	sky.mux.Lock()
-	defer sky.mux.Unlock()
+	sky.mux.RLock()
+	defer sky.mux.RUnlock()
 
-	return len(sky.aircrafts)
+	if sky.filter.IsEmpty() && sky.ignore == nil {
+		return len(sky.aircrafts)
+	}
+	n := 0
+	for _, ac := range sky.aircrafts {
+		if !ac.filtered {
+			n++
+		}
+	}
+	return n
 }
 
+/* Update the sky with a decoded message. Returns the updated aircraft,
+ * nil if the message was not used or the aircraft is filtered out (see
+ * SetFilter). */
 func (sky *Sky) UpdateData(mm *ModeSMessage) *Aircraft {
+	a := sky.updateData(mm)
+	sky.dispatchEvents()
+	return a
+}
+
+func (sky *Sky) updateData(mm *ModeSMessage) *Aircraft {
 	sky.mux.Lock()
 	defer sky.mux.Unlock()
 
@@ -93,68 +513,305 @@ func (sky *Sky) UpdateData(mm *ModeSMessage) *Aircraft {
 		return nil
 	}
 
+	if mm.msgtype == MODES_AC_MSGTYPE {
+		if a := sky.updateModeAC(mm); a != nil && !a.filtered {
+			return a
+		}
+		return nil
+	}
+
 	var addr uint32
 	addr = (mm.aa1 << 16) | (mm.aa2 << 8) | mm.aa3
+	if nonICAOAddress(mm) {
+		addr |= MODES_NON_ICAO_ADDRESS
+	}
+
+	if sky.ignore.HasAddr(addr) {
+		return nil
+	}
 
 	/* Loookup our aircraft or create a new one. */
 	a := sky.aircrafts[addr]
 	if a == nil {
 		a = NewAircraft(addr)
+		sky.enrich(a)
 		sky.aircrafts[addr] = a
 	}
+	sky.touch(a)
 
 	a.Seen = time.Now()
 	a.Messages++
+	a.DFCounts[mm.msgtype&31]++
+	a.MsgCounts.add(mm)
+	a.rate.add(a.Seen)
+	a.addFrame(mm, a.Seen)
+	if level, ok := mm.SignalLevel(); ok {
+		a.addSignal(level)
+	}
+
+	if mm.received.IsZero() {
+		mm.received = a.Seen
+	}
+	a.Provenance = mm.Provenance()
+
+	if mm.msgtype == 0 || mm.msgtype == 4 || mm.msgtype == 16 || mm.msgtype == 20 {
+		sky.setAltitude(a, altitudeFeet(mm))
+	}
+
+	if onGround, ok := mm.OnGround(); ok {
+		a.OnGround = onGround
+	}
+	if fs, _, _, ok := mm.Surveillance(); ok {
+		a.FlightStatusValid, a.FlightStatus = true, fs
+	}
+
+	if status, ok := mm.ACASStatus(); ok {
+		a.ACASValid, a.ACAS = true, status
+	}
+	if ra, ok := mm.ACASRA(); ok {
+		sky.setRA(a, ra)
+	}
+
+	if mm.msgtype == 5 || mm.msgtype == 21 {
+		sky.setSquawk(a, mm.identity)
+	}
+
+	if mm.msgtype == 20 || mm.msgtype == 21 {
+		if mm.bds == BDS_20 {
+			if callsign, ok := sanitizeCallsign(mm.flight[:]); ok {
+				sky.setCallsign(a, callsign)
+			}
+		} else if mm.bds != BDS_UNKNOWN {
+			a.EHS.merge(mm.bds, &mm.ehs)
+			a.mergeCommBDirections(mm.bds, &mm.ehs)
+		}
+	} else if source := esSource(mm); source != "" {
+		if sourceRank[source] > sourceRank[a.Source] {
+			a.Source = source
+		}
+		a.ADSB.update(mm)
 
-	if mm.msgtype == 0 || mm.msgtype == 4 || mm.msgtype == 20 {
-		a.Altitude = mm.altitude
-	} else if mm.msgtype == 17 {
 		if mm.metype >= 1 && mm.metype <= 4 {
-			a.Flight = string(mm.flight[:])
-		} else if mm.metype >= 9 && mm.metype <= 18 {
-			a.Altitude = mm.altitude
+			if callsign, ok := sanitizeCallsign(mm.flight[:]); ok {
+				sky.setCallsign(a, callsign)
+			}
+			if category, ok := mm.Category(); ok {
+				a.Category = category
+			}
+		} else if (mm.metype >= 9 && mm.metype <= 18) || (mm.metype >= 20 && mm.metype <= 22) {
+			if mm.metype <= 18 {
+				sky.setAltitude(a, altitudeFeet(mm))
+				a.deriveAltitudeGeom()
+			} else if alt, ok := mm.GeometricAltitude(); ok {
+				a.AltitudeGeomValid, a.AltitudeGeom = true, alt
+			}
 			if mm.fflag != 0 {
 				a.OddCprLat = mm.raw_latitude
 				a.OddCprLon = mm.raw_longitude
 				a.OddCprTime = mstime()
+				a.cpr_odd_last = true
 			} else {
 				a.EvenCprLat = mm.raw_latitude
 				a.EvenCprLon = mm.raw_longitude
 				a.EvenCprTime = mstime()
+				a.cpr_odd_last = false
+			}
+			/* If the two data are close enough in time, compute the
+			 * position. Otherwise decode this message alone
+			 * relative to a known position. */
+			prevLat, prevLon := a.Latitude, a.Longitude
+			decoded := false
+			pairAge := time.Duration(a.EvenCprTime-a.OddCprTime) * time.Millisecond
+			if pairAge.Abs() <= sky.cpr_pair_max_age {
+				decoded = decodeCPR(a)
 			}
-			/* If the two data is less than 10 seconds apart, compute
-			 * the position. */
-			if math.Abs(float64(a.EvenCprTime-a.OddCprTime)) <= 10000 {
-				decodeCPR(a)
+			if !decoded {
+				decoded = sky.decodeCPRLocalPosition(a, mm)
+			}
+			if decoded && !sky.plausiblePosition(a) {
+				a.Latitude, a.Longitude = prevLat, prevLon
+				decoded = false
+			}
+			if decoded {
+				sky.mergePosition(a)
+				sky.setRange(a)
+				a.addTrailPoint()
 			}
 		} else if mm.metype == 19 {
 			if mm.mesub == 1 || mm.mesub == 2 {
-				a.Speed = mm.velocity
-				a.Track = mm.heading
+				sky.setVelocity(a, mm.velocity, mm.heading)
+			} else if heading, ok := mm.Heading(); ok {
+				a.setHeading(heading, a.ADSB.headingReference(), DIRECTION_ADSB_AIRSPEED)
+			}
+			if rate, ok := mm.VerticalRate(); ok {
+				a.VertRateValid, a.VertRate = true, rate
+			}
+			if diff, ok := mm.GNSSBaroDiff(); ok {
+				a.GNSSBaroDiffValid, a.GNSSBaroDiff = true, diff
+				a.deriveAltitudeGeom()
 			}
+		} else if mm.metype == 28 && mm.mesub == 1 {
+			a.EmergencyState = mm.emergency_state
+			a.EmergencyStateDesc = emergencyStr(mm.emergency_state)
+			sky.setSquawk(a, mm.identity)
 		}
 	}
 
+	sky.applyFilter(a)
+	if !a.filtered {
+		sky.applyWatch(a)
+		sky.applyZones(a, a.Seen)
+	}
+	if a.filtered {
+		return nil
+	}
 	return a
 }
 
-/* This algorithm comes from:
+/* Return 1 if the aircraft is climbing, -1 if it is descending, 0 if it
+ * is level or its vertical rate is unknown. */
+func (a *Aircraft) Climb() int {
+	switch {
+	case !a.VertRateValid:
+		return 0
+	case a.VertRate >= MODES_LEVEL_VERT_RATE:
+		return 1
+	case a.VertRate <= -MODES_LEVEL_VERT_RATE:
+		return -1
+	}
+	return 0
+}
+
+/* Compute the geometric altitude from the barometric altitude and the
+ * last reported difference, when both are known. */
+func (a *Aircraft) deriveAltitudeGeom() {
+	if a.GNSSBaroDiffValid && !a.altitude_time.IsZero() && a.Altitude != 0 {
+		a.AltitudeGeomValid, a.AltitudeGeom = true, a.Altitude+a.GNSSBaroDiff
+	}
+}
+
+/* Append the current position to the trail, overwriting the oldest point
+ * when the trail is full. */
+func (a *Aircraft) addTrailPoint() {
+	a.Trail.add(TrailPoint{
+		Latitude:   a.Latitude,
+		Longitude:  a.Longitude,
+		Altitude:   a.Altitude,
+		Speed:      a.Speed,
+		Time:       a.Seen,
+		Provenance: a.Provenance,
+		NIC:        a.ADSB.NIC,
+	})
+}
+
+/* Update the squawk of an aircraft and its emergency flag. An event is
+ * raised when the aircraft starts squawking an emergency code. */
+func (sky *Sky) setSquawk(a *Aircraft, squawk int) {
+	changed := squawk != a.Squawk
+
+	a.Squawk = squawk
+	a.SquawkMeaning = SquawkMeaning(squawk, sky.squawk_region)
+	a.Emergency = IsEmergencySquawk(a.Squawk) || a.EmergencyState != 0
+
+	if changed && IsEmergencySquawk(squawk) {
+		sky.emit(Event{
+			Type:     EVENT_EMERGENCY_SQUAWK,
+			Time:     a.Seen,
+			Aircraft: a.Clone(),
+			Squawk:   squawk,
+		})
+	}
+}
+
+/* Aircraft altitudes are kept in feet; convert altitudes reported in
+ * meters (M bit set). */
+func altitudeFeet(mm *ModeSMessage) int {
+	if mm.unit == MODES_UNIT_METERS {
+		return int(math.Round(float64(mm.altitude) * FEET_PER_METER))
+	}
+	return mm.altitude
+}
+
+/* Decode a single CPR encoded position relative to a reference position
+ * (locally unambiguous decoding). The result is correct when the
+ * reference is within half a latitude zone (about 180 NM) of the actual
+ * position. */
+func decodeCPRLocal(zone *cprZone, refLat, refLon float64, rawLat, rawLon int, odd int) (float64, float64) {
+	const nb = 131072.0 /* 2^17 */
+
+	dlat := 360.0 / float64(60-odd)
+	j := math.Floor(refLat/dlat) +
+		math.Floor(0.5+cprModFloat(refLat, dlat)/dlat-float64(rawLat)/nb)
+	lat := dlat * (j + float64(rawLat)/nb)
+
+	dlon := cprDlonFunction(zone.NL(lat), odd)
+	m := math.Floor(refLon/dlon) +
+		math.Floor(0.5+cprModFloat(refLon, dlon)/dlon-float64(rawLon)/nb)
+	lon := dlon * (m + float64(rawLon)/nb)
+
+	return lat, lon
+}
+
+/* Decode the position of the last received CPR message alone, relative to
+ * the last known position of the aircraft if recent enough, or else to
+ * the receiver location. Returns true if a position was computed. */
+func (sky *Sky) decodeCPRLocalPosition(a *Aircraft, mm *ModeSMessage) bool {
+	odd := 0
+	if mm.fflag != 0 {
+		odd = 1
+	}
+
+	var refLat, refLon float64
+	if last, ok := a.Trail.Last(); ok && a.Seen.Sub(last.Time) <= MODES_CPR_LOCAL_MAX_AGE {
+		refLat, refLon = last.Latitude, last.Longitude
+	} else if sky.has_receiver {
+		refLat, refLon = sky.receiver_lat, sky.receiver_lon
+	} else {
+		return false
+	}
+
+	lat, lon := decodeCPRLocal(&a.cpr_zone, refLat, refLon, mm.raw_latitude, mm.raw_longitude, odd)
+	if greatCircleKm(refLat, refLon, lat, lon) > MODES_CPR_LOCAL_MAX_RANGE_NM*KM_PER_NM {
+		return false
+	}
+
+	a.Latitude, a.Longitude = lat, lon
+	return true
+}
+
+/* Decode the position of an aircraft from its last even and odd CPR
+ * messages, see DecodeCPRGlobal. */
+func decodeCPR(a *Aircraft) bool {
+	lat, lon, ok := decodeCPRGlobal(&a.cpr_zone, a.EvenCprLat, a.EvenCprLon, a.OddCprLat, a.OddCprLon, a.cpr_odd_last)
+	if !ok {
+		return false
+	}
+	a.Latitude, a.Longitude = lat, lon
+	return true
+}
+
+/* Decode an airborne position from an even and an odd CPR message
+ * (globally unambiguous decoding), given as their 17 bit latitude and
+ * longitude (131072 is 2^17). This algorithm comes from:
  * http://www.lll.lu/~edward/edward/adsb/DecodingADSBposition.html.
  *
- *
- * A few remarks:
- * 1) 131072 is 2^17 since CPR latitude and longitude are encoded in 17 bits.
- * 2) We assume that we always received the odd packet as last packet for
- *    simplicity. This may provide a position that is less fresh of a few
- *    seconds.
- */
-func decodeCPR(a *Aircraft) {
+ * The position is the one of the most recent message, the odd one if
+ * oddLast. ok is false if the two messages are in different latitude
+ * zones, the aircraft having crossed a zone boundary between them, or if
+ * the result is not a valid position. */
+func DecodeCPRGlobal(evenLat, evenLon, oddLat, oddLon int, oddLast bool) (lat, lon float64, ok bool) {
+	var zone cprZone
+	return decodeCPRGlobal(&zone, evenLat, evenLon, oddLat, oddLon, oddLast)
+}
+
+/* DecodeCPRGlobal, looking up the NL of the latitudes in zone first. */
+func decodeCPRGlobal(zone *cprZone, evenLat, evenLon, oddLat, oddLon int, oddLast bool) (lat, lon float64, ok bool) {
 	const AirDlat0 float64 = 360.0 / 60
 	const AirDlat1 float64 = 360.0 / 59
-	lat0 := float64(a.EvenCprLat)
-	lat1 := float64(a.OddCprLat)
-	lon0 := float64(a.EvenCprLon)
-	lon1 := float64(a.OddCprLon)
+	lat0 := float64(evenLat)
+	lat1 := float64(oddLat)
+	lon0 := float64(evenLon)
+	lon1 := float64(oddLon)
 
 	/* Compute the Latitude Index "j" */
 	j := int(math.Floor(((59*lat0 - 60*lat1) / 131072) + 0.5))
@@ -167,31 +824,45 @@ func decodeCPR(a *Aircraft) {
 	if rlat1 >= 270 {
 		rlat1 -= 360
 	}
+	if rlat0 < -90 || rlat0 > 90 || rlat1 < -90 || rlat1 > 90 {
+		return 0, 0, false
+	}
 
 	/* Check that both are in the same latitude zone, or abort. */
-	if cprNLFunction(rlat0) != cprNLFunction(rlat1) {
-		return
+	nl := zone.NL(rlat0)
+	if nl != zone.NL(rlat1) {
+		return 0, 0, false
 	}
 
-	/* Compute ni and the longitude index m */
-	if a.EvenCprTime > a.OddCprTime {
-		/* Use even packet. */
-		var ni int = cprNFunction(rlat0, 0)
-		m := math.Floor((((lon0 * float64(cprNLFunction(rlat0)-1)) -
-			(lon1 * float64(cprNLFunction(rlat0)))) / 131072) + 0.5)
-		a.Longitude = cprDlonFunction(rlat0, 0) * (float64(cprModFunction(int(m), ni)) + lon0/131072)
-		a.Latitude = rlat0
+	/* Compute ni and the longitude index m, from the most recent
+	 * message. */
+	odd, rlon := 0, lon0
+	lat = rlat0
+	if oddLast {
+		odd, rlon = 1, lon1
+		lat = rlat1
+	}
+	ni := cprNFunction(nl, odd)
+	m := math.Floor((((lon0 * float64(nl-1)) - (lon1 * float64(nl))) / 131072) + 0.5)
+	lon = cprDlonFunction(nl, odd) * (float64(cprModFunction(int(m), ni)) + rlon/131072)
+	if lon >= 180 {
+		lon -= 360
+	}
+
+	/* Re-encoding the position must give back the message it was
+	 * decoded from: else the number of longitude zones of the decoded
+	 * latitude is not the one used, the latitude being on a zone
+	 * boundary. */
+	rawLat, rawLon := encodeCPR(zone, lat, lon, odd)
+	if odd == 1 {
+		ok = rawLat == oddLat && rawLon == oddLon
 	} else {
-		/* Use odd packet. */
-		var ni int = cprNFunction(rlat1, 1)
-		m := math.Floor((((lon0 * float64(cprNLFunction(rlat1)-1)) -
-			(lon1 * float64(cprNLFunction(rlat1)))) / 131072.0) + 0.5)
-		a.Longitude = cprDlonFunction(rlat1, 1) * (float64(cprModFunction(int(m), ni)) + lon1/131072)
-		a.Latitude = rlat1
+		ok = rawLat == evenLat && rawLon == evenLon
 	}
-	if a.Longitude > 180 {
-		a.Longitude -= 360
+	if !ok {
+		return 0, 0, false
 	}
+	return lat, lon, true
 }
 
 /* Always positive MOD operation, used for CPR decoding. */
@@ -204,150 +875,102 @@ func cprModFunction(a, b int) int {
 	return res
 }
 
-/* The NL function uses the precomputed table from 1090-WP-9-14 */
+/* Latitudes where the number of longitude zones NL drops by one, from
+ * the precomputed table of 1090-WP-9-14: NL is 59 below the first one,
+ * and 1 from the last one to the poles. */
+var cprNLTable = [...]float64{
+	10.47047130, 14.82817437, 18.18626357, 21.02939493,
+	23.54504487, 25.82924707, 27.93898710, 29.91135686,
+	31.77209708, 33.53993436, 35.22899598, 36.85025108,
+	38.41241892, 39.92256684, 41.38651832, 42.80914012,
+	44.19454951, 45.54626723, 46.86733252, 48.16039128,
+	49.42776439, 50.67150166, 51.89342469, 53.09516153,
+	54.27817472, 55.44378444, 56.59318756, 57.72747354,
+	58.84763776, 59.95459277, 61.04917774, 62.13216659,
+	63.20427479, 64.26616523, 65.31845310, 66.36171008,
+	67.39646774, 68.42322022, 69.44242631, 70.45451075,
+	71.45986473, 72.45884545, 73.45177442, 74.43893416,
+	75.42056257, 76.39684391, 77.36789461, 78.33374083,
+	79.29428225, 80.24923213, 81.19801349, 82.13956981,
+	83.07199445, 83.99173563, 84.89166191, 85.75541621,
+	86.53536998, 87.00000000,
+}
+
+/* The NL function: the number of the table latitudes at or below lat
+ * gives the zones lost from the equator. */
 func cprNLFunction(lat float64) int {
 	/* Table is simmetric about the equator. */
 	if lat < 0 {
 		lat = -lat
 	}
+	return 59 - cprNLIndex(lat)
+}
 
-	switch {
-	case lat < 10.47047130:
-		return 59
-	case lat < 14.82817437:
-		return 58
-	case lat < 18.18626357:
-		return 57
-	case lat < 21.02939493:
-		return 56
-	case lat < 23.54504487:
-		return 55
-	case lat < 25.82924707:
-		return 54
-	case lat < 27.93898710:
-		return 53
-	case lat < 29.91135686:
-		return 52
-	case lat < 31.77209708:
-		return 51
-	case lat < 33.53993436:
-		return 50
-	case lat < 35.22899598:
-		return 49
-	case lat < 36.85025108:
-		return 48
-	case lat < 38.41241892:
-		return 47
-	case lat < 39.92256684:
-		return 46
-	case lat < 41.38651832:
-		return 45
-	case lat < 42.80914012:
-		return 44
-	case lat < 44.19454951:
-		return 43
-	case lat < 45.54626723:
-		return 42
-	case lat < 46.86733252:
-		return 41
-	case lat < 48.16039128:
-		return 40
-	case lat < 49.42776439:
-		return 39
-	case lat < 50.67150166:
-		return 38
-	case lat < 51.89342469:
-		return 37
-	case lat < 53.09516153:
-		return 36
-	case lat < 54.27817472:
-		return 35
-	case lat < 55.44378444:
-		return 34
-	case lat < 56.59318756:
-		return 33
-	case lat < 57.72747354:
-		return 32
-	case lat < 58.84763776:
-		return 31
-	case lat < 59.95459277:
-		return 30
-	case lat < 61.04917774:
-		return 29
-	case lat < 62.13216659:
-		return 28
-	case lat < 63.20427479:
-		return 27
-	case lat < 64.26616523:
-		return 26
-	case lat < 65.31845310:
-		return 25
-	case lat < 66.36171008:
-		return 24
-	case lat < 67.39646774:
-		return 23
-	case lat < 68.42322022:
-		return 22
-	case lat < 69.44242631:
-		return 21
-	case lat < 70.45451075:
-		return 20
-	case lat < 71.45986473:
-		return 19
-	case lat < 72.45884545:
-		return 18
-	case lat < 73.45177442:
-		return 17
-	case lat < 74.43893416:
-		return 16
-	case lat < 75.42056257:
-		return 15
-	case lat < 76.39684391:
-		return 14
-	case lat < 77.36789461:
-		return 13
-	case lat < 78.33374083:
-		return 12
-	case lat < 79.29428225:
-		return 11
-	case lat < 80.24923213:
-		return 10
-	case lat < 81.19801349:
-		return 9
-	case lat < 82.13956981:
-		return 8
-	case lat < 83.07199445:
-		return 7
-	case lat < 83.99173563:
-		return 6
-	case lat < 84.89166191:
-		return 5
-	case lat < 85.75541621:
-		return 4
-	case lat < 86.53536998:
-		return 3
-	case lat < 87.00000000:
-		return 2
-	default:
-		return 1
+/* Index of the first table latitude above lat (positive), by binary
+ * search. */
+func cprNLIndex(lat float64) int {
+	lo, hi := 0, len(cprNLTable)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if cprNLTable[mid] > lat {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo
+}
+
+/* The NL zone of the last latitude looked up. An aircraft stays in the
+ * same zone for dozens of positions (a zone spans 1 to 10 degrees of
+ * latitude), which are then found without searching the table. */
+type cprZone struct {
+	lo, hi float64 /* Absolute latitudes of the zone, hi excluded. */
+	nl     int     /* 0 before the first lookup. */
+}
+
+func (z *cprZone) NL(lat float64) int {
+	if lat < 0 {
+		lat = -lat
+	}
+	if z.nl != 0 && lat >= z.lo && lat < z.hi {
+		return z.nl
+	}
+
+	i := cprNLIndex(lat)
+	z.nl = 59 - i
+	z.lo, z.hi = 0, math.Inf(1)
+	if i > 0 {
+		z.lo = cprNLTable[i-1]
+	}
+	if i < len(cprNLTable) {
+		z.hi = cprNLTable[i]
 	}
+	return z.nl
 }
 
-func cprNFunction(lat float64, isodd int) int {
-	nl := cprNLFunction(lat) - isodd
-	if nl < 1 {
-		nl = 1
+/* Number of longitude zones of a latitude of the given NL, in the odd or
+ * even format. */
+func cprNFunction(nl int, isodd int) int {
+	n := nl - isodd
+	if n < 1 {
+		n = 1
 	}
-	return nl
+	return n
 }
 
-func cprDlonFunction(lat float64, isodd int) float64 {
-	return 360.0 / float64(cprNFunction(lat, isodd))
+func cprDlonFunction(nl int, isodd int) float64 {
+	return 360.0 / float64(cprNFunction(nl, isodd))
 }
 
 /* When in interactive mode If we don't receive new nessages within
- * MODES_AIRCRAFT_TTL seconds we remove the aircraft from the list. */
+ * the aircraft TTL we remove the aircraft from the list. */
 func (sky *Sky) RemoveStaleAircrafts() {
+	sky.removeStaleAircrafts()
+	sky.dispatchEvents()
+}
+
+func (sky *Sky) removeStaleAircrafts() {
 	sky.mux.Lock()
 	defer sky.mux.Unlock()
 
@@ -356,13 +979,22 @@ func (sky *Sky) RemoveStaleAircrafts() {
 	remKeys := make([]uint32, 0)
 
 	for k, a := range sky.aircrafts {
-		dur := now.Sub(a.Seen)
-		if int(dur.Seconds()) > sky.aircraft_ttl {
+		if now.Sub(a.Seen) > sky.aircraft_ttl {
 			remKeys = append(remKeys, k)
 		}
 	}
 
+	sky.snapshot_mux.Lock()
 	for _, k := range remKeys {
+		sky.leaveZones(sky.aircrafts[k], now)
 		delete(sky.aircrafts, k)
+		delete(sky.snapshots, k)
+	}
+	sky.snapshot_mux.Unlock()
+
+	for code, t := range sky.modeac {
+		if now.Sub(t.Seen) > sky.aircraft_ttl {
+			delete(sky.modeac, code)
+		}
 	}
 }
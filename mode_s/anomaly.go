@@ -0,0 +1,164 @@
+package mode_s
+
+import (
+	"go1090/i18n"
+	"math"
+	"time"
+)
+
+/* Anomaly detection, for the study of ADS-B spoofing: an ICAO address
+ * reported at two distant positions within seconds (two transmitters
+ * using the same address), or kinematics no real aircraft has. */
+
+/* Kinds of anomalies. */
+const (
+	ANOMALY_POSITION_JUMP = "position_jump" /* Implied ground speed above ANOMALY_MAX_SPEED_KT. */
+	ANOMALY_ALTITUDE_JUMP = "altitude_jump" /* Vertical rate above ANOMALY_MAX_VERT_RATE_FPM. */
+	ANOMALY_SPEED         = "speed"         /* Ground speed above ANOMALY_MAX_SPEED_KT. */
+	ANOMALY_SLOW_AT_ALT   = "slow_at_alt"   /* Too slow for the altitude. */
+	ANOMALY_FAST_AT_LOW   = "fast_at_low"   /* Too fast for the altitude. */
+)
+
+const ANOMALY_HISTORY_LENGTH = 8 /* Anomalies kept per aircraft. */
+
+/* Position jumps of an aircraft are reported at most once in this
+ * interval: two transmitters using the same address give one at every
+ * position. */
+const ANOMALY_JUMP_INTERVAL = time.Minute
+
+const (
+	ANOMALY_MAX_SPEED_KT      = 1500  /* Faster than any aircraft ever was at cruise. */
+	ANOMALY_MAX_VERT_RATE_FPM = 30000 /* Far above the climb rate of any aircraft. */
+	ANOMALY_MIN_JUMP_KM       = 5     /* Ignore jumps below the CPR error. */
+	ANOMALY_MIN_JUMP_FT       = 2000  /* Ignore altitude jumps below this. */
+	ANOMALY_SLOW_ALT_FT       = 10000 /* Above this altitude... */
+	ANOMALY_SLOW_SPEED_KT     = 50    /* ...ground speed must be above this. */
+	ANOMALY_FAST_ALT_FT       = 3000  /* Below this altitude... */
+	ANOMALY_FAST_SPEED_KT     = 600   /* ...ground speed must be below this. */
+)
+
+/* An anomaly detected on an aircraft. */
+type Anomaly struct {
+	Kind   string    `json:"kind"`
+	Detail string    `json:"detail"`
+	Time   time.Time `json:"time"`
+}
+
+/* Flag the aircraft and emit the event. Kinematic anomalies are reported
+ * at most once per kind for every change of the offending values. */
+func (sky *Sky) flagAnomaly(a *Aircraft, kind string, data map[string]interface{}) {
+	an := Anomaly{
+		Kind:   kind,
+		Detail: i18n.T("anomaly."+kind, data),
+		Time:   a.Seen,
+	}
+
+	a.Suspect = true
+	if len(a.Anomalies) >= ANOMALY_HISTORY_LENGTH {
+		copy(a.Anomalies, a.Anomalies[1:])
+		a.Anomalies = a.Anomalies[:len(a.Anomalies)-1]
+	}
+	a.Anomalies = append(a.Anomalies, an)
+
+	sky.emit(Event{
+		Type:     EVENT_ANOMALY,
+		Time:     an.Time,
+		Aircraft: a.Clone(),
+		Anomaly:  &an,
+	})
+}
+
+/* Compare a newly decoded position with the last one of the trail. Must
+ * be called before the position is added to the trail. */
+func (sky *Sky) checkPosition(a *Aircraft) {
+	last, ok := a.Trail.Last()
+	if !ok {
+		return
+	}
+
+	if impliedSpeedKt(last.Latitude, last.Longitude, last.Time, a.Latitude, a.Longitude, a.Seen) > ANOMALY_MAX_SPEED_KT {
+		km := greatCircleKm(last.Latitude, last.Longitude, a.Latitude, a.Longitude)
+		secs := math.Max(1, a.Seen.Sub(last.Time).Seconds())
+		sky.flagAnomaly(a, ANOMALY_POSITION_JUMP, map[string]interface{}{
+			"Km":      math.Round(km),
+			"Seconds": math.Round(secs),
+		})
+	}
+}
+
+/* Report a position jump, see checkPosition, unless one was reported
+ * within ANOMALY_JUMP_INTERVAL. */
+func (sky *Sky) reportJump(a *Aircraft) {
+	if !a.jump_reported.IsZero() && a.Seen.Sub(a.jump_reported) < ANOMALY_JUMP_INTERVAL {
+		return
+	}
+	a.jump_reported = a.Seen
+	sky.checkPosition(a)
+}
+
+/* Update the altitude of an aircraft, checking the implied vertical rate. */
+func (sky *Sky) setAltitude(a *Aircraft, altitude int) {
+	/* 0 is also what undecodable altitudes give, don't compare it. */
+	if a.Altitude != 0 && altitude != 0 && !a.altitude_time.IsZero() {
+		ft := altitude - a.Altitude
+		if ft < 0 {
+			ft = -ft
+		}
+
+		mins := a.Seen.Sub(a.altitude_time).Minutes()
+		if mins < 1.0/60 {
+			mins = 1.0 / 60
+		}
+		if ft >= ANOMALY_MIN_JUMP_FT && float64(ft)/mins > ANOMALY_MAX_VERT_RATE_FPM {
+			sky.flagAnomaly(a, ANOMALY_ALTITUDE_JUMP, map[string]interface{}{
+				"From": a.Altitude,
+				"To":   altitude,
+			})
+		}
+	}
+
+	a.Altitude = altitude
+	a.altitude_time = a.Seen
+	sky.checkSpeedAltitude(a)
+}
+
+/* Update the velocity of an aircraft, checking it against the altitude. */
+func (sky *Sky) setVelocity(a *Aircraft, speed, track int) {
+	changed := speed != a.Speed
+	a.Speed = speed
+	a.Track = track
+	a.TrackValid = speed != 0
+	a.TrackSource = DIRECTION_ADSB_VELOCITY
+
+	if changed {
+		sky.checkSpeedAltitude(a)
+	}
+}
+
+func (sky *Sky) checkSpeedAltitude(a *Aircraft) {
+	if a.Speed == 0 || a.Altitude == 0 {
+		return
+	}
+
+	kind := ""
+	switch {
+	case a.Speed > ANOMALY_MAX_SPEED_KT:
+		kind = ANOMALY_SPEED
+	case a.Altitude > ANOMALY_SLOW_ALT_FT && a.Speed < ANOMALY_SLOW_SPEED_KT:
+		kind = ANOMALY_SLOW_AT_ALT
+	case a.Altitude < ANOMALY_FAST_ALT_FT && a.Speed > ANOMALY_FAST_SPEED_KT:
+		kind = ANOMALY_FAST_AT_LOW
+	}
+
+	/* Report a kinematic anomaly once, not on every message. */
+	if kind == "" || kind == a.kinematic_anomaly {
+		a.kinematic_anomaly = kind
+		return
+	}
+	a.kinematic_anomaly = kind
+
+	sky.flagAnomaly(a, kind, map[string]interface{}{
+		"Speed":    a.Speed,
+		"Altitude": a.Altitude,
+	})
+}
@@ -0,0 +1,44 @@
+package mode_s
+
+import "strings"
+
+/* Clean a decoded identification: the trailing spaces and NUL are
+ * stripped. ok is false for a corrupted one, empty, with a character out
+ * of the charset ('?' in aisCharset) or a space inside. */
+func sanitizeCallsign(flight []rune) (callsign string, ok bool) {
+	callsign = strings.TrimRight(string(flight), " \x00")
+	if callsign == "" {
+		return "", false
+	}
+	for _, c := range callsign {
+		if !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+			return "", false
+		}
+	}
+	return callsign, true
+}
+
+/* Update the callsign of an aircraft. The first one is taken as is; a
+ * different one is only taken when received again, to ignore the glitch
+ * of a single frame, and raises an EVENT_CALLSIGN_CHANGED event. */
+func (sky *Sky) setCallsign(a *Aircraft, callsign string) {
+	switch {
+	case callsign == a.Flight:
+		a.flight_candidate = ""
+	case a.Flight == "":
+		a.Flight = callsign
+		a.flight_candidate = ""
+	case callsign != a.flight_candidate:
+		a.flight_candidate = callsign
+	default:
+		previous := a.Flight
+		a.Flight = callsign
+		a.flight_candidate = ""
+		sky.emit(Event{
+			Type:           EVENT_CALLSIGN_CHANGED,
+			Time:           a.Seen,
+			Aircraft:       a.Clone(),
+			PreviousFlight: previous,
+		})
+	}
+}
@@ -0,0 +1,378 @@
+package mode_s
+
+import (
+	"encoding/json"
+	"math"
+)
+
+/* Comm-B Data Selectors we are able to identify in the MB field of
+ * DF20/DF21 replies. The register number is written as in the
+ * specification, 0x40 is "BDS 4,0". */
+const (
+	BDS_UNKNOWN = 0x00
+	BDS_10      = 0x10 /* Data link capability report */
+	BDS_17      = 0x17 /* Common usage GICB capability report */
+	BDS_20      = 0x20 /* Aircraft identification */
+	BDS_40      = 0x40 /* Selected vertical intention */
+	BDS_50      = 0x50 /* Track and turn report */
+	BDS_60      = 0x60 /* Heading and speed report */
+)
+
+/* Enhanced Surveillance data decoded from Comm-B replies. A field is only
+ * meaningful when its status flag is true. */
+type EHSData struct {
+	/* BDS 1,7 */
+	GICBCapability GICBRegisters `json:"gicb_capability,omitempty"` /* Registers the transponder can deliver. */
+
+	/* BDS 4,0 */
+	SelectedAltitudeValid bool    `json:"selected_altitude_valid,omitempty"`
+	SelectedAltitude      int     `json:"selected_altitude,omitempty"` /* MCP/FCU selected altitude, ft */
+	FMSAltitudeValid      bool    `json:"fms_altitude_valid,omitempty"`
+	FMSAltitude           int     `json:"fms_altitude,omitempty"` /* FMS selected altitude, ft */
+	BaroSettingValid      bool    `json:"baro_setting_valid,omitempty"`
+	BaroSetting           float64 `json:"baro_setting,omitempty"` /* Barometric pressure setting, mb */
+
+	/* BDS 5,0 */
+	RollValid        bool    `json:"roll_valid,omitempty"`
+	Roll             float64 `json:"roll,omitempty"` /* Roll angle, degrees (negative = left wing down) */
+	TrueTrackValid   bool    `json:"true_track_valid,omitempty"`
+	TrueTrack        float64 `json:"true_track,omitempty"` /* True track angle, degrees */
+	GroundSpeedValid bool    `json:"ground_speed_valid,omitempty"`
+	GroundSpeed      int     `json:"ground_speed,omitempty"` /* kt */
+	TrackRateValid   bool    `json:"track_rate_valid,omitempty"`
+	TrackRate        float64 `json:"track_rate,omitempty"` /* degrees/second */
+	TASValid         bool    `json:"tas_valid,omitempty"`
+	TAS              int     `json:"tas,omitempty"` /* True airspeed, kt */
+
+	/* BDS 6,0 */
+	MagHeadingValid       bool    `json:"mag_heading_valid,omitempty"`
+	MagHeading            float64 `json:"mag_heading,omitempty"` /* Magnetic heading, degrees */
+	IASValid              bool    `json:"ias_valid,omitempty"`
+	IAS                   int     `json:"ias,omitempty"` /* Indicated airspeed, kt */
+	MachValid             bool    `json:"mach_valid,omitempty"`
+	Mach                  float64 `json:"mach,omitempty"`
+	BaroVertRateValid     bool    `json:"baro_vert_rate_valid,omitempty"`
+	BaroVertRate          int     `json:"baro_vert_rate,omitempty"` /* ft/min */
+	InertialVertRateValid bool    `json:"inertial_vert_rate_valid,omitempty"`
+	InertialVertRate      int     `json:"inertial_vert_rate,omitempty"` /* ft/min */
+}
+
+/* The GICB capability bits of BDS 1,7, MB bit 1 to 24. */
+var gicbRegisters = [24]int{
+	0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x20, 0x21,
+	0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x48, 0x50,
+	0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x5F, 0x60,
+}
+
+/* The registers of a GICB capability report, MB bit 1 to 24 of BDS 1,7
+ * (MB bit 1 is the most significant one). A mask rather than a list so
+ * that decoding doesn't allocate; it is written in JSON as the list of
+ * the registers, e.g. [32, 64] for BDS 2,0 and 4,0. */
+type GICBRegisters uint32
+
+/* Return the registers, e.g. 0x40 for BDS 4,0. */
+func (g GICBRegisters) Registers() []int {
+	var regs []int
+	for i, reg := range gicbRegisters {
+		if g&(1<<uint(23-i)) != 0 {
+			regs = append(regs, reg)
+		}
+	}
+	return regs
+}
+
+func (g GICBRegisters) MarshalJSON() ([]byte, error) {
+	regs := g.Registers()
+	if regs == nil {
+		regs = []int{}
+	}
+	return json.Marshal(regs)
+}
+
+/* Read the list of the registers, the registers a report can't hold are
+ * ignored. */
+func (g *GICBRegisters) UnmarshalJSON(data []byte) error {
+	var regs []int
+	if err := json.Unmarshal(data, &regs); err != nil {
+		return err
+	}
+	*g = 0
+	for _, reg := range regs {
+		for i, r := range gicbRegisters {
+			if r == reg {
+				*g |= 1 << uint(23-i)
+			}
+		}
+	}
+	return nil
+}
+
+/* Extract the 56 bit MB field (message bits 33-88) of a DF20/DF21. */
+func extractMB(msg []byte) uint64 {
+	var mb uint64
+	for i := 4; i < 11; i++ {
+		mb = (mb << 8) | uint64(msg[i])
+	}
+	return mb
+}
+
+/* Return 'n' bits of the MB field starting at bit 'start'. Bits are
+ * numbered 1 to 56 from the most significant one, as in the
+ * specification. */
+func mbBits(mb uint64, start, n int) int {
+	return int((mb >> uint(56-(start+n-1))) & ((1 << uint(n)) - 1))
+}
+
+/* Return a signed value made of a sign bit followed by 'n' bits of
+ * magnitude in two's complement. */
+func mbSigned(mb uint64, signBit, n int) int {
+	v := mbBits(mb, signBit+1, n)
+	if mbBits(mb, signBit, 1) != 0 {
+		v -= 1 << uint(n)
+	}
+	return v
+}
+
+/* A status bit set to zero requires the associated value bits to be
+ * zero as well. */
+func mbStatusOK(mb uint64, status, start, n int) bool {
+	return mbBits(mb, status, 1) != 0 || mbBits(mb, start, n) == 0
+}
+
+func isBDS10(mb uint64) bool {
+	/* First 8 bits are the register number, bits 10-14 are reserved. */
+	return mbBits(mb, 1, 8) == 0x10 && mbBits(mb, 10, 5) == 0
+}
+
+func isBDS17(mb uint64) bool {
+	/* Bits 25-56 are reserved, and every transponder able to report
+	 * capabilities supports BDS 2,0 (bit 7). */
+	return mb != 0 && mbBits(mb, 25, 32) == 0 && mbBits(mb, 7, 1) == 1
+}
+
+func isBDS20(mb uint64) bool {
+	if mbBits(mb, 1, 8) != 0x20 {
+		return false
+	}
+
+	for i := 0; i < 8; i++ {
+		if aisCharset[mbBits(mb, 9+i*6, 6)] == '?' {
+			return false
+		}
+	}
+	return true
+}
+
+func isBDS40(mb uint64) bool {
+	if mb == 0 ||
+		!mbStatusOK(mb, 1, 2, 12) ||
+		!mbStatusOK(mb, 14, 15, 12) ||
+		!mbStatusOK(mb, 27, 28, 12) ||
+		!mbStatusOK(mb, 48, 49, 3) ||
+		!mbStatusOK(mb, 54, 55, 2) {
+		return false
+	}
+
+	/* Reserved bits. */
+	if mbBits(mb, 40, 8) != 0 || mbBits(mb, 52, 2) != 0 {
+		return false
+	}
+
+	/* Selected altitudes must be plausible. */
+	if mbBits(mb, 2, 12)*16 > 50000 || mbBits(mb, 15, 12)*16 > 50000 {
+		return false
+	}
+	return true
+}
+
+func isBDS50(mb uint64) bool {
+	if mb == 0 ||
+		!mbStatusOK(mb, 1, 2, 10) ||
+		!mbStatusOK(mb, 12, 13, 11) ||
+		!mbStatusOK(mb, 24, 25, 10) ||
+		!mbStatusOK(mb, 35, 36, 10) ||
+		!mbStatusOK(mb, 46, 47, 10) {
+		return false
+	}
+
+	if math.Abs(float64(mbSigned(mb, 2, 9))*45/256) > 50 {
+		return false
+	}
+
+	gs := mbBits(mb, 25, 10) * 2
+	tas := mbBits(mb, 47, 10) * 2
+	if gs > 600 || tas > 600 {
+		return false
+	}
+	if gs != 0 && tas != 0 && math.Abs(float64(gs-tas)) > 200 {
+		return false
+	}
+	return true
+}
+
+func isBDS60(mb uint64) bool {
+	if mb == 0 ||
+		!mbStatusOK(mb, 1, 2, 11) ||
+		!mbStatusOK(mb, 13, 14, 10) ||
+		!mbStatusOK(mb, 24, 25, 10) ||
+		!mbStatusOK(mb, 35, 36, 10) ||
+		!mbStatusOK(mb, 46, 47, 10) {
+		return false
+	}
+
+	if mbBits(mb, 14, 10) > 500 ||
+		float64(mbBits(mb, 25, 10))*2.048/512 > 1 {
+		return false
+	}
+
+	if math.Abs(float64(mbSigned(mb, 36, 9)*32)) > 6000 ||
+		math.Abs(float64(mbSigned(mb, 47, 9)*32)) > 6000 {
+		return false
+	}
+	return true
+}
+
+/* Guess the register carried by the MB field. Registers with a fixed
+ * header are tested first; the header-less 4,0 5,0 and 6,0 are only
+ * accepted when exactly one of them matches. */
+func inferBDS(mb uint64) int {
+	switch {
+	case isBDS10(mb):
+		return BDS_10
+	case isBDS20(mb):
+		return BDS_20
+	case isBDS17(mb):
+		return BDS_17
+	}
+
+	candidates := 0
+	bds := BDS_UNKNOWN
+	if isBDS40(mb) {
+		candidates++
+		bds = BDS_40
+	}
+	if isBDS50(mb) {
+		candidates++
+		bds = BDS_50
+	}
+	if isBDS60(mb) {
+		candidates++
+		bds = BDS_60
+	}
+
+	if candidates != 1 {
+		return BDS_UNKNOWN
+	}
+	return bds
+}
+
+/* Convert a signed angle to the 0-360 scale. */
+func normalizeAngle(a float64) float64 {
+	if a < 0 {
+		a += 360
+	}
+	return a
+}
+
+/* Decode the MB field of a DF20/DF21 message into mm. */
+func decodeCommB(mm *ModeSMessage, msg []byte) {
+	mb := extractMB(msg)
+	mm.mb = mb
+	mm.bds = inferBDS(mb)
+	mm.ehs = EHSData{}
+
+	ehs := &mm.ehs
+
+	switch mm.bds {
+	case BDS_17:
+		ehs.GICBCapability = GICBRegisters(mbBits(mb, 1, 24))
+
+	case BDS_20:
+		for i := 0; i < 8; i++ {
+			mm.flight[i] = aisCharset[mbBits(mb, 9+i*6, 6)]
+		}
+		mm.flight[8] = 0
+
+	case BDS_40:
+		ehs.SelectedAltitudeValid = mbBits(mb, 1, 1) != 0
+		ehs.SelectedAltitude = mbBits(mb, 2, 12) * 16
+		ehs.FMSAltitudeValid = mbBits(mb, 14, 1) != 0
+		ehs.FMSAltitude = mbBits(mb, 15, 12) * 16
+		ehs.BaroSettingValid = mbBits(mb, 27, 1) != 0
+		ehs.BaroSetting = float64(mbBits(mb, 28, 12))*0.1 + 800
+
+	case BDS_50:
+		ehs.RollValid = mbBits(mb, 1, 1) != 0
+		ehs.Roll = float64(mbSigned(mb, 2, 9)) * 45 / 256
+		ehs.TrueTrackValid = mbBits(mb, 12, 1) != 0
+		ehs.TrueTrack = normalizeAngle(float64(mbSigned(mb, 13, 10)) * 90 / 512)
+		ehs.GroundSpeedValid = mbBits(mb, 24, 1) != 0
+		ehs.GroundSpeed = mbBits(mb, 25, 10) * 2
+		ehs.TrackRateValid = mbBits(mb, 35, 1) != 0
+		ehs.TrackRate = float64(mbSigned(mb, 36, 9)) * 8 / 256
+		ehs.TASValid = mbBits(mb, 46, 1) != 0
+		ehs.TAS = mbBits(mb, 47, 10) * 2
+
+	case BDS_60:
+		ehs.MagHeadingValid = mbBits(mb, 1, 1) != 0
+		ehs.MagHeading = normalizeAngle(float64(mbSigned(mb, 2, 10)) * 90 / 512)
+		ehs.IASValid = mbBits(mb, 13, 1) != 0
+		ehs.IAS = mbBits(mb, 14, 10)
+		ehs.MachValid = mbBits(mb, 24, 1) != 0
+		ehs.Mach = float64(mbBits(mb, 25, 10)) * 2.048 / 512
+		ehs.BaroVertRateValid = mbBits(mb, 35, 1) != 0
+		ehs.BaroVertRate = mbSigned(mb, 36, 9) * 32
+		ehs.InertialVertRateValid = mbBits(mb, 46, 1) != 0
+		ehs.InertialVertRate = mbSigned(mb, 47, 9) * 32
+	}
+}
+
+/* Merge the valid fields of a decoded register into the aircraft state. */
+func (e *EHSData) merge(bds int, n *EHSData) {
+	switch bds {
+	case BDS_17:
+		e.GICBCapability = n.GICBCapability
+	case BDS_40:
+		if n.SelectedAltitudeValid {
+			e.SelectedAltitudeValid, e.SelectedAltitude = true, n.SelectedAltitude
+		}
+		if n.FMSAltitudeValid {
+			e.FMSAltitudeValid, e.FMSAltitude = true, n.FMSAltitude
+		}
+		if n.BaroSettingValid {
+			e.BaroSettingValid, e.BaroSetting = true, n.BaroSetting
+		}
+	case BDS_50:
+		if n.RollValid {
+			e.RollValid, e.Roll = true, n.Roll
+		}
+		if n.TrueTrackValid {
+			e.TrueTrackValid, e.TrueTrack = true, n.TrueTrack
+		}
+		if n.GroundSpeedValid {
+			e.GroundSpeedValid, e.GroundSpeed = true, n.GroundSpeed
+		}
+		if n.TrackRateValid {
+			e.TrackRateValid, e.TrackRate = true, n.TrackRate
+		}
+		if n.TASValid {
+			e.TASValid, e.TAS = true, n.TAS
+		}
+	case BDS_60:
+		if n.MagHeadingValid {
+			e.MagHeadingValid, e.MagHeading = true, n.MagHeading
+		}
+		if n.IASValid {
+			e.IASValid, e.IAS = true, n.IAS
+		}
+		if n.MachValid {
+			e.MachValid, e.Mach = true, n.Mach
+		}
+		if n.BaroVertRateValid {
+			e.BaroVertRateValid, e.BaroVertRate = true, n.BaroVertRate
+		}
+		if n.InertialVertRateValid {
+			e.InertialVertRateValid, e.InertialVertRate = true, n.InertialVertRate
+		}
+	}
+}
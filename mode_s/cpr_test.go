@@ -0,0 +1,55 @@
+package mode_s
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDecodeCPRGlobal(t *testing.T) {
+	/* The example pair of 8D40621D58C382D690C8AC2863A7 (even) and
+	 * 8D40621D58C386435CC412692AD6 (odd), the even one received last. */
+	lat, lon, ok := DecodeCPRGlobal(93000, 51372, 74158, 50194, false)
+	if !ok {
+		t.Fatal("pair not decoded")
+	}
+	if math.Abs(lat-52.25720) > 1e-5 || math.Abs(lon-3.91937) > 1e-5 {
+		t.Errorf("position %.5f, %.5f, want 52.25720, 3.91937", lat, lon)
+	}
+}
+
+func TestDecodeCPRGlobalZoneBoundary(t *testing.T) {
+	/* The NL is 59 up to 10.47047 degrees of latitude, 58 above. */
+	evenLat, evenLon := EncodeCPR(10.46, 100.0, 0)
+	oddLat, oddLon := EncodeCPR(10.48, 100.0, 1)
+	if _, _, ok := DecodeCPRGlobal(evenLat, evenLon, oddLat, oddLon, true); ok {
+		t.Error("pair across a latitude zone boundary decoded")
+	}
+
+	/* The same distance apart within a zone. */
+	evenLat, evenLon = EncodeCPR(10.44, 100.0, 0)
+	oddLat, oddLon = EncodeCPR(10.46, 100.0, 1)
+	if _, _, ok := DecodeCPRGlobal(evenLat, evenLon, oddLat, oddLon, true); !ok {
+		t.Error("pair within a latitude zone not decoded")
+	}
+}
+
+func TestCPRPairMaxAge(t *testing.T) {
+	const addr = 0x4840D6
+	d := NewDecoder()
+	sky := NewSky(WithCPRPairMaxAge(10 * time.Millisecond))
+	for _, odd := range []int{0, 1} {
+		if odd == 1 {
+			time.Sleep(20 * time.Millisecond)
+		}
+		var mm ModeSMessage
+		d.DecodeModesMessage(&mm, EncodeAirbornePosition(addr, 52.25, 3.92, 38000, odd))
+		sky.UpdateData(&mm)
+	}
+
+	/* Without a receiver location, nor a previous position, to decode
+	 * the last message alone. */
+	if a := sky.Aircrafts()[addr]; a == nil || a.Trail.Len() != 0 {
+		t.Error("pair older than the max age decoded")
+	}
+}
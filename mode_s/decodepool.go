@@ -0,0 +1,96 @@
+package mode_s
+
+import (
+	"sync"
+)
+
+const MODES_DECODE_POOL_QUEUE = 64 /* Frames queued per worker. */
+
+/* Decode frames on several goroutines, for the sources feeding more
+ * frames than one core decodes. The handler still sees the messages in
+ * the order of the frames: the frames are dealt to the workers in turn
+ * and their messages collected in the same turn, so that the messages
+ * of an aircraft update the sky in order. */
+type DecodePool struct {
+	decoder *Decoder
+	handler func(mm *ModeSMessage)
+
+	in   []chan Frame
+	out  []chan *ModeSMessage
+	next int /* Worker of the next frame. */
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+/* Create a pool of 'workers' goroutines decoding with 'decoder' and
+ * passing every message, accepted or not (see Accept), to the handler.
+ * The handler is called from a single goroutine; the messages come from
+ * the message pool and are given back when it returns, copy them to keep
+ * them. With less than two workers the frames are decoded by Decode
+ * itself. */
+func NewDecodePool(decoder *Decoder, workers int, handler func(mm *ModeSMessage)) *DecodePool {
+	p := &DecodePool{decoder: decoder, handler: handler, done: make(chan struct{})}
+	if workers < 2 {
+		close(p.done)
+		return p
+	}
+
+	p.in = make([]chan Frame, workers)
+	p.out = make([]chan *ModeSMessage, workers)
+	for i := range p.in {
+		p.in[i] = make(chan Frame, MODES_DECODE_POOL_QUEUE)
+		p.out[i] = make(chan *ModeSMessage, MODES_DECODE_POOL_QUEUE)
+		go p.work(p.in[i], p.out[i])
+	}
+	go p.collect()
+	return p
+}
+
+/* Queue a frame, blocking while its worker is busy. Not safe to call
+ * from several goroutines: the order of the calls is the order of the
+ * messages. */
+func (p *DecodePool) Decode(f Frame) {
+	if p.in == nil {
+		mm := p.decoder.decodeFrame(f)
+		p.handler(mm)
+		ReleaseMessage(mm)
+		return
+	}
+
+	p.in[p.next] <- f
+	p.next = (p.next + 1) % len(p.in)
+}
+
+/* Stop the workers, after the queued frames are handled. */
+func (p *DecodePool) Close() {
+	p.closeOnce.Do(func() {
+		for _, in := range p.in {
+			close(in)
+		}
+	})
+	<-p.done
+}
+
+func (p *DecodePool) work(in <-chan Frame, out chan<- *ModeSMessage) {
+	defer close(out)
+
+	for f := range in {
+		out <- p.decoder.decodeFrame(f)
+	}
+}
+
+/* Pass the messages to the handler in the order the frames were dealt:
+ * the first closed worker is the one the next frame would have gone to. */
+func (p *DecodePool) collect() {
+	defer close(p.done)
+
+	for i := 0; ; i = (i + 1) % len(p.out) {
+		mm, ok := <-p.out[i]
+		if !ok {
+			return
+		}
+		p.handler(mm)
+		ReleaseMessage(mm)
+	}
+}
@@ -1,11 +1,12 @@
 package mode_s
 
 import (
+	"errors"
 	"fmt"
+	"go1090/i18n"
 	"math"
+	"sync"
 	"time"
-
-	"github.com/patrickmn/go-cache"
 )
 
 const MODES_PREAMBLE_US = 8 /* microseconds */
@@ -16,7 +17,8 @@ const MODES_LONG_MSG_BYTES = (112 / 8)
 const MODES_SHORT_MSG_BYTES = (56 / 8)
 
 const (
-	MODES_ICAO_CACHE_TTL = 60 /* Time to live of cached addresses. */
+	MODES_ICAO_CACHE_TTL     = 60   /* Time to live of cached addresses. */
+	MODES_TWO_BIT_FIX_BUDGET = 5000 /* Two bit error corrections tried per second. */
 )
 
 const (
@@ -24,6 +26,8 @@ const (
 	MODES_UNIT_METERS = 1
 )
 
+const FEET_PER_METER = 3.28084
+
 const (
 	East = 0
 	West = 1
@@ -32,9 +36,14 @@ const (
 	South = 1
 )
 
+/* A Mode S decoder. Once created by NewDecoder (or Init) it is safe to use
+ * from several goroutines: the settings are guarded by a lock and the
+ * ICAO address cache is lock-free, so several sources or the workers of
+ * a DecodePool share one decoder and its cache. */
 type Decoder struct {
 	/* Internal state */
-	icao_cache *cache.Cache /* Recently seen ICAO addresses cache. */
+	icao_cache     *icaoCache    /* Recently seen ICAO addresses cache. */
+	icao_cache_ttl time.Duration /* 0 for MODES_ICAO_CACHE_TTL. */
 
 	/* Configuration */
 	fix_errors       bool /* Single bit error correction if true. */
@@ -43,12 +52,20 @@ type Decoder struct {
 	interactive_rows int  /* Interactive mode: max number of rows. */
 	metric           int  /* Use metric units. */
 	aggressive       bool /* Aggressive detection algorithm. */
+
+	settings_mux sync.RWMutex /* fix_errors, aggressive, check_crc and metric can change while decoding. */
+
+	/* Two bit error correction budget of the current second. */
+	two_bit_second   int64
+	two_bit_attempts int
+	budget_mux       sync.Mutex
 }
 
 /* The struct we use to store information about a decoded message. */
 type ModeSMessage struct {
 	/* Generic fields */
-	msg             []byte /* Binary message. */
+	msg [MODES_LONG_MSG_BYTES]byte /* Binary message, msgbits/8 bytes used. */
+
 	msgbits         int    /* Number of bits in message */
 	msgtype         int    /* Downlink format # */
 	crcok           bool   /* True if CRC was valid */
@@ -57,12 +74,23 @@ type ModeSMessage struct {
 	aa1, aa2, aa3   uint32 /* ICAO Address bytes 1 2 and 3 */
 	phase_corrected int    /* True if phase correction was applied. */
 
+	/* Reception, see SetReceived */
+	source    string    /* Receiver id. */
+	received  time.Time /* Local reception time. */
+	timestamp uint64    /* 12 MHz MLAT counter of the source, 0 if none. */
+	signal    float64   /* Signal power, 0-1 of full scale, 0 if unknown. */
+
 	/* DF 11 */
-	ca int /* Responder capabilities. */
+	ca  int /* Responder capabilities. */
+	iid int /* Interrogator identifier, CL and IC fields (7 bits). */
+
+	/* DF 18 */
+	cf int /* Control field: TIS-B, ADS-R, ... */
 
-	/* DF 17 */
-	metype           int /* Extended squitter message type. */
-	mesub            int /* Extended squitter message subtype. */
+	/* DF 17, DF 18 */
+	me               uint64 /* 56 bit ME field. */
+	metype           int    /* Extended squitter message type. */
+	mesub            int    /* Extended squitter message subtype. */
 	heading_is_valid int
 	heading          int
 	aircraft_type    int
@@ -79,6 +107,15 @@ type ModeSMessage struct {
 	vert_rate_sign   int     /* Vertical rate sign. */
 	vert_rate        int     /* Vertical rate. */
 	velocity         int     /* Computed from EW and NS velocity. */
+	gnss_diff_sign   int     /* 1 = GNSS altitude below baro altitude. */
+	gnss_diff        int     /* GNSS-baro difference, 0 = not available. */
+	emergency_state  int     /* TC 28/1 emergency/priority status. */
+	acas_ra          ACASRA  /* TC 28/2 or DF16 resolution advisory. */
+
+	/* DF0, DF16 */
+	vs int /* Vertical status, 1 = on ground. */
+	sl int /* ACAS sensitivity level, 0 = inoperative. */
+	ri int /* Reply information: ACAS capability or maximum airspeed. */
 
 	/* DF4, DF5, DF20, DF21 */
 	fs       int /* Flight status for DF4,5,20,21 */
@@ -86,11 +123,22 @@ type ModeSMessage struct {
 	um       int /* Request extraction of downlink request. */
 	identity int /* 13 bits identity (Squawk). */
 
+	/* Mode A/C */
+	spi bool /* Special position identification. */
+
+	/* DF20, DF21 */
+	mb  uint64  /* 56 bit Comm-B message field. */
+	bds int     /* Inferred Comm-B register, BDS_UNKNOWN if not identified. */
+	ehs EHSData /* Decoded Comm-B register content. */
+
 	/* Fields used by multiple message types. */
 	altitude int
 	unit     int
 }
 
+/* Character set of the aircraft identification (DF17 and BDS 2,0). */
+var aisCharset []rune = []rune("?ABCDEFGHIJKLMNOPQRSTUVWXYZ????? ???????????????0123456789??????")
+
 /* Parity table for MODE S Messages.
  * The table contains 112 elements, every element corresponds to a bit set
  * in the message, starting from the first bit of actual data after the
@@ -109,23 +157,21 @@ type ModeSMessage struct {
  * the CRC xored with the sender address as they are reply to interrogations,
  * but a casual listener can't split the address from the checksum.
  */
-func modesChecksumTable() []uint32 {
-	return []uint32{
-		0x3935ea, 0x1c9af5, 0xf1b77e, 0x78dbbf, 0xc397db, 0x9e31e9, 0xb0e2f0, 0x587178,
-		0x2c38bc, 0x161c5e, 0x0b0e2f, 0xfa7d13, 0x82c48d, 0xbe9842, 0x5f4c21, 0xd05c14,
-		0x682e0a, 0x341705, 0xe5f186, 0x72f8c3, 0xc68665, 0x9cb936, 0x4e5c9b, 0xd8d449,
-		0x939020, 0x49c810, 0x24e408, 0x127204, 0x093902, 0x049c81, 0xfdb444, 0x7eda22,
-		0x3f6d11, 0xe04c8c, 0x702646, 0x381323, 0xe3f395, 0x8e03ce, 0x4701e7, 0xdc7af7,
-		0x91c77f, 0xb719bb, 0xa476d9, 0xadc168, 0x56e0b4, 0x2b705a, 0x15b82d, 0xf52612,
-		0x7a9309, 0xc2b380, 0x6159c0, 0x30ace0, 0x185670, 0x0c2b38, 0x06159c, 0x030ace,
-		0x018567, 0xff38b7, 0x80665f, 0xbfc92b, 0xa01e91, 0xaff54c, 0x57faa6, 0x2bfd53,
-		0xea04ad, 0x8af852, 0x457c29, 0xdd4410, 0x6ea208, 0x375104, 0x1ba882, 0x0dd441,
-		0xf91024, 0x7c8812, 0x3e4409, 0xe0d800, 0x706c00, 0x383600, 0x1c1b00, 0x0e0d80,
-		0x0706c0, 0x038360, 0x01c1b0, 0x00e0d8, 0x00706c, 0x003836, 0x001c1b, 0xfff409,
-		0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000,
-		0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000,
-		0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000,
-	}
+var modesChecksumTable = [112]uint32{
+	0x3935ea, 0x1c9af5, 0xf1b77e, 0x78dbbf, 0xc397db, 0x9e31e9, 0xb0e2f0, 0x587178,
+	0x2c38bc, 0x161c5e, 0x0b0e2f, 0xfa7d13, 0x82c48d, 0xbe9842, 0x5f4c21, 0xd05c14,
+	0x682e0a, 0x341705, 0xe5f186, 0x72f8c3, 0xc68665, 0x9cb936, 0x4e5c9b, 0xd8d449,
+	0x939020, 0x49c810, 0x24e408, 0x127204, 0x093902, 0x049c81, 0xfdb444, 0x7eda22,
+	0x3f6d11, 0xe04c8c, 0x702646, 0x381323, 0xe3f395, 0x8e03ce, 0x4701e7, 0xdc7af7,
+	0x91c77f, 0xb719bb, 0xa476d9, 0xadc168, 0x56e0b4, 0x2b705a, 0x15b82d, 0xf52612,
+	0x7a9309, 0xc2b380, 0x6159c0, 0x30ace0, 0x185670, 0x0c2b38, 0x06159c, 0x030ace,
+	0x018567, 0xff38b7, 0x80665f, 0xbfc92b, 0xa01e91, 0xaff54c, 0x57faa6, 0x2bfd53,
+	0xea04ad, 0x8af852, 0x457c29, 0xdd4410, 0x6ea208, 0x375104, 0x1ba882, 0x0dd441,
+	0xf91024, 0x7c8812, 0x3e4409, 0xe0d800, 0x706c00, 0x383600, 0x1c1b00, 0x0e0d80,
+	0x0706c0, 0x038360, 0x01c1b0, 0x00e0d8, 0x00706c, 0x003836, 0x001c1b, 0xfff409,
+	0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000,
+	0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000,
+	0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000, 0x000000,
 }
 
 func modesChecksum(msg []byte, bits int) uint32 {
@@ -144,7 +190,7 @@ func modesChecksum(msg []byte, bits int) uint32 {
 
 		/* If bit is set, xor with corresponding table entry. */
 		if (msg[s_byte] & s_bitmask) != 0 {
-			crc ^= modesChecksumTable()[j+offset]
+			crc ^= modesChecksumTable[j+offset]
 		}
 	}
 	return crc /* 24 bit checksum. */
@@ -154,7 +200,7 @@ func modesChecksum(msg []byte, bits int) uint32 {
  * in bits. */
 func modesMessageLenByType(msgType int) int {
 	switch msgType {
-	case 16, 17, 19, 20, 21:
+	case 16, 17, 18, 19, 20, 21:
 		return MODES_LONG_MSG_BITS
 	default:
 		return MODES_SHORT_MSG_BITS
@@ -167,75 +213,116 @@ func modesMessageLenByType(msgType int) int {
  * of the error bit. Otherwise if fixing failed -1 is returned. */
 func fixSingleBitErrors(msg []byte, bits int) int {
 	msgBytes := bits / 8
-	var aux []byte = make([]byte, msgBytes)
 
-	for j := 0; j < bits; j++ {
-		s_byte := j / 8
-		var bitmask byte = 1 << (7 - (j % 8))
-		var crc1, crc2 uint32
+	crc := (uint32(msg[msgBytes-3]) << 16) |
+		(uint32(msg[msgBytes-2]) << 8) |
+		uint32(msg[msgBytes-1])
+	syndrome := crc ^ modesChecksum(msg, bits)
+
+	j, ok := singleBitSyndromes[bits][syndrome]
+	if !ok {
+		return -1
+	}
 
-		copy(aux, msg)
-		aux[s_byte] ^= bitmask /* Flip j-th bit. */
+	/* The error is fixed by flipping the j-th bit. */
+	msg[j/8] ^= 1 << (7 - uint(j%8))
+	return j
+}
 
-		crc1 = (uint32(aux[msgBytes-3]) << 16) |
+/* The CRC is linear: the syndrome of a message (received CRC xor computed
+ * CRC) is the xor of the syndromes of its bit errors. A single bit error
+ * is then found with one CRC and a lookup in this table of the syndrome
+ * of every bit, for both message lengths. */
+var singleBitSyndromes = map[int]map[uint32]int{
+	MODES_SHORT_MSG_BITS: bitSyndromes(MODES_SHORT_MSG_BITS),
+	MODES_LONG_MSG_BITS:  bitSyndromes(MODES_LONG_MSG_BITS),
+}
+
+func bitSyndromes(bits int) map[uint32]int {
+	msgBytes := bits / 8
+	table := make(map[uint32]int, bits)
+	aux := make([]byte, msgBytes)
+
+	for j := 0; j < bits; j++ {
+		for i := range aux {
+			aux[i] = 0
+		}
+		aux[j/8] = 1 << (7 - uint(j%8)) /* Only the j-th bit set. */
+
+		crc := (uint32(aux[msgBytes-3]) << 16) |
 			(uint32(aux[msgBytes-2]) << 8) |
 			uint32(aux[msgBytes-1])
-		crc2 = modesChecksum(aux, bits)
-
-		if crc1 == crc2 {
-			/* The error is fixed. Overwrite the original buffer with
-			 * the corrected sequence, and returns the error bit
-			 * position. */
-			copy(msg, aux)
-			return j
-		}
+		table[crc^modesChecksum(aux, bits)] = j
 	}
-
-	return -1
+	return table
 }
 
-/* Similar to fixSingleBitErrors() but try every possible two bit combination.
- * This is very slow and should be tried only against DF17 messages that
- * don't pass the checksum, and only in Aggressive Mode. */
+/* Similar to fixSingleBitErrors() but for every possible two bit
+ * combination, looked up in a table of the syndromes of all the bit pairs
+ * of long messages. Only DF17 messages that don't pass the checksum are
+ * tried, in Aggressive Mode, within a budget of attempts per second. */
 func fixTwoBitsErrors(msg []byte, bits int) int {
 	msgBytes := bits / 8
-	var aux []byte = make([]byte, msgBytes)
+	if bits != MODES_LONG_MSG_BITS {
+		return -1
+	}
 
-	for j := 0; j < bits; j++ {
-		byte1 := j / 8
-		var bitmask1 byte = 1 << (7 - (j % 8))
-
-		/* Don't check the same pairs multiple times, so i starts from j+1 */
-		for i := j + 1; i < bits; i++ {
-			byte2 := i / 8
-			var bitmask2 byte = 1 << (7 - (i % 8))
-			var crc1, crc2 uint32
-
-			copy(aux, msg)
-
-			aux[byte1] ^= bitmask1 /* Flip j-th bit. */
-			aux[byte2] ^= bitmask2 /* Flip i-th bit. */
-
-			crc1 = (uint32(aux[msgBytes-3]) << 16) |
-				(uint32(aux[msgBytes-2]) << 8) |
-				uint32(aux[msgBytes-1])
-			crc2 = modesChecksum(aux, bits)
-
-			if crc1 == crc2 {
-				/* The error is fixed. Overwrite the original buffer with
-				 * the corrected sequence, and returns the error bit
-				 * position. */
-				copy(msg, aux)
-
-				/* We return the two bits as a 16 bit integer by shifting
-				 * 'i' on the left. This is possible since 'i' will always
-				 * be non-zero because i starts from j+1. */
-				return j | (i << 8)
+	crc := (uint32(msg[msgBytes-3]) << 16) |
+		(uint32(msg[msgBytes-2]) << 8) |
+		uint32(msg[msgBytes-1])
+	syndrome := crc ^ modesChecksum(msg, bits)
+
+	pair, ok := twoBitSyndromes[syndrome]
+	if !ok {
+		return -1
+	}
+
+	/* We return the two bits as a 16 bit integer by shifting 'i' on the
+	 * left. This is possible since 'i' will always be non-zero because i
+	 * starts from j+1. */
+	j, i := pair&0xff, pair>>8
+	msg[j/8] ^= 1 << (7 - uint(j%8))
+	msg[i/8] ^= 1 << (7 - uint(i%8))
+	return pair
+}
+
+/* Syndromes of the bit pairs of long messages. When pairs share a
+ * syndrome, the first one (lowest bits) is kept. */
+var twoBitSyndromes = func() map[uint32]int {
+	single := bitSyndromes(MODES_LONG_MSG_BITS)
+	bySingle := make([]uint32, MODES_LONG_MSG_BITS)
+	for syndrome, j := range single {
+		bySingle[j] = syndrome
+	}
+
+	table := make(map[uint32]int, MODES_LONG_MSG_BITS*(MODES_LONG_MSG_BITS-1)/2)
+	for j := 0; j < MODES_LONG_MSG_BITS; j++ {
+		for i := j + 1; i < MODES_LONG_MSG_BITS; i++ {
+			syndrome := bySingle[j] ^ bySingle[i]
+			if _, dup := table[syndrome]; !dup {
+				table[syndrome] = j | (i << 8)
 			}
 		}
 	}
-
-	return -1
+	return table
+}()
+
+/* Take one attempt of two bit error correction from the budget of the
+ * current second. */
+func (self *Decoder) twoBitBudget() bool {
+	self.budget_mux.Lock()
+	defer self.budget_mux.Unlock()
+
+	now := time.Now().Unix()
+	if now != self.two_bit_second {
+		self.two_bit_second = now
+		self.two_bit_attempts = 0
+	}
+	if self.two_bit_attempts >= MODES_TWO_BIT_FIX_BUDGET {
+		return false
+	}
+	self.two_bit_attempts++
+	return true
 }
 
 func (self *Decoder) modesInitConfig() {
@@ -245,26 +332,115 @@ func (self *Decoder) modesInitConfig() {
 	self.aggressive = false
 }
 
+/* Select the error correction: single bit errors are fixed if fix is
+ * true; with aggressive, two bit errors of DF17 and noisier frames are
+ * accepted too. Safe to call while decoding. */
+func (self *Decoder) SetErrorCorrection(fix, aggressive bool) {
+	self.settings_mux.Lock()
+	defer self.settings_mux.Unlock()
+
+	self.fix_errors = fix
+	self.aggressive = aggressive
+}
+
+/* Select whether messages with a bad CRC are dropped (the default) or
+ * still delivered, flagged by CRCOk, for statistics and debugging. The
+ * sky never uses them. Safe to call while decoding. */
+func (self *Decoder) SetCheckCRC(check bool) {
+	self.settings_mux.Lock()
+	defer self.settings_mux.Unlock()
+
+	self.check_crc = check
+}
+
+/* Return the CRC check setting, see SetCheckCRC. */
+func (self *Decoder) CheckCRC() bool {
+	self.settings_mux.RLock()
+	defer self.settings_mux.RUnlock()
+
+	return self.check_crc
+}
+
+/* Return true if the decoded message must be delivered: its CRC is
+ * good, or CRC checking is disabled. */
+func (self *Decoder) Accept(mm *ModeSMessage) bool {
+	return mm.crcok || !self.CheckCRC()
+}
+
+/* Select metric units, as WithMetricUnits. Safe to call while
+ * decoding. */
+func (self *Decoder) SetMetricUnits(enabled bool) {
+	self.settings_mux.Lock()
+	defer self.settings_mux.Unlock()
+
+	self.metric = 0
+	if enabled {
+		self.metric = 1
+	}
+}
+
+/* Return true if metric units are selected, see WithMetricUnits. */
+func (self *Decoder) MetricUnits() bool {
+	self.settings_mux.RLock()
+	defer self.settings_mux.RUnlock()
+
+	return self.metric != 0
+}
+
+/* Return the units selected with WithMetricUnits. */
+func (self *Decoder) Units() Units {
+	if self.MetricUnits() {
+		return UNITS_METRIC
+	}
+	return UNITS_AVIATION
+}
+
+/* Return the maximum number of aircraft listed, 0 = no limit, see
+ * WithInteractiveRows. */
+func (self *Decoder) InteractiveRows() int {
+	return self.interactive_rows
+}
+
+/* Return the error correction settings, see SetErrorCorrection. */
+func (self *Decoder) ErrorCorrection() (fix, aggressive bool) {
+	self.settings_mux.RLock()
+	defer self.settings_mux.RUnlock()
+
+	return self.fix_errors, self.aggressive
+}
+
+/* Initialize a zero Decoder with the default configuration. See also
+ * NewDecoder. */
 func (self *Decoder) Init() {
 	self.modesInitConfig()
+	self.initICAOCache()
+}
 
-	/* Allocate the ICAO address cache. */
-	self.icao_cache = cache.New(MODES_ICAO_CACHE_TTL*time.Second, 10*time.Second)
+/* Return the time ICAO addresses are cached, see WithICAOCacheTTL. */
+func (self *Decoder) ICAOCacheTTL() time.Duration {
+	return self.icao_cache_ttl
+}
+
+/* Allocate the ICAO address cache. */
+func (self *Decoder) initICAOCache() {
+	if self.icao_cache_ttl <= 0 {
+		self.icao_cache_ttl = MODES_ICAO_CACHE_TTL * time.Second
+	}
+	self.icao_cache = newICAOCache(self.icao_cache_ttl)
 }
 
 /* Add the specified entry to the cache of recently seen ICAO addresses.
  * Note that we also add a timestamp so that we can make sure that the
  * entry is only valid for MODES_ICAO_CACHE_TTL seconds. */
 func (self *Decoder) addRecentlySeenICAOAddr(addr uint32) {
-	self.icao_cache.SetDefault(fmt.Sprint(addr), addr)
+	self.icao_cache.add(addr)
 }
 
 /* Returns true if the specified ICAO address was seen in a DF format with
  * proper checksum (not xored with address) no more than MODES_ICAO_CACHE_TTL
  * seconds ago. Otherwise returns 0. */
 func (self *Decoder) icaoAddressWasRecentlySeen(addr uint32) bool {
-	_, found := self.icao_cache.Get(fmt.Sprint(addr))
-	return found
+	return self.icao_cache.seen(addr)
 }
 
 /* If the message type has the checksum xored with the ICAO address, try to
@@ -296,20 +472,20 @@ func (self *Decoder) bruteForceAP(msg []byte, mm *ModeSMessage) error {
 		21, /* Comm-A, identity request */
 		24: /* Comm-C ELM */
 
-		var aux []byte = make([]byte, MODES_LONG_MSG_BYTES)
+		var aux [MODES_LONG_MSG_BYTES]byte /* On the stack. */
 
 		var addr uint32
 		var crc uint32
 		lastbyte := (msgbits / 8) - 1
 
 		/* Work on a copy. */
-		copy(aux, msg)
+		copy(aux[:], msg)
 
 		/* Compute the CRC of the message and XOR it with the AP field
 		 * so that we recover the address, because:
 		 *
 		 * (ADDR xor CRC) xor CRC = ADDR. */
-		crc = modesChecksum(aux, msgbits)
+		crc = modesChecksum(aux[:], msgbits)
 		aux[lastbyte] ^= byte(crc & 0xff)
 		aux[lastbyte-1] ^= byte((crc >> 8) & 0xff)
 		aux[lastbyte-2] ^= byte((crc >> 16) & 0xff)
@@ -326,13 +502,17 @@ func (self *Decoder) bruteForceAP(msg []byte, mm *ModeSMessage) error {
 		}
 	}
 
-	return fmt.Errorf("can't recover message")
+	return errNoRecovery
 }
 
+/* Returned by bruteForceAP, allocated once as most messages with an AP
+ * field are not recovered. */
+var errNoRecovery = errors.New("can't recover message")
+
 /* Decode the 13 bit AC altitude field (in DF 20 and others).
- * Returns the altitude, and set 'unit' to either MODES_UNIT_METERS
- * or MDOES_UNIT_FEETS. */
-func decodeAC13Field(msg []byte, unit int) (altitude, newUnit int) {
+ * Returns the altitude, and its unit: either MODES_UNIT_METERS or
+ * MODES_UNIT_FEET. */
+func decodeAC13Field(msg []byte) (altitude, newUnit int) {
 	m_bit := msg[3] & (1 << 6)
 	q_bit := msg[3] & (1 << 4)
 
@@ -341,10 +521,10 @@ func decodeAC13Field(msg []byte, unit int) (altitude, newUnit int) {
 		if q_bit != 0 {
 			/* N is the 11 bit integer resulting from the removal of bit
 			 * Q and M */
-			n := ((msg[2] & 31) << 6) |
-				((msg[3] & 0x80) >> 2) |
-				((msg[3] & 0x20) >> 1) |
-				(msg[3] & 15)
+			n := ((int(msg[2]) & 31) << 6) |
+				((int(msg[3]) & 0x80) >> 2) |
+				((int(msg[3]) & 0x20) >> 1) |
+				(int(msg[3]) & 15)
 			/* The final altitude is due to the resulting number multiplied
 			 * by 25, minus 1000. */
 			altitude = int(n)*25 - 1000
@@ -354,8 +534,11 @@ func decodeAC13Field(msg []byte, unit int) (altitude, newUnit int) {
 		}
 	} else {
 		newUnit = MODES_UNIT_METERS
-		altitude = 0
-		/* TODO: Implement altitude when meter unit is selected. */
+		/* The altitude in meters is the 12 bit integer resulting from
+		 * the removal of bit M. */
+		altitude = ((int(msg[2]) & 31) << 7) |
+			((int(msg[3]) & 0x80) >> 1) |
+			(int(msg[3]) & 0x3f)
 	}
 
 	return
@@ -370,7 +553,7 @@ func decodeAC12Field(msg []byte, unit int) (altitude, newUnit int) {
 		/* N is the 11 bit integer resulting from the removal of bit
 		 * Q */
 		newUnit = MODES_UNIT_FEET
-		n := ((msg[5] >> 1) << 4) | ((msg[6] & 0xF0) >> 4)
+		n := ((int(msg[5]) >> 1) << 4) | ((int(msg[6]) & 0xF0) >> 4)
 		/* The final altitude is due to the resulting number multiplied
 		 * by 25, minus 1000. */
 		altitude = int(n)*25 - 1000
@@ -382,80 +565,141 @@ func decodeAC12Field(msg []byte, unit int) (altitude, newUnit int) {
 	return
 }
 
-/* Capability table. */
-func caStr() []string {
-	return []string{
-		/* 0 */ "Level 1 (Survillance Only)",
-		/* 1 */ "Level 2 (DF0,4,5,11)",
-		/* 2 */ "Level 3 (DF0,4,5,11,20,21)",
-		/* 3 */ "Level 4 (DF0,4,5,11,20,21,24)",
-		/* 4 */ "Level 2+3+4 (DF0,4,5,11,20,21,24,code7 - is on ground)",
-		/* 5 */ "Level 2+3+4 (DF0,4,5,11,20,21,24,code7 - is on airborne)",
-		/* 6 */ "Level 2+3+4 (DF0,4,5,11,20,21,24,code7)",
-		/* 7 */ "Level 7 ???",
-	}
+/* Decode a 13 bit identity (squawk) field.
+ *
+ * In the squawk (identity) field bits are interleaved like that:
+ *
+ * C1-A1-C2-A2-C4-A4-ZERO-B1-D1-B2-D2-B4-D4
+ *
+ * So every group of three bits A, B, C, D represent an integer
+ * from 0 to 7.
+ *
+ * The actual meaning is just 4 octal numbers, but we convert it
+ * into a base ten number tha happens to represent the four
+ * octal numbers.
+ *
+ * For more info: http://en.wikipedia.org/wiki/Gillham_code */
+func decodeID13Field(id13 int) int {
+	a := ((id13 & 0x0080) >> 5) |
+		((id13 & 0x0200) >> 8) |
+		((id13 & 0x0800) >> 11)
+	b := ((id13 & 0x0002) << 1) |
+		((id13 & 0x0008) >> 2) |
+		((id13 & 0x0020) >> 5)
+	c := ((id13 & 0x0100) >> 6) |
+		((id13 & 0x0400) >> 9) |
+		((id13 & 0x1000) >> 12)
+	d := ((id13 & 0x0001) << 2) |
+		((id13 & 0x0004) >> 1) |
+		((id13 & 0x0010) >> 4)
+	return a*1000 + b*100 + c*10 + d
+}
+
+/* Capability description (DF11, DF17). */
+func caStr(ca int) string {
+	return i18n.S(fmt.Sprintf("ca.%d", ca&7))
 }
 
-/* Flight status table. */
-func fsStr() []string {
-	return []string{
-		/* 0 */ "Normal, Airborne",
-		/* 1 */ "Normal, On the ground",
-		/* 2 */ "ALERT,  Airborne",
-		/* 3 */ "ALERT,  On the ground",
-		/* 4 */ "ALERT & Special Position Identification. Airborne or Ground",
-		/* 5 */ "Special Position Identification. Airborne or Ground",
-		/* 6 */ "Value 6 is not assigned",
-		/* 7 */ "Value 7 is not assigned",
+/* Emergency/priority status description (TC 28 subtype 1). */
+func emergencyStr(state int) string {
+	return i18n.S(fmt.Sprintf("emergency.%d", state&7))
+}
+
+/* Flight status description (DF4, DF5, DF20, DF21). */
+func FlightStatusString(fs int) string {
+	return i18n.S(fmt.Sprintf("fs.%d", fs&7))
+}
+
+/* Downlink request description (DF4, DF5, DF20, DF21): the Comm-B or
+ * ACAS message waiting to be read, or the length of a Comm-D extended
+ * length message (ELM) the transponder asks to send. */
+func DownlinkRequestString(dr int) string {
+	dr &= 31
+	switch {
+	case dr >= 16:
+		return i18n.T("dr.elm", map[string]interface{}{"Segments": dr - 15})
+	case dr >= 8:
+		return i18n.T("dr.unassigned", map[string]interface{}{"Value": dr})
 	}
+	return i18n.S(fmt.Sprintf("dr.%d", dr))
+}
+
+/* Utility message description (DF4, DF5, DF20, DF21): the interrogator
+ * (IIS) holding a multisite reservation of the transponder, and its kind
+ * (IDS). */
+func UtilityMessageString(um int) string {
+	return i18n.T("um", map[string]interface{}{
+		"IIS":         um >> 2 & 15,
+		"Reservation": i18n.S(fmt.Sprintf("um.ids.%d", um&3)),
+	})
 }
 
 func getMEDescription(metype, mesub int) string {
 	switch {
 	case metype >= 1 && metype <= 4:
-		return "Aircraft Identification and Category"
+		return i18n.S("me.identification")
 	case metype >= 5 && metype <= 8:
-		return "Surface Position"
+		return i18n.S("me.surface_position")
 	case metype >= 9 && metype <= 18:
-		return "Airborne Position (Baro Altitude)"
+		return i18n.S("me.airborne_baro")
 	case metype == 19 && mesub >= 1 && mesub <= 4:
-		return "Airborne Velocity"
+		return i18n.S("me.airborne_velocity")
 	case metype >= 20 && metype <= 22:
-		return "Airborne Position (GNSS Height)"
+		return i18n.S("me.airborne_gnss")
 	case metype == 23 && mesub == 0:
-		return "Test Message"
+		return i18n.S("me.test")
 	case metype == 24 && mesub == 1:
-		return "Surface System Status"
+		return i18n.S("me.surface_status")
 	case metype == 28 && mesub == 1:
-		return "Extended Squitter Aircraft Status (Emergency)"
+		return i18n.S("me.status_emergency")
 	case metype == 28 && mesub == 2:
-		return "Extended Squitter Aircraft Status (1090ES TCAS RA)"
+		return i18n.S("me.status_tcas_ra")
 	case metype == 29 && (mesub == 0 || mesub == 1):
-		return "Target State and Status Message"
+		return i18n.S("me.target_state")
 	case metype == 31 && (mesub == 0 || mesub == 1):
-		return "Aircraft Operational Status Message"
+		return i18n.S("me.operational")
 	}
 
-	return "Unknown"
+	return i18n.S("me.unknown")
+}
+
+/* Clear the fields of a previous decoding, the message being reused
+ * (see AcquireMessage), keeping the reception recorded before decoding
+ * (see SetReceived). */
+func (mm *ModeSMessage) reset() {
+	*mm = ModeSMessage{
+		source:    mm.source,
+		received:  mm.received,
+		timestamp: mm.timestamp,
+		signal:    mm.signal,
+	}
 }
 
 /* Decode a raw Mode S message demodulated as a stream of bytes by
  * detectModeS(), and split it into fields populating a modesMessage
- * structure. */
+ * structure. The fields of a previous decoding are cleared. */
 func (self *Decoder) DecodeModesMessage(mm *ModeSMessage, msg []byte) {
 	var crc2 uint32 /* Computed CRC, used to verify the message CRC. */
-	var ais_charset []rune = []rune("?ABCDEFGHIJKLMNOPQRSTUVWXYZ????? ???????????????0123456789??????")
+	fix_errors, aggressive := self.ErrorCorrection()
 
-	/* Work on our local copy */
-	mm.msg = make([]byte, len(msg))
-	copy(mm.msg, msg)
+	mm.reset()
 
-	msg = mm.msg
+	/* Work on our local copy, in the message itself so that decoding
+	 * doesn't allocate. */
+	msg = mm.msg[:copy(mm.msg[:], msg)]
 
 	/* Get the message type ASAP as other operations depend on this */
 	mm.msgtype = int(msg[0]) >> 3 /* Downlink Format */
 	mm.msgbits = modesMessageLenByType(mm.msgtype)
 
+	/* A short frame whose DF was corrupted into the one of a long
+	 * message: there is no CRC to read. */
+	if len(msg) < mm.msgbits/8 {
+		mm.errorbit = -1
+		mm.crcok = false
+		return
+	}
+
 	/* CRC is always the last three bytes. */
 	mm.crc = (uint32(msg[(mm.msgbits/8)-3]) << 16) |
 		(uint32(msg[(mm.msgbits/8)-2]) << 8) |
@@ -463,17 +707,33 @@ func (self *Decoder) DecodeModesMessage(mm *ModeSMessage, msg []byte) {
 	crc2 = modesChecksum(msg, mm.msgbits)
 
 	/* Check CRC and fix single bit errors using the CRC when
-	 * possible (DF 11 and 17). */
+	 * possible (DF 11, 17 and 18). */
 	mm.errorbit = -1 /* No error */
 	mm.crcok = (mm.crc == crc2)
 
-	if !mm.crcok && self.fix_errors && (mm.msgtype == 11 || mm.msgtype == 17) {
-		if mm.errorbit = fixSingleBitErrors(msg, mm.msgbits); mm.errorbit != -1 {
-			mm.crc = modesChecksum(msg, mm.msgbits)
+	/* The parity of a DF11 reply to an interrogation is overlaid with the
+	 * identifier of the interrogator in its low 7 bits (CL 0 to 4, SI code
+	 * 0 is not assigned). As noise passes
+	 * this looser check more often, it is only trusted for an address
+	 * already seen with a plain parity. */
+	if !mm.crcok && mm.msgtype == 11 {
+		syndrome := mm.crc ^ crc2
+		addr := uint32(msg[1])<<16 | uint32(msg[2])<<8 | uint32(msg[3])
+		if syndrome&^0x7f == 0 && syndrome>>4 <= 4 && syndrome != 0x10 && self.icaoAddressWasRecentlySeen(addr) {
+			mm.iid = int(syndrome)
 			mm.crcok = true
-		} else if mm.errorbit = fixTwoBitsErrors(msg, mm.msgbits); self.aggressive && (mm.msgtype == 17) && mm.errorbit != -1 {
+		}
+	}
+
+	if !mm.crcok && fix_errors && (mm.msgtype == 11 || mm.msgtype == 17 || mm.msgtype == 18) {
+		if mm.errorbit = fixSingleBitErrors(msg, mm.msgbits); mm.errorbit != -1 {
 			mm.crc = modesChecksum(msg, mm.msgbits)
 			mm.crcok = true
+		} else if aggressive && mm.msgtype == 17 && self.twoBitBudget() {
+			if mm.errorbit = fixTwoBitsErrors(msg, mm.msgbits); mm.errorbit != -1 {
+				mm.crc = modesChecksum(msg, mm.msgbits)
+				mm.crcok = true
+			}
 		}
 	}
 
@@ -481,13 +741,14 @@ func (self *Decoder) DecodeModesMessage(mm *ModeSMessage, msg []byte) {
 	 * the single bit errors, otherwise we would need to recompute the
 	 * fields again. */
 	mm.ca = int(msg[0]) & 7 /* Responder capabilities. */
+	mm.cf = int(msg[0]) & 7 /* DF 18 control field. */
 
 	/* ICAO address */
 	mm.aa1 = uint32(msg[1])
 	mm.aa2 = uint32(msg[2])
 	mm.aa3 = uint32(msg[3])
 
-	/* DF 17 type (assuming this is a DF17, otherwise not used) */
+	/* DF 17/18 type (assuming this is an extended squitter, otherwise not used) */
 	mm.metype = int(msg[4]) >> 3 /* Extended squitter message type. */
 	mm.mesub = int(msg[4]) & 7   /* Extended squitter message subtype. */
 
@@ -497,40 +758,25 @@ func (self *Decoder) DecodeModesMessage(mm *ModeSMessage, msg []byte) {
 	mm.um = ((int(msg[1]) & 7) << 3) | /* Request extraction of downlink request. */
 		int(msg[2])>>5
 
-	/* In the squawk (identity) field bits are interleaved like that
-	 * (message bit 20 to bit 32):
-	 *
-	 * C1-A1-C2-A2-C4-A4-ZERO-B1-D1-B2-D2-B4-D4
-	 *
-	 * So every group of three bits A, B, C, D represent an integer
-	 * from 0 to 7.
-	 *
-	 * The actual meaning is just 4 octal numbers, but we convert it
-	 * into a base ten number tha happens to represent the four
-	 * octal numbers.
-	 *
-	 * For more info: http://en.wikipedia.org/wiki/Gillham_code */
-	{
-		var a, b, c, d byte
-
-		a = ((msg[3] & 0x80) >> 5) |
-			((msg[2] & 0x02) >> 0) |
-			((msg[2] & 0x08) >> 3)
-		b = ((msg[3] & 0x02) << 1) |
-			((msg[3] & 0x08) >> 2) |
-			((msg[3] & 0x20) >> 5)
-		c = ((msg[2] & 0x01) << 2) |
-			((msg[2] & 0x04) >> 1) |
-			((msg[2] & 0x10) >> 4)
-		d = ((msg[3] & 0x01) << 2) |
-			((msg[3] & 0x04) >> 1) |
-			((msg[3] & 0x10) >> 4)
-		mm.identity = int(a)*1000 + int(b)*100 + int(c)*10 + int(d)
+	/* Fields for DF0,16 */
+	mm.vs = int(msg[0]) >> 2 & 1                      /* Vertical status. */
+	mm.sl = int(msg[1]) >> 5                          /* Sensitivity level. */
+	mm.ri = ((int(msg[1]) & 7) << 1) | int(msg[2])>>7 /* Reply information. */
+
+	/* Squawk (identity) field, message bit 20 to bit 32. */
+	mm.identity = decodeID13Field(((int(msg[2]) & 31) << 8) | int(msg[3]))
+
+	/* DF16 MV field holding a resolution advisory (BDS 3,0): the ARA
+	 * starts at message bit 41, as in TC 28/2. */
+	if mm.msgtype == 16 && msg[4] == ACAS_VDS_RA {
+		mm.acas_ra = decodeACASRA(msg, 40)
 	}
 
 	/* DF 11 & 17: try to populate our ICAO addresses whitelist.
-	 * DFs with an AP field (xored addr and crc), try to decode it. */
-	if mm.msgtype != 11 && mm.msgtype != 17 {
+	 * DFs with an AP field (xored addr and crc), try to decode it.
+	 * DF 18 has a plain CRC but its address is not the one of a
+	 * transponder we can interrogate, unless CF is 0. */
+	if mm.msgtype != 11 && mm.msgtype != 17 && mm.msgtype != 18 {
 		/* Check if we can check the checksum for the Downlink Formats where
 		 * the checksum is xored with the aircraft ICAO address. We try to
 		 * brute force it using a list of recently seen aircraft addresses. */
@@ -544,7 +790,7 @@ func (self *Decoder) DecodeModesMessage(mm *ModeSMessage, msg []byte) {
 		/* If this is DF 11 or DF 17 and the checksum was ok,
 		 * we can add this address to the list of recently seen
 		 * addresses. */
-		if mm.crcok && mm.errorbit == -1 {
+		if mm.crcok && mm.errorbit == -1 && mm.iid == 0 && (mm.msgtype != 18 || mm.cf == 0) {
 			var addr uint32 = (mm.aa1 << 16) | (mm.aa2 << 8) | mm.aa3
 			self.addRecentlySeenICAOAddr(addr)
 		}
@@ -553,31 +799,44 @@ func (self *Decoder) DecodeModesMessage(mm *ModeSMessage, msg []byte) {
 	/* Decode 13 bit altitude for DF0, DF4, DF16, DF20 */
 	if mm.msgtype == 0 || mm.msgtype == 4 ||
 		mm.msgtype == 16 || mm.msgtype == 20 {
-		mm.altitude, mm.unit = decodeAC13Field(msg, mm.unit)
+		mm.altitude, mm.unit = decodeAC13Field(msg)
+	}
+
+	/* Decode the Comm-B message of DF20, DF21 */
+	if mm.msgtype == 20 || mm.msgtype == 21 {
+		decodeCommB(mm, msg)
 	}
 
-	/* Decode extended squitter specific stuff. */
-	if mm.msgtype == 17 {
+	/* Decode extended squitter specific stuff. DF 18 messages we know
+	 * (see esSource) share the DF 17 format. */
+	if esSource(mm) != "" {
 		/* Decode the extended squitter message. */
+		mm.me = extractMB(msg)
 
 		if mm.metype >= 1 && mm.metype <= 4 {
 			/* Aircraft Identification and Category */
 			mm.aircraft_type = mm.metype - 1
 
-			mm.flight[0] = ais_charset[msg[5]>>2]
-			mm.flight[1] = ais_charset[((msg[5]&3)<<4)|(msg[6]>>4)]
-			mm.flight[2] = ais_charset[((msg[6]&15)<<2)|(msg[7]>>6)]
-			mm.flight[3] = ais_charset[msg[7]&63]
-			mm.flight[4] = ais_charset[msg[8]>>2]
-			mm.flight[5] = ais_charset[((msg[8]&3)<<4)|(msg[9]>>4)]
-			mm.flight[6] = ais_charset[((msg[9]&15)<<2)|(msg[10]>>6)]
-			mm.flight[7] = ais_charset[msg[10]&63]
+			mm.flight[0] = aisCharset[msg[5]>>2]
+			mm.flight[1] = aisCharset[((msg[5]&3)<<4)|(msg[6]>>4)]
+			mm.flight[2] = aisCharset[((msg[6]&15)<<2)|(msg[7]>>6)]
+			mm.flight[3] = aisCharset[msg[7]&63]
+			mm.flight[4] = aisCharset[msg[8]>>2]
+			mm.flight[5] = aisCharset[((msg[8]&3)<<4)|(msg[9]>>4)]
+			mm.flight[6] = aisCharset[((msg[9]&15)<<2)|(msg[10]>>6)]
+			mm.flight[7] = aisCharset[msg[10]&63]
 			mm.flight[8] = 0
-		} else if mm.metype >= 9 && mm.metype <= 18 {
-			/* Airborne position Message */
+		} else if (mm.metype >= 9 && mm.metype <= 18) || (mm.metype >= 20 && mm.metype <= 22) {
+			/* Airborne position Message, with the barometric altitude
+			 * (TC 9-18) or the GNSS height in meters (TC 20-22). */
 			mm.fflag = int(msg[6]) & (1 << 2)
 			mm.tflag = int(msg[6]) & (1 << 3)
-			mm.altitude, mm.unit = decodeAC12Field(msg, mm.unit)
+			if mm.metype <= 18 {
+				mm.altitude, mm.unit = decodeAC12Field(msg, mm.unit)
+			} else {
+				mm.altitude = (int(msg[5]) << 4) | (int(msg[6]) >> 4)
+				mm.unit = MODES_UNIT_METERS
+			}
 			mm.raw_latitude = ((int(msg[6]) & 3) << 15) |
 				(int(msg[7]) << 7) |
 				(int(msg[8]) >> 1)
@@ -623,8 +882,21 @@ func (self *Decoder) DecodeModesMessage(mm *ModeSMessage, msg []byte) {
 				}
 			} else if mm.mesub == 3 || mm.mesub == 4 {
 				mm.heading_is_valid = int(msg[5]) & (1 << 2)
-				mm.heading = int((360.0 / 128) * float64(((int(msg[5])&3)<<5)|(int(msg[6])>>3)))
+				mm.heading = int((360.0 / 1024) * float64(((int(msg[5])&3)<<8)|int(msg[6])))
 			}
+
+			/* Difference of the GNSS altitude from the barometric
+			 * altitude, in 25 feet steps. */
+			mm.gnss_diff_sign = (int(msg[10]) & 0x80) >> 7
+			mm.gnss_diff = int(msg[10]) & 0x7f
+		} else if mm.metype == 28 && mm.mesub == 1 {
+			/* Emergency/priority status and Mode A code */
+			mm.emergency_state = int(msg[5]) >> 5
+			mm.identity = decodeID13Field(((int(msg[5]) & 31) << 8) | int(msg[6]))
+		} else if mm.metype == 28 && mm.mesub == 2 {
+			/* ACAS resolution advisory broadcast, ARA starts at ME
+			 * bit 9 (message bit 41). */
+			mm.acas_ra = decodeACASRA(msg, 40)
 		}
 	}
 
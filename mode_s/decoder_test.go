@@ -0,0 +1,75 @@
+package mode_s
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+/* DF20 reply of 'addr' with a BDS 1,7 GICB capability report of BDS 2,0,
+ * 4,0 and 5,0: the parity is overlaid with the address. */
+func commBReply(addr uint32) []byte {
+	msg := make([]byte, MODES_LONG_MSG_BYTES)
+	msg[0] = 20 << 3
+	msg[4] = 0x02 /* MB bit 7: BDS 2,0 */
+	msg[5] = 0x81 /* MB bit 9 and 16: BDS 4,0 and 5,0 */
+	setChecksum(msg)
+	msg[11] ^= byte(addr >> 16)
+	msg[12] ^= byte(addr >> 8)
+	msg[13] ^= byte(addr)
+	return msg
+}
+
+func TestDecodeGICBCapability(t *testing.T) {
+	d := NewDecoder()
+	var mm ModeSMessage
+	d.DecodeModesMessage(&mm, commBReply(0x4840D6))
+
+	bds, ehs := mm.CommB()
+	if bds != BDS_17 {
+		t.Fatalf("BDS %02X, want 17", bds)
+	}
+	if regs := ehs.GICBCapability.Registers(); !reflect.DeepEqual(regs, []int{0x20, 0x40, 0x50}) {
+		t.Errorf("registers %X, want [20 40 50]", regs)
+	}
+}
+
+func TestDecodeModesMessageAllocs(t *testing.T) {
+	const addr = 0x4840D6
+	frames := map[string][]byte{
+		"DF11": EncodeAllCallReply(addr),
+		"DF17": EncodeAirbornePosition(addr, 52.25, 3.92, 38000, 0),
+		"DF20": commBReply(addr),
+	}
+
+	d := NewDecoder()
+	var mm ModeSMessage
+	for name, msg := range frames {
+		allocs := testing.AllocsPerRun(100, func() {
+			d.DecodeModesMessage(&mm, msg)
+		})
+		if allocs != 0 {
+			t.Errorf("%s: %.1f allocations per message, want 0", name, allocs)
+		}
+	}
+}
+
+func TestDecodeModesMessageReset(t *testing.T) {
+	const addr = 0x4840D6
+	d := NewDecoder()
+	var mm ModeSMessage
+	d.DecodeModesMessage(&mm, commBReply(addr))
+	mm.iid = 0x12 /* of a DF11 reply to an interrogation */
+	mm.SetReceived("A", time.Unix(1700000000, 0))
+
+	d.DecodeModesMessage(&mm, EncodeAllCallReply(addr))
+	if mm.iid != 0 || mm.bds != BDS_UNKNOWN || mm.ehs != (EHSData{}) {
+		t.Errorf("fields of the previous message kept: iid %d, BDS %02X", mm.iid, mm.bds)
+	}
+	if mm.source != "A" || mm.received.IsZero() {
+		t.Errorf("reception cleared")
+	}
+	if !d.icaoAddressWasRecentlySeen(addr) {
+		t.Errorf("address of the all-call reply not recorded")
+	}
+}
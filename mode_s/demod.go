@@ -0,0 +1,335 @@
+package mode_s
+
+import (
+	"io"
+	"math"
+)
+
+const MODES_DATA_LEN = (16 * 16384) /* 256k */
+
+/* I/Q -> Magnitude lookup table. It is used because sqrt or round may be
+ * expensive and may vary a lot depending on the platform.
+ *
+ * We scale to 0-255 range multiplying by 1.4 in order to ensure that
+ * every different I/Q pair will result in a different magnitude value,
+ * not losing any resolution. */
+var maglut []uint16 = func() []uint16 {
+	lut := make([]uint16, 129*129)
+	for i := 0; i <= 128; i++ {
+		for q := 0; q <= 128; q++ {
+			lut[i*129+q] = uint16(math.Round(math.Sqrt(float64(i*i+q*q)) * 360))
+		}
+	}
+	return lut
+}()
+
+/* Turn I/Q samples pointed by 'data' into the magnitude vector 'm'.
+ * 'm' must be at least len(data)/2 elements long. */
+func ComputeMagnitudeVector(data []byte, m []uint16) {
+	/* Compute the magnitudo vector. It's just SQRT(I^2 + Q^2), but
+	 * we rescale to the 0-255 range to exploit the full resolution. */
+	for j := 0; j+1 < len(data); j += 2 {
+		i := int(data[j]) - 127
+		q := int(data[j+1]) - 127
+
+		if i < 0 {
+			i = -i
+		}
+		if q < 0 {
+			q = -q
+		}
+		m[j/2] = maglut[i*129+q]
+	}
+}
+
+/* Return -1 if the message is out of fase left-side
+ * Return  1 if the message is out of fase right-size
+ * Return  0 if the message is not particularly out of phase.
+ *
+ * Note: this function will access m[j-1], so the caller should make sure to
+ * call it only if we are not at the start of the current buffer. */
+func detectOutOfPhase(m []uint16, j int) int {
+	if m[j+3] > m[j+2]/3 {
+		return 1
+	}
+	if m[j+10] > m[j+9]/3 {
+		return 1
+	}
+	if m[j+6] > m[j+7]/3 {
+		return -1
+	}
+	if m[j-1] > m[j+1]/3 {
+		return -1
+	}
+	return 0
+}
+
+/* This function does not really correct the phase of the message, it just
+ * applies a transformation to the first sample representing a given bit:
+ *
+ * If the previous bit was one, we amplify it a bit.
+ * If the previous bit was zero, we decrease it a bit.
+ *
+ * This simple transformation makes the message a bit more likely to be
+ * correctly decoded for out of phase messages. */
+func applyPhaseCorrection(m []uint16, j int) {
+	j += 16 /* Skip preamble. */
+	for k := 0; k < (MODES_LONG_MSG_BITS-1)*2; k += 2 {
+		if m[j+k] > m[j+k+1] {
+			/* One */
+			m[j+k+2] = uint16((int(m[j+k+2]) * 5) / 4)
+		} else {
+			/* Zero */
+			m[j+k+2] = uint16((int(m[j+k+2]) * 4) / 5)
+		}
+	}
+}
+
+/* Detect Mode S messages inside the magnitude buffer 'm'. Every detected
+ * Mode S message is converted into a stream of bits, decoded, and passed
+ * to the handler (unless its CRC is bad and CRC checking is enabled).
+ * The messages come from the pool (see AcquireMessage) and are given
+ * back when the handler returns: copy them to keep them. */
+func (self *Decoder) DetectModeS(m []uint16, handler func(mm *ModeSMessage)) {
+	var bits [MODES_LONG_MSG_BITS]byte
+	var msg [MODES_LONG_MSG_BITS / 2]byte
+	var aux [MODES_LONG_MSG_BITS * 2]uint16
+	use_correction := false
+	_, aggressive := self.ErrorCorrection()
+
+	/* The Mode S preamble is made of impulses of 0.5 microseconds at
+	 * the following time offsets:
+	 *
+	 * 0   - 0.5 usec: first impulse.
+	 * 1.0 - 1.5 usec: second impulse.
+	 * 3.5 - 4   usec: third impulse.
+	 * 4.5 - 5   usec: last impulse.
+	 *
+	 * Since we are sampling at 2 Mhz every sample in our magnitude vector
+	 * is 0.5 usec, so the preamble will look like this, assuming there is
+	 * an impulse at offset 0 in the array:
+	 *
+	 * 0   -----------------
+	 * 1   -
+	 * 2   ------------------
+	 * 3   --
+	 * 4   -
+	 * 5   --
+	 * 6   -
+	 * 7   ------------------
+	 * 8   --
+	 * 9   -------------------
+	 */
+	for j := 0; j < len(m)-MODES_FULL_LEN*2; j++ {
+		var low, high, delta int
+		var errors int
+		good_message := false
+
+		if !use_correction { /* Otherwise we already checked it. */
+			/* First check of relations between the first 10 samples
+			 * representing a valid preamble. We don't even investigate further
+			 * if this simple test is not passed. */
+			if !(m[j] > m[j+1] &&
+				m[j+1] < m[j+2] &&
+				m[j+2] > m[j+3] &&
+				m[j+3] < m[j] &&
+				m[j+4] < m[j] &&
+				m[j+5] < m[j] &&
+				m[j+6] < m[j] &&
+				m[j+7] > m[j+8] &&
+				m[j+8] < m[j+9] &&
+				m[j+9] > m[j+6]) {
+				continue
+			}
+
+			/* The samples between the two spikes must be < than the average
+			 * of the high spikes level. We don't test bits too near to
+			 * the high levels as signals can be out of phase so part of the
+			 * energy can be in the near samples. */
+			high = (int(m[j]) + int(m[j+2]) + int(m[j+7]) + int(m[j+9])) / 6
+			if int(m[j+4]) >= high ||
+				int(m[j+5]) >= high {
+				continue
+			}
+
+			/* Similarly samples in the range 11-14 must be low, as it is the
+			 * space between the preamble and real data. Again we don't test
+			 * bits too near to high levels, see above. */
+			if int(m[j+11]) >= high ||
+				int(m[j+12]) >= high ||
+				int(m[j+13]) >= high ||
+				int(m[j+14]) >= high {
+				continue
+			}
+		}
+
+		/* If the previous attempt with this message failed, retry using
+		 * magnitude correction. */
+		if use_correction {
+			copy(aux[:], m[j+MODES_PREAMBLE_US*2:])
+			if j > 0 && detectOutOfPhase(m, j) != 0 {
+				applyPhaseCorrection(m, j)
+			}
+		}
+
+		/* Decode all the next 112 bits, regardless of the actual message
+		 * size. We'll check the actual message type later. */
+		errors = 0
+		for i := 0; i < MODES_LONG_MSG_BITS*2; i += 2 {
+			low = int(m[j+i+MODES_PREAMBLE_US*2])
+			high = int(m[j+i+MODES_PREAMBLE_US*2+1])
+			delta = low - high
+			if delta < 0 {
+				delta = -delta
+			}
+
+			if i > 0 && delta < 256 {
+				bits[i/2] = bits[i/2-1]
+			} else if low == high {
+				/* Checking if two adiacent samples have the same magnitude
+				 * is an effective way to detect if it's just random noise
+				 * that was detected as a valid preamble. */
+				bits[i/2] = 2 /* error */
+				if i < MODES_SHORT_MSG_BITS*2 {
+					errors++
+				}
+			} else if low > high {
+				bits[i/2] = 1
+			} else {
+				/* (low < high) for exclusion  */
+				bits[i/2] = 0
+			}
+		}
+
+		/* Restore the original message if we used magnitude correction. */
+		if use_correction {
+			copy(m[j+MODES_PREAMBLE_US*2:], aux[:])
+		}
+
+		/* Pack bits into bytes */
+		for i := 0; i < MODES_LONG_MSG_BITS; i += 8 {
+			msg[i/8] = bits[i]<<7 |
+				bits[i+1]<<6 |
+				bits[i+2]<<5 |
+				bits[i+3]<<4 |
+				bits[i+4]<<3 |
+				bits[i+5]<<2 |
+				bits[i+6]<<1 |
+				bits[i+7]
+		}
+
+		msgtype := int(msg[0]) >> 3
+		msglen := modesMessageLenByType(msgtype) / 8
+
+		/* Last check, high and low bits are different enough in magnitude
+		 * to mark this as real message and not just noise? */
+		delta = 0
+		for i := 0; i < msglen*8*2; i += 2 {
+			d := int(m[j+i+MODES_PREAMBLE_US*2]) - int(m[j+i+MODES_PREAMBLE_US*2+1])
+			if d < 0 {
+				d = -d
+			}
+			delta += d
+		}
+		delta /= msglen * 4
+
+		/* Filter for an average delta of three is small enough to let almost
+		 * every kind of message to pass, but high enough to filter some
+		 * random noise. */
+		if delta < 10*255 {
+			use_correction = false
+			continue
+		}
+
+		/* If we reached this point, and error is zero, we are very likely
+		 * with a Mode S message in our hands, but it may still be broken
+		 * and CRC may not be correct. This is handled by the next layer. */
+		if errors == 0 || (aggressive && errors < 3) {
+			mm := AcquireMessage()
+
+			/* Decode the received message */
+			self.DecodeModesMessage(mm, msg[:])
+			mm.signal = signalLevel(m[j+MODES_PREAMBLE_US*2:], msglen*8)
+
+			/* Skip this message if we are sure it's fine. */
+			if mm.crcok {
+				j += (MODES_PREAMBLE_US + (msglen * 8)) * 2
+				good_message = true
+				if use_correction {
+					mm.phase_corrected = 1
+				}
+			}
+
+			/* Pass data to the next layer */
+			if self.Accept(mm) {
+				handler(mm)
+			}
+			ReleaseMessage(mm)
+		}
+
+		/* Retry with phase correction if possible. */
+		if !good_message && !use_correction {
+			j--
+			use_correction = true
+		} else {
+			use_correction = false
+		}
+	}
+}
+
+/* Mean power of the high samples of the 'bits' bits of a message starting
+ * at m[0], from 0 to 1 (full scale). */
+func signalLevel(m []uint16, bits int) float64 {
+	sum := 0.0
+	for i := 0; i < bits*2; i += 2 {
+		high := m[i]
+		if m[i+1] > high {
+			high = m[i+1]
+		}
+		level := float64(high) / 65535
+		sum += level * level
+	}
+	return sum / float64(bits)
+}
+
+/* Read 8-bit unsigned I/Q samples (as produced by rtl_sdr sampling at
+ * 2 MHz) from 'r' until EOF, demodulating every buffer and passing the
+ * decoded messages to the handler, see DetectModeS.
+ *
+ * The last (MODES_FULL_LEN-1)*4 bytes of every buffer are kept at the
+ * start of the next one, so messages crossing a buffer boundary are not
+ * lost. */
+func (self *Decoder) DecodeIQStream(r io.Reader, handler func(mm *ModeSMessage)) error {
+	const overlap = (MODES_FULL_LEN - 1) * 4
+
+	data := make([]byte, MODES_DATA_LEN+overlap)
+	magnitude := make([]uint16, (MODES_DATA_LEN+overlap)/2)
+
+	/* Fill the overlap area with "zero" samples (127 is the middle of
+	 * the unsigned range) for the very first buffer. */
+	for i := 0; i < overlap; i++ {
+		data[i] = 127
+	}
+
+	for {
+		n, err := io.ReadFull(r, data[overlap:])
+		if n > 0 {
+			/* Zero out the unfilled part of a short last read. */
+			for i := overlap + n; i < len(data); i++ {
+				data[i] = 127
+			}
+
+			ComputeMagnitudeVector(data, magnitude)
+			self.DetectModeS(magnitude, handler)
+
+			/* Keep the tail of this buffer for the next round. */
+			copy(data, data[MODES_DATA_LEN:])
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
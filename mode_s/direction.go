@@ -0,0 +1,49 @@
+package mode_s
+
+import "math"
+
+/* North references of a direction. */
+const (
+	NORTH_TRUE     = "true"
+	NORTH_MAGNETIC = "magnetic"
+)
+
+/* Messages a track or a heading is taken from. */
+const (
+	DIRECTION_ADSB_VELOCITY = "adsb_velocity" /* TC 19 subtypes 1 and 2: ground track, true. */
+	DIRECTION_ADSB_AIRSPEED = "adsb_airspeed" /* TC 19 subtypes 3 and 4: heading, see ADSBQuality.HRD. */
+	DIRECTION_COMMB_BDS50   = "commb_bds50"   /* Track and turn report: ground track, true. */
+	DIRECTION_COMMB_BDS60   = "commb_bds60"   /* Heading and speed report: heading, magnetic. */
+)
+
+/* North reference of the heading of an airspeed message: magnetic,
+ * unless the horizontal reference direction of the operational status
+ * (version 1 and 2) says true north. */
+func (q *ADSBQuality) headingReference() string {
+	if q.HRD == 0 {
+		return NORTH_TRUE
+	}
+	return NORTH_MAGNETIC
+}
+
+/* Update the heading of an aircraft. */
+func (a *Aircraft) setHeading(heading int, reference, source string) {
+	a.HeadingValid = true
+	a.Heading = heading
+	a.HeadingRef = reference
+	a.HeadingSource = source
+}
+
+/* Update the track and heading of an aircraft from a Comm-B register.
+ * The ground track of ADS-B is preferred, more frequent and precise, and
+ * the heading of BDS 6,0 is only used without ADS-B heading. */
+func (a *Aircraft) mergeCommBDirections(bds int, ehs *EHSData) {
+	switch {
+	case bds == BDS_50 && ehs.TrueTrackValid && a.TrackSource != DIRECTION_ADSB_VELOCITY:
+		a.TrackValid = true
+		a.Track = int(math.Round(ehs.TrueTrack)) % 360
+		a.TrackSource = DIRECTION_COMMB_BDS50
+	case bds == BDS_60 && ehs.MagHeadingValid && a.HeadingSource != DIRECTION_ADSB_AIRSPEED:
+		a.setHeading(int(math.Round(ehs.MagHeading))%360, NORTH_MAGNETIC, DIRECTION_COMMB_BDS60)
+	}
+}
@@ -0,0 +1,161 @@
+package mode_s
+
+import (
+	"math"
+	"strings"
+)
+
+/* Encoders for the messages we are able to decode. They are used to
+ * generate synthetic traffic for benchmarks and simulations. Every
+ * function returns a complete frame with a valid CRC. */
+
+/* Return the CRC of a message, computed over all the bits but the last 24
+ * (the checksum itself). The message length is taken from its DF. */
+func Checksum(msg []byte) uint32 {
+	return modesChecksum(msg, modesMessageLenByType(int(msg[0])>>3))
+}
+
+/* Store the checksum in the last three bytes of the message. */
+func setChecksum(msg []byte) {
+	bits := modesMessageLenByType(int(msg[0]) >> 3)
+	crc := modesChecksum(msg, bits)
+	last := bits/8 - 1
+
+	msg[last-2] = byte(crc >> 16)
+	msg[last-1] = byte(crc >> 8)
+	msg[last] = byte(crc)
+}
+
+func extendedSquitter(addr uint32) []byte {
+	msg := make([]byte, MODES_LONG_MSG_BYTES)
+	msg[0] = 17<<3 | 5 /* DF17, CA=5 (airborne) */
+	msg[1] = byte(addr >> 16)
+	msg[2] = byte(addr >> 8)
+	msg[3] = byte(addr)
+	return msg
+}
+
+/* DF11 all-call reply with interrogator code 0. */
+func EncodeAllCallReply(addr uint32) []byte {
+	msg := make([]byte, MODES_SHORT_MSG_BYTES)
+	msg[0] = 11<<3 | 5
+	msg[1] = byte(addr >> 16)
+	msg[2] = byte(addr >> 8)
+	msg[3] = byte(addr)
+	setChecksum(msg)
+	return msg
+}
+
+/* DF17 aircraft identification (TC 4, category 0). */
+func EncodeIdentification(addr uint32, flight string) []byte {
+	msg := extendedSquitter(addr)
+	msg[4] = 4 << 3
+
+	flight = strings.ToUpper(flight)
+	var chars [8]byte
+	for i := range chars {
+		chars[i] = 32 /* space */
+		if i < len(flight) {
+			if idx := strings.IndexRune(string(aisCharset), rune(flight[i])); idx > 0 {
+				chars[i] = byte(idx)
+			}
+		}
+	}
+
+	msg[5] = chars[0]<<2 | chars[1]>>4
+	msg[6] = chars[1]<<4 | chars[2]>>2
+	msg[7] = chars[2]<<6 | chars[3]
+	msg[8] = chars[4]<<2 | chars[5]>>4
+	msg[9] = chars[5]<<4 | chars[6]>>2
+	msg[10] = chars[6]<<6 | chars[7]
+	setChecksum(msg)
+	return msg
+}
+
+/* Positive modulo for floats. */
+func cprModFloat(a, b float64) float64 {
+	return a - b*math.Floor(a/b)
+}
+
+/* Compute the 17 bit CPR encoded latitude and longitude for an airborne
+ * position. odd selects the odd (1) or even (0) format. */
+func EncodeCPR(lat, lon float64, odd int) (int, int) {
+	var zone cprZone
+	return encodeCPR(&zone, lat, lon, odd)
+}
+
+/* EncodeCPR, looking up the NL of the latitude in zone first. */
+func encodeCPR(zone *cprZone, lat, lon float64, odd int) (int, int) {
+	const nb = 131072.0 /* 2^17 */
+
+	dlat := 360.0 / float64(60-odd)
+	yz := math.Floor(nb*cprModFloat(lat, dlat)/dlat + 0.5)
+	rlat := dlat * (yz/nb + math.Floor(lat/dlat))
+
+	dlon := cprDlonFunction(zone.NL(rlat), odd)
+	xz := math.Floor(nb*cprModFloat(lon, dlon)/dlon + 0.5)
+
+	return int(yz) & 0x1FFFF, int(xz) & 0x1FFFF
+}
+
+/* DF17 airborne position with barometric altitude (TC 11). */
+func EncodeAirbornePosition(addr uint32, lat, lon float64, altitude int, odd int) []byte {
+	msg := extendedSquitter(addr)
+	msg[4] = 11 << 3
+
+	/* 25 ft encoding, Q bit set. */
+	n := (altitude + 1000) / 25
+	if n < 0 {
+		n = 0
+	}
+	msg[5] = byte((n>>4)&0x7f)<<1 | 1
+	msg[6] = byte(n&0xf) << 4
+
+	rawLat, rawLon := EncodeCPR(lat, lon, odd)
+	if odd != 0 {
+		msg[6] |= 1 << 2
+	}
+	msg[6] |= byte(rawLat >> 15)
+	msg[7] = byte(rawLat >> 7)
+	msg[8] = byte(rawLat<<1) | byte(rawLon>>16)
+	msg[9] = byte(rawLon >> 8)
+	msg[10] = byte(rawLon)
+	setChecksum(msg)
+	return msg
+}
+
+/* DF17 airborne velocity over ground (TC 19, subtype 1). Speed is in
+ * knots, track in degrees and vertical rate in feet per minute. */
+func EncodeVelocity(addr uint32, speed, track float64, vertRate int) []byte {
+	msg := extendedSquitter(addr)
+	msg[4] = 19<<3 | 1
+
+	rad := track * math.Pi / 180
+	ew := int(math.Round(speed * math.Sin(rad)))
+	ns := int(math.Round(speed * math.Cos(rad)))
+
+	var ewDir, nsDir, vrSign int
+	if ew < 0 {
+		ewDir, ew = West, -ew
+	}
+	if ns < 0 {
+		nsDir, ns = South, -ns
+	}
+	if vertRate < 0 {
+		vrSign, vertRate = 1, -vertRate
+	}
+
+	/* Velocities and vertical rate are transmitted as value+1, 0 meaning
+	 * "not available". */
+	ew++
+	ns++
+	vr := vertRate/64 + 1
+
+	msg[5] = byte(ewDir<<2) | byte(ew>>8)&3
+	msg[6] = byte(ew)
+	msg[7] = byte(nsDir<<7) | byte(ns>>3)&0x7f
+	msg[8] = byte(ns&7)<<5 | 1<<4 | byte(vrSign<<3) | byte(vr>>6)&7 /* baro source */
+	msg[9] = byte(vr&0x3f) << 2
+	setChecksum(msg)
+	return msg
+}
@@ -0,0 +1,73 @@
+package mode_s
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+/* An Enricher attaches extra information (external links, database
+ * records, ...) to an aircraft. Enrichers are called once, in the order
+ * they were added, when an aircraft is first seen. */
+type Enricher func(ac *Aircraft)
+
+/* Default external link templates. The templates are executed with the
+ * *Aircraft as data, with the functions lower, upper, path (escaping a
+ * path segment) and query (escaping a query value). The registration link
+ * is only made for a known registration: the enrichers filling it (see
+ * CountryEnricher) must be added before. */
+var DefaultLinkTemplates = map[string]string{
+	"photo":        "https://www.planespotters.net/hex/{{.HexAddr}}",
+	"profile":      "https://opensky-network.org/aircraft-profile?icao24={{lower .HexAddr}}",
+	"registration": "{{with .Registration}}https://www.jetphotos.com/registration/{{path .}}{{end}}",
+}
+
+/* Return an Enricher that fills Aircraft.Links with the given templates.
+ * Links whose template produces an empty string are omitted. */
+func NewLinkEnricher(templates map[string]string) (Enricher, error) {
+	funcs := template.FuncMap{
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+		"path":  url.PathEscape,
+		"query": url.QueryEscape,
+	}
+
+	tmpls := make(map[string]*template.Template, len(templates))
+	for name, text := range templates {
+		t, err := template.New(name).Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("link %s: %s", name, err.Error())
+		}
+		tmpls[name] = t
+	}
+
+	return func(ac *Aircraft) {
+		for name, t := range tmpls {
+			var buf bytes.Buffer
+			if err := t.Execute(&buf, ac); err != nil || buf.Len() == 0 {
+				continue
+			}
+
+			if ac.Links == nil {
+				ac.Links = make(map[string]string)
+			}
+			ac.Links[name] = buf.String()
+		}
+	}, nil
+}
+
+/* Add an enricher called for every newly seen aircraft. */
+func (sky *Sky) AddEnricher(e Enricher) {
+	sky.mux.Lock()
+	defer sky.mux.Unlock()
+
+	sky.enrichers = append(sky.enrichers, e)
+}
+
+func (sky *Sky) enrich(ac *Aircraft) {
+	for _, e := range sky.enrichers {
+		e(ac)
+	}
+}
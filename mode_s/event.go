@@ -0,0 +1,63 @@
+package mode_s
+
+import "time"
+
+/* Types of events. */
+const (
+	EVENT_ANOMALY          = "anomaly"          /* Event.Anomaly is set. */
+	EVENT_EMERGENCY_SQUAWK = "emergency_squawk" /* Event.Squawk is 7500, 7600 or 7700. */
+	EVENT_WATCHED          = "watched"          /* A watched aircraft appeared, see SetWatchList. */
+	EVENT_CALLSIGN_CHANGED = "callsign_changed" /* Event.PreviousFlight is set. */
+	EVENT_ACAS_RA          = "acas_ra"          /* A new resolution advisory, in Aircraft.LastRA. */
+	EVENT_ZONE_ENTER       = "zone_enter"       /* Event.Zone is the zone entered, see SetZones. */
+	EVENT_ZONE_EXIT        = "zone_exit"        /* Event.Zone is the zone left, also when the aircraft is lost. */
+)
+
+/* Something noteworthy happened to an aircraft. */
+type Event struct {
+	Type     string
+	Time     time.Time
+	Aircraft *Aircraft /* Copy of the aircraft. */
+	Anomaly  *Anomaly
+	Squawk   int
+
+	PreviousFlight string /* Callsign before the change. */
+	Zone           string /* Zone entered or left. */
+}
+
+/* An EventHandler is called for every event, in the order the handlers
+ * were added. Handlers are called without the Sky lock held. */
+type EventHandler func(e Event)
+
+/* Add a handler called for every event. */
+func (sky *Sky) AddEventHandler(h EventHandler) {
+	sky.mux.Lock()
+	defer sky.mux.Unlock()
+
+	sky.event_handlers = append(sky.event_handlers, h)
+}
+
+/* Queue an event. Must be called with the lock held; the event is
+ * dispatched by dispatchEvents. */
+func (sky *Sky) emit(e Event) {
+	if len(sky.event_handlers) == 0 {
+		return
+	}
+	sky.pending_events = append(sky.pending_events, e)
+}
+
+/* Call the handlers with the queued events. Must be called without the
+ * lock held. */
+func (sky *Sky) dispatchEvents() {
+	sky.mux.Lock()
+	events := sky.pending_events
+	handlers := sky.event_handlers
+	sky.pending_events = nil
+	sky.mux.Unlock()
+
+	for _, e := range events {
+		for _, h := range handlers {
+			h(e)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package mode_s
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/* A latitude/longitude box. MinLon > MaxLon for boxes crossing the
+ * antimeridian. */
+type GeoBox struct {
+	MinLat, MinLon float64
+	MaxLat, MaxLon float64
+}
+
+/* Parse a box given by two opposite corners, "lat1,lon1,lat2,lon2". The
+ * box crosses the antimeridian when lon1 is east of lon2. */
+func ParseGeoBox(s string) (GeoBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return GeoBox{}, fmt.Errorf("filter error: invalid box %q (lat1,lon1,lat2,lon2)", s)
+	}
+
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return GeoBox{}, fmt.Errorf("filter error: invalid box %q (lat1,lon1,lat2,lon2)", s)
+		}
+		v[i] = f
+	}
+	if math.Abs(v[0]) > 90 || math.Abs(v[2]) > 90 || math.Abs(v[1]) > 180 || math.Abs(v[3]) > 180 {
+		return GeoBox{}, fmt.Errorf("filter error: box %q out of range", s)
+	}
+
+	return GeoBox{
+		MinLat: math.Min(v[0], v[2]), MaxLat: math.Max(v[0], v[2]),
+		MinLon: v[1], MaxLon: v[3],
+	}, nil
+}
+
+/* Parse a list of boxes separated by ';', "" giving none. */
+func ParseGeoBoxes(s string) ([]GeoBox, error) {
+	var boxes []GeoBox
+	for _, item := range strings.Split(s, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		b, err := ParseGeoBox(item)
+		if err != nil {
+			return nil, err
+		}
+		boxes = append(boxes, b)
+	}
+	return boxes, nil
+}
+
+func (b GeoBox) Contains(lat, lon float64) bool {
+	if lat < b.MinLat || lat > b.MaxLat {
+		return false
+	}
+	if b.MinLon <= b.MaxLon {
+		return lon >= b.MinLon && lon <= b.MaxLon
+	}
+	return lon >= b.MinLon || lon <= b.MaxLon
+}
+
+/* An altitude band in feet, both limits included. */
+type AltitudeBand struct {
+	Min, Max int
+}
+
+/* Parse a band "min:max" in feet, one of the limits may be left out,
+ * e.g. ":10000" for below 10000 ft. */
+func ParseAltitudeBand(s string) (AltitudeBand, error) {
+	lo, hi, ok := strings.Cut(s, ":")
+	if !ok || (strings.TrimSpace(lo) == "" && strings.TrimSpace(hi) == "") {
+		return AltitudeBand{}, fmt.Errorf("filter error: invalid altitude band %q (min:max)", s)
+	}
+
+	band := AltitudeBand{Min: math.MinInt32, Max: math.MaxInt32}
+	for _, limit := range []struct {
+		s string
+		v *int
+	}{{lo, &band.Min}, {hi, &band.Max}} {
+		if limit.s = strings.TrimSpace(limit.s); limit.s == "" {
+			continue
+		}
+		v, err := strconv.Atoi(limit.s)
+		if err != nil {
+			return AltitudeBand{}, fmt.Errorf("filter error: invalid altitude band %q (min:max)", s)
+		}
+		*limit.v = v
+	}
+	if band.Min > band.Max {
+		return AltitudeBand{}, fmt.Errorf("filter error: empty altitude band %q", s)
+	}
+	return band, nil
+}
+
+func (b AltitudeBand) Contains(altitude int) bool {
+	return altitude >= b.Min && altitude <= b.Max
+}
+
+/* Traffic of interest, see Sky.SetFilter. The aircraft outside are still
+ * tracked, so that they appear as soon as they enter, but are left out of
+ * Aircrafts, ForEach and AircraftCount and not returned by UpdateData.
+ * An aircraft whose position (or altitude) is not known yet is left out
+ * when the filter has a criterion on it. The zero value passes every
+ * aircraft. */
+type TrafficFilter struct {
+	Include    []GeoBox      /* Positions in one of the boxes, none = anywhere. */
+	Exclude    []GeoBox      /* Positions in none of the boxes. */
+	MaxRangeKm float64       /* Distance from the receiver, 0 = any. */
+	Altitude   *AltitudeBand /* nil = any altitude. */
+}
+
+/* Return true if the filter passes every aircraft. */
+func (f TrafficFilter) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0 && f.MaxRangeKm <= 0 && f.Altitude == nil
+}
+
+/* Return true if the aircraft is of interest. */
+func (f TrafficFilter) Match(a *Aircraft) bool {
+	if f.Altitude != nil && (a.altitude_time.IsZero() || !f.Altitude.Contains(a.Altitude)) {
+		return false
+	}
+
+	if len(f.Include) == 0 && len(f.Exclude) == 0 && f.MaxRangeKm <= 0 {
+		return true
+	}
+	if a.Trail.Len() == 0 {
+		return false
+	}
+
+	if f.MaxRangeKm > 0 && (!a.Ranged || a.DistanceKm > f.MaxRangeKm) {
+		return false
+	}
+	for _, b := range f.Exclude {
+		if b.Contains(a.Latitude, a.Longitude) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, b := range f.Include {
+		if b.Contains(a.Latitude, a.Longitude) {
+			return true
+		}
+	}
+	return false
+}
+
+/* Only show the traffic of interest. The aircraft already tracked are
+ * checked again. */
+func (sky *Sky) SetFilter(f TrafficFilter) {
+	sky.mux.Lock()
+	defer sky.mux.Unlock()
+
+	sky.filter = f
+	for _, a := range sky.aircrafts {
+		sky.applyFilter(a)
+	}
+}
+
+/* Return the traffic filter, see SetFilter. */
+func (sky *Sky) Filter() TrafficFilter {
+	sky.mux.RLock()
+	defer sky.mux.RUnlock()
+
+	return sky.filter
+}
+
+/* Check the aircraft against the filter and the ignore list. Must be
+ * called with the lock held. */
+func (sky *Sky) applyFilter(a *Aircraft) {
+	a.filtered = sky.ignore.Match(a) || (!sky.filter.IsEmpty() && !sky.filter.Match(a))
+}
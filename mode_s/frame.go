@@ -0,0 +1,14 @@
+package mode_s
+
+import "time"
+
+/* A frame as received from a source, with its reception metadata: the
+ * input of Decoder.Stream and DecodePool. The sources of the rtl_adsb
+ * package produce them. */
+type Frame struct {
+	Msg       [MODES_LONG_MSG_BYTES]byte
+	Received  time.Time /* Local reception time. */
+	Timestamp uint64    /* 12 MHz MLAT counter, 0 if the source has none. */
+	Source    string    /* Name of the source, set by the caller. */
+	ModeAC    bool      /* Mode A/C reply, in the first 2 bytes of Msg. */
+}
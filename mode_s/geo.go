@@ -0,0 +1,56 @@
+package mode_s
+
+import "math"
+
+const EARTH_RADIUS_KM = 6371.0
+
+const KM_PER_NM = 1.852
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radToDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+/* Initial great circle bearing from the first position to the second, in
+ * degrees (0-360, true north). */
+func initialBearing(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := degToRad(lat1), degToRad(lat2)
+	dlon := degToRad(lon2 - lon1)
+
+	y := math.Sin(dlon) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dlon)
+
+	brg := radToDeg(math.Atan2(y, x))
+	if brg < 0 {
+		brg += 360
+	}
+	return brg
+}
+
+/* Great circle (haversine) distance between two positions, in km. */
+func greatCircleKm(lat1, lon1, lat2, lon2 float64) float64 {
+	dlat := degToRad(lat2 - lat1)
+	dlon := degToRad(lon2 - lon1)
+
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) +
+		math.Cos(degToRad(lat1))*math.Cos(degToRad(lat2))*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * EARTH_RADIUS_KM * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+/* Position reached from a position following a great circle at an
+ * initial bearing (degrees, true north) for a distance in km. */
+func destination(lat, lon, bearing, km float64) (float64, float64) {
+	phi1, lambda1 := degToRad(lat), degToRad(lon)
+	theta := degToRad(bearing)
+	delta := km / EARTH_RADIUS_KM
+
+	phi2 := math.Asin(math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi1),
+		math.Cos(delta)-math.Sin(phi1)*math.Sin(phi2))
+
+	lon2 := math.Mod(radToDeg(lambda2)+540, 360) - 180
+	return radToDeg(phi2), lon2
+}
@@ -0,0 +1,54 @@
+package mode_s
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const MODES_ICAO_CACHE_LEN = 1024 /* Power of two, see icaoCacheHash. */
+
+/* Recently seen ICAO addresses, as in dump1090: a fixed size table
+ * indexed by a hash of the address, where a new address overwrites the
+ * one of the same slot. A lost address is only a message whose parity
+ * can't be checked until the aircraft sends a DF11 or DF17 again.
+ *
+ * Every slot packs the 24 bit address and the time it was seen, in
+ * milliseconds since the creation of the cache plus one (0 = empty), so
+ * that it is read and written atomically without a lock. */
+type icaoCache struct {
+	slots [MODES_ICAO_CACHE_LEN]atomic.Uint64
+	epoch time.Time
+	ttl   time.Duration
+}
+
+func newICAOCache(ttl time.Duration) *icaoCache {
+	return &icaoCache{epoch: time.Now(), ttl: ttl}
+}
+
+/* Slot of an address. The three rounds make sure that every bit of the
+ * address affects every bit of the hash with ~50% probability. */
+func icaoCacheHash(addr uint32) uint32 {
+	addr = ((addr >> 16) ^ addr) * 0x45d9f3b
+	addr = ((addr >> 16) ^ addr) * 0x45d9f3b
+	addr = (addr >> 16) ^ addr
+	return addr & (MODES_ICAO_CACHE_LEN - 1)
+}
+
+/* Milliseconds since the creation of the cache, plus one. */
+func (c *icaoCache) now() uint64 {
+	return uint64(time.Since(c.epoch)/time.Millisecond) + 1
+}
+
+func (c *icaoCache) add(addr uint32) {
+	addr &= 0xffffff
+	c.slots[icaoCacheHash(addr)].Store(c.now()<<24 | uint64(addr))
+}
+
+func (c *icaoCache) seen(addr uint32) bool {
+	addr &= 0xffffff
+	slot := c.slots[icaoCacheHash(addr)].Load()
+	if slot == 0 || uint32(slot&0xffffff) != addr {
+		return false
+	}
+	return time.Duration(c.now()-(slot>>24))*time.Millisecond <= c.ttl
+}
@@ -0,0 +1,336 @@
+package mode_s
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+/* JSON of aircraft and messages, shared by the outputs that don't need
+ * a format of their own. The schema is stable: fields are only added.
+ *
+ *   - ICAO addresses are 6 lowercase hex digits ("4840d6"), squawks 4
+ *     octal digits ("7700"), raw frames uppercase hex.
+ *   - Times are ISO 8601 (RFC 3339) in UTC, with the fraction of second.
+ *   - Altitudes are in feet, speeds in knots, vertical rates in ft/min
+ *     (or in the units given to Aircraft.JSONIn, stated by units), angles
+ *     in degrees, distances in km, signal levels in dBFS.
+ *   - Unknown or not applicable fields are omitted: a field is present
+ *     only if it was received (or derived from received fields). */
+
+/* Aircraft: the last known state.
+ *
+ *   hex            ICAO address
+ *   flight         callsign, without padding
+ *   category       ADS-B emitter category, "A1" to "D7"
+ *   squawk         Mode A code, with squawk_meaning for the special
+ *                  purpose codes
+ *   registration, type, operator
+ *                  from the aircraft database (type is the ICAO designator)
+ *   country        ISO 3166-1 alpha-2 code of the registry country
+ *   alt_baro       barometric altitude
+ *   alt_geom       geometric (GNSS) altitude
+ *   gs, track      ground speed and track (true)
+ *   heading        heading, in the north of heading_ref ("true" or "magnetic")
+ *   vert_rate      vertical rate, negative when descending
+ *   lat, lon       position, with its time (position_time) and NIC (nic)
+ *   distance, bearing
+ *                  from the receiver, once its location is known
+ *   on_ground      last air/ground state received
+ *   flight_status  FS of the last DF4, 5, 20 or 21 reply
+ *   acas           ACAS fields of the last DF0 or DF16 reply
+ *   last_ra        last ACAS resolution advisory
+ *   emergency      emergency squawk or status, with emergency_state
+ *   suspect        an anomaly was detected (see Aircraft.Anomalies)
+ *   watched        on the watch list
+ *   rssi           signal level of the last messages
+ *   zones          names of the zones the aircraft is in
+ *   links          external URLs by name, see NewLinkEnricher
+ *   messages       messages received
+ *   first_seen, seen
+ *                  times of the first and the last message
+ *   units          "metric" if not in aviation units
+ *
+ * Exported for the outputs adding fields of their own. */
+type AircraftJSON struct {
+	Hex            string            `json:"hex"`
+	Flight         string            `json:"flight,omitempty"`
+	Category       string            `json:"category,omitempty"`
+	Squawk         string            `json:"squawk,omitempty"`
+	SquawkMeaning  string            `json:"squawk_meaning,omitempty"`
+	Registration   string            `json:"registration,omitempty"`
+	TypeCode       string            `json:"type,omitempty"`
+	Operator       string            `json:"operator,omitempty"`
+	Country        string            `json:"country,omitempty"`
+	Altitude       *int              `json:"alt_baro,omitempty"`
+	AltitudeGeom   *int              `json:"alt_geom,omitempty"`
+	Speed          *int              `json:"gs,omitempty"`
+	Track          *int              `json:"track,omitempty"`
+	Heading        *int              `json:"heading,omitempty"`
+	HeadingRef     string            `json:"heading_ref,omitempty"`
+	VertRate       *int              `json:"vert_rate,omitempty"`
+	Latitude       *float64          `json:"lat,omitempty"`
+	Longitude      *float64          `json:"lon,omitempty"`
+	PositionTime   *time.Time        `json:"position_time,omitempty"`
+	NIC            *int              `json:"nic,omitempty"`
+	Distance       *float64          `json:"distance,omitempty"`
+	Bearing        *float64          `json:"bearing,omitempty"`
+	OnGround       bool              `json:"on_ground"`
+	FlightStatus   *int              `json:"flight_status,omitempty"`
+	ACAS           *ACASStatus       `json:"acas,omitempty"`
+	LastRA         *raJSON           `json:"last_ra,omitempty"`
+	Emergency      bool              `json:"emergency,omitempty"`
+	EmergencyState string            `json:"emergency_state,omitempty"`
+	Suspect        bool              `json:"suspect,omitempty"`
+	Watched        bool              `json:"watched,omitempty"`
+	RSSI           *float64          `json:"rssi,omitempty"`
+	Zones          []string          `json:"zones,omitempty"`
+	Links          map[string]string `json:"links,omitempty"`
+	Messages       int64             `json:"messages"`
+	FirstSeen      time.Time         `json:"first_seen"`
+	Seen           time.Time         `json:"seen"`
+	Units          string            `json:"units,omitempty"`
+}
+
+/* ACAS resolution advisory: the ACASRA fields, with the address of the
+ * threat (threat_hex) only if given and the time only if known. */
+type raJSON struct {
+	ARA             int        `json:"ara"`
+	RAC             int        `json:"rac"`
+	Terminated      bool       `json:"terminated"`
+	MultipleThreats bool       `json:"multiple_threats"`
+	ThreatType      int        `json:"threat_type"`
+	ThreatHex       string     `json:"threat_hex,omitempty"`
+	Time            *time.Time `json:"time,omitempty"`
+}
+
+func newRAJSON(ra ACASRA) *raJSON {
+	j := &raJSON{
+		ARA:             ra.ARA,
+		RAC:             ra.RAC,
+		Terminated:      ra.Terminated,
+		MultipleThreats: ra.MultipleThreats,
+		ThreatType:      ra.ThreatType,
+	}
+	if ra.ThreatType == 1 {
+		j.ThreatHex = fmt.Sprintf("%06x", ra.ThreatAddr)
+	}
+	j.Time = jsonTime(ra.Time)
+	return j
+}
+
+/* The time in UTC, nil if zero. */
+func jsonTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func (a *Aircraft) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.JSONIn(UNITS_AVIATION))
+}
+
+/* The aircraft in the schema above, its altitudes, speed and vertical rate
+ * in units. The values are copied: a may change afterwards. */
+func (a *Aircraft) JSONIn(units Units) AircraftJSON {
+	j := AircraftJSON{
+		Hex:          fmt.Sprintf("%06x", a.Addr),
+		Flight:       strings.TrimRight(a.Flight, " \x00"),
+		Category:     a.Category,
+		Registration: a.Registration,
+		TypeCode:     a.TypeCode,
+		Operator:     a.Operator,
+		Country:      a.CountryCode,
+		OnGround:     a.OnGround,
+		Emergency:    a.Emergency,
+		Suspect:      a.Suspect,
+		Watched:      a.Watched,
+		Zones:        a.Zones,
+		Links:        a.Links,
+		Messages:     a.Messages,
+		FirstSeen:    a.FirstSeen.UTC(),
+		Seen:         a.Seen.UTC(),
+	}
+	if a.Squawk != 0 {
+		j.Squawk, j.SquawkMeaning = fmt.Sprintf("%04d", a.Squawk), a.SquawkMeaning
+	}
+	if a.Altitude != 0 {
+		j.Altitude = jsonInt(a.AltitudeIn(units), true)
+	}
+	if a.AltitudeGeomValid {
+		j.AltitudeGeom = jsonInt(a.AltitudeGeomIn(units), true)
+	}
+	if a.Speed != 0 {
+		j.Speed = jsonInt(a.SpeedIn(units), true)
+	}
+	if a.TrackValid {
+		j.Track = jsonInt(a.Track, true)
+	}
+	if a.HeadingValid {
+		j.Heading, j.HeadingRef = jsonInt(a.Heading, true), a.HeadingRef
+	}
+	if a.VertRateValid {
+		j.VertRate = jsonInt(a.VertRateIn(units), true)
+	}
+	if a.Latitude != 0 || a.Longitude != 0 {
+		lat, lon := a.Latitude, a.Longitude
+		j.Latitude, j.Longitude = &lat, &lon
+		if p, ok := a.Trail.Last(); ok {
+			j.PositionTime = jsonTime(p.Time)
+			if p.NIC >= 0 {
+				j.NIC = &p.NIC
+			}
+		}
+	}
+	if a.Ranged {
+		dist, brg := a.DistanceKm, a.Bearing
+		j.Distance, j.Bearing = &dist, &brg
+	}
+	if a.FlightStatusValid {
+		j.FlightStatus = jsonInt(a.FlightStatus, true)
+	}
+	if a.ACASValid {
+		acas := a.ACAS
+		j.ACAS = &acas
+	}
+	if !a.LastRA.Time.IsZero() {
+		j.LastRA = newRAJSON(a.LastRA)
+	}
+	if a.EmergencyState != 0 {
+		j.EmergencyState = a.EmergencyStateDesc
+	}
+	if a.RSSIValid {
+		rssi := math.Round(100*a.RSSI) / 100
+		j.RSSI = &rssi
+	}
+	if units != UNITS_AVIATION {
+		j.Units = units.String()
+	}
+	return j
+}
+
+/* Message: the frame and its decoded fields.
+ *
+ *   hex            ICAO address (or, for DF11, the one recovered)
+ *   df             downlink format
+ *   raw            frame
+ *   crc_ok         the parity checked, with corrected_bits fixed
+ *   received       local reception time, with source the receiver id
+ *   rssi           signal level
+ *   ca, interrogator
+ *                  capability; II or SI code of a DF11 reply ("II3", "SI12")
+ *   fs, dr, um     flight status, downlink request and utility message of
+ *                  DF4, 5, 20 and 21 replies
+ *   acas           ACAS fields of DF0 and DF16 replies
+ *   type_code, subtype
+ *                  ME type and subtype of extended squitters
+ *   flight, category, squawk, alt_baro, alt_geom, gs, track, heading,
+ *   vert_rate      as for aircraft
+ *   gnss_baro_diff GNSS minus barometric altitude
+ *   cpr            raw CPR position: lat, lon and odd
+ *   acas_ra        ACAS resolution advisory */
+type messageJSON struct {
+	Hex          string      `json:"hex"`
+	DF           int         `json:"df"`
+	Raw          string      `json:"raw"`
+	CRCOk        bool        `json:"crc_ok"`
+	Corrected    int         `json:"corrected_bits,omitempty"`
+	Received     *time.Time  `json:"received,omitempty"`
+	Source       string      `json:"source,omitempty"`
+	RSSI         *float64    `json:"rssi,omitempty"`
+	CA           *int        `json:"ca,omitempty"`
+	Interrogator string      `json:"interrogator,omitempty"`
+	FS           *int        `json:"fs,omitempty"`
+	DR           *int        `json:"dr,omitempty"`
+	UM           *int        `json:"um,omitempty"`
+	ACAS         *ACASStatus `json:"acas,omitempty"`
+	TypeCode     *int        `json:"type_code,omitempty"`
+	Subtype      *int        `json:"subtype,omitempty"`
+	Flight       string      `json:"flight,omitempty"`
+	Category     string      `json:"category,omitempty"`
+	Squawk       string      `json:"squawk,omitempty"`
+	Altitude     *int        `json:"alt_baro,omitempty"`
+	AltitudeGeom *int        `json:"alt_geom,omitempty"`
+	Speed        *int        `json:"gs,omitempty"`
+	Track        *int        `json:"track,omitempty"`
+	Heading      *int        `json:"heading,omitempty"`
+	VertRate     *int        `json:"vert_rate,omitempty"`
+	GNSSBaroDiff *int        `json:"gnss_baro_diff,omitempty"`
+	CPR          *cprJSON    `json:"cpr,omitempty"`
+	ACASRA       *raJSON     `json:"acas_ra,omitempty"`
+}
+
+type cprJSON struct {
+	Lat int  `json:"lat"`
+	Lon int  `json:"lon"`
+	Odd bool `json:"odd"`
+}
+
+/* Pointer to v if ok, nil otherwise. */
+func jsonInt(v int, ok bool) *int {
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+func (mm *ModeSMessage) MarshalJSON() ([]byte, error) {
+	j := messageJSON{
+		Hex:       fmt.Sprintf("%06x", mm.ICAO()),
+		DF:        mm.msgtype,
+		Raw:       fmt.Sprintf("%X", mm.Bytes()),
+		CRCOk:     mm.crcok,
+		Corrected: mm.CorrectedBits(),
+		Received:  jsonTime(mm.received),
+		Source:    mm.source,
+	}
+	if level, ok := mm.SignalLevel(); ok {
+		rssi := math.Round(100*10*math.Log10(level)) / 100
+		j.RSSI = &rssi
+	}
+
+	switch mm.msgtype {
+	case 11, 17, 18:
+		j.CA = jsonInt(mm.ca, true)
+	}
+	if code, si, ok := mm.InterrogatorCode(); ok && si {
+		j.Interrogator = fmt.Sprintf("SI%d", code)
+	} else if ok {
+		j.Interrogator = fmt.Sprintf("II%d", code)
+	}
+	if fs, dr, um, ok := mm.Surveillance(); ok {
+		j.FS, j.DR, j.UM = &fs, &dr, &um
+	}
+	if status, ok := mm.ACASStatus(); ok {
+		j.ACAS = &status
+	}
+	if mm.IsExtendedSquitter() {
+		j.TypeCode = jsonInt(mm.metype, true)
+		j.Subtype = jsonInt(mm.mesub, true)
+	}
+
+	j.Flight, _ = mm.Callsign()
+	j.Category, _ = mm.Category()
+	if squawk, ok := mm.Squawk(); ok {
+		j.Squawk = fmt.Sprintf("%04d", squawk)
+	}
+	j.Altitude = jsonInt(mm.Altitude())
+	j.AltitudeGeom = jsonInt(mm.GeometricAltitude())
+	if speed, track, ok := mm.Velocity(); ok {
+		j.Speed, j.Track = &speed, &track
+	}
+	j.Heading = jsonInt(mm.Heading())
+	j.VertRate = jsonInt(mm.VerticalRate())
+	j.GNSSBaroDiff = jsonInt(mm.GNSSBaroDiff())
+	if lat, lon, odd, ok := mm.CPR(); ok {
+		j.CPR = &cprJSON{Lat: lat, Lon: lon, Odd: odd}
+	}
+	if ra, ok := mm.ACASRA(); ok {
+		j.ACASRA = newRAJSON(ra)
+	}
+	return json.Marshal(j)
+}
@@ -0,0 +1,138 @@
+package mode_s
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+/* Merge of the positions reported by several receivers. Every receiver
+ * (see ModeSMessage.SetReceived) decodes its own positions of an
+ * aircraft; the positions received within MODES_MERGE_WINDOW of each
+ * other are compared, and the ones farther than MODES_MERGE_OUTLIER_KM
+ * from their median are outliers. The agreement of every receiver with
+ * the others is counted, to find a misbehaving antenna or clock. */
+
+/* Merge policies. */
+const (
+	MERGE_FRESHEST = "freshest" /* The last decoded position wins. */
+	MERGE_AVERAGE  = "average"  /* Average of the recent positions, without the outliers. */
+)
+
+const MODES_MERGE_WINDOW = 2 * time.Second
+const MODES_MERGE_OUTLIER_KM = 1.0
+
+/* Agreement of the positions of a receiver with the other receivers. */
+type SourceAgreement struct {
+	Positions       int64   `json:"positions"`
+	Compared        int64   `json:"compared"` /* with positions of other receivers */
+	Outliers        int64   `json:"outliers"`
+	MeanDeviationKm float64 `json:"mean_deviation_km"` /* from the median, of the compared positions */
+
+	total_deviation_km float64
+}
+
+/* Select the merge policy: MERGE_FRESHEST (the default) or MERGE_AVERAGE. */
+func (sky *Sky) SetMergePolicy(policy string) error {
+	if policy != MERGE_FRESHEST && policy != MERGE_AVERAGE {
+		return fmt.Errorf("merge error: unknown policy %q (freshest, average)", policy)
+	}
+
+	sky.mux.Lock()
+	defer sky.mux.Unlock()
+
+	sky.merge_policy = policy
+	return nil
+}
+
+/* Return the agreement statistics of every receiver. */
+func (sky *Sky) SourceAgreement() map[string]SourceAgreement {
+	sky.mux.RLock()
+	defer sky.mux.RUnlock()
+
+	stats := make(map[string]SourceAgreement, len(sky.agreement))
+	for source, s := range sky.agreement {
+		stats[source] = *s
+	}
+	return stats
+}
+
+/* Record the position just decoded for the aircraft, compare it with the
+ * positions of the other receivers and, with MERGE_AVERAGE, replace it by
+ * the merged position. Must be called with the lock held. */
+func (sky *Sky) mergePosition(a *Aircraft) {
+	source := a.Provenance.Source
+	if source == "" {
+		return
+	}
+
+	if a.source_positions == nil {
+		a.source_positions = make(map[string]TrailPoint)
+	}
+	a.source_positions[source] = TrailPoint{Latitude: a.Latitude, Longitude: a.Longitude, Time: a.Seen}
+
+	stats := sky.agreement[source]
+	if stats == nil {
+		stats = &SourceAgreement{}
+		sky.agreement[source] = stats
+	}
+	stats.Positions++
+
+	var recent []TrailPoint
+	for s, p := range a.source_positions {
+		if a.Seen.Sub(p.Time) <= MODES_MERGE_WINDOW {
+			recent = append(recent, p)
+		} else {
+			delete(a.source_positions, s)
+		}
+	}
+	if len(recent) < 2 {
+		return
+	}
+
+	medLat, medLon := medianPosition(recent)
+
+	deviation := greatCircleKm(medLat, medLon, a.Latitude, a.Longitude)
+	stats.Compared++
+	stats.total_deviation_km += deviation
+	stats.MeanDeviationKm = stats.total_deviation_km / float64(stats.Compared)
+	if deviation > MODES_MERGE_OUTLIER_KM {
+		stats.Outliers++
+	}
+
+	if sky.merge_policy != MERGE_AVERAGE {
+		return
+	}
+
+	var lat, lon float64
+	n := 0
+	for _, p := range recent {
+		if greatCircleKm(medLat, medLon, p.Latitude, p.Longitude) <= MODES_MERGE_OUTLIER_KM {
+			lat += p.Latitude
+			lon += p.Longitude
+			n++
+		}
+	}
+	if n > 0 {
+		a.Latitude, a.Longitude = lat/float64(n), lon/float64(n)
+	}
+}
+
+/* Component-wise median of positions. */
+func medianPosition(points []TrailPoint) (float64, float64) {
+	lats := make([]float64, len(points))
+	lons := make([]float64, len(points))
+	for i, p := range points {
+		lats[i], lons[i] = p.Latitude, p.Longitude
+	}
+	return median(lats), median(lons)
+}
+
+func median(v []float64) float64 {
+	sort.Float64s(v)
+	n := len(v)
+	if n%2 == 1 {
+		return v[n/2]
+	}
+	return (v[n/2-1] + v[n/2]) / 2
+}
@@ -0,0 +1,305 @@
+package mode_s
+
+import "strconv"
+
+/* Read-only access to the decoded message, for the users of the decoder
+ * outside this package (forwarding, logging, other applications). */
+
+/* Downlink format, MODES_AC_MSGTYPE for Mode A/C replies. */
+func (mm *ModeSMessage) DF() int {
+	return mm.msgtype
+}
+
+/* ICAO address of the message (recovered from the AP field for DF 0, 4,
+ * 5, 16, 20, 21). */
+func (mm *ModeSMessage) ICAO() uint32 {
+	return (mm.aa1 << 16) | (mm.aa2 << 8) | mm.aa3
+}
+
+/* True if the CRC is valid, possibly after error correction. */
+func (mm *ModeSMessage) CRCOk() bool {
+	return mm.crcok
+}
+
+/* True if bit errors were corrected. */
+func (mm *ModeSMessage) Corrected() bool {
+	return mm.errorbit != -1
+}
+
+/* Number of bit errors corrected: 0, 1 or 2. */
+func (mm *ModeSMessage) CorrectedBits() int {
+	switch {
+	case mm.errorbit == -1:
+		return 0
+	case mm.errorbit>>8 != 0: /* two bits, see fixTwoBitsErrors */
+		return 2
+	}
+	return 1
+}
+
+/* The message bytes (after error correction). */
+func (mm *ModeSMessage) Bytes() []byte {
+	return mm.msg[:mm.msgbits/8]
+}
+
+/* Capability field of DF11 and DF17. */
+func (mm *ModeSMessage) CA() int {
+	return mm.ca
+}
+
+/* Flight status, downlink request and utility message of a surveillance
+ * or Comm-B reply (DF4, DF5, DF20, DF21), see FlightStatusString,
+ * DownlinkRequestString and UtilityMessageString. */
+func (mm *ModeSMessage) Surveillance() (fs, dr, um int, ok bool) {
+	switch mm.msgtype {
+	case 4, 5, 20, 21:
+		return mm.fs, mm.dr, mm.um, true
+	}
+	return 0, 0, 0, false
+}
+
+/* Air/ground state given by the message: the flight status of DF4, 5, 20
+ * and 21, the vertical status of DF0 and 16, the capability of DF11 and
+ * 17, or the position type of an extended squitter. ok is false when the
+ * message doesn't tell, e.g. a flight status of 4 or 5 (SPI, airborne or
+ * on the ground). */
+func (mm *ModeSMessage) OnGround() (onGround bool, ok bool) {
+	switch {
+	case mm.IsExtendedSquitter() && mm.metype >= 5 && mm.metype <= 22 && mm.metype != 19:
+		return mm.metype <= 8, true
+	case mm.msgtype == 4 || mm.msgtype == 5 || mm.msgtype == 20 || mm.msgtype == 21:
+		return mm.fs == 1 || mm.fs == 3, mm.fs <= 3
+	case mm.msgtype == 0 || mm.msgtype == 16:
+		return mm.vs != 0, true
+	case mm.msgtype == 11 || mm.msgtype == 17:
+		return mm.ca == 4, mm.ca == 4 || mm.ca == 5
+	}
+	return false, false
+}
+
+/* Interrogator identifier of a DF11 all-call reply, its CL and IC fields
+ * recovered from the parity: 0 for acquisition squitters and the replies
+ * to interrogators of II code 0. See InterrogatorCode. */
+func (mm *ModeSMessage) IID() int {
+	return mm.iid
+}
+
+/* Interrogator a DF11 reply answers: its II code (0-15) if si is false,
+ * else its SI code (1-63). ok is false for other messages and DF11 with a
+ * bad CRC. */
+func (mm *ModeSMessage) InterrogatorCode() (code int, si bool, ok bool) {
+	if mm.msgtype != 11 || !mm.crcok {
+		return 0, false, false
+	}
+	cl, ic := mm.iid>>4, mm.iid&15
+	if cl == 0 {
+		return ic, false, true
+	}
+	return (cl-1)*16 + ic, true, true
+}
+
+/* Control field of DF18 (0 = ADS-B, 2 and 3 = TIS-B, 6 = ADS-R...). */
+func (mm *ModeSMessage) CF() int {
+	return mm.cf
+}
+
+/* True for an extended squitter decoded by this package: DF17, and the
+ * DF18 messages sharing its format. */
+func (mm *ModeSMessage) IsExtendedSquitter() bool {
+	return esSource(mm) != ""
+}
+
+/* Extended squitter type code, 0 if the message is not an extended
+ * squitter. */
+func (mm *ModeSMessage) TypeCode() int {
+	if !mm.IsExtendedSquitter() {
+		return 0
+	}
+	return mm.metype
+}
+
+/* Extended squitter subtype. */
+func (mm *ModeSMessage) Subtype() int {
+	if !mm.IsExtendedSquitter() {
+		return 0
+	}
+	return mm.mesub
+}
+
+/* Barometric altitude in feet, converted from meters if needed. ok is
+ * false if the message carries no barometric altitude. */
+func (mm *ModeSMessage) Altitude() (altitude int, ok bool) {
+	switch {
+	case mm.msgtype == 0 || mm.msgtype == 4 || mm.msgtype == 16 || mm.msgtype == 20:
+		ok = true
+	case mm.msgtype == MODES_AC_MSGTYPE:
+		ok = mm.altitude != MODES_AC_INVALID_ALTITUDE
+	case mm.IsExtendedSquitter():
+		ok = mm.metype >= 9 && mm.metype <= 18
+	}
+	if !ok {
+		return 0, false
+	}
+	return altitudeFeet(mm), true
+}
+
+/* Geometric (GNSS) height in feet of an airborne position message of
+ * type code 20 to 22. ok is false for other messages and when the height
+ * is not available. */
+func (mm *ModeSMessage) GeometricAltitude() (altitude int, ok bool) {
+	if !mm.IsExtendedSquitter() || mm.metype < 20 || mm.metype > 22 || mm.altitude == 0 {
+		return 0, false
+	}
+	return altitudeFeet(mm), true
+}
+
+/* Mode A code (squawk), as the decimal digits of the octal code, e.g.
+ * 7700. ok is false if the message carries no identity. */
+func (mm *ModeSMessage) Squawk() (squawk int, ok bool) {
+	switch {
+	case mm.msgtype == 5 || mm.msgtype == 21 || mm.msgtype == MODES_AC_MSGTYPE:
+		ok = true
+	case mm.IsExtendedSquitter():
+		ok = mm.metype == 28 && mm.mesub == 1
+	}
+	if !ok {
+		return 0, false
+	}
+	return mm.identity, true
+}
+
+/* Aircraft identification without the trailing spaces. ok is false if
+ * the message carries no identification, or a corrupted one. */
+func (mm *ModeSMessage) Callsign() (callsign string, ok bool) {
+	switch {
+	case mm.msgtype == 20 || mm.msgtype == 21:
+		ok = mm.bds == BDS_20
+	case mm.IsExtendedSquitter():
+		ok = mm.metype >= 1 && mm.metype <= 4
+	}
+	if !ok {
+		return "", false
+	}
+	return sanitizeCallsign(mm.flight[:8])
+}
+
+/* Aircraft category (0 to 3 for type codes 4 to 1) of an identification
+ * message. */
+func (mm *ModeSMessage) AircraftType() int {
+	return mm.aircraft_type
+}
+
+/* ADS-B emitter category of an identification message, set 'A' to 'D'
+ * (type codes 4 to 1) and the category in the set, e.g. "A7" for a
+ * rotorcraft. ok is false for other messages and "no information". */
+func (mm *ModeSMessage) Category() (category string, ok bool) {
+	if !mm.IsExtendedSquitter() || mm.metype < 1 || mm.metype > 4 || mm.mesub == 0 {
+		return "", false
+	}
+	return string(rune('A'+4-mm.metype)) + strconv.Itoa(mm.mesub), true
+}
+
+/* Raw CPR encoded position of an airborne position message, with the
+ * barometric or the GNSS altitude. ok is false for other messages. */
+func (mm *ModeSMessage) CPR() (rawLat, rawLon int, odd bool, ok bool) {
+	if !mm.IsExtendedSquitter() || mm.metype < 9 || mm.metype > 22 || mm.metype == 19 {
+		return 0, 0, false, false
+	}
+	return mm.raw_latitude, mm.raw_longitude, mm.fflag != 0, true
+}
+
+/* Ground speed in knots and track in degrees of an airborne velocity
+ * message (subtypes 1 and 2). */
+func (mm *ModeSMessage) Velocity() (speed, track int, ok bool) {
+	if !mm.IsExtendedSquitter() || mm.metype != 19 || (mm.mesub != 1 && mm.mesub != 2) {
+		return 0, 0, false
+	}
+	return mm.velocity, mm.heading, true
+}
+
+/* Magnetic heading in degrees of an airspeed message (subtypes 3 and
+ * 4), when the heading is available. */
+func (mm *ModeSMessage) Heading() (heading int, ok bool) {
+	if !mm.IsExtendedSquitter() || mm.metype != 19 || (mm.mesub != 3 && mm.mesub != 4) ||
+		mm.heading_is_valid == 0 {
+		return 0, false
+	}
+	return mm.heading, true
+}
+
+/* Vertical rate in feet per minute, negative when descending, of an
+ * airborne velocity message. ok is false if it is not available. */
+func (mm *ModeSMessage) VerticalRate() (rate int, ok bool) {
+	if _, _, vok := mm.Velocity(); !vok || mm.vert_rate == 0 {
+		return 0, false
+	}
+	rate = (mm.vert_rate - 1) * 64
+	if mm.vert_rate_sign != 0 {
+		rate = -rate
+	}
+	return rate, true
+}
+
+/* Difference in feet of the GNSS altitude from the barometric altitude,
+ * negative when below, of an airborne velocity message. ok is false if
+ * it is not available. */
+func (mm *ModeSMessage) GNSSBaroDiff() (diff int, ok bool) {
+	if !mm.IsExtendedSquitter() || mm.metype != 19 || mm.mesub < 1 || mm.mesub > 4 || mm.gnss_diff == 0 {
+		return 0, false
+	}
+	diff = (mm.gnss_diff - 1) * 25
+	if mm.gnss_diff_sign != 0 {
+		diff = -diff
+	}
+	return diff, true
+}
+
+/* Emergency/priority status of a TC 28/1 message. */
+func (mm *ModeSMessage) EmergencyState() int {
+	return mm.emergency_state
+}
+
+/* Resolution advisory of a TC 28/2 message, or of a DF16 reply
+ * carrying one. */
+func (mm *ModeSMessage) ACASRA() (ra ACASRA, ok bool) {
+	switch {
+	case mm.IsExtendedSquitter() && mm.metype == 28 && mm.mesub == 2:
+	case mm.msgtype == 16 && mm.msg[4] == ACAS_VDS_RA:
+	default:
+		return ACASRA{}, false
+	}
+	return mm.acas_ra, true
+}
+
+/* ACAS fields of a DF0 or DF16 air-air surveillance reply. */
+func (mm *ModeSMessage) ACASStatus() (status ACASStatus, ok bool) {
+	if mm.msgtype != 0 && mm.msgtype != 16 {
+		return ACASStatus{}, false
+	}
+	return ACASStatus{
+		OnGround:         mm.vs != 0,
+		SensitivityLevel: mm.sl,
+		ReplyInfo:        mm.ri,
+	}, true
+}
+
+/* Comm-B register of DF20 and DF21, BDS_UNKNOWN if not identified, and
+ * its decoded content. */
+func (mm *ModeSMessage) CommB() (bds int, ehs EHSData) {
+	return mm.bds, mm.ehs
+}
+
+/* The 56 bit MB field of DF20 and DF21. */
+func (mm *ModeSMessage) MB() uint64 {
+	return mm.mb
+}
+
+/* The 56 bit ME field of an extended squitter. */
+func (mm *ModeSMessage) ME() uint64 {
+	return mm.me
+}
+
+/* True if the message was decoded after phase correction. */
+func (mm *ModeSMessage) PhaseCorrected() bool {
+	return mm.phase_corrected != 0
+}
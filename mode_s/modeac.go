@@ -0,0 +1,175 @@
+package mode_s
+
+import (
+	"sort"
+	"time"
+)
+
+/* Mode A/C (SSR) replies carry no address: a Mode A reply is the 4 digit
+ * identity, a Mode C reply the Gillham coded altitude, and a receiver
+ * can't tell the two apart. Sources such as Beast receivers with A/C
+ * enabled forward them as 2 bytes, the identity digits as hex nibbles
+ * (0x7700 for 7700) with the SPI bit at 0x0080.
+ *
+ * Every reply is kept as a target identified by its code, and correlated
+ * with the Mode S aircraft having the same squawk (Mode A) or the same
+ * altitude (Mode C). */
+
+const MODES_AC_MSGTYPE = 32 /* Pseudo DF of Mode A/C replies, after DF 0-31. */
+
+const MODES_AC_INVALID_ALTITUDE = -9999
+
+/* A Mode A/C reply code, seen as a target. */
+type ModeACTarget struct {
+	Code     int       /* Identity, decimal digits (7700 is "7700"). */
+	Altitude int       /* Mode C altitude in feet, MODES_AC_INVALID_ALTITUDE if not a valid Mode C code. */
+	SPI      bool      /* Special position identification pulse. */
+	Messages int64     /* Number of replies received. */
+	Seen     time.Time /* Time of the last reply. */
+
+	ModeAAddr uint32 /* Mode S aircraft with this squawk, 0 if none. */
+	ModeCAddr uint32 /* Mode S aircraft at this altitude, 0 if none. */
+}
+
+/* Decode a 2 bytes Mode A/C reply. The message gets the pseudo DF
+ * MODES_AC_MSGTYPE; there is no checksum, so crcok is always true. */
+func (self *Decoder) DecodeModeAC(mm *ModeSMessage, msg []byte) {
+	mm.reset()
+	mm.msg[0], mm.msg[1] = msg[0], msg[1]
+	mm.msgtype = MODES_AC_MSGTYPE
+	mm.msgbits = 16
+	mm.crcok = true
+	mm.errorbit = -1
+
+	code := int(msg[0])<<8 | int(msg[1])
+	mm.identity = (code>>12&7)*1000 + (code>>8&7)*100 + (code>>4&7)*10 + (code & 7)
+	mm.spi = code&0x0080 != 0
+
+	mm.altitude = MODES_AC_INVALID_ALTITUDE
+	mm.unit = MODES_UNIT_FEET
+	if !mm.spi {
+		if c := modeAToModeC(code); c != MODES_AC_INVALID_ALTITUDE {
+			mm.altitude = c * 100
+		}
+	}
+}
+
+/* Convert a Mode A code (hex digits, as above) to a Mode C altitude in
+ * hundreds of feet, MODES_AC_INVALID_ALTITUDE if the code is not a valid
+ * Gillham coded altitude. From dump1090 mode_ac.c. */
+func modeAToModeC(modeA int) int {
+	var fiveHundreds, oneHundreds int
+
+	/* Unused bits and SPI must be zero, D1 set is illegal, and C1..C4
+	 * can't be all zero. */
+	if modeA&0xffff8889 != 0 || modeA&0x0070 == 0 {
+		return MODES_AC_INVALID_ALTITUDE
+	}
+
+	if modeA&0x0010 != 0 { /* C1 */
+		oneHundreds ^= 0x007
+	}
+	if modeA&0x0020 != 0 { /* C2 */
+		oneHundreds ^= 0x003
+	}
+	if modeA&0x0040 != 0 { /* C4 */
+		oneHundreds ^= 0x001
+	}
+
+	/* Remove 7s from oneHundreds (make 7->5, and 5->7). */
+	if oneHundreds&5 == 5 {
+		oneHundreds ^= 2
+	}
+
+	/* Only 1 to 5 are valid. */
+	if oneHundreds > 5 {
+		return MODES_AC_INVALID_ALTITUDE
+	}
+
+	if modeA&0x0002 != 0 { /* D2 */
+		fiveHundreds ^= 0x0ff
+	}
+	if modeA&0x0004 != 0 { /* D4 */
+		fiveHundreds ^= 0x07f
+	}
+	if modeA&0x1000 != 0 { /* A1 */
+		fiveHundreds ^= 0x03f
+	}
+	if modeA&0x2000 != 0 { /* A2 */
+		fiveHundreds ^= 0x01f
+	}
+	if modeA&0x4000 != 0 { /* A4 */
+		fiveHundreds ^= 0x00f
+	}
+	if modeA&0x0100 != 0 { /* B1 */
+		fiveHundreds ^= 0x007
+	}
+	if modeA&0x0200 != 0 { /* B2 */
+		fiveHundreds ^= 0x003
+	}
+	if modeA&0x0400 != 0 { /* B4 */
+		fiveHundreds ^= 0x001
+	}
+
+	/* Correct the order of oneHundreds. */
+	if fiveHundreds&1 != 0 {
+		oneHundreds = 6 - oneHundreds
+	}
+
+	return fiveHundreds*5 + oneHundreds - 13
+}
+
+/* Record a Mode A/C reply and correlate it with the Mode S aircraft.
+ * Returns the correlated aircraft, nil if none. Must be called with the
+ * lock held. */
+func (sky *Sky) updateModeAC(mm *ModeSMessage) *Aircraft {
+	t := sky.modeac[mm.identity]
+	if t == nil {
+		t = &ModeACTarget{Code: mm.identity, Altitude: mm.altitude}
+		sky.modeac[mm.identity] = t
+	}
+	t.SPI = mm.spi
+	t.Messages++
+	t.Seen = time.Now()
+	t.ModeAAddr, t.ModeCAddr = 0, 0
+
+	var match *Aircraft
+	for addr, a := range sky.aircrafts {
+		if a.Squawk != 0 && a.Squawk == t.Code {
+			t.ModeAAddr = addr
+			a.ModeACount++
+			sky.touch(a)
+			match = a
+		} else if t.Altitude != MODES_AC_INVALID_ALTITUDE && a.Altitude != 0 &&
+			modeCRound(a.Altitude) == t.Altitude {
+			t.ModeCAddr = addr
+			a.ModeCCount++
+			sky.touch(a)
+			if match == nil {
+				match = a
+			}
+		}
+	}
+	return match
+}
+
+/* Round an altitude to the 100 ft resolution of Mode C. */
+func modeCRound(altitude int) int {
+	if altitude < 0 {
+		return -((-altitude + 50) / 100 * 100)
+	}
+	return (altitude + 50) / 100 * 100
+}
+
+// return copy of the Mode A/C targets, by code
+func (sky *Sky) ModeACTargets() []ModeACTarget {
+	sky.mux.RLock()
+	defer sky.mux.RUnlock()
+
+	targets := make([]ModeACTarget, 0, len(sky.modeac))
+	for _, t := range sky.modeac {
+		targets = append(targets, *t)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Code < targets[j].Code })
+	return targets
+}
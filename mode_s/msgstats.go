@@ -0,0 +1,69 @@
+package mode_s
+
+import "time"
+
+/* Seconds of the rolling message rate of an aircraft. */
+const MODES_RATE_WINDOW = 10
+
+/* Messages received from an aircraft by category. */
+type MessageCounts struct {
+	Positions      int64 `json:"positions"`      /* ES airborne and surface positions. */
+	Velocities     int64 `json:"velocities"`     /* ES airborne velocities. */
+	Identification int64 `json:"identification"` /* ES aircraft identification. */
+	CommB          int64 `json:"comm_b"`         /* DF20 and DF21 replies. */
+	Other          int64 `json:"other"`
+}
+
+/* Count the message in its category. */
+func (c *MessageCounts) add(mm *ModeSMessage) {
+	switch {
+	case mm.msgtype == 20 || mm.msgtype == 21:
+		c.CommB++
+	case !mm.IsExtendedSquitter():
+		c.Other++
+	case mm.metype >= 1 && mm.metype <= 4:
+		c.Identification++
+	case (mm.metype >= 5 && mm.metype <= 18) || (mm.metype >= 20 && mm.metype <= 22):
+		c.Positions++
+	case mm.metype == 19:
+		c.Velocities++
+	default:
+		c.Other++
+	}
+}
+
+/* Messages received in each of the last MODES_RATE_WINDOW seconds. */
+type messageRate struct {
+	counts  [MODES_RATE_WINDOW]int
+	seconds [MODES_RATE_WINDOW]int64 /* Unix time of the counts. */
+}
+
+func (r *messageRate) add(t time.Time) {
+	sec := t.Unix()
+	i := int(sec % MODES_RATE_WINDOW)
+	if r.seconds[i] != sec {
+		r.seconds[i], r.counts[i] = sec, 0
+	}
+	r.counts[i]++
+}
+
+/* Messages per second of the aircraft over the last MODES_RATE_WINDOW
+ * seconds, or since it was first seen if later. */
+func (a *Aircraft) MessageRate(now time.Time) float64 {
+	sec := now.Unix()
+	n := 0
+	for i, s := range a.rate.seconds {
+		if s > sec-MODES_RATE_WINDOW && s <= sec {
+			n += a.rate.counts[i]
+		}
+	}
+
+	window := now.Sub(a.FirstSeen).Seconds()
+	switch {
+	case window > MODES_RATE_WINDOW:
+		window = MODES_RATE_WINDOW
+	case window < 1:
+		window = 1
+	}
+	return float64(n) / window
+}
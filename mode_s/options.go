@@ -0,0 +1,69 @@
+package mode_s
+
+import (
+	"time"
+)
+
+/* Option configures a Decoder created with NewDecoder. */
+type Option func(*Decoder)
+
+/* Create a decoder with the default configuration (single bit error
+ * correction, CRC check) changed by the options. */
+func NewDecoder(opts ...Option) *Decoder {
+	d := &Decoder{}
+	d.modesInitConfig()
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.initICAOCache()
+	return d
+}
+
+/* Fix single bit errors (enabled by default). */
+func WithErrorCorrection(enabled bool) Option {
+	return func(d *Decoder) {
+		d.fix_errors = enabled
+	}
+}
+
+/* Also fix two bit errors of DF17, and accept noisier frames from the
+ * demodulator. */
+func WithAggressiveCorrection(enabled bool) Option {
+	return func(d *Decoder) {
+		d.aggressive = enabled
+	}
+}
+
+/* Only pass messages with a good CRC (enabled by default), see
+ * SetCheckCRC. */
+func WithCRCCheckOnly(enabled bool) Option {
+	return func(d *Decoder) {
+		d.check_crc = enabled
+	}
+}
+
+/* Use metric units. */
+func WithMetricUnits(enabled bool) Option {
+	return func(d *Decoder) {
+		d.metric = 0
+		if enabled {
+			d.metric = 1
+		}
+	}
+}
+
+/* Maximum number of aircraft listed in interactive mode, 0 = no limit. */
+func WithInteractiveRows(rows int) Option {
+	return func(d *Decoder) {
+		d.interactive_rows = rows
+	}
+}
+
+/* Time an ICAO address seen in a DF11 or DF17 message is kept to check the
+ * address/parity field of other messages (MODES_ICAO_CACHE_TTL seconds by
+ * default). */
+func WithICAOCacheTTL(ttl time.Duration) Option {
+	return func(d *Decoder) {
+		d.icao_cache_ttl = ttl
+	}
+}
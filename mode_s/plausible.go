@@ -0,0 +1,88 @@
+package mode_s
+
+import "time"
+
+/* Position plausibility filter. Corrupted frames that pass the CRC (or
+ * that are "fixed" wrongly) and mismatched odd/even pairs give positions
+ * far from the receiver or far from the track of the aircraft. Such
+ * positions are rejected, unless several consecutive positions agree with
+ * each other: the aircraft really is there, and the jump is reported as
+ * an anomaly (see anomaly.go). Jumps rejected again and again, e.g. two
+ * transmitters using the same address, are reported too. */
+
+/* Consistent positions needed to accept a jump. */
+const MODES_POSITION_CONFIRMATIONS = 3
+
+/* Default max distance of positions from the receiver, in km (beyond the
+ * radio horizon of an aircraft at FL450). */
+const MODES_DEFAULT_MAX_RANGE_KM = 600
+
+/* Set the max distance of positions from the receiver location, in km.
+ * 0 disables the range check. */
+func (sky *Sky) SetMaxRange(km float64) {
+	sky.mux.Lock()
+	defer sky.mux.Unlock()
+
+	sky.max_range_km = km
+}
+
+/* Ground speed implied by moving between two positions, in knots. Moves
+ * shorter than ANOMALY_MIN_JUMP_KM, within the CPR error, give 0. */
+func impliedSpeedKt(lat1, lon1 float64, t1 time.Time, lat2, lon2 float64, t2 time.Time) float64 {
+	km := greatCircleKm(lat1, lon1, lat2, lon2)
+	if km < ANOMALY_MIN_JUMP_KM {
+		return 0
+	}
+
+	secs := t2.Sub(t1).Seconds()
+	if secs < 1 {
+		secs = 1
+	}
+	return km / KM_PER_NM / (secs / 3600)
+}
+
+/* Check the newly decoded position of the aircraft (a.Latitude and
+ * a.Longitude). Returns false if it must be discarded. */
+func (sky *Sky) plausiblePosition(a *Aircraft) bool {
+	if sky.has_receiver && sky.max_range_km > 0 &&
+		greatCircleKm(sky.receiver_lat, sky.receiver_lon, a.Latitude, a.Longitude) > sky.max_range_km {
+		a.PositionsRejected++
+		return false
+	}
+
+	last, ok := a.Trail.Last()
+	if !ok {
+		return true
+	}
+
+	if impliedSpeedKt(last.Latitude, last.Longitude, last.Time, a.Latitude, a.Longitude, a.Seen) <= ANOMALY_MAX_SPEED_KT {
+		a.jump_confirmations = 0
+		return true
+	}
+
+	/* Count the positions agreeing with the previous rejected one. */
+	if a.jump_confirmations > 0 &&
+		impliedSpeedKt(a.jump_position.Latitude, a.jump_position.Longitude, a.jump_position.Time,
+			a.Latitude, a.Longitude, a.Seen) <= ANOMALY_MAX_SPEED_KT {
+		a.jump_confirmations++
+	} else {
+		a.jump_confirmations = 1
+	}
+	a.jump_position = TrailPoint{Latitude: a.Latitude, Longitude: a.Longitude, Time: a.Seen}
+
+	if a.jump_confirmations >= MODES_POSITION_CONFIRMATIONS {
+		a.jump_confirmations = 0
+		sky.reportJump(a)
+		return true
+	}
+
+	/* A single rejected jump is likely a corrupted frame, not another
+	 * one soon after. */
+	if !a.jump_rejected.IsZero() && a.Seen.Sub(a.jump_rejected) <= ANOMALY_JUMP_INTERVAL {
+		sky.reportJump(a)
+	}
+	a.jump_rejected = a.Seen
+
+	a.PositionsRejected++
+	return false
+}
@@ -0,0 +1,22 @@
+package mode_s
+
+import "sync"
+
+/* Messages given back with ReleaseMessage, so that decoding a stream of
+ * frames doesn't allocate a message for each. */
+var messagePool = sync.Pool{
+	New: func() interface{} { return new(ModeSMessage) },
+}
+
+/* Get a zeroed message to decode into, from the pool. Give it back with
+ * ReleaseMessage once done with it. */
+func AcquireMessage() *ModeSMessage {
+	return messagePool.Get().(*ModeSMessage)
+}
+
+/* Give back a message obtained with AcquireMessage. Neither the message
+ * nor its Bytes must be used afterwards: copy them to keep them. */
+func ReleaseMessage(mm *ModeSMessage) {
+	*mm = ModeSMessage{}
+	messagePool.Put(mm)
+}
@@ -0,0 +1,116 @@
+package mode_s
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+/* Write the message as dump1090 prints it: the frame, the CRC and the
+ * DF, then a decoded field per line, then an empty line. */
+func (mm *ModeSMessage) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	field := func(indent int, name string, format string, args ...interface{}) {
+		fmt.Fprintf(&b, "%s%-15s: %s\n", strings.Repeat("  ", indent), name, fmt.Sprintf(format, args...))
+	}
+
+	fmt.Fprintf(&b, "*%X;\n", mm.Bytes())
+	switch {
+	case !mm.crcok:
+		b.WriteString("CRC: bad\n")
+	case mm.Corrected():
+		fmt.Fprintf(&b, "CRC: ok (%d bit error fixed)\n", mm.CorrectedBits())
+	default:
+		b.WriteString("CRC: ok\n")
+	}
+	fmt.Fprintf(&b, "DF %d\n", mm.msgtype)
+
+	switch mm.msgtype {
+	case 11, 17, 18:
+		field(1, "Capability", "%d (%s)", mm.ca, caStr(mm.ca))
+	}
+	if code, si, ok := mm.InterrogatorCode(); ok && si {
+		field(1, "Interrogator", "SI %d", code)
+	} else if ok {
+		field(1, "Interrogator", "II %d", code)
+	}
+	field(1, "ICAO Address", "%06x", mm.ICAO())
+	if mm.IsExtendedSquitter() {
+		field(1, "Type", "%d", mm.metype)
+		field(1, "Subtype", "%d", mm.mesub)
+		field(1, "Description", "%s", getMEDescription(mm.metype, mm.mesub))
+	}
+	if fs, dr, um, ok := mm.Surveillance(); ok {
+		field(1, "Flight status", "%d (%s)", fs, FlightStatusString(fs))
+		field(1, "Downlink req.", "%d (%s)", dr, DownlinkRequestString(dr))
+		field(1, "Utility msg.", "%d (%s)", um, UtilityMessageString(um))
+	}
+	if status, ok := mm.ACASStatus(); ok {
+		field(1, "Vertical status", "%s", airborneGround(status.OnGround))
+		field(1, "Sensitivity", "%d", status.SensitivityLevel)
+		field(1, "Reply info", "%d (%s)", status.ReplyInfo, ReplyInfoString(status.ReplyInfo))
+	}
+
+	if flight, ok := mm.Callsign(); ok {
+		field(2, "Identification", "%s", flight)
+	}
+	if cat, ok := mm.Category(); ok {
+		field(2, "Category", "%s", cat)
+	}
+	if squawk, ok := mm.Squawk(); ok {
+		field(2, "Squawk", "%04d", squawk)
+	}
+	if alt, ok := mm.Altitude(); ok {
+		field(2, "Altitude", "%d feet", alt)
+	}
+	if alt, ok := mm.GeometricAltitude(); ok {
+		field(2, "GNSS altitude", "%d feet", alt)
+	}
+	if lat, lon, odd, ok := mm.CPR(); ok {
+		field(2, "CPR", "%s", evenOdd(odd))
+		field(2, "Latitude", "%d (not decoded)", lat)
+		field(2, "Longitude", "%d (not decoded)", lon)
+	}
+	if speed, track, ok := mm.Velocity(); ok {
+		field(2, "Speed", "%d kt", speed)
+		field(2, "Track", "%d", track)
+	}
+	if hdg, ok := mm.Heading(); ok {
+		field(2, "Heading", "%d", hdg)
+	}
+	if vr, ok := mm.VerticalRate(); ok {
+		field(2, "Vertical rate", "%+d ft/min", vr)
+	}
+	if diff, ok := mm.GNSSBaroDiff(); ok {
+		field(2, "GNSS-baro diff", "%+d feet", diff)
+	}
+	if ra, ok := mm.ACASRA(); ok {
+		field(2, "ACAS RA", "ARA %014b RAC %04b", ra.ARA, ra.RAC)
+		field(2, "RA terminated", "%v", ra.Terminated)
+	}
+	b.WriteString("\n")
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+/* The message as written by WriteTo. */
+func (mm *ModeSMessage) String() string {
+	var b strings.Builder
+	mm.WriteTo(&b)
+	return b.String()
+}
+
+func evenOdd(odd bool) string {
+	if odd {
+		return "odd"
+	}
+	return "even"
+}
+
+func airborneGround(onGround bool) string {
+	if onGround {
+		return "on ground"
+	}
+	return "airborne"
+}
@@ -0,0 +1,59 @@
+package mode_s
+
+import (
+	"time"
+)
+
+/* How a message was received and decoded, so the consumers of aircraft
+ * data can audit where every data point comes from. */
+type Provenance struct {
+	Source         string    `json:"source,omitempty"` /* Receiver (input) id. */
+	Received       time.Time `json:"received"`         /* Local reception time. */
+	DF             int       `json:"df"`
+	CRCOk          bool      `json:"crc_ok"`
+	Corrected      bool      `json:"corrected"`                 /* Bit errors were fixed. */
+	PhaseCorrected bool      `json:"phase_corrected,omitempty"` /* Decoded after phase correction. */
+}
+
+/* Record the receiver and the reception time of the message, before
+ * passing it to Sky.UpdateData. Messages without a reception time are
+ * stamped when the sky is updated. */
+func (mm *ModeSMessage) SetReceived(source string, received time.Time) {
+	mm.source = source
+	mm.received = received
+}
+
+/* Record the 12 MHz MLAT counter of the source at reception, forwarded
+ * by the Beast outputs. */
+func (mm *ModeSMessage) SetTimestamp(timestamp uint64) {
+	mm.timestamp = timestamp
+}
+
+/* Record the signal level of the message, a power from 0 to 1 (full
+ * scale), e.g. from the Beast signal byte b as (b/255)^2. */
+func (mm *ModeSMessage) SetSignalLevel(level float64) {
+	mm.signal = level
+}
+
+/* Signal level of the message, see SetSignalLevel; ok is false if the
+ * source has none. */
+func (mm *ModeSMessage) SignalLevel() (level float64, ok bool) {
+	return mm.signal, mm.signal > 0
+}
+
+/* MLAT timestamp of the message, 0 if the source has none. */
+func (mm *ModeSMessage) Timestamp() uint64 {
+	return mm.timestamp
+}
+
+/* Provenance of the message. */
+func (mm *ModeSMessage) Provenance() Provenance {
+	return Provenance{
+		Source:         mm.source,
+		Received:       mm.received,
+		DF:             mm.msgtype,
+		CRCOk:          mm.crcok,
+		Corrected:      mm.Corrected(),
+		PhaseCorrected: mm.PhaseCorrected(),
+	}
+}
@@ -0,0 +1,28 @@
+package mode_s
+
+import "time"
+
+/* Positions younger than this are shown as decoded, not extrapolated. */
+const MODES_DR_MIN_AGE = 2 * time.Second
+
+/* Return the position of the aircraft at 'now' by dead reckoning: when
+ * its last position is older than MODES_DR_MIN_AGE, it is moved along its
+ * track at its ground speed for the time elapsed, up to maxAge. Beyond
+ * maxAge, or without speed, the last position is returned as it is.
+ * estimated is true when the position was extrapolated; ok is false when
+ * the aircraft has no position. */
+func (a *Aircraft) EstimatedPosition(now time.Time, maxAge time.Duration) (lat, lon float64, estimated, ok bool) {
+	last, ok := a.Trail.Last()
+	if !ok {
+		return 0, 0, false, false
+	}
+
+	elapsed := now.Sub(last.Time)
+	if maxAge <= 0 || a.Speed <= 0 || elapsed < MODES_DR_MIN_AGE || elapsed > maxAge {
+		return a.Latitude, a.Longitude, false, true
+	}
+
+	km := float64(a.Speed) * KM_PER_NM * elapsed.Hours()
+	lat, lon = destination(a.Latitude, a.Longitude, float64(a.Track), km)
+	return lat, lon, true, true
+}
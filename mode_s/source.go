@@ -0,0 +1,60 @@
+package mode_s
+
+/* Data sources of an aircraft, as reported by extended squitters. DF17 is
+ * sent by the aircraft transponder itself; DF18 is sent by non-transponder
+ * devices and ground stations (TIS-B, ADS-R), the CF field tells which. */
+const (
+	SOURCE_MODE_S       = "mode_s"       /* No extended squitter received. */
+	SOURCE_ADSB_ICAO    = "adsb_icao"    /* DF17 */
+	SOURCE_ADSB_ICAO_NT = "adsb_icao_nt" /* DF18 CF 0, non-transponder */
+	SOURCE_ADSB_OTHER   = "adsb_other"   /* DF18 CF 1, anonymous address */
+	SOURCE_TISB_ICAO    = "tisb_icao"    /* DF18 CF 2, fine TIS-B */
+	SOURCE_TISB_OTHER   = "tisb_other"   /* DF18 CF 5, fine TIS-B, non-ICAO address */
+	SOURCE_ADSR_ICAO    = "adsr_icao"    /* DF18 CF 6, ADS-B rebroadcast */
+)
+
+/* Flag set on the address of aircraft identified by a non-ICAO (anonymous
+ * or TIS-B track) address, so they never collide with ICAO addresses. */
+const MODES_NON_ICAO_ADDRESS = 1 << 24
+
+/* Preference of the data sources, the direct ones first. An aircraft
+ * keeps the best source it was received from. */
+var sourceRank = map[string]int{
+	SOURCE_ADSB_ICAO:    6,
+	SOURCE_ADSB_ICAO_NT: 5,
+	SOURCE_ADSR_ICAO:    4,
+	SOURCE_ADSB_OTHER:   3,
+	SOURCE_TISB_ICAO:    2,
+	SOURCE_TISB_OTHER:   1,
+	SOURCE_MODE_S:       0,
+}
+
+/* Data source of an extended squitter, "" if the message is not one we
+ * are able to decode (DF18 coarse TIS-B, management and reserved CF). */
+func esSource(mm *ModeSMessage) string {
+	if mm.msgtype == 17 {
+		return SOURCE_ADSB_ICAO
+	}
+	if mm.msgtype != 18 {
+		return ""
+	}
+
+	switch mm.cf {
+	case 0:
+		return SOURCE_ADSB_ICAO_NT
+	case 1:
+		return SOURCE_ADSB_OTHER
+	case 2:
+		return SOURCE_TISB_ICAO
+	case 5:
+		return SOURCE_TISB_OTHER
+	case 6:
+		return SOURCE_ADSR_ICAO
+	}
+	return ""
+}
+
+/* True if the DF18 address field is not an ICAO address. */
+func nonICAOAddress(mm *ModeSMessage) bool {
+	return mm.msgtype == 18 && (mm.cf == 1 || mm.cf == 5)
+}
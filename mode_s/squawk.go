@@ -0,0 +1,84 @@
+package mode_s
+
+import (
+	"go1090/i18n"
+	"strings"
+)
+
+/* Squawk codes with the same meaning everywhere. */
+var emergencySquawks = map[int]string{
+	7500: "squawk.hijack",
+	7600: "squawk.radio_failure",
+	7700: "squawk.emergency",
+}
+
+/* Conspicuity and special purpose squawk codes by region. The meaning is
+ * a message catalog key. */
+var regionalSquawks = map[string]map[int]string{
+	/* ICAO / Europe */
+	"ICAO": {
+		1000: "squawk.ifr_conspicuity",
+		2000: "squawk.no_code",
+		7000: "squawk.vfr",
+	},
+	"US": {
+		1200: "squawk.vfr",
+		1202: "squawk.glider",
+		1255: "squawk.firefighting",
+		1277: "squawk.sar",
+		4000: "squawk.military_vfr",
+		7400: "squawk.uas_lost_link",
+		7777: "squawk.interceptor",
+	},
+	"CA": {
+		1200: "squawk.vfr",
+		1400: "squawk.vfr_high",
+		2000: "squawk.no_code",
+	},
+	"AU": {
+		1200: "squawk.vfr",
+		2000: "squawk.no_code",
+	},
+	"UK": {
+		20:   "squawk.hems",      /* 0020 */
+		33:   "squawk.parachute", /* 0033 */
+		2000: "squawk.no_code",
+		7000: "squawk.vfr",
+		7001: "squawk.military_low_level",
+		7004: "squawk.aerobatics",
+		7010: "squawk.circuit",
+	},
+	"DE": {
+		20:   "squawk.hems", /* 0020 */
+		1000: "squawk.ifr_conspicuity",
+		2000: "squawk.no_code",
+		7000: "squawk.vfr",
+	},
+}
+
+/* Squawk regions known by SquawkMeaning(). */
+func SquawkRegions() []string {
+	return []string{"ICAO", "US", "CA", "AU", "UK", "DE"}
+}
+
+/* Return true for the 7500, 7600 and 7700 emergency codes. */
+func IsEmergencySquawk(squawk int) bool {
+	_, ok := emergencySquawks[squawk]
+	return ok
+}
+
+/* Describe a squawk code (written in decimal digits, 7700 is "7700") for
+ * the given region, or return an empty string if the code has no special
+ * meaning. */
+func SquawkMeaning(squawk int, region string) string {
+	if key, ok := emergencySquawks[squawk]; ok {
+		return i18n.S(key)
+	}
+
+	if codes, ok := regionalSquawks[strings.ToUpper(region)]; ok {
+		if key, ok := codes[squawk]; ok {
+			return i18n.S(key)
+		}
+	}
+	return ""
+}
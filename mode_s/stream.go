@@ -0,0 +1,60 @@
+package mode_s
+
+import (
+	"context"
+)
+
+/* Decode the frames received on 'in', sending the accepted messages (see
+ * Accept) on the returned channel. Mode A/C replies are decoded as well.
+ * The channel is unbuffered, so a slow reader slows down the source, and
+ * is closed when 'in' is closed or ctx is canceled. The messages come
+ * from the pool: the reader may give them back with ReleaseMessage once
+ * done. */
+func (self *Decoder) Stream(ctx context.Context, in <-chan Frame) <-chan *ModeSMessage {
+	out := make(chan *ModeSMessage)
+
+	go func() {
+		defer close(out)
+
+		for {
+			var f Frame
+			var ok bool
+			select {
+			case f, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+
+			mm := self.decodeFrame(f)
+			if !self.Accept(mm) {
+				ReleaseMessage(mm)
+				continue
+			}
+
+			select {
+			case out <- mm:
+			case <-ctx.Done():
+				ReleaseMessage(mm)
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+/* Decode a received frame into a message of the pool, see AcquireMessage. */
+func (self *Decoder) decodeFrame(f Frame) *ModeSMessage {
+	mm := AcquireMessage()
+	mm.SetReceived(f.Source, f.Received)
+	mm.SetTimestamp(f.Timestamp)
+	if f.ModeAC {
+		self.DecodeModeAC(mm, f.Msg[:2])
+	} else {
+		self.DecodeModesMessage(mm, f.Msg[:])
+	}
+	return mm
+}
@@ -0,0 +1,99 @@
+package mode_s
+
+import (
+	"encoding/json"
+	"time"
+)
+
+/* Track history of an aircraft: the last MODES_TRAIL_LEN decoded
+ * positions in a ring buffer, the oldest one overwritten when it is
+ * full. The zero value is an empty trail. */
+type Trail struct {
+	points []TrailPoint /* Ring buffer, allocated with the first point. */
+	start  int          /* Index of the oldest point. */
+	n      int          /* Number of points. */
+}
+
+/* Number of points of the trail. */
+func (t *Trail) Len() int {
+	return t.n
+}
+
+/* Return the i-th point, 0 being the oldest. */
+func (t *Trail) At(i int) TrailPoint {
+	return t.points[(t.start+i)%len(t.points)]
+}
+
+/* Return the most recent point, ok is false if the trail is empty. */
+func (t *Trail) Last() (p TrailPoint, ok bool) {
+	if t.n == 0 {
+		return TrailPoint{}, false
+	}
+	return t.At(t.n - 1), true
+}
+
+/* Return a copy of the points, oldest first. */
+func (t *Trail) Points() []TrailPoint {
+	return t.Since(time.Time{})
+}
+
+/* Return a copy of the points decoded after 'since', oldest first. */
+func (t *Trail) Since(since time.Time) []TrailPoint {
+	points := make([]TrailPoint, 0, t.n)
+	for i := 0; i < t.n; i++ {
+		if p := t.At(i); p.Time.After(since) {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+/* Call f on every point, oldest first. f may modify the point, e.g. to
+ * hide the position of a copy of the aircraft. */
+func (t *Trail) Update(f func(p *TrailPoint)) {
+	for i := 0; i < t.n; i++ {
+		f(&t.points[(t.start+i)%len(t.points)])
+	}
+}
+
+/* Append a point, overwriting the oldest one when the trail is full. */
+func (t *Trail) add(p TrailPoint) {
+	if t.points == nil {
+		t.points = make([]TrailPoint, MODES_TRAIL_LEN)
+	}
+
+	if t.n < len(t.points) {
+		t.points[(t.start+t.n)%len(t.points)] = p
+		t.n++
+	} else {
+		t.points[t.start] = p
+		t.start = (t.start + 1) % len(t.points)
+	}
+}
+
+/* Return a copy not sharing the ring buffer. */
+func (t *Trail) clone() Trail {
+	c := Trail{}
+	for _, p := range t.Points() {
+		c.add(p)
+	}
+	return c
+}
+
+/* A trail is saved as the list of its points, oldest first. */
+func (t Trail) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Points())
+}
+
+func (t *Trail) UnmarshalJSON(data []byte) error {
+	var points []TrailPoint
+	if err := json.Unmarshal(data, &points); err != nil {
+		return err
+	}
+
+	*t = Trail{}
+	for _, p := range points {
+		t.add(p)
+	}
+	return nil
+}
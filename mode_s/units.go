@@ -0,0 +1,96 @@
+package mode_s
+
+import (
+	"fmt"
+	"math"
+)
+
+/* Units of the altitudes, speeds and vertical rates shown and output.
+ * Distances are in km with both. */
+type Units int
+
+const (
+	UNITS_AVIATION Units = iota /* Feet, knots, feet per minute. */
+	UNITS_METRIC                /* Meters, km/h, meters per second. */
+)
+
+/* Parse a units name: aviation or metric. */
+func ParseUnits(name string) (Units, error) {
+	switch name {
+	case "aviation":
+		return UNITS_AVIATION, nil
+	case "metric":
+		return UNITS_METRIC, nil
+	}
+	return UNITS_AVIATION, fmt.Errorf("units error: unknown units %q (aviation or metric)", name)
+}
+
+func (u Units) String() string {
+	if u == UNITS_METRIC {
+		return "metric"
+	}
+	return "aviation"
+}
+
+/* Convert an altitude in feet. */
+func (u Units) Altitude(ft int) int {
+	if u == UNITS_METRIC {
+		return int(math.Round(float64(ft) / FEET_PER_METER))
+	}
+	return ft
+}
+
+/* Convert a speed in knots. */
+func (u Units) Speed(kt int) int {
+	if u == UNITS_METRIC {
+		return int(math.Round(float64(kt) * 1.852))
+	}
+	return kt
+}
+
+/* Convert a vertical rate in feet per minute. */
+func (u Units) VertRate(fpm int) int {
+	if u == UNITS_METRIC {
+		return int(math.Round(float64(fpm) / FEET_PER_METER / 60))
+	}
+	return fpm
+}
+
+/* Symbols of the units of altitude, speed and vertical rate. */
+func (u Units) AltitudeSymbol() string {
+	if u == UNITS_METRIC {
+		return "m"
+	}
+	return "ft"
+}
+
+func (u Units) SpeedSymbol() string {
+	if u == UNITS_METRIC {
+		return "km/h"
+	}
+	return "kt"
+}
+
+func (u Units) VertRateSymbol() string {
+	if u == UNITS_METRIC {
+		return "m/s"
+	}
+	return "ft/min"
+}
+
+/* Altitudes, speed and vertical rate of an aircraft in units. */
+func (a *Aircraft) AltitudeIn(u Units) int {
+	return u.Altitude(a.Altitude)
+}
+
+func (a *Aircraft) AltitudeGeomIn(u Units) int {
+	return u.Altitude(a.AltitudeGeom)
+}
+
+func (a *Aircraft) SpeedIn(u Units) int {
+	return u.Speed(a.Speed)
+}
+
+func (a *Aircraft) VertRateIn(u Units) int {
+	return u.VertRate(a.VertRate)
+}
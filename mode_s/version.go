@@ -0,0 +1,230 @@
+package mode_s
+
+/* ADS-B versions, from the TC 31 operational status message. The
+ * version selects the layout of the quality fields of the extended
+ * squitter messages: a field may move, change meaning or not exist from
+ * one version to the other. Decoders of these fields read them through
+ * the esLayout of the version of the aircraft, so the version branching
+ * stays in this file. */
+const (
+	ADSB_VERSION_0 = 0 /* DO-260 */
+	ADSB_VERSION_1 = 1 /* DO-260A */
+	ADSB_VERSION_2 = 2 /* DO-260B */
+)
+
+/* Quality and integrity of the ADS-B data of an aircraft, in the same
+ * model whatever its ADS-B version. -1 marks a field not received yet,
+ * or not existing in the version of the aircraft. */
+type ADSBQuality struct {
+	Version        int  `json:"version"`
+	VersionKnown   bool `json:"version_known"` /* TC 31 received, version 0 is assumed before. */
+	NICSupplementA int  `json:"nic_a"`
+	NICSupplementB int  `json:"nic_b"`
+	NICSupplementC int  `json:"nic_c"`
+	NACp           int  `json:"nac_p"`
+	NACv           int  `json:"nac_v"` /* NUCr in version 0 */
+	SIL            int  `json:"sil"`
+	SILSupplement  int  `json:"sil_supplement"` /* 0 = per hour, 1 = per sample */
+	GVA            int  `json:"gva"`
+	NICBaro        int  `json:"nic_baro"`
+	HRD            int  `json:"hrd"` /* Horizontal reference direction, 0 = true north, 1 = magnetic. */
+
+	/* Integrity of the last position, from its type code and the NIC
+	 * supplements, see positionNIC. */
+	NIC int     `json:"nic"`
+	Rc  float64 `json:"rc"` /* Containment radius, meters, 0 if unknown. */
+}
+
+/* Return the quality of an aircraft nothing was received from. */
+func newADSBQuality() ADSBQuality {
+	return ADSBQuality{
+		Version:        ADSB_VERSION_0,
+		NICSupplementA: -1,
+		NICSupplementB: -1,
+		NICSupplementC: -1,
+		NACp:           -1,
+		NACv:           -1,
+		SIL:            -1,
+		SILSupplement:  -1,
+		GVA:            -1,
+		NICBaro:        -1,
+		HRD:            -1,
+		NIC:            -1,
+	}
+}
+
+/* A field of the ME field of an extended squitter: 'n' bits starting at
+ * ME bit 'start' (1 to 56). n = 0 if the field does not exist, and reads
+ * as -1: a field that does not exist in the new version of an aircraft
+ * is reset. */
+type esField struct {
+	start, n int
+}
+
+func (f esField) get(me uint64) int {
+	if f.n == 0 {
+		return -1
+	}
+	return mbBits(me, f.start, f.n)
+}
+
+/* Version specific fields of the extended squitter messages. */
+type esLayout struct {
+	/* TC 9-18, airborne position */
+	nicSupplementB esField
+
+	/* TC 19, airborne velocity */
+	nacv esField
+
+	/* TC 31, operational status, subtype 0 (airborne) or 1 (surface) */
+	nicSupplementA esField
+	nicSupplementC esField /* surface */
+	nacp           esField
+	sil            esField
+	silSupplement  esField
+	gva            esField /* airborne */
+	nicBaro        esField /* airborne */
+	hrd            esField
+}
+
+var esLayouts = [...]esLayout{
+	ADSB_VERSION_0: {
+		nacv: esField{11, 3},
+	},
+	ADSB_VERSION_1: {
+		/* ME bit 8 of the position is the single antenna flag. */
+		nacv:           esField{11, 3},
+		nicSupplementA: esField{44, 1},
+		nacp:           esField{45, 4},
+		sil:            esField{51, 2},
+		nicBaro:        esField{53, 1},
+		hrd:            esField{54, 1},
+	},
+	ADSB_VERSION_2: {
+		nicSupplementB: esField{8, 1},
+		nacv:           esField{11, 3},
+		nicSupplementA: esField{44, 1},
+		nicSupplementC: esField{20, 1},
+		nacp:           esField{45, 4},
+		sil:            esField{51, 2},
+		silSupplement:  esField{55, 1},
+		gva:            esField{49, 2},
+		nicBaro:        esField{53, 1},
+		hrd:            esField{54, 1},
+	},
+}
+
+/* Return the layout of an ADS-B version. Versions above 2 are reserved,
+ * and read as version 2. */
+func esLayoutOf(version int) *esLayout {
+	if version < 0 || version >= len(esLayouts) {
+		version = len(esLayouts) - 1
+	}
+	return &esLayouts[version]
+}
+
+/* Update the quality from an extended squitter message. */
+func (q *ADSBQuality) update(mm *ModeSMessage) {
+	if mm.metype == 31 && (mm.mesub == 0 || mm.mesub == 1) {
+		/* The version field (ME bits 41-43) is at the same place in
+		 * every version, it is zero in version 0. */
+		q.Version = mbBits(mm.me, 41, 3)
+		q.VersionKnown = true
+	}
+
+	l := esLayoutOf(q.Version)
+	switch {
+	case mm.metype >= 5 && mm.metype <= 8:
+		q.NIC, q.Rc = q.positionNIC(mm.metype)
+	case mm.metype >= 9 && mm.metype <= 18:
+		q.NICSupplementB = l.nicSupplementB.get(mm.me)
+		q.NIC, q.Rc = q.positionNIC(mm.metype)
+	case mm.metype >= 20 && mm.metype <= 22:
+		q.NIC, q.Rc = q.positionNIC(mm.metype)
+	case mm.metype == 19 && mm.mesub >= 1 && mm.mesub <= 4:
+		q.NACv = l.nacv.get(mm.me)
+	case mm.metype == 31 && (mm.mesub == 0 || mm.mesub == 1):
+		q.NICSupplementA = l.nicSupplementA.get(mm.me)
+		q.NACp = l.nacp.get(mm.me)
+		q.SIL = l.sil.get(mm.me)
+		q.SILSupplement = l.silSupplement.get(mm.me)
+		q.HRD = l.hrd.get(mm.me)
+		if mm.mesub == 0 {
+			q.GVA = l.gva.get(mm.me)
+			q.NICBaro = l.nicBaro.get(mm.me)
+		} else {
+			q.NICSupplementC = l.nicSupplementC.get(mm.me)
+		}
+	}
+}
+
+/* Meters in a nautical mile. */
+const METERS_PER_NM = 1852.0
+
+/* Navigation integrity category and containment radius of a position
+ * message (TC 5-8 surface, 9-18 and 20-22 airborne) of the aircraft. A
+ * type code covers several NIC in version 1 and 2, told apart by the
+ * supplements, read as 0 when not received yet. Version 0 has no
+ * supplement: the NIC is the lowest of the type code, matching its NUCp.
+ * NIC 0 has an unknown radius. */
+func (q *ADSBQuality) positionNIC(metype int) (nic int, rc float64) {
+	supplement := func(v int) bool {
+		return v == 1 && q.Version != ADSB_VERSION_0
+	}
+	a, b, c := supplement(q.NICSupplementA), supplement(q.NICSupplementB), supplement(q.NICSupplementC)
+	if q.Version == ADSB_VERSION_1 {
+		/* Version 1 has a single supplement for every type code. */
+		b, c = a, false
+	}
+
+	switch metype {
+	case 5, 9, 20:
+		return 11, 7.5
+	case 6, 10, 21:
+		return 10, 25
+	case 7:
+		if a && !c {
+			return 9, 75
+		}
+		return 8, 0.1 * METERS_PER_NM
+	case 8:
+		switch {
+		case q.Version < ADSB_VERSION_2:
+			return 0, 0
+		case a && c:
+			return 7, 0.2 * METERS_PER_NM
+		case a:
+			return 6, 0.3 * METERS_PER_NM
+		case c:
+			return 6, 0.6 * METERS_PER_NM
+		}
+		return 0, 0
+	case 11:
+		if a && b {
+			return 9, 75
+		}
+		return 8, 0.1 * METERS_PER_NM
+	case 12:
+		return 7, 0.2 * METERS_PER_NM
+	case 13:
+		switch {
+		case a && b:
+			return 6, 0.6 * METERS_PER_NM
+		case b:
+			return 6, 0.3 * METERS_PER_NM
+		}
+		return 6, 0.5 * METERS_PER_NM
+	case 14:
+		return 5, 1 * METERS_PER_NM
+	case 15:
+		return 4, 2 * METERS_PER_NM
+	case 16:
+		if a && b {
+			return 3, 4 * METERS_PER_NM
+		}
+		return 2, 8 * METERS_PER_NM
+	case 17:
+		return 1, 20 * METERS_PER_NM
+	}
+	return 0, 0
+}
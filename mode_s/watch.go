@@ -0,0 +1,169 @@
+package mode_s
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* A list of aircraft, by address or callsign, see Sky.SetWatchList and
+ * Sky.SetIgnoreList. */
+type AircraftList struct {
+	addrs     map[uint32]bool
+	callsigns []string /* Upper case patterns, '*' and '?' wildcards. */
+}
+
+/* Read an aircraft list, an entry per line:
+ *
+ *   4840D6        ICAO address (6 hex digits, '~' prefix for non-ICAO)
+ *   KLM*          callsign, '*' and '?' as wildcards
+ *   flight:ABC123 callsign, for those looking like an address
+ *
+ * Empty lines and text after '#' are ignored. */
+func ParseAircraftList(r io.Reader) (*AircraftList, error) {
+	l := &AircraftList{addrs: make(map[uint32]bool)}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		entry, _, _ := strings.Cut(s.Text(), "#")
+		entry = strings.ToUpper(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+
+		if callsign, ok := strings.CutPrefix(entry, "FLIGHT:"); ok {
+			entry = strings.TrimSpace(callsign)
+		} else if addr, ok := parseListAddr(entry); ok {
+			l.addrs[addr] = true
+			continue
+		}
+		if _, err := path.Match(entry, ""); err != nil || entry == "" {
+			return nil, fmt.Errorf("list error: line %d: invalid callsign %q", n, entry)
+		}
+		l.callsigns = append(l.callsigns, entry)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("list error: %s", err.Error())
+	}
+	return l, nil
+}
+
+/* Read an aircraft list file, see ParseAircraftList. */
+func LoadAircraftList(name string) (*AircraftList, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("list error: %s", err.Error())
+	}
+	defer f.Close()
+
+	return ParseAircraftList(f)
+}
+
+func parseListAddr(s string) (uint32, bool) {
+	var flag uint32
+	if strings.HasPrefix(s, "~") {
+		s, flag = s[1:], MODES_NON_ICAO_ADDRESS
+	}
+	if len(s) != 6 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(n) | flag, true
+}
+
+/* Number of entries of the list. */
+func (l *AircraftList) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.addrs) + len(l.callsigns)
+}
+
+/* Return true if the address is listed. A nil list has no entry. */
+func (l *AircraftList) HasAddr(addr uint32) bool {
+	return l != nil && l.addrs[addr]
+}
+
+/* Return true if the address or the callsign of the aircraft is listed. */
+func (l *AircraftList) Match(a *Aircraft) bool {
+	if l == nil {
+		return false
+	}
+	if l.addrs[a.Addr] {
+		return true
+	}
+
+	flight := strings.TrimRight(a.Flight, " \x00")
+	if flight == "" {
+		return false
+	}
+	for _, pattern := range l.callsigns {
+		if ok, _ := path.Match(pattern, flight); ok {
+			return true
+		}
+	}
+	return false
+}
+
+/* Set the watched aircraft: they are marked Watched, and an
+ * EVENT_WATCHED event is raised when one appears. nil watches none. */
+func (sky *Sky) SetWatchList(l *AircraftList) {
+	sky.mux.Lock()
+	sky.watch = l
+	for _, a := range sky.aircrafts {
+		if !a.filtered {
+			sky.applyWatch(a)
+		}
+	}
+	sky.mux.Unlock()
+
+	sky.dispatchEvents()
+}
+
+/* Set the ignored aircraft, e.g. test transmitters: the messages of the
+ * listed addresses are dropped, and the aircraft of a listed callsign are
+ * hidden as the filtered ones (see SetFilter). nil ignores none. */
+func (sky *Sky) SetIgnoreList(l *AircraftList) {
+	sky.mux.Lock()
+	now := time.Now()
+	sky.ignore = l
+	sky.snapshot_mux.Lock()
+	for addr, a := range sky.aircrafts {
+		if l.HasAddr(addr) {
+			sky.leaveZones(a, now)
+			delete(sky.aircrafts, addr)
+			delete(sky.snapshots, addr)
+		} else {
+			sky.applyFilter(a)
+		}
+	}
+	sky.snapshot_mux.Unlock()
+	sky.mux.Unlock()
+
+	sky.dispatchEvents()
+}
+
+/* Mark the aircraft watched if listed, raising an event when it starts
+ * to be. Must be called with the lock held. */
+func (sky *Sky) applyWatch(a *Aircraft) {
+	watched := sky.watch.Match(a)
+	if watched == a.Watched {
+		return
+	}
+	a.Watched = watched
+	sky.touch(a)
+	if watched {
+		sky.emit(Event{
+			Type:     EVENT_WATCHED,
+			Time:     a.Seen,
+			Aircraft: a.Clone(),
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package mode_s
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* A named area, e.g. a final approach corridor, see Sky.SetZones: a
+ * circle (RadiusKm > 0) or a polygon. */
+type Zone struct {
+	Name     string       `json:"name"`
+	Lat      float64      `json:"lat,omitempty"` /* Center of a circle. */
+	Lon      float64      `json:"lon,omitempty"`
+	RadiusKm float64      `json:"radius_km,omitempty"`
+	Polygon  [][2]float64 `json:"polygon,omitempty"` /* Latitude, longitude of the vertices. */
+}
+
+/* Read zones, a zone per line:
+ *
+ *   Airport: 37.4602,126.4407 5                          circle, radius in km
+ *   Final 33L: 37.50,126.38; 37.52,126.36; 37.47,126.42  polygon, 3 vertices at least
+ *
+ * Empty lines and text after '#' are ignored. Polygons are drawn in
+ * latitude and longitude, so they must not cross the antimeridian. */
+func ParseZones(r io.Reader) ([]Zone, error) {
+	var zones []Zone
+	names := make(map[string]bool)
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		name, shape, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("zone error: line %d: expected name: shape", n)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("zone error: line %d: duplicate zone %q", n, name)
+		}
+		names[name] = true
+
+		z, err := parseZoneShape(name, shape)
+		if err != nil {
+			return nil, fmt.Errorf("zone error: line %d: %s", n, err.Error())
+		}
+		zones = append(zones, z)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("zone error: %s", err.Error())
+	}
+	return zones, nil
+}
+
+/* Read a zones file, see ParseZones. */
+func LoadZones(name string) ([]Zone, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("zone error: %s", err.Error())
+	}
+	defer f.Close()
+
+	return ParseZones(f)
+}
+
+/* Circle "lat,lon radius" or polygon "lat,lon; lat,lon; lat,lon". */
+func parseZoneShape(name, shape string) (Zone, error) {
+	z := Zone{Name: name}
+	if !strings.Contains(shape, ";") {
+		fields := strings.Fields(shape)
+		if len(fields) != 2 {
+			return z, fmt.Errorf("invalid circle %q (lat,lon radius_km)", strings.TrimSpace(shape))
+		}
+		lat, lon, err := parseZonePoint(fields[0])
+		if err != nil {
+			return z, err
+		}
+		radius, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || radius <= 0 {
+			return z, fmt.Errorf("invalid radius %q", fields[1])
+		}
+		z.Lat, z.Lon, z.RadiusKm = lat, lon, radius
+		return z, nil
+	}
+
+	for _, vertex := range strings.Split(shape, ";") {
+		if vertex = strings.TrimSpace(vertex); vertex == "" {
+			continue
+		}
+		lat, lon, err := parseZonePoint(vertex)
+		if err != nil {
+			return z, err
+		}
+		z.Polygon = append(z.Polygon, [2]float64{lat, lon})
+	}
+	if len(z.Polygon) < 3 {
+		return z, fmt.Errorf("polygon %q with less than 3 vertices", name)
+	}
+	return z, nil
+}
+
+func parseZonePoint(s string) (float64, float64, error) {
+	lat, lon, ok := strings.Cut(s, ",")
+	la, err1 := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	lo, err2 := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	if !ok || err1 != nil || err2 != nil || math.Abs(la) > 90 || math.Abs(lo) > 180 {
+		return 0, 0, fmt.Errorf("invalid position %q (lat,lon)", strings.TrimSpace(s))
+	}
+	return la, lo, nil
+}
+
+/* Return true if the position is in the zone (or on its edge). */
+func (z Zone) Contains(lat, lon float64) bool {
+	if z.RadiusKm > 0 {
+		return greatCircleKm(z.Lat, z.Lon, lat, lon) <= z.RadiusKm
+	}
+
+	/* Even-odd rule: count the edges crossed by a ray going east. */
+	inside := false
+	for i, j := 0, len(z.Polygon)-1; i < len(z.Polygon); j, i = i, i+1 {
+		a, b := z.Polygon[i], z.Polygon[j]
+		if (a[0] > lat) != (b[0] > lat) &&
+			lon < (b[1]-a[1])*(lat-a[0])/(b[0]-a[0])+a[1] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+/* Set the zones: the aircraft record the zones they are in (Zones), and
+ * EVENT_ZONE_ENTER and EVENT_ZONE_EXIT events are raised when they enter
+ * and leave one. The aircraft already tracked are checked again. */
+func (sky *Sky) SetZones(zones []Zone) {
+	sky.mux.Lock()
+	sky.zones = zones
+	for _, a := range sky.aircrafts {
+		if !a.filtered {
+			sky.applyZones(a, a.Seen)
+		}
+	}
+	sky.mux.Unlock()
+
+	sky.dispatchEvents()
+}
+
+/* Return the zones, see SetZones. */
+func (sky *Sky) Zones() []Zone {
+	sky.mux.RLock()
+	defer sky.mux.RUnlock()
+
+	return sky.zones
+}
+
+/* Update the zones of the aircraft from its position, raising an event
+ * for every zone entered or left. Must be called with the lock held. */
+func (sky *Sky) applyZones(a *Aircraft, t time.Time) {
+	var zones []string
+	if a.Trail.Len() > 0 {
+		for _, z := range sky.zones {
+			if z.Contains(a.Latitude, a.Longitude) {
+				zones = append(zones, z.Name)
+			}
+		}
+	}
+
+	/* A new slice every time: the clones share it. */
+	old := a.Zones
+	a.Zones = zones
+	changed := len(zones) != len(old)
+	for _, name := range zones {
+		if !containsString(old, name) {
+			changed = true
+			sky.emit(Event{Type: EVENT_ZONE_ENTER, Time: t, Aircraft: a.Clone(), Zone: name})
+		}
+	}
+	for _, name := range old {
+		if !containsString(zones, name) {
+			sky.emit(Event{Type: EVENT_ZONE_EXIT, Time: t, Aircraft: a.Clone(), Zone: name})
+		}
+	}
+	if changed {
+		sky.touch(a)
+	}
+}
+
+/* Leave the zones the aircraft is in, when it is removed. Must be called
+ * with the lock held. */
+func (sky *Sky) leaveZones(a *Aircraft, t time.Time) {
+	for _, name := range a.Zones {
+		sky.emit(Event{Type: EVENT_ZONE_EXIT, Time: t, Aircraft: a.Clone(), Zone: name})
+	}
+	a.Zones = nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
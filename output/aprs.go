@@ -0,0 +1,228 @@
+package output
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go1090/mode_s"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default port of the APRS-IS servers accepting packets.
+const aprsDefaultPort = "14580"
+
+// Delay before connecting again to the APRS-IS server after an error.
+const aprsRetryDelay = 30 * time.Second
+
+// APRSOptions configure an APRSSink.
+type APRSOptions struct {
+	Server   string        // host[:port], e.g. "rotate.aprs2.net".
+	Callsign string        // Login and source of the packets, e.g. "N0CALL-10".
+	Passcode string        // APRS-IS passcode of the callsign.
+	Interval time.Duration // Minimum interval between objects of one aircraft.
+	Rate     int           // Maximum packets per minute, all aircraft together.
+}
+
+// APRSSink publishes the aircraft with a position as APRS objects to an
+// APRS-IS server, for aprs.fi style maps. Every aircraft is sent at most
+// once per interval, and packets beyond the rate limit are dropped, as
+// APRS-IS is a shared network.
+type APRSSink struct {
+	opts    APRSOptions
+	conn    net.Conn
+	dialed  time.Time
+	sent    map[uint32]time.Time
+	stale   time.Duration
+	window  time.Time // Start of the current minute of the rate limit.
+	packets int       // Sent in the current minute.
+	privacy *Privacy
+
+	mux sync.Mutex
+}
+
+// NewAPRSSink function.
+// The server must be reachable at start, so a wrong address is reported.
+func NewAPRSSink(opts APRSOptions) (*APRSSink, error) {
+	if opts.Callsign == "" || opts.Passcode == "" {
+		return nil, errors.New("APRS error: a callsign and its passcode are required")
+	}
+	opts.Callsign = strings.ToUpper(opts.Callsign)
+
+	s := &APRSSink{
+		opts:  opts,
+		sent:  make(map[uint32]time.Time),
+		stale: time.Duration(mode_s.MODES_AIRCRAFT_TTL) * time.Second,
+	}
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Connect and log in.
+func (s *APRSSink) dial() error {
+	s.dialed = time.Now()
+
+	addr := s.opts.Server
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, aprsDefaultPort)
+	}
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("APRS error: %s", err.Error())
+	}
+
+	/* The server answers with a "# logresp" line; whether the passcode
+	 * is verified is not checked, unverified packets are just dropped
+	 * by the server. */
+	login := fmt.Sprintf("user %s pass %s vers go1090 1.0\r\n", s.opts.Callsign, s.opts.Passcode)
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := io.WriteString(conn, login); err != nil {
+		conn.Close()
+		return fmt.Errorf("APRS error: %s", err.Error())
+	}
+
+	/* Discard what the server sends (banner, keep alive comments). */
+	go io.Copy(ioutil.Discard, bufio.NewReader(conn))
+
+	s.conn = conn
+	return nil
+}
+
+// Update function.
+func (s *APRSSink) Update(ac *mode_s.Aircraft) error {
+	if ac.Trail.Len() == 0 {
+		return nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if ac = s.privacy.Apply(ac); ac == nil {
+		return nil
+	}
+
+	now := time.Now()
+	if last, ok := s.sent[ac.Addr]; ok && now.Sub(last) < s.opts.Interval {
+		return nil
+	}
+	if now.Sub(s.window) >= time.Minute {
+		s.window, s.packets = now, 0
+	}
+	if s.opts.Rate > 0 && s.packets >= s.opts.Rate {
+		return nil
+	}
+
+	if s.conn == nil {
+		if now.Sub(s.dialed) < aprsRetryDelay {
+			return errors.New("APRS error: not connected")
+		}
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+
+	packet := fmt.Sprintf("%s>APRS,TCPIP*:%s\r\n", s.opts.Callsign, aprsObject(ac, now))
+	s.conn.SetWriteDeadline(now.Add(10 * time.Second))
+	if _, err := io.WriteString(s.conn, packet); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("APRS error: %s", err.Error())
+	}
+
+	s.packets++
+	s.sent[ac.Addr] = now
+	s.expire(now)
+	return nil
+}
+
+// APRS object report of an aircraft:
+// ;NAME_____*DDHHMMzDDMM.hhN/DDDMM.hhE^CSE/SPD/A=AAAAAA comment
+func aprsObject(ac *mode_s.Aircraft, now time.Time) string {
+	name := strings.TrimRight(ac.Flight, " \x00")
+	if name == "" {
+		name = ac.HexAddr
+	}
+	if len(name) > 9 {
+		name = name[:9]
+	}
+
+	symbol := byte('^') /* large aircraft */
+	switch ac.Category {
+	case "A1", "B1", "B4":
+		symbol = '\'' /* small aircraft */
+	case "A7":
+		symbol = 'X' /* helicopter */
+	case "B2":
+		symbol = 'O' /* balloon */
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, ";%-9s*%sz", name, now.UTC().Format("021504"))
+	b.WriteString(aprsCoordinate(ac.Latitude, 2, "N", "S"))
+	b.WriteByte('/')
+	b.WriteString(aprsCoordinate(ac.Longitude, 3, "E", "W"))
+	b.WriteByte(symbol)
+	if ac.Speed != 0 {
+		course := ac.Track % 360
+		if course == 0 {
+			course = 360 /* 000 means unknown */
+		}
+		fmt.Fprintf(&b, "%03d/%03d", course, ac.Speed)
+	}
+	if ac.Altitude > 0 {
+		fmt.Fprintf(&b, "/A=%06d", ac.Altitude)
+	}
+	fmt.Fprintf(&b, " ICAO %s", ac.HexAddr)
+	if ac.Squawk != 0 {
+		fmt.Fprintf(&b, " sq %04d", ac.Squawk)
+	}
+	return b.String()
+}
+
+// Degrees and decimal minutes, e.g. "4903.50N" or "07201.75W".
+func aprsCoordinate(v float64, degDigits int, pos, neg string) string {
+	hemisphere := pos
+	if v < 0 {
+		hemisphere, v = neg, -v
+	}
+	hundredths := int(math.Round(v * 60 * 100)) /* of minutes */
+	deg, minutes := hundredths/6000, float64(hundredths%6000)/100
+	return fmt.Sprintf("%0*d%05.2f%s", degDigits, deg, minutes, hemisphere)
+}
+
+// Forget aircraft not sent for a while.
+func (s *APRSSink) expire(now time.Time) {
+	for addr, last := range s.sent {
+		if now.Sub(last) > s.stale+s.opts.Interval {
+			delete(s.sent, addr)
+		}
+	}
+}
+
+// SetPrivacy hides the positions and the blocked aircraft.
+func (s *APRSSink) SetPrivacy(p *Privacy) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.privacy = p
+}
+
+// Close function.
+func (s *APRSSink) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,216 @@
+package output
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Compression of the rotated archive files.
+const (
+	ArchiveCompressNone = ""
+	ArchiveCompressGzip = "gzip"
+)
+
+// Suffix of a rotated archive file: the time the file was started.
+const archiveTimeFormat = "20060102T150405.000"
+
+// ArchivePolicy selects when an archive file is rotated, whether the
+// rotated files are compressed and when they are deleted.
+type ArchivePolicy struct {
+	Rotate   time.Duration // Start a new file after this long, 0 = never.
+	Compress string        // ArchiveCompressNone or ArchiveCompressGzip.
+	MaxAge   time.Duration // Delete the rotated files older than this, 0 = never.
+}
+
+// ParseArchiveCompression checks the name of a compression.
+func ParseArchiveCompression(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return ArchiveCompressNone, nil
+	case "gzip", "gz":
+		return ArchiveCompressGzip, nil
+	}
+	return "", fmt.Errorf("archive error: unsupported compression %q (none, gzip)", name)
+}
+
+// ArchiveFile is an append-only file, like a JSON lines log, rotated
+// according to an ArchivePolicy. The current file is always at path; the
+// rotated ones are named path.<start time>, with ".gz" when compressed.
+type ArchiveFile struct {
+	path    string
+	policy  ArchivePolicy
+	file    *os.File
+	started time.Time
+	header  []byte // Written at the start of every file.
+
+	pending sync.WaitGroup // Compressions in progress.
+	mux     sync.Mutex
+}
+
+// OpenArchive function.
+func OpenArchive(path string, policy ArchivePolicy) (*ArchiveFile, error) {
+	compress, err := ParseArchiveCompression(policy.Compress)
+	if err != nil {
+		return nil, err
+	}
+	policy.Compress = compress
+
+	a := &ArchiveFile{path: path, policy: policy}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	a.prune()
+	return a, nil
+}
+
+func (a *ArchiveFile) open() error {
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("archive error: %s", err.Error())
+	}
+	a.file = f
+	a.started = time.Now()
+	return a.writeHeader()
+}
+
+// SetHeader sets the first line(s) of every file, like the column names of
+// a CSV file. It is written now if the current file is empty.
+func (a *ArchiveFile) SetHeader(header []byte) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	a.header = header
+	if a.file == nil {
+		return os.ErrClosed
+	}
+	return a.writeHeader()
+}
+
+// Write the header to the current file if it is empty.
+func (a *ArchiveFile) writeHeader() error {
+	if len(a.header) == 0 {
+		return nil
+	}
+	info, err := a.file.Stat()
+	if err != nil {
+		return fmt.Errorf("archive error: %s", err.Error())
+	}
+	if info.Size() == 0 {
+		if _, err := a.file.Write(a.header); err != nil {
+			return fmt.Errorf("archive error: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// Write appends to the current file, rotating it first if it is due.
+func (a *ArchiveFile) Write(p []byte) (int, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if a.file == nil {
+		return 0, os.ErrClosed
+	}
+	if a.policy.Rotate > 0 && time.Since(a.started) >= a.policy.Rotate {
+		if err := a.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	return a.file.Write(p)
+}
+
+// Close the current file, waiting for the compressions in progress.
+func (a *ArchiveFile) Close() error {
+	a.mux.Lock()
+	var err error
+	if a.file != nil {
+		err = a.file.Close()
+		a.file = nil
+	}
+	a.mux.Unlock()
+
+	a.pending.Wait()
+	return err
+}
+
+// Rename the current file and start a new one. Compression and deletion
+// of the old files run in the background.
+func (a *ArchiveFile) rotate() error {
+	a.file.Close()
+	a.file = nil
+
+	rotated := a.path + "." + a.started.Format(archiveTimeFormat)
+	if err := os.Rename(a.path, rotated); err != nil {
+		a.open() /* keep appending to the current file */
+		return fmt.Errorf("archive error: %s", err.Error())
+	}
+	if err := a.open(); err != nil {
+		return err
+	}
+
+	a.pending.Add(1)
+	go func() {
+		defer a.pending.Done()
+
+		if a.policy.Compress == ArchiveCompressGzip {
+			compressFile(rotated)
+		}
+		a.prune()
+	}()
+	return nil
+}
+
+// Delete the rotated files started more than the maximum age ago.
+func (a *ArchiveFile) prune() {
+	if a.policy.MaxAge <= 0 {
+		return
+	}
+
+	matches, _ := filepath.Glob(a.path + ".*")
+	for _, name := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, a.path+"."), ".gz")
+		started, err := time.ParseInLocation(archiveTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue /* not one of ours */
+		}
+		if time.Since(started) > a.policy.MaxAge {
+			os.Remove(name)
+		}
+	}
+}
+
+// Compress a file to name.gz, removing the original on success.
+func compressFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(name + ".gz")
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+
+	in.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,91 @@
+package output
+
+import (
+	"go1090/mode_s"
+	"go1090/rtl_adsb"
+	"math"
+	"time"
+)
+
+// Beast binary format, as written by dump1090 and readsb on port 30005:
+// every frame is <0x1a> <type> <6 bytes timestamp> <1 byte signal>
+// <message>, type '1' for Mode A/C (2 bytes), '2' for short (7 bytes)
+// and '3' for long (14 bytes) Mode S messages. 0x1a bytes after the type
+// are doubled. The timestamp is the 12 MHz MLAT counter, big endian.
+const beastEscape = 0x1a
+
+const (
+	beastModeAC     = '1'
+	beastModeSShort = '2'
+	beastModeSLong  = '3'
+)
+
+// AppendBeast appends the Beast frame of a message to b. signal is the
+// signal level, 0 if unknown.
+func AppendBeast(b []byte, msg []byte, timestamp uint64, signal byte) []byte {
+	var kind byte
+	switch len(msg) {
+	case 2:
+		kind = beastModeAC
+	case 7:
+		kind = beastModeSShort
+	case 14:
+		kind = beastModeSLong
+	default:
+		return b
+	}
+
+	b = append(b, beastEscape, kind)
+	for shift := uint(40); ; shift -= 8 {
+		b = appendBeastByte(b, byte(timestamp>>shift))
+		if shift == 0 {
+			break
+		}
+	}
+	b = appendBeastByte(b, signal)
+	for _, c := range msg {
+		b = appendBeastByte(b, c)
+	}
+	return b
+}
+
+func appendBeastByte(b []byte, c byte) []byte {
+	if c == beastEscape {
+		b = append(b, beastEscape)
+	}
+	return append(b, c)
+}
+
+// Beast frame of a decoded message, nil if it has a bad CRC: aggregators
+// only want messages they can trust.
+func beastFrame(mm *mode_s.ModeSMessage) []byte {
+	return beastFrameAt(mm, mm.Timestamp())
+}
+
+// Timestamp of a message in the Beast and AVR outputs: the MLAT timestamp
+// of its source or, for sources without one (rtl_adsb), its reception
+// time counted in 12 MHz ticks since the Unix epoch, modulo 2^48. 0 if
+// neither is known.
+func frameTimestamp(mm *mode_s.ModeSMessage) uint64 {
+	if ts := mm.Timestamp(); ts != 0 {
+		return ts
+	}
+	received := mm.Provenance().Received
+	if received.IsZero() {
+		return 0
+	}
+	us := uint64(received.UnixNano() / int64(time.Microsecond))
+	return us * uint64(rtl_adsb.MLATClockRate/1e6) & (1<<48 - 1)
+}
+
+// Beast frame of a decoded message with the given timestamp.
+func beastFrameAt(mm *mode_s.ModeSMessage, timestamp uint64) []byte {
+	if mm.DF() != mode_s.MODES_AC_MSGTYPE && !mm.CRCOk() {
+		return nil
+	}
+	var signal byte
+	if level, ok := mm.SignalLevel(); ok {
+		signal = byte(math.Round(math.Min(1, math.Sqrt(level)) * 255))
+	}
+	return AppendBeast(nil, mm.Bytes(), timestamp, signal)
+}
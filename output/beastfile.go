@@ -0,0 +1,67 @@
+package output
+
+import (
+	"fmt"
+	"go1090/mode_s"
+	"sync"
+)
+
+// BeastFile appends the decoded frames to a file in the Beast binary
+// format, the stream readsb and dump1090 send on port 30005: a capture is
+// replayed by sending it to their Beast input port (e.g. nc host 30004 <
+// capture.bin), or read by any tool taking readsb's Beast output. Frames
+// with a bad CRC are not written, as for the feeders.
+//
+// Frames are stamped as by frameTimestamp, keeping their spacing in the
+// capture.
+type BeastFile struct {
+	file *ArchiveFile
+	err  error // Write error, nothing is written after.
+
+	mux sync.Mutex
+}
+
+// NewBeastFile function.
+// Frames are appended to an existing file, which is rotated according to
+// policy.
+func NewBeastFile(path string, policy ArchivePolicy) (*BeastFile, error) {
+	f, err := OpenArchive(path, policy)
+	if err != nil {
+		return nil, err
+	}
+	return &BeastFile{file: f}, nil
+}
+
+// Forward appends a decoded frame. After a write error the file is closed
+// and the frames dropped: the error is returned once.
+func (b *BeastFile) Forward(mm *mode_s.ModeSMessage) error {
+	data := beastFrameAt(mm, frameTimestamp(mm))
+	if data == nil {
+		return nil
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.err != nil {
+		return nil
+	}
+	if _, err := b.file.Write(data); err != nil {
+		b.err = fmt.Errorf("beast file error: %s", err.Error())
+		b.file.Close()
+		return b.err
+	}
+	return nil
+}
+
+// Close function.
+func (b *BeastFile) Close() error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.err != nil {
+		return nil
+	}
+	b.err = fmt.Errorf("beast file error: closed")
+	return b.file.Close()
+}
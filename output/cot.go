@@ -0,0 +1,253 @@
+package output
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"go1090/mode_s"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	feetToMeters = 0.3048
+	knotsToMps   = 0.514444
+)
+
+// Cursor-on-Target event, see MIL-STD-2525 / CoT base schema.
+type cotEvent struct {
+	XMLName xml.Name  `xml:"event"`
+	Version string    `xml:"version,attr"`
+	UID     string    `xml:"uid,attr"`
+	Type    string    `xml:"type,attr"`
+	How     string    `xml:"how,attr"`
+	Time    string    `xml:"time,attr"`
+	Start   string    `xml:"start,attr"`
+	Stale   string    `xml:"stale,attr"`
+	Point   cotPoint  `xml:"point"`
+	Detail  cotDetail `xml:"detail"`
+}
+
+type cotPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+	Hae float64 `xml:"hae,attr"`
+	Ce  float64 `xml:"ce,attr"`
+	Le  float64 `xml:"le,attr"`
+}
+
+type cotDetail struct {
+	Contact cotContact `xml:"contact"`
+	Track   cotTrack   `xml:"track"`
+	Remarks string     `xml:"remarks"`
+}
+
+type cotContact struct {
+	Callsign string `xml:"callsign,attr"`
+}
+
+type cotTrack struct {
+	Course float64 `xml:"course,attr"`
+	Speed  float64 `xml:"speed,attr"`
+}
+
+// Delay before connecting again to a TAK server after an error.
+const cotRetryDelay = 5 * time.Second
+
+// CoTSink sends a Cursor-on-Target event for every aircraft with a known
+// position, at most once per interval for each aircraft: over UDP
+// (unicast or multicast, e.g. to ATAK devices), or over TCP to a TAK
+// server, connecting again after an error.
+type CoTSink struct {
+	network  string // "udp" or "tcp"
+	addr     string
+	conn     net.Conn // nil while a TCP connection is lost
+	dialed   time.Time
+	interval time.Duration
+	stale    time.Duration
+	sent     map[uint32]time.Time
+	privacy  *Privacy
+
+	mux sync.Mutex
+}
+
+// NewCoTSink function.
+// addr is a UDP host:port, e.g. the SA multicast group "239.2.3.1:6969",
+// or a TAK server as "tcp://host:port", e.g. "tcp://takserver:8087".
+// "udp://" may prefix UDP addresses.
+func NewCoTSink(addr string, interval time.Duration) (*CoTSink, error) {
+	network := "udp"
+	if i := strings.Index(addr, "://"); i >= 0 {
+		network, addr = addr[:i], addr[i+3:]
+	}
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("CoT error: unsupported protocol %q (udp or tcp)", network)
+	}
+
+	s := &CoTSink{
+		network:  network,
+		addr:     addr,
+		interval: interval,
+		stale:    time.Duration(mode_s.MODES_AIRCRAFT_TTL) * time.Second,
+		sent:     make(map[uint32]time.Time),
+	}
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *CoTSink) dial() error {
+	s.dialed = time.Now()
+	conn, err := net.DialTimeout(s.network, s.addr, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("CoT error: %s", err.Error())
+	}
+	s.conn = conn
+	return nil
+}
+
+// Update function.
+func (s *CoTSink) Update(ac *mode_s.Aircraft) error {
+	if ac.Latitude == 0 && ac.Longitude == 0 {
+		return nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if ac = s.privacy.Apply(ac); ac == nil {
+		return nil
+	}
+
+	now := time.Now()
+	if last, ok := s.sent[ac.Addr]; ok && now.Sub(last) < s.interval {
+		return nil
+	}
+
+	if s.conn == nil {
+		if now.Sub(s.dialed) < cotRetryDelay {
+			return errors.New("CoT error: not connected")
+		}
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+
+	data, err := marshalCoT(ac, now, s.stale)
+	if err != nil {
+		return err
+	}
+
+	s.conn.SetWriteDeadline(now.Add(10 * time.Second))
+	if _, err := s.conn.Write(data); err != nil {
+		if s.network == "tcp" {
+			s.conn.Close()
+			s.conn = nil
+		}
+		return fmt.Errorf("CoT error: %s", err.Error())
+	}
+
+	s.sent[ac.Addr] = now
+	s.expire(now)
+	return nil
+}
+
+// Forget aircraft not sent for a while.
+func (s *CoTSink) expire(now time.Time) {
+	for addr, last := range s.sent {
+		if now.Sub(last) > s.stale {
+			delete(s.sent, addr)
+		}
+	}
+}
+
+// SetPrivacy hides the positions and the blocked aircraft.
+func (s *CoTSink) SetPrivacy(p *Privacy) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.privacy = p
+}
+
+// Close function.
+func (s *CoTSink) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func marshalCoT(ac *mode_s.Aircraft, now time.Time, stale time.Duration) ([]byte, error) {
+	callsign := strings.TrimSpace(strings.Trim(ac.Flight, "\x00"))
+	if callsign == "" {
+		callsign = ac.HexAddr
+	}
+
+	ev := cotEvent{
+		Version: "2.0",
+		UID:     "ICAO-" + ac.HexAddr,
+		Type:    cotType(ac),
+		How:     "m-g",
+		Time:    now.UTC().Format(time.RFC3339),
+		Start:   now.UTC().Format(time.RFC3339),
+		Stale:   now.Add(stale).UTC().Format(time.RFC3339),
+		Point: cotPoint{
+			Lat: ac.Latitude,
+			Lon: ac.Longitude,
+			Hae: float64(ac.Altitude) * feetToMeters,
+			Ce:  9999999.0,
+			Le:  9999999.0,
+		},
+		Detail: cotDetail{
+			Contact: cotContact{Callsign: callsign},
+			Track: cotTrack{
+				Course: float64(ac.Track),
+				Speed:  float64(ac.Speed) * knotsToMps,
+			},
+			Remarks: cotRemarks(ac),
+		},
+	}
+
+	data, err := xml.Marshal(&ev)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// CoT type of an aircraft, from its ADS-B emitter category: neutral
+// civilian fixed wing unless known otherwise.
+func cotType(ac *mode_s.Aircraft) string {
+	switch ac.Category {
+	case "A7":
+		return "a-n-A-C-H" /* rotorcraft */
+	case "B2":
+		return "a-n-A-C-L" /* lighter than air */
+	case "B6":
+		return "a-n-A-C-F-q" /* unmanned */
+	case "C1", "C2":
+		return "a-n-G-E-V" /* surface vehicle */
+	case "C3", "C4", "C5":
+		return "a-n-G-I" /* obstacle */
+	}
+	return "a-n-A-C-F"
+}
+
+func cotRemarks(ac *mode_s.Aircraft) string {
+	r := fmt.Sprintf("ICAO %s, %d ft", ac.HexAddr, ac.Altitude)
+	if ac.Category != "" {
+		r += ", category " + ac.Category
+	}
+	if ac.Squawk != 0 {
+		r += fmt.Sprintf(", squawk %04d", ac.Squawk)
+	}
+	return r
+}
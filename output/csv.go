@@ -0,0 +1,106 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"go1090/mode_s"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Columns of the CSV flight log.
+var csvHeader = []string{"time", "icao", "callsign", "lat", "lon", "alt", "gs", "track", "vr", "squawk", "nic"}
+
+// CSVSink appends a row to a CSV file for every new position of an
+// aircraft, for spreadsheets and GIS tools. Unknown values are empty.
+type CSVSink struct {
+	file  *ArchiveFile
+	csv   *csv.Writer
+	saved map[uint32]time.Time // Time of the last written position.
+
+	mux sync.Mutex
+}
+
+// NewCSVSink function.
+// The file is rotated according to policy, every file starting with the
+// column names.
+func NewCSVSink(path string, policy ArchivePolicy) (*CSVSink, error) {
+	f, err := OpenArchive(path, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	var header bytes.Buffer
+	w := csv.NewWriter(&header)
+	w.Write(csvHeader)
+	w.Flush()
+	if err := f.SetHeader(header.Bytes()); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &CSVSink{
+		file:  f,
+		csv:   csv.NewWriter(f),
+		saved: make(map[uint32]time.Time),
+	}, nil
+}
+
+// Update writes the position of the aircraft if it is new.
+func (s *CSVSink) Update(ac *mode_s.Aircraft) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	p, ok := ac.Trail.Last()
+	if !ok || !p.Time.After(s.saved[ac.Addr]) {
+		return nil
+	}
+	s.saved[ac.Addr] = p.Time
+
+	s.csv.Write(csvRow(ac, p))
+	s.csv.Flush()
+	if err := s.csv.Error(); err != nil {
+		return fmt.Errorf("CSV error: %s", err.Error())
+	}
+	return nil
+}
+
+// Row of a position.
+func csvRow(ac *mode_s.Aircraft, p mode_s.TrailPoint) []string {
+	optional := func(v int, known bool) string {
+		if !known {
+			return ""
+		}
+		return strconv.Itoa(v)
+	}
+
+	squawk := ""
+	if ac.Squawk != 0 {
+		squawk = fmt.Sprintf("%04d", ac.Squawk)
+	}
+
+	return []string{
+		p.Time.UTC().Format("2006-01-02T15:04:05.000Z"),
+		strings.ToLower(ac.HexAddr),
+		strings.TrimRight(ac.Flight, " \x00"),
+		strconv.FormatFloat(p.Latitude, 'f', -1, 64),
+		strconv.FormatFloat(p.Longitude, 'f', -1, 64),
+		optional(p.Altitude, p.Altitude != 0),
+		optional(p.Speed, p.Speed != 0),
+		optional(ac.Track, ac.Speed != 0),
+		optional(ac.VertRate, ac.VertRateValid),
+		squawk,
+		optional(p.NIC, p.NIC >= 0),
+	}
+}
+
+// Close function.
+func (s *CSVSink) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.file.Close()
+}
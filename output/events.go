@@ -0,0 +1,82 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"go1090/mode_s"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventLog appends aircraft events to a file, one JSON object per line.
+type EventLog struct {
+	file *ArchiveFile
+	enc  *json.Encoder
+
+	mux sync.Mutex
+}
+
+type eventJSON struct {
+	Type    string          `json:"type"`
+	Time    time.Time       `json:"time"`
+	Hex     string          `json:"hex"`
+	Flight  string          `json:"flight,omitempty"`
+	Anomaly *mode_s.Anomaly `json:"anomaly,omitempty"`
+	Squawk  string          `json:"squawk,omitempty"`
+
+	PreviousFlight string         `json:"previous_flight,omitempty"`
+	RA             *mode_s.ACASRA `json:"ra,omitempty"` /* of an acas_ra event */
+	Zone           string         `json:"zone,omitempty"`
+
+	Provenance mode_s.Provenance `json:"provenance"` /* of the message raising the event */
+}
+
+// NewEventLog function.
+// The file is rotated according to policy.
+func NewEventLog(path string, policy ArchivePolicy) (*EventLog, error) {
+	f, err := OpenArchive(path, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EventLog{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Handle writes an event; use it as a mode_s.EventHandler.
+func (l *EventLog) Handle(e mode_s.Event) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	var squawk string
+	if e.Squawk != 0 {
+		squawk = fmt.Sprintf("%04d", e.Squawk)
+	}
+	var ra *mode_s.ACASRA
+	if e.Type == mode_s.EVENT_ACAS_RA {
+		ra = &e.Aircraft.LastRA
+	}
+
+	l.enc.Encode(eventJSON{
+		Type:    e.Type,
+		Time:    e.Time,
+		Hex:     strings.ToLower(e.Aircraft.HexAddr),
+		Flight:  strings.TrimRight(e.Aircraft.Flight, " \x00"),
+		Anomaly: e.Anomaly,
+		Squawk:  squawk,
+
+		PreviousFlight: e.PreviousFlight,
+		RA:             ra,
+		Zone:           e.Zone,
+
+		Provenance: e.Aircraft.Provenance,
+	})
+}
+
+// Close function.
+func (l *EventLog) Close() error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	return l.file.Close()
+}
@@ -0,0 +1,165 @@
+package output
+
+import (
+	"go1090/mode_s"
+	"net"
+	"sync"
+	"time"
+)
+
+// Delays before connecting again to an aggregator: the delay doubles at
+// every failure, up to feederMaxDelay.
+const (
+	feederMinDelay = time.Second
+	feederMaxDelay = time.Minute
+)
+
+// Number of frames queued for an aggregator before frames are dropped.
+const feederQueueLen = 1024
+
+// BeastFeeder connects to a remote aggregator accepting Beast input (e.g.
+// the feed port of ADSBExchange or adsb.fi) and sends it the decoded
+// frames, connecting again whenever the connection is lost.
+type BeastFeeder struct {
+	addr      string
+	queue     chan []byte
+	stop      chan struct{}
+	connected bool
+	sent      int64
+	dropped   int64
+	err       error // Last connection error.
+	privacy   *Privacy
+
+	mux sync.Mutex
+}
+
+// FeederStatus describes the connection of a feeder.
+type FeederStatus struct {
+	Addr      string `json:"addr"`
+	Connected bool   `json:"connected"`
+	Sent      int64  `json:"sent"`    // Frames.
+	Dropped   int64  `json:"dropped"` // Frames, queue full.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// NewBeastFeeder function.
+// addr is the host:port of the aggregator. The connection is opened in
+// the background, frames are queued meanwhile.
+func NewBeastFeeder(addr string) *BeastFeeder {
+	f := &BeastFeeder{
+		addr:  addr,
+		queue: make(chan []byte, feederQueueLen),
+		stop:  make(chan struct{}),
+	}
+	go f.run()
+	return f
+}
+
+// SetPrivacy drops the frames of blocked aircraft.
+func (f *BeastFeeder) SetPrivacy(p *Privacy) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.privacy = p
+}
+
+// Forward a decoded frame to the aggregator. Frames with a bad CRC are
+// not sent. When the aggregator is slow or unreachable, frames are dropped
+// rather than blocking the decoder.
+func (f *BeastFeeder) Forward(mm *mode_s.ModeSMessage) {
+	data := beastFrame(mm)
+	if data == nil {
+		return
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if f.privacy.Blocked(mm.ICAO()) {
+		return
+	}
+	select {
+	case f.queue <- data:
+	default:
+		f.dropped++
+	}
+}
+
+// Status function.
+func (f *BeastFeeder) Status() FeederStatus {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	s := FeederStatus{
+		Addr:      f.addr,
+		Connected: f.connected,
+		Sent:      f.sent,
+		Dropped:   f.dropped,
+	}
+	if f.err != nil {
+		s.LastError = f.err.Error()
+	}
+	return s
+}
+
+// Connect and send the queued frames until Close.
+func (f *BeastFeeder) run() {
+	delay := feederMinDelay
+
+	for {
+		conn, err := net.DialTimeout("tcp", f.addr, 10*time.Second)
+		if err == nil {
+			delay = feederMinDelay
+			f.setConnected(true, nil)
+			err = f.send(conn)
+			conn.Close()
+			if err == nil {
+				return /* closed */
+			}
+		}
+		f.setConnected(false, err)
+
+		select {
+		case <-time.After(delay):
+		case <-f.stop:
+			return
+		}
+		if delay *= 2; delay > feederMaxDelay {
+			delay = feederMaxDelay
+		}
+	}
+}
+
+// Write the queued frames to conn, nil when the feeder is closed.
+func (f *BeastFeeder) send(conn net.Conn) error {
+	for {
+		select {
+		case data := <-f.queue:
+			conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+			if _, err := conn.Write(data); err != nil {
+				return err
+			}
+			f.mux.Lock()
+			f.sent++
+			f.mux.Unlock()
+		case <-f.stop:
+			return nil
+		}
+	}
+}
+
+func (f *BeastFeeder) setConnected(connected bool, err error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.connected = connected
+	if err != nil {
+		f.err = err
+	}
+}
+
+// Close disconnects from the aggregator.
+func (f *BeastFeeder) Close() error {
+	close(f.stop)
+	return nil
+}
@@ -0,0 +1,167 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"go1090/mode_s"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStore is a Store keeping the aircraft state in a directory:
+// aircraft.json holds the last state of every aircraft (written on Flush and
+// Close), with their trails and CPR messages,
+// positions.jsonl the positions, one JSON object per line, rotated
+// according to an ArchivePolicy.
+type FileStore struct {
+	dir       string
+	aircraft  map[uint32]*mode_s.Aircraft
+	positions *ArchiveFile
+	enc       *json.Encoder
+	maxAge    time.Duration // Aircraft not seen for longer are not kept, 0 = all.
+
+	mux sync.Mutex
+}
+
+type positionJSON struct {
+	Hex       string  `json:"hex"`
+	Flight    string  `json:"flight,omitempty"`
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+	Altitude  int     `json:"alt_baro"`
+	Time      float64 `json:"time"` /* unix */
+
+	Provenance mode_s.Provenance `json:"provenance"`
+}
+
+// Aircraft as stored in aircraft.json: every exported field, unlike the
+// schema of Aircraft.MarshalJSON, so that LoadState restores the state.
+type storedAircraft mode_s.Aircraft
+
+// NewFileStore function.
+func NewFileStore(dir string, policy ArchivePolicy) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("store error: %s", err.Error())
+	}
+
+	f, err := OpenArchive(filepath.Join(dir, "positions.jsonl"), policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStore{
+		dir:       dir,
+		aircraft:  make(map[uint32]*mode_s.Aircraft),
+		positions: f,
+		enc:       json.NewEncoder(f),
+	}, nil
+}
+
+// SetMaxAge drops from aircraft.json the aircraft not seen for longer than
+// maxAge, e.g. the aircraft TTL: they would not be restored anyway.
+func (s *FileStore) SetMaxAge(maxAge time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.maxAge = maxAge
+}
+
+// SaveAircraft function.
+func (s *FileStore) SaveAircraft(ac *mode_s.Aircraft) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.aircraft[ac.Addr] = ac
+	return nil
+}
+
+// SavePosition function.
+func (s *FileStore) SavePosition(ac *mode_s.Aircraft, p mode_s.TrailPoint) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	err := s.enc.Encode(positionJSON{
+		Hex:       strings.ToLower(ac.HexAddr),
+		Flight:    strings.TrimRight(ac.Flight, " \x00"),
+		Latitude:  p.Latitude,
+		Longitude: p.Longitude,
+		Altitude:  ac.Altitude,
+		Time:      float64(p.Time.UnixNano()) / 1e9,
+
+		Provenance: p.Provenance,
+	})
+	if err != nil {
+		return fmt.Errorf("store error: %s", err.Error())
+	}
+	return nil
+}
+
+// LoadState reads aircraft.json, no aircraft if it does not exist.
+func (s *FileStore) LoadState() ([]*mode_s.Aircraft, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, "aircraft.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("store error: %s", err.Error())
+	}
+
+	var stored []*storedAircraft
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("store error: %s", err.Error())
+	}
+	list := make([]*mode_s.Aircraft, len(stored))
+	for i, ac := range stored {
+		list[i] = (*mode_s.Aircraft)(ac)
+	}
+	return list, nil
+}
+
+// Flush writes aircraft.json.
+func (s *FileStore) Flush() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.writeAircraft()
+}
+
+// Close writes aircraft.json.
+func (s *FileStore) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	err := s.writeAircraft()
+	s.positions.Close()
+	return err
+}
+
+// Write aircraft.json through a temporary file, so that a crash while
+// writing leaves the previous state.
+func (s *FileStore) writeAircraft() error {
+	now := time.Now()
+	list := make([]*storedAircraft, 0, len(s.aircraft))
+	for addr, ac := range s.aircraft {
+		if s.maxAge > 0 && now.Sub(ac.Seen) > s.maxAge {
+			delete(s.aircraft, addr)
+			continue
+		}
+		list = append(list, (*storedAircraft)(ac))
+	}
+
+	name := filepath.Join(s.dir, "aircraft.json")
+	data, err := json.Marshal(list)
+	if err == nil {
+		err = ioutil.WriteFile(name+".tmp", data, 0644)
+	}
+	if err == nil {
+		err = os.Rename(name+".tmp", name)
+	}
+
+	if err != nil {
+		return fmt.Errorf("store error: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package output
+
+import (
+	"fmt"
+	"go1090/mode_s"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// FrameFilter selects the raw frames forwarded to a consumer.
+//
+// It is written as URL query parameters, every parameter taking a comma
+// separated list of values:
+//
+//	df=17,18      downlink formats
+//	icao=4CA,3C   ICAO address prefixes (hex)
+//	crc=ok        ok (default), bad or any
+//	fix=never     any (default), never or only: frames with corrected bit errors
+type FrameFilter struct {
+	DF   map[int]bool // nil means every DF.
+	ICAO []string     // Upper case hex prefixes, nil means every address.
+	CRC  string       // "ok", "bad" or "any".
+	Fix  string       // "any", "never" or "only".
+}
+
+// ParseFrameFilter parses a filter, "" forwarding every valid frame.
+func ParseFrameFilter(s string) (*FrameFilter, error) {
+	q, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, fmt.Errorf("filter error: %s", err.Error())
+	}
+
+	f := &FrameFilter{CRC: "ok", Fix: "any"}
+	for key, values := range q {
+		var list []string
+		for _, v := range values {
+			list = append(list, strings.Split(v, ",")...)
+		}
+
+		switch key {
+		case "df":
+			f.DF = make(map[int]bool)
+			for _, v := range list {
+				df, err := strconv.Atoi(v)
+				if err != nil || df < 0 || df > mode_s.MODES_AC_MSGTYPE {
+					return nil, fmt.Errorf("filter error: invalid DF %q", v)
+				}
+				f.DF[df] = true
+			}
+		case "icao":
+			for _, v := range list {
+				if _, err := strconv.ParseUint(v, 16, 32); err != nil || len(v) > 6 {
+					return nil, fmt.Errorf("filter error: invalid ICAO prefix %q", v)
+				}
+				f.ICAO = append(f.ICAO, strings.ToUpper(v))
+			}
+		case "crc":
+			if len(list) != 1 || (list[0] != "ok" && list[0] != "bad" && list[0] != "any") {
+				return nil, fmt.Errorf("filter error: crc must be ok, bad or any")
+			}
+			f.CRC = list[0]
+		case "fix":
+			if len(list) != 1 || (list[0] != "any" && list[0] != "never" && list[0] != "only") {
+				return nil, fmt.Errorf("filter error: fix must be any, never or only")
+			}
+			f.Fix = list[0]
+		default:
+			return nil, fmt.Errorf("filter error: unknown parameter %q", key)
+		}
+	}
+
+	return f, nil
+}
+
+// Match reports whether the frame passes the filter.
+func (f *FrameFilter) Match(mm *mode_s.ModeSMessage) bool {
+	if f.DF != nil && !f.DF[mm.DF()] {
+		return false
+	}
+
+	switch f.CRC {
+	case "ok":
+		if !mm.CRCOk() {
+			return false
+		}
+	case "bad":
+		if mm.CRCOk() {
+			return false
+		}
+	}
+
+	switch f.Fix {
+	case "never":
+		if mm.Corrected() {
+			return false
+		}
+	case "only":
+		if !mm.Corrected() {
+			return false
+		}
+	}
+
+	if f.ICAO != nil {
+		hex := fmt.Sprintf("%06X", mm.ICAO())
+		for _, prefix := range f.ICAO {
+			if strings.HasPrefix(hex, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
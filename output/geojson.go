@@ -0,0 +1,156 @@
+package output
+
+import (
+	"fmt"
+	"go1090/mode_s"
+	"go1090/stats"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FeatureCollection is a GeoJSON (RFC 7946) feature collection.
+type FeatureCollection struct {
+	Type     string    `json:"type"` // "FeatureCollection"
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature.
+type Feature struct {
+	Type       string                 `json:"type"` // "Feature"
+	ID         string                 `json:"id,omitempty"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON Point ([lon, lat]), LineString ([[lon, lat], ...])
+// or Polygon ([[[lon, lat], ...]]).
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// GeoJSON returns the aircraft with a position as Point features, and
+// their trails since 'since' as LineString features, sorted by address.
+func GeoJSON(aircrafts []*mode_s.Aircraft, since time.Time) FeatureCollection {
+	sort.Slice(aircrafts, func(i, j int) bool { return aircrafts[i].Addr < aircrafts[j].Addr })
+
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	for _, ac := range aircrafts {
+		if ac.Trail.Len() == 0 {
+			continue
+		}
+		hex := strings.ToLower(ac.HexAddr)
+
+		fc.Features = append(fc.Features, Feature{
+			Type:       "Feature",
+			ID:         hex,
+			Geometry:   Geometry{Type: "Point", Coordinates: [2]float64{ac.Longitude, ac.Latitude}},
+			Properties: geoJSONProperties(ac, "aircraft"),
+		})
+
+		points := ac.Trail.Since(since)
+		if len(points) < 2 {
+			continue
+		}
+		line := make([][2]float64, 0, len(points))
+		for _, p := range points {
+			line = append(line, [2]float64{p.Longitude, p.Latitude})
+		}
+		props := geoJSONProperties(ac, "trail")
+		props["start"] = points[0].Time.UTC()
+		props["end"] = points[len(points)-1].Time.UTC()
+		fc.Features = append(fc.Features, Feature{
+			Type:       "Feature",
+			ID:         hex + "-trail",
+			Geometry:   Geometry{Type: "LineString", Coordinates: line},
+			Properties: props,
+		})
+	}
+	return fc
+}
+
+func geoJSONProperties(ac *mode_s.Aircraft, kind string) map[string]interface{} {
+	props := map[string]interface{}{
+		"kind":     kind,
+		"hex":      strings.ToLower(ac.HexAddr),
+		"flight":   strings.TrimRight(ac.Flight, " \x00"),
+		"alt_baro": ac.Altitude,
+		"gs":       ac.Speed,
+		"track":    ac.Track,
+		"seen":     ac.Seen.UTC(),
+	}
+	if ac.Squawk != 0 {
+		props["squawk"] = fmt.Sprintf("%04d", ac.Squawk)
+	}
+	if ac.AltitudeGeomValid {
+		props["alt_geom"] = ac.AltitudeGeom
+	}
+	if ac.VertRateValid {
+		props["vert_rate"] = ac.VertRate
+	}
+	if ac.HeadingValid {
+		props["heading"] = ac.Heading
+		props["heading_ref"] = ac.HeadingRef
+	}
+	if p, ok := ac.Trail.Last(); ok && p.NIC >= 0 {
+		props["nic"] = p.NIC
+	}
+	if ac.Registration != "" {
+		props["registration"] = ac.Registration
+	}
+	if ac.TypeCode != "" {
+		props["type"] = ac.TypeCode
+	}
+	if ac.Operator != "" {
+		props["operator"] = ac.Operator
+	}
+	if ac.Country != "" {
+		props["country"] = ac.Country
+	}
+	return props
+}
+
+// CoverageGeoJSON returns the coverage of the receiver: a Polygon joining
+// the farthest positions of the sectors, by bearing, and those positions
+// as Point features.
+func CoverageGeoJSON(c stats.CoverageSnapshot) FeatureCollection {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+
+	ring := make([][2]float64, 0, len(c.Sectors)+1)
+	for i := range c.Sectors {
+		/* Counterclockwise, as RFC 7946 wants the exterior rings. */
+		ring = append(ring, [2]float64{c.Sectors[len(c.Sectors)-1-i].Longitude, c.Sectors[len(c.Sectors)-1-i].Latitude})
+	}
+	for _, s := range c.Sectors {
+		fc.Features = append(fc.Features, Feature{
+			Type:     "Feature",
+			ID:       fmt.Sprintf("sector-%03d", s.Sector),
+			Geometry: Geometry{Type: "Point", Coordinates: [2]float64{s.Longitude, s.Latitude}},
+			Properties: map[string]interface{}{
+				"kind":        "sector",
+				"sector":      s.Sector,
+				"distance_km": s.DistanceKm,
+				"bearing":     s.Bearing,
+				"alt_baro":    s.Altitude,
+				"hex":         s.Hex,
+				"time":        s.Time.UTC(),
+			},
+		})
+	}
+
+	if len(ring) >= 3 {
+		ring = append(ring, ring[0])
+		props := map[string]interface{}{"kind": "coverage"}
+		if c.MaxRange != nil {
+			props["max_range_km"] = c.MaxRange.DistanceKm
+		}
+		fc.Features = append([]Feature{{
+			Type:       "Feature",
+			ID:         "coverage",
+			Geometry:   Geometry{Type: "Polygon", Coordinates: [][][2]float64{ring}},
+			Properties: props,
+		}}, fc.Features...)
+	}
+	return fc
+}
@@ -0,0 +1,82 @@
+package output
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Names of the 16 compass points, clockwise from north.
+var compassPoints = [16]string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// HeadingFormat selects how tracks and headings are presented. The zero
+// value presents degrees true.
+type HeadingFormat struct {
+	Magnetic    bool    // Magnetic instead of true north reference.
+	Declination float64 // Magnetic declination at the receiver, degrees east positive.
+	Cardinal    bool    // Include compass point names (N, NNE, ...).
+}
+
+// ParseHeadingReference parses a north reference, "true" or "magnetic".
+func ParseHeadingReference(s string) (magnetic bool, err error) {
+	switch strings.ToLower(s) {
+	case "true", "t":
+		return false, nil
+	case "magnetic", "mag", "m":
+		return true, nil
+	}
+	return false, fmt.Errorf("heading reference error: unknown reference %q", s)
+}
+
+// Reference returns the name of the north reference.
+func (h HeadingFormat) Reference() string {
+	if h.Magnetic {
+		return "magnetic"
+	}
+	return "true"
+}
+
+// Degrees converts a track or heading in degrees true to the configured
+// reference, in the 0-359 range.
+func (h HeadingFormat) Degrees(track int) int {
+	return h.DegreesFrom(track, false)
+}
+
+// DegreesFrom converts a direction in degrees magnetic, or true, to the
+// configured reference, in the 0-359 range.
+func (h HeadingFormat) DegreesFrom(direction int, magnetic bool) int {
+	deg := float64(direction)
+	switch {
+	case h.Magnetic && !magnetic:
+		deg -= h.Declination
+	case !h.Magnetic && magnetic:
+		deg += h.Declination
+	}
+
+	n := int(math.Round(deg)) % 360
+	if n < 0 {
+		n += 360
+	}
+	return n
+}
+
+// CardinalOf returns the compass point of a track in the configured
+// reference, "" unless Cardinal is set.
+func (h HeadingFormat) CardinalOf(track int) string {
+	if !h.Cardinal {
+		return ""
+	}
+	return Cardinal(h.Degrees(track))
+}
+
+// Cardinal returns the nearest of the 16 compass points of a direction.
+func Cardinal(degrees int) string {
+	n := int(math.Round(float64(degrees)/22.5)) % 16
+	if n < 0 {
+		n += 16
+	}
+	return compassPoints[n]
+}
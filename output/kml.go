@@ -0,0 +1,158 @@
+package output
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"go1090/mode_s"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Google Earth document of the aircraft: a placemark per aircraft with
+// its position and its trail, extruded down to the ground, at the
+// reported altitude.
+type kmlDocument struct {
+	XMLName xml.Name  `xml:"kml"`
+	NS      string    `xml:"xmlns,attr"`
+	Name    string    `xml:"Document>name"`
+	Style   kmlStyle  `xml:"Document>Style"`
+	Marks   []kmlMark `xml:"Document>Placemark,omitempty"`
+	Link    *kmlLink  `xml:"Document>NetworkLink,omitempty"`
+}
+
+type kmlStyle struct {
+	ID        string `xml:"id,attr"`
+	Icon      string `xml:"IconStyle>Icon>href"`
+	LineColor string `xml:"LineStyle>color"`
+	LineWidth int    `xml:"LineStyle>width"`
+	PolyColor string `xml:"PolyStyle>color"`
+}
+
+type kmlMark struct {
+	Name        string       `xml:"name"`
+	Description string       `xml:"description"`
+	Style       string       `xml:"styleUrl"`
+	Point       kmlGeometry  `xml:"MultiGeometry>Point"`
+	Track       *kmlGeometry `xml:"MultiGeometry>LineString,omitempty"`
+}
+
+type kmlGeometry struct {
+	Extrude      int    `xml:"extrude"`
+	AltitudeMode string `xml:"altitudeMode"`
+	Coordinates  string `xml:"coordinates"`
+}
+
+type kmlLink struct {
+	Name     string  `xml:"name"`
+	Href     string  `xml:"Link>href"`
+	Mode     string  `xml:"Link>refreshMode"`
+	Interval float64 `xml:"Link>refreshInterval"`
+}
+
+func newKMLDocument() kmlDocument {
+	return kmlDocument{
+		NS:   "http://www.opengis.net/kml/2.2",
+		Name: "go1090",
+		Style: kmlStyle{
+			ID:        "aircraft",
+			Icon:      "http://maps.google.com/mapfiles/kml/shapes/airports.png",
+			LineColor: "ff00ffff",
+			LineWidth: 2,
+			PolyColor: "4000ffff",
+		},
+	}
+}
+
+// KML coordinates of a position, altitude in meters.
+func kmlCoordinates(lat, lon float64, altitude int) string {
+	return strconv.FormatFloat(lon, 'f', -1, 64) + "," +
+		strconv.FormatFloat(lat, 'f', -1, 64) + "," +
+		strconv.FormatFloat(float64(altitude)*feetToMeters, 'f', 0, 64)
+}
+
+// WriteKML writes the aircraft with a position as a KML document.
+func WriteKML(w io.Writer, aircrafts []*mode_s.Aircraft) error {
+	sort.Slice(aircrafts, func(i, j int) bool { return aircrafts[i].Addr < aircrafts[j].Addr })
+
+	doc := newKMLDocument()
+	for _, ac := range aircrafts {
+		if ac.Trail.Len() == 0 {
+			continue
+		}
+
+		name := strings.TrimRight(ac.Flight, " \x00")
+		if name == "" {
+			name = ac.HexAddr
+		}
+		mark := kmlMark{
+			Name: name,
+			Description: fmt.Sprintf("%s, %d ft, %d kt, track %d, seen %s",
+				ac.HexAddr, ac.Altitude, ac.Speed, ac.Track, ac.Seen.UTC().Format(time.RFC3339)),
+			Style: "#aircraft",
+			Point: kmlGeometry{
+				AltitudeMode: "absolute",
+				Coordinates:  kmlCoordinates(ac.Latitude, ac.Longitude, ac.Altitude),
+			},
+		}
+
+		if points := ac.Trail.Points(); len(points) >= 2 {
+			coords := make([]string, 0, len(points))
+			for _, p := range points {
+				coords = append(coords, kmlCoordinates(p.Latitude, p.Longitude, p.Altitude))
+			}
+			mark.Track = &kmlGeometry{
+				Extrude:      1,
+				AltitudeMode: "absolute",
+				Coordinates:  strings.Join(coords, " "),
+			}
+		}
+		doc.Marks = append(doc.Marks, mark)
+	}
+
+	return writeKMLDocument(w, doc)
+}
+
+// WriteKMZ writes the KML document of WriteKML zipped, as a KMZ file.
+func WriteKMZ(w io.Writer, aircrafts []*mode_s.Aircraft) error {
+	z := zip.NewWriter(w)
+	f, err := z.Create("doc.kml")
+	if err != nil {
+		return fmt.Errorf("KML error: %s", err.Error())
+	}
+	if err := WriteKML(f, aircrafts); err != nil {
+		return err
+	}
+	if err := z.Close(); err != nil {
+		return fmt.Errorf("KML error: %s", err.Error())
+	}
+	return nil
+}
+
+// WriteKMLNetworkLink writes a KML document loading the document at href
+// every interval, so Google Earth follows the traffic.
+func WriteKMLNetworkLink(w io.Writer, href string, interval time.Duration) error {
+	doc := newKMLDocument()
+	doc.Link = &kmlLink{
+		Name:     "go1090 aircraft",
+		Href:     href,
+		Mode:     "onInterval",
+		Interval: interval.Seconds(),
+	}
+	return writeKMLDocument(w, doc)
+}
+
+func writeKMLDocument(w io.Writer, doc kmlDocument) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("KML error: %s", err.Error())
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("KML error: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go1090/mode_s"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMQTTTopic is the topic template of the MQTT sink.
+const DefaultMQTTTopic = "adsb/{icao}/position"
+
+// Delay before connecting again to the broker after an error.
+const mqttRetryDelay = 5 * time.Second
+
+// MQTTOptions configure an MQTTSink.
+type MQTTOptions struct {
+	Broker   string // host[:port], port 1883 by default.
+	Topic    string // Template, {icao} and {callsign} are replaced.
+	QoS      int    // 0 or 1.
+	Retain   bool   // Brokers keep the last message of every topic for new subscribers.
+	ClientID string
+	Username string
+	Password string
+	Interval time.Duration // Minimum interval between messages of one aircraft.
+	Units    mode_s.Units  // Of the altitude, speed and vertical rate.
+}
+
+// MQTTSink publishes the aircraft as JSON to an MQTT broker, a topic per
+// aircraft, for home automation and IoT platforms. The connection is
+// opened again at the next update after an error.
+type MQTTSink struct {
+	opts    MQTTOptions
+	client  *mqttClient
+	dialed  time.Time
+	sent    map[uint32]time.Time
+	stale   time.Duration
+	privacy *Privacy
+
+	mux sync.Mutex
+}
+
+// NewMQTTSink function.
+// The broker must be reachable at start, so a wrong address is reported.
+func NewMQTTSink(opts MQTTOptions) (*MQTTSink, error) {
+	if opts.QoS < 0 || opts.QoS > 1 {
+		return nil, fmt.Errorf("MQTT error: unsupported QoS %d (0 or 1)", opts.QoS)
+	}
+	if opts.Topic == "" {
+		opts.Topic = DefaultMQTTTopic
+	}
+	if strings.ContainsAny(opts.Topic, "+#") {
+		return nil, fmt.Errorf("MQTT error: wildcard in topic %q", opts.Topic)
+	}
+	if opts.ClientID == "" {
+		opts.ClientID = "go1090"
+	}
+
+	s := &MQTTSink{
+		opts:  opts,
+		sent:  make(map[uint32]time.Time),
+		stale: time.Duration(mode_s.MODES_AIRCRAFT_TTL) * time.Second,
+	}
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MQTTSink) dial() error {
+	s.dialed = time.Now()
+	c, err := dialMQTT(s.opts.Broker, s.opts.ClientID, s.opts.Username, s.opts.Password)
+	if err != nil {
+		return fmt.Errorf("MQTT error: %s", err.Error())
+	}
+	s.client = c
+	return nil
+}
+
+// Update function.
+func (s *MQTTSink) Update(ac *mode_s.Aircraft) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if ac = s.privacy.Apply(ac); ac == nil {
+		return nil
+	}
+
+	now := time.Now()
+	if last, ok := s.sent[ac.Addr]; ok && now.Sub(last) < s.opts.Interval {
+		return nil
+	}
+
+	if s.client == nil {
+		if now.Sub(s.dialed) < mqttRetryDelay {
+			return errors.New("MQTT error: not connected")
+		}
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+
+	payload, err := json.Marshal(ac.JSONIn(s.opts.Units))
+	if err != nil {
+		return err
+	}
+	if err := s.client.publish(s.topic(ac), payload, byte(s.opts.QoS), s.opts.Retain); err != nil {
+		s.client.close()
+		s.client = nil
+		return fmt.Errorf("MQTT error: %s", err.Error())
+	}
+
+	s.sent[ac.Addr] = now
+	s.expire(now)
+	return nil
+}
+
+// Topic of an aircraft.
+func (s *MQTTSink) topic(ac *mode_s.Aircraft) string {
+	callsign := strings.TrimRight(ac.Flight, " \x00")
+	if callsign == "" {
+		callsign = "unknown"
+	}
+	r := strings.NewReplacer(
+		"{icao}", strings.ToLower(ac.HexAddr),
+		"{callsign}", callsign,
+	)
+	return r.Replace(s.opts.Topic)
+}
+
+// Forget aircraft not sent for a while.
+func (s *MQTTSink) expire(now time.Time) {
+	for addr, last := range s.sent {
+		if now.Sub(last) > s.stale {
+			delete(s.sent, addr)
+		}
+	}
+}
+
+// SetPrivacy hides the positions and the blocked aircraft.
+func (s *MQTTSink) SetPrivacy(p *Privacy) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.privacy = p
+}
+
+// Close function.
+func (s *MQTTSink) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.close()
+	s.client = nil
+	return err
+}
@@ -0,0 +1,253 @@
+package output
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// MQTT 3.1.1 control packet types, in the high nibble of the first byte.
+const (
+	mqttConnect    = 1
+	mqttConnAck    = 2
+	mqttPublish    = 3
+	mqttPubAck     = 4
+	mqttPingReq    = 12
+	mqttPingResp   = 13
+	mqttDisconnect = 14
+)
+
+const (
+	mqttDefaultPort = "1883"
+	mqttKeepAlive   = 60 * time.Second
+	mqttTimeout     = 10 * time.Second // For the broker to acknowledge a packet.
+)
+
+// Minimal MQTT 3.1.1 publisher: clean session, QoS 0 and 1, and a
+// PINGREQ when nothing was sent for half the keep alive. Every packet is
+// sent and acknowledged under the lock, so the only packets expected from
+// the broker are the answers to our own.
+type mqttClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	id   uint16 // Last packet identifier.
+	sent time.Time
+	done chan struct{}
+
+	mux sync.Mutex
+}
+
+// Connect to a broker, addr being host[:port].
+func dialMQTT(addr, clientID, username, password string) (*mqttClient, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, mqttDefaultPort)
+	}
+	conn, err := net.DialTimeout("tcp", addr, mqttTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &mqttClient{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		done: make(chan struct{}),
+	}
+	if err := c.connect(clientID, username, password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go c.keepAlive()
+	return c, nil
+}
+
+func (c *mqttClient) connect(clientID, username, password string) error {
+	flags := byte(0x02) // clean session
+	var payload []byte
+	payload = appendMQTTString(payload, clientID)
+	if username != "" {
+		flags |= 0x80
+		payload = appendMQTTString(payload, username)
+		if password != "" {
+			flags |= 0x40
+			payload = appendMQTTString(payload, password)
+		}
+	}
+
+	var body []byte
+	body = appendMQTTString(body, "MQTT")
+	body = append(body, 4, flags) // protocol level 4 = 3.1.1
+	body = appendUint16(body, uint16(mqttKeepAlive/time.Second))
+	body = append(body, payload...)
+
+	if err := c.send(mqttConnect<<4, body); err != nil {
+		return err
+	}
+	kind, ack, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if kind != mqttConnAck || len(ack) != 2 {
+		return errors.New("unexpected answer to CONNECT")
+	}
+	if ack[1] != 0 {
+		return fmt.Errorf("connection refused (code %d)", ack[1])
+	}
+	return nil
+}
+
+// Publish a message, waiting for the broker to acknowledge it with QoS 1.
+func (c *mqttClient) publish(topic string, payload []byte, qos byte, retain bool) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	header := byte(mqttPublish<<4) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+
+	var body []byte
+	body = appendMQTTString(body, topic)
+	if qos > 0 {
+		if c.id++; c.id == 0 {
+			c.id = 1
+		}
+		body = appendUint16(body, c.id)
+	}
+	body = append(body, payload...)
+
+	if err := c.send(header, body); err != nil {
+		return err
+	}
+	if qos == 0 {
+		return nil
+	}
+	return c.await(mqttPubAck, c.id)
+}
+
+// Read packets until the acknowledgment of packet id.
+func (c *mqttClient) await(kind byte, id uint16) error {
+	for {
+		k, body, err := c.receive()
+		if err != nil {
+			return err
+		}
+		if k == kind && len(body) >= 2 && uint16(body[0])<<8|uint16(body[1]) == id {
+			return nil
+		}
+		if k != mqttPingResp && k != mqttPubAck {
+			return fmt.Errorf("unexpected packet type %d", k)
+		}
+	}
+}
+
+// Send a PINGREQ when nothing was sent for half the keep alive, so the
+// broker does not drop a connection while no aircraft is received.
+func (c *mqttClient) keepAlive() {
+	ticker := time.NewTicker(mqttKeepAlive / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+
+		c.mux.Lock()
+		if time.Since(c.sent) >= mqttKeepAlive/2 {
+			if err := c.send(mqttPingReq<<4, nil); err == nil {
+				c.await(mqttPingResp, 0)
+			}
+		}
+		c.mux.Unlock()
+	}
+}
+
+// Write a packet, under the lock.
+func (c *mqttClient) send(header byte, body []byte) error {
+	packet := []byte{header}
+	packet = appendRemainingLength(packet, len(body))
+	packet = append(packet, body...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(mqttTimeout))
+	if _, err := c.conn.Write(packet); err != nil {
+		return err
+	}
+	c.sent = time.Now()
+	return nil
+}
+
+// Read a packet, under the lock.
+func (c *mqttClient) receive() (kind byte, body []byte, err error) {
+	c.conn.SetReadDeadline(time.Now().Add(mqttTimeout))
+
+	header, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	n, shift := 0, uint(0)
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+	}
+
+	body = make([]byte, n)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+
+	kind = header >> 4
+	if kind == mqttPingResp {
+		/* Answer of a PINGREQ, matched by await with id 0. */
+		body = []byte{0, 0}
+	}
+	return kind, body, nil
+}
+
+// Disconnect from the broker.
+func (c *mqttClient) close() error {
+	close(c.done)
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.send(mqttDisconnect<<4, nil)
+	return c.conn.Close()
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	b = appendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func appendRemainingLength(b []byte, n int) []byte {
+	for {
+		digit := byte(n % 128)
+		if n /= 128; n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			return b
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Package output forwards aircraft updates to external consumers (sinks).
+package output
+
+import (
+	"go1090/mode_s"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Sink is a consumer of aircraft updates.
+type Sink interface {
+	// Update is called with a copy of the aircraft every time it changes,
+	// from a goroutine of the sink.
+	Update(ac *mode_s.Aircraft) error
+	// Close releases the resources held by the sink.
+	Close() error
+}
+
+// Number of updates queued for a sink before updates are dropped.
+const sinkQueueLen = 256
+
+// Number of consecutive Ticks with dropped updates after which a sink is
+// reported behind.
+const sinkBehindTicks = 10
+
+// Interval between log records of a sink failing with the same error.
+const sinkErrorLogInterval = time.Minute
+
+// Dispatcher fans aircraft updates out to every registered sink. Sinks
+// are registered by name, and can be disabled at runtime. Every sink has
+// its own queue and goroutine, so a slow sink does not block the decoder
+// or the other sinks: when its queue is full, updates are dropped.
+type Dispatcher struct {
+	sinks []*dispatcherSink
+	log   *slog.Logger /* nil: errors are not logged */
+
+	mux sync.Mutex
+}
+
+type dispatcherSink struct {
+	name    string
+	sink    Sink
+	enabled bool
+	queue   chan *mode_s.Aircraft
+	done    chan struct{}
+
+	/* Health, under the Dispatcher lock */
+	updates     int64
+	dropped     int64
+	errors      int64
+	lastError   string
+	lastErrorAt time.Time
+	loggedAt    time.Time /* of the last error */
+	tickDropped int64     /* dropped at the last Tick */
+	behindTicks int
+}
+
+// SinkStatus describes a registered sink and its health.
+type SinkStatus struct {
+	Name        string     `json:"name"`
+	Enabled     bool       `json:"enabled"`
+	Queue       int        `json:"queue"` /* updates waiting */
+	QueueCap    int        `json:"queue_cap"`
+	Updates     int64      `json:"updates"`
+	Dropped     int64      `json:"dropped"` /* queue full */
+	Errors      int64      `json:"errors"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	Behind      bool       `json:"behind"` /* dropping updates persistently */
+}
+
+// NewDispatcher function.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// SetLogger logs the errors of the sinks: the first one, then the ones
+// different from the previous error or at most every minute.
+func (d *Dispatcher) SetLogger(l *slog.Logger) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.log = l
+}
+
+// Add registers an enabled sink.
+func (d *Dispatcher) Add(name string, s Sink) {
+	e := &dispatcherSink{
+		name:    name,
+		sink:    s,
+		enabled: true,
+		queue:   make(chan *mode_s.Aircraft, sinkQueueLen),
+		done:    make(chan struct{}),
+	}
+	go d.run(e)
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.sinks = append(d.sinks, e)
+}
+
+// Send the queued updates to a sink, until the queue is closed.
+func (d *Dispatcher) run(e *dispatcherSink) {
+	defer close(e.done)
+
+	for ac := range e.queue {
+		err := e.sink.Update(ac)
+
+		d.mux.Lock()
+		e.updates++
+		if err != nil {
+			e.errors++
+			now := time.Now()
+			if d.log != nil && (err.Error() != e.lastError || now.Sub(e.loggedAt) >= sinkErrorLogInterval) {
+				d.log.Warn("sink error", "sink", e.name, "error", err, "errors", e.errors)
+				e.loggedAt = now
+			}
+			e.lastError = err.Error()
+			e.lastErrorAt = now
+		}
+		d.mux.Unlock()
+	}
+}
+
+// Remove unregisters a sink, without closing it. The updates already
+// queued are sent first.
+func (d *Dispatcher) Remove(s Sink) {
+	d.mux.Lock()
+	var removed *dispatcherSink
+	for i, e := range d.sinks {
+		if e.sink == s {
+			removed = e
+			d.sinks = append(d.sinks[:i], d.sinks[i+1:]...)
+			break
+		}
+	}
+	d.mux.Unlock()
+
+	if removed != nil {
+		close(removed.queue)
+		<-removed.done
+	}
+}
+
+// SetEnabled enables or disables the sinks registered under a name.
+// Returns false if there is none.
+func (d *Dispatcher) SetEnabled(name string, enabled bool) bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	found := false
+	for _, e := range d.sinks {
+		if e.name == name {
+			e.enabled = enabled
+			found = true
+		}
+	}
+	return found
+}
+
+// Status lists the registered sinks.
+func (d *Dispatcher) Status() []SinkStatus {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	list := make([]SinkStatus, 0, len(d.sinks))
+	for _, e := range d.sinks {
+		st := SinkStatus{
+			Name:      e.name,
+			Enabled:   e.enabled,
+			Queue:     len(e.queue),
+			QueueCap:  cap(e.queue),
+			Updates:   e.updates,
+			Dropped:   e.dropped,
+			Errors:    e.errors,
+			LastError: e.lastError,
+			Behind:    e.behindTicks >= sinkBehindTicks,
+		}
+		if !e.lastErrorAt.IsZero() {
+			t := e.lastErrorAt
+			st.LastErrorAt = &t
+		}
+		list = append(list, st)
+	}
+	return list
+}
+
+// Tick checks whether the sinks keep up. Call it regularly (every
+// second): a sink dropping updates at every Tick for sinkBehindTicks is
+// reported behind, see SinkStatus.
+func (d *Dispatcher) Tick() {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	for _, e := range d.sinks {
+		if e.dropped > e.tickDropped {
+			e.behindTicks++
+		} else {
+			e.behindTicks = 0
+		}
+		e.tickDropped = e.dropped
+	}
+}
+
+// Behind returns the names of the sinks falling behind.
+func (d *Dispatcher) Behind() []string {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	var names []string
+	for _, e := range d.sinks {
+		if e.behindTicks >= sinkBehindTicks {
+			names = append(names, e.name)
+		}
+	}
+	return names
+}
+
+// Each calls f with every registered sink.
+func (d *Dispatcher) Each(f func(name string, s Sink)) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	for _, e := range d.sinks {
+		f(e.name, e.sink)
+	}
+}
+
+// Update queues the aircraft for every enabled sink.
+func (d *Dispatcher) Update(ac *mode_s.Aircraft) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	for _, e := range d.sinks {
+		if !e.enabled {
+			continue
+		}
+		select {
+		case e.queue <- ac:
+		default:
+			e.dropped++
+		}
+	}
+}
+
+// Close sends the queued updates and closes every sink.
+func (d *Dispatcher) Close() {
+	d.mux.Lock()
+	sinks := d.sinks
+	d.sinks = nil
+	d.mux.Unlock()
+
+	for _, e := range sinks {
+		close(e.queue)
+		<-e.done
+		e.sink.Close()
+	}
+}
@@ -0,0 +1,51 @@
+package output
+
+import "fmt"
+
+// DefaultCoordinateDecimals prints coordinates to about 10 m.
+const DefaultCoordinateDecimals = 4
+
+// Most decimals of a printed coordinate, about 1 mm.
+const maxCoordinateDecimals = 8
+
+// PositionFormat selects how coordinates are printed by the text outputs,
+// like the TUI. Positions are kept and sent as JSON at full precision.
+type PositionFormat struct {
+	Decimals int // Decimals of the coordinates.
+}
+
+// NewPositionFormat function.
+func NewPositionFormat(decimals int) (PositionFormat, error) {
+	if decimals < 0 || decimals > maxCoordinateDecimals {
+		return PositionFormat{}, fmt.Errorf("position format error: decimals must be 0 to %d, not %d", maxCoordinateDecimals, decimals)
+	}
+	return PositionFormat{Decimals: decimals}, nil
+}
+
+// Latitude formats a latitude, right aligned to LatitudeWidth.
+func (f PositionFormat) Latitude(lat float64) string {
+	return fmt.Sprintf("%*.*f", f.LatitudeWidth(), f.Decimals, lat)
+}
+
+// Longitude formats a longitude, right aligned to LongitudeWidth.
+func (f PositionFormat) Longitude(lon float64) string {
+	return fmt.Sprintf("%*.*f", f.LongitudeWidth(), f.Decimals, lon)
+}
+
+// LatitudeWidth returns the width of a formatted latitude, e.g. "-90.00".
+func (f PositionFormat) LatitudeWidth() int {
+	return f.width(3)
+}
+
+// LongitudeWidth returns the width of a formatted longitude, e.g. "-180.00".
+func (f PositionFormat) LongitudeWidth() int {
+	return f.width(4)
+}
+
+// Width of a coordinate with a sign and integer digits.
+func (f PositionFormat) width(integer int) int {
+	if f.Decimals == 0 {
+		return integer
+	}
+	return integer + 1 + f.Decimals
+}
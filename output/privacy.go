@@ -0,0 +1,134 @@
+package output
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"go1090/mode_s"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Privacy hides data from the public outputs (web, CoT, raw frames):
+// positions are rounded and/or shifted by a fixed offset per aircraft, and
+// blocked aircraft are not shown at all. A nil Privacy changes nothing.
+type Privacy struct {
+	Decimals int     // Round coordinates to this many decimals, -1 = exact.
+	FuzzKm   float64 // Shift positions by up to this distance, 0 = none.
+
+	blocked map[uint32]bool
+	salt    uint64 /* random, so the offsets can't be computed back */
+}
+
+// NewPrivacy function.
+// Returns nil if there is nothing to hide.
+func NewPrivacy(decimals int, fuzzKm float64, blocked []uint32) *Privacy {
+	if decimals < 0 && fuzzKm <= 0 && len(blocked) == 0 {
+		return nil
+	}
+
+	p := &Privacy{
+		Decimals: decimals,
+		FuzzKm:   fuzzKm,
+		blocked:  make(map[uint32]bool),
+	}
+	var salt [8]byte
+	crand.Read(salt[:])
+	p.salt = binary.LittleEndian.Uint64(salt[:])
+
+	for _, addr := range blocked {
+		p.blocked[addr] = true
+	}
+	return p
+}
+
+// ParseAddressList parses a comma separated list of hex addresses, '~'
+// marking non-ICAO addresses.
+func ParseAddressList(s string) ([]uint32, error) {
+	var list []uint32
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		var flag uint32
+		hex := field
+		if strings.HasPrefix(hex, "~") {
+			hex, flag = hex[1:], mode_s.MODES_NON_ICAO_ADDRESS
+		}
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return nil, fmt.Errorf("privacy error: invalid address %q", field)
+		}
+		list = append(list, uint32(n)|flag)
+	}
+	return list, nil
+}
+
+// Blocked returns true if the aircraft must not be shown.
+func (p *Privacy) Blocked(addr uint32) bool {
+	return p != nil && p.blocked[addr]
+}
+
+// Apply returns a copy of the aircraft with its positions hidden, nil if
+// it is blocked. Without privacy, the aircraft itself is returned.
+func (p *Privacy) Apply(ac *mode_s.Aircraft) *mode_s.Aircraft {
+	if p == nil {
+		return ac
+	}
+	if p.blocked[ac.Addr] {
+		return nil
+	}
+	if p.Decimals < 0 && p.FuzzKm <= 0 {
+		return ac
+	}
+
+	/* Without a position, (0, 0) must stay as it is: the outputs would take a
+	 * position near it for a known one. */
+	c := ac.Clone()
+	if ac.Trail.Len() > 0 || ac.Latitude != 0 || ac.Longitude != 0 {
+		c.Latitude, c.Longitude = p.Position(ac.Addr, ac.Latitude, ac.Longitude)
+	}
+	c.Trail.Update(func(t *mode_s.TrailPoint) {
+		t.Latitude, t.Longitude = p.Position(ac.Addr, t.Latitude, t.Longitude)
+	})
+
+	/* The range from a known receiver would give the position back. */
+	c.DistanceKm = math.Round(c.DistanceKm)
+	c.Bearing = math.Round(c.Bearing)
+	return c
+}
+
+// Position hides a position of an aircraft. The offset of an aircraft
+// does not change, so averaging its positions does not reveal it.
+func (p *Privacy) Position(addr uint32, lat, lon float64) (float64, float64) {
+	if p == nil {
+		return lat, lon
+	}
+
+	if p.FuzzKm > 0 {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%d:%d", p.salt, addr)
+		r := rand.New(rand.NewSource(int64(h.Sum64())))
+
+		dist := p.FuzzKm * math.Sqrt(r.Float64()) /* uniform over the disc */
+		angle := r.Float64() * 2 * math.Pi
+		lat += dist * math.Cos(angle) / 111.32
+		lon += dist * math.Sin(angle) / (111.32 * math.Max(math.Cos(lat*math.Pi/180), 0.01))
+	}
+
+	return p.Round(lat), p.Round(lon)
+}
+
+// Round a coordinate to the configured decimals.
+func (p *Privacy) Round(v float64) float64 {
+	if p == nil || p.Decimals < 0 {
+		return v
+	}
+	scale := math.Pow(10, float64(p.Decimals))
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,151 @@
+package output
+
+import (
+	"fmt"
+	"go1090/mode_s"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Number of frames queued for a client before frames are dropped.
+const rawClientQueueLen = 256
+
+// RawServer serves frames in the AVR text format ("*8D4840D6...;") to TCP
+// clients, like the raw output port (30002) of dump1090.
+type RawServer struct {
+	listener net.Listener
+	filter   *FrameFilter
+	clients  map[*rawClient]bool
+	disabled bool
+	privacy  *Privacy
+
+	mux sync.Mutex
+}
+
+type rawClient struct {
+	conn  net.Conn
+	queue chan []byte
+}
+
+// NewRawServer function.
+// Only the frames passing filter are forwarded.
+func NewRawServer(addr string, filter *FrameFilter) (*RawServer, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("raw output error: %s", err.Error())
+	}
+
+	s := &RawServer{
+		listener: l,
+		filter:   filter,
+		clients:  make(map[*rawClient]bool),
+	}
+	go s.accept()
+	return s, nil
+}
+
+// Addr returns the listening address.
+func (s *RawServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// SetEnabled stops or resumes forwarding frames. Clients stay connected.
+func (s *RawServer) SetEnabled(enabled bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.disabled = !enabled
+}
+
+// SetPrivacy drops the frames of blocked aircraft. The positions of the
+// frames are not changed: keep the raw outputs private when hiding them.
+func (s *RawServer) SetPrivacy(p *Privacy) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.privacy = p
+}
+
+// Enabled function.
+func (s *RawServer) Enabled() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return !s.disabled
+}
+
+func (s *RawServer) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &rawClient{conn: conn, queue: make(chan []byte, rawClientQueueLen)}
+		s.mux.Lock()
+		s.clients[c] = true
+		s.mux.Unlock()
+
+		go s.serve(c)
+	}
+}
+
+func (s *RawServer) serve(c *rawClient) {
+	for line := range c.queue {
+		if _, err := c.conn.Write(line); err != nil {
+			break
+		}
+	}
+
+	s.remove(c)
+}
+
+func (s *RawServer) remove(c *rawClient) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.clients[c] {
+		delete(s.clients, c)
+		close(c.queue)
+		c.conn.Close()
+	}
+}
+
+// Forward a decoded frame to the clients, if it passes the filter. Slow
+// clients lose frames rather than blocking the decoder.
+func (s *RawServer) Forward(mm *mode_s.ModeSMessage) {
+	if !s.filter.Match(mm) {
+		return
+	}
+
+	line := []byte("*" + strings.ToUpper(fmt.Sprintf("%x", mm.Bytes())) + ";\n")
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.disabled || s.privacy.Blocked(mm.ICAO()) {
+		return
+	}
+	for c := range s.clients {
+		select {
+		case c.queue <- line:
+		default:
+		}
+	}
+}
+
+// Close stops listening and disconnects the clients.
+func (s *RawServer) Close() error {
+	err := s.listener.Close()
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for c := range s.clients {
+		delete(s.clients, c)
+		close(c.queue)
+		c.conn.Close()
+	}
+	return err
+}
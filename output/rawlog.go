@@ -0,0 +1,67 @@
+package output
+
+import (
+	"fmt"
+	"go1090/mode_s"
+	"sync"
+)
+
+// RawLog appends the decoded frames to a log in the AVR format with
+// timestamps that dump1090 and readsb write with --net-ro-port and MLAT
+// enabled, a frame per line:
+//
+//	@0002CB4178008D4840D6202CC371C32CE0576098;
+//
+// '@', the 12 hex digits of the timestamp (see frameTimestamp), then the
+// message, 4 hex digits for Mode A/C. Every frame
+// is written, including those with a bad CRC when they are kept
+// (-check-crc=false), to be decoded again by other tools or used for MLAT
+// research.
+type RawLog struct {
+	file *ArchiveFile
+	err  error // Write error, nothing is written after.
+
+	mux sync.Mutex
+}
+
+// NewRawLog function.
+// Frames are appended to an existing log, which is rotated according to
+// policy.
+func NewRawLog(path string, policy ArchivePolicy) (*RawLog, error) {
+	f, err := OpenArchive(path, policy)
+	if err != nil {
+		return nil, err
+	}
+	return &RawLog{file: f}, nil
+}
+
+// Forward appends a decoded frame. After a write error the log is closed
+// and the frames dropped: the error is returned once.
+func (l *RawLog) Forward(mm *mode_s.ModeSMessage) error {
+	line := fmt.Sprintf("@%012X%X;\n", frameTimestamp(mm), mm.Bytes())
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.err != nil {
+		return nil
+	}
+	if _, err := l.file.Write([]byte(line)); err != nil {
+		l.err = fmt.Errorf("raw log error: %s", err.Error())
+		l.file.Close()
+		return l.err
+	}
+	return nil
+}
+
+// Close function.
+func (l *RawLog) Close() error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.err != nil {
+		return nil
+	}
+	l.err = fmt.Errorf("raw log error: closed")
+	return l.file.Close()
+}
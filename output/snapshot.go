@@ -0,0 +1,100 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go1090/mode_s"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Columns of the CSV snapshots.
+var snapshotHeader = []string{"time", "units", "icao", "callsign", "registration", "type", "operator", "country",
+	"lat", "lon", "alt", "gs", "track", "vr", "squawk", "messages"}
+
+// SnapshotPath returns the file name of a snapshot taken at now: the time
+// is inserted before the extension of path, e.g. snapshot.csv gives
+// snapshot-20240131-235959.csv.
+func SnapshotPath(path string, now time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + now.Format("-20060102-150405") + ext
+}
+
+// WriteSnapshot function.
+// Writes the aircraft to a new file named by SnapshotPath, as a JSON array
+// in the schema of Aircraft.MarshalJSON when path ends with .json, else as
+// CSV, and returns the name.
+func WriteSnapshot(path string, aircraft []*mode_s.Aircraft, now time.Time, units mode_s.Units) (string, error) {
+	records := make([]mode_s.AircraftJSON, len(aircraft))
+	for i, ac := range aircraft {
+		records[i] = ac.JSONIn(units)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Hex < records[j].Hex })
+
+	name := SnapshotPath(path, now)
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", fmt.Errorf("snapshot error: %s", err.Error())
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(records)
+	} else {
+		w := csv.NewWriter(f)
+		w.Write(snapshotHeader)
+		for _, r := range records {
+			w.Write(snapshotRow(r, now, units))
+		}
+		w.Flush()
+		err = w.Error()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("snapshot error: %s", err.Error())
+	}
+	return name, nil
+}
+
+// Row of a snapshot, unknown values being empty.
+func snapshotRow(r mode_s.AircraftJSON, now time.Time, units mode_s.Units) []string {
+	optional := func(v *int) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	}
+	coordinate := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	}
+
+	return []string{
+		now.UTC().Format("2006-01-02T15:04:05.000Z"),
+		units.String(),
+		r.Hex,
+		r.Flight,
+		r.Registration,
+		r.TypeCode,
+		r.Operator,
+		r.Country,
+		coordinate(r.Latitude),
+		coordinate(r.Longitude),
+		optional(r.Altitude),
+		optional(r.Speed),
+		optional(r.Track),
+		optional(r.VertRate),
+		r.Squawk,
+		strconv.FormatInt(r.Messages, 10),
+	}
+}
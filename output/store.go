@@ -0,0 +1,100 @@
+package output
+
+import (
+	"go1090/mode_s"
+	"sync"
+	"time"
+)
+
+// Store is a storage backend of aircraft state. Implement it to keep
+// aircraft in a database or an object store, and register it with
+// NewStoreSink.
+type Store interface {
+	// SaveAircraft stores the current state of an aircraft.
+	SaveAircraft(ac *mode_s.Aircraft) error
+	// SavePosition stores a new position of an aircraft.
+	SavePosition(ac *mode_s.Aircraft, p mode_s.TrailPoint) error
+	// LoadState returns the last saved state of the aircraft, to restore
+	// the sky at startup.
+	LoadState() ([]*mode_s.Aircraft, error)
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// Flusher is implemented by the stores buffering state, to write it out
+// before Close.
+type Flusher interface {
+	Flush() error
+}
+
+// StoreSink is the Sink saving aircraft updates to a Store.
+type StoreSink struct {
+	store  Store
+	saved  map[uint32]time.Time // Time of the last saved position.
+	maxAge time.Duration        // of the positions kept in saved.
+
+	mux sync.Mutex
+}
+
+// NewStoreSink function.
+func NewStoreSink(store Store) *StoreSink {
+	return &StoreSink{
+		store:  store,
+		saved:  make(map[uint32]time.Time),
+		maxAge: time.Duration(mode_s.MODES_AIRCRAFT_TTL) * time.Second,
+	}
+}
+
+// SetMaxAge sets the time the last saved position of an aircraft is
+// remembered, e.g. the aircraft TTL: a position older than that is not
+// saved again.
+func (s *StoreSink) SetMaxAge(maxAge time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.maxAge = maxAge
+}
+
+// Update saves the aircraft, and its position if it is new.
+func (s *StoreSink) Update(ac *mode_s.Aircraft) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := s.store.SaveAircraft(ac); err != nil {
+		return err
+	}
+
+	if p, ok := ac.Trail.Last(); ok && ac.Seen.Sub(p.Time) <= s.maxAge {
+		if p.Time.After(s.saved[ac.Addr]) {
+			if err := s.store.SavePosition(ac, p); err != nil {
+				return err
+			}
+			s.saved[ac.Addr] = p.Time
+			s.expire(p.Time)
+		}
+	}
+
+	return nil
+}
+
+// Forget the positions older than maxAge at now, of the aircraft gone.
+func (s *StoreSink) expire(now time.Time) {
+	for addr, last := range s.saved {
+		if now.Sub(last) > s.maxAge {
+			delete(s.saved, addr)
+		}
+	}
+}
+
+// Flush writes out the state buffered by the store, if it buffers any.
+func (s *StoreSink) Flush() error {
+	if f, ok := s.store.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close closes the store.
+func (s *StoreSink) Close() error {
+	return s.store.Close()
+}
@@ -0,0 +1,318 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go1090/mode_s"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of the webhook alerts.
+const (
+	AlertWatched     = "watched"          // A watched aircraft appeared.
+	AlertEmergency   = "emergency_squawk" // An aircraft squawks 7500, 7600 or 7700.
+	AlertLowAltitude = "low_altitude"     // An aircraft flies low near the receiver.
+	AlertRangeRecord = "range_record"     // A position farther than ever.
+	AlertZoneEnter   = "zone_enter"       // An aircraft entered a zone, see mode_s.Sky.SetZones.
+	AlertZoneExit    = "zone_exit"        // An aircraft left a zone.
+)
+
+const (
+	webhookQueueLen = 64 // Alerts waiting to be posted before alerts are dropped.
+	webhookTimeout  = 10 * time.Second
+)
+
+// WebhookOptions configure a WebhookSink.
+type WebhookOptions struct {
+	URLs          []string
+	LowAltitude   int           // Feet, alert for the aircraft flying lower, 0 = none.
+	LowRadiusKm   float64       // Only within this distance of the receiver, 0 = any.
+	RangeRecordKm float64       // Alert for positions farther than the record, starting here, 0 = none.
+	Cooldown      time.Duration // Minimum interval between alerts of a type for one aircraft.
+	Units         mode_s.Units  // Of the altitudes in the texts.
+}
+
+// WebhookSink posts alerts to webhooks, for notifications on a phone or
+// in a chat. The payload depends on the URL: {"content": text} for
+// Discord, {"text": text} for Slack, and for other URLs
+//
+//	{"type": "zone_enter", "time": "...", "text": "...", "zone": "...", "aircraft": {...}}
+//
+// with the aircraft as written by Aircraft.MarshalJSON, and the zone of
+// the zone alerts. Alerts are posted
+// in the background, in order; they are dropped when the webhooks are
+// slower than the alerts.
+type WebhookSink struct {
+	opts    WebhookOptions
+	client  *http.Client
+	queue   chan webhookAlert
+	done    chan struct{}
+	sent    map[string]time.Time // By type and address.
+	record  float64              // Farthest position, km.
+	closed  bool
+	privacy *Privacy
+	log     *slog.Logger // nil: errors are not logged.
+
+	mux sync.Mutex
+}
+
+type webhookAlert struct {
+	Type     string           `json:"type"`
+	Time     time.Time        `json:"time"`
+	Text     string           `json:"text"`
+	Zone     string           `json:"zone,omitempty"`
+	Aircraft *mode_s.Aircraft `json:"aircraft"`
+}
+
+// NewWebhookSink function.
+// The URLs must be http or https URLs.
+func NewWebhookSink(opts WebhookOptions) (*WebhookSink, error) {
+	for _, u := range opts.URLs {
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("webhook error: invalid URL %q", u)
+		}
+	}
+
+	s := &WebhookSink{
+		opts:   opts,
+		client: &http.Client{Timeout: webhookTimeout},
+		queue:  make(chan webhookAlert, webhookQueueLen),
+		done:   make(chan struct{}),
+		sent:   make(map[string]time.Time),
+		record: opts.RangeRecordKm,
+	}
+	go s.run()
+	return s, nil
+}
+
+// SetPrivacy drops the alerts of blocked aircraft and hides the positions
+// in the payloads.
+func (s *WebhookSink) SetPrivacy(p *Privacy) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.privacy = p
+}
+
+// SetLogger logs the failed posts.
+func (s *WebhookSink) SetLogger(l *slog.Logger) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.log = l
+}
+
+// Handle raises the alerts of the watched, emergency squawk and zone
+// events; use it as a mode_s.EventHandler.
+func (s *WebhookSink) Handle(e mode_s.Event) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	ac := e.Aircraft
+	switch e.Type {
+	case mode_s.EVENT_WATCHED:
+		s.alert(AlertWatched, "", e.Time, ac, "Watched aircraft "+aircraftName(ac)+" seen")
+	case mode_s.EVENT_EMERGENCY_SQUAWK:
+		text := fmt.Sprintf("%s squawks %04d", aircraftName(ac), e.Squawk)
+		if ac.SquawkMeaning != "" {
+			text += " (" + ac.SquawkMeaning + ")"
+		}
+		s.alert(AlertEmergency, "", e.Time, ac, text)
+	case mode_s.EVENT_ZONE_ENTER:
+		s.alert(AlertZoneEnter, e.Zone, e.Time, ac, aircraftName(ac)+" entered "+e.Zone)
+	case mode_s.EVENT_ZONE_EXIT:
+		s.alert(AlertZoneExit, e.Zone, e.Time, ac, aircraftName(ac)+" left "+e.Zone)
+	}
+}
+
+// Update raises the low altitude and range record alerts.
+func (s *WebhookSink) Update(ac *mode_s.Aircraft) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	/* The texts give the range of the copy with the position hidden. */
+	now := time.Now()
+	if s.lowAltitude(ac) {
+		if hidden := s.due(AlertLowAltitude, "", now, ac); hidden != nil {
+			s.send(AlertLowAltitude, "", now, hidden, fmt.Sprintf("%s at %d %s%s", aircraftName(hidden),
+				hidden.AltitudeIn(s.opts.Units), s.opts.Units.AltitudeSymbol(), rangeText(hidden)))
+		}
+	}
+	if s.opts.RangeRecordKm > 0 && ac.Ranged && !ac.Suspect && ac.DistanceKm > s.record {
+		s.record = ac.DistanceKm
+		if hidden := s.due(AlertRangeRecord, "", now, ac); hidden != nil {
+			s.send(AlertRangeRecord, "", now, hidden, fmt.Sprintf("New range record: %s%s", aircraftName(hidden), rangeText(hidden)))
+		}
+	}
+	s.expire(now)
+	return nil
+}
+
+// The aircraft is airborne below the altitude of the options, within
+// their radius.
+func (s *WebhookSink) lowAltitude(ac *mode_s.Aircraft) bool {
+	if s.opts.LowAltitude <= 0 || ac.Altitude == 0 || ac.Altitude >= s.opts.LowAltitude || ac.OnGround {
+		return false
+	}
+	return s.opts.LowRadiusKm <= 0 || (ac.Ranged && ac.DistanceKm <= s.opts.LowRadiusKm)
+}
+
+// Queue an alert, see due. Called with the lock held.
+func (s *WebhookSink) alert(kind, zone string, t time.Time, ac *mode_s.Aircraft, text string) {
+	if hidden := s.due(kind, zone, t, ac); hidden != nil {
+		s.send(kind, zone, t, hidden, text)
+	}
+}
+
+// Return the aircraft with its position hidden if an alert is to be sent,
+// recording it; nil if one of this type (and zone) was sent for the
+// aircraft within the cooldown: an aircraft flying away raises the range
+// record at every position, one flying along the edge of a zone enters
+// and leaves it again and again. Called with the lock held.
+func (s *WebhookSink) due(kind, zone string, t time.Time, ac *mode_s.Aircraft) *mode_s.Aircraft {
+	if s.closed {
+		return nil
+	}
+	key := kind + ac.HexAddr + zone
+	if last, ok := s.sent[key]; ok && t.Sub(last) < s.opts.Cooldown {
+		return nil
+	}
+	if ac = s.privacy.Apply(ac); ac == nil {
+		return nil
+	}
+	s.sent[key] = t
+	return ac
+}
+
+// Queue an alert of an aircraft returned by due. Called with the lock
+// held.
+func (s *WebhookSink) send(kind, zone string, t time.Time, ac *mode_s.Aircraft, text string) {
+	select {
+	case s.queue <- webhookAlert{Type: kind, Time: t.UTC(), Text: text, Zone: zone, Aircraft: ac}:
+	default:
+		if s.log != nil {
+			s.log.Warn("webhook alert dropped", "type", kind, "hex", ac.HexAddr)
+		}
+	}
+}
+
+// Forget the alerts older than the cooldown. Called with the lock held.
+func (s *WebhookSink) expire(now time.Time) {
+	for key, last := range s.sent {
+		if now.Sub(last) >= s.opts.Cooldown {
+			delete(s.sent, key)
+		}
+	}
+}
+
+// Post the queued alerts, until the queue is closed.
+func (s *WebhookSink) run() {
+	defer close(s.done)
+
+	for a := range s.queue {
+		for _, u := range s.opts.URLs {
+			if err := s.post(u, a); err != nil {
+				s.mux.Lock()
+				if s.log != nil {
+					s.log.Warn("webhook error", "type", a.Type, "error", err)
+				}
+				s.mux.Unlock()
+			}
+		}
+	}
+}
+
+func (s *WebhookSink) post(u string, a webhookAlert) error {
+	var payload interface{} = a
+	switch webhookKind(u) {
+	case "discord":
+		payload = map[string]string{"content": a.Text}
+	case "slack":
+		payload = map[string]string{"text": a.Text}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("webhook error: %s", err.Error())
+	}
+
+	resp, err := s.client.Post(u, "application/json", bytes.NewReader(body))
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err /* without the URL */
+	}
+	if err != nil {
+		return fmt.Errorf("webhook error: %s: %s", redactURL(u), err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook error: %s answered %s", redactURL(u), resp.Status)
+	}
+	return nil
+}
+
+// Service of a webhook URL, by host: "discord", "slack" or "" for a
+// generic webhook.
+func webhookKind(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(parsed.Hostname())
+	switch {
+	case host == "discord.com" || host == "discordapp.com" || strings.HasSuffix(host, ".discord.com"):
+		return "discord"
+	case host == "hooks.slack.com":
+		return "slack"
+	}
+	return ""
+}
+
+// The scheme and host of a URL, the path of a webhook being its secret.
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "webhook"
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+// Callsign, or registration, and address of an aircraft, e.g.
+// "KLM1023 (4840d6)".
+func aircraftName(ac *mode_s.Aircraft) string {
+	name := strings.TrimRight(ac.Flight, " \x00")
+	if name == "" {
+		name = ac.Registration
+	}
+	hex := strings.ToLower(ac.HexAddr)
+	if name == "" {
+		return hex
+	}
+	return name + " (" + hex + ")"
+}
+
+// Distance and bearing from the receiver, "" if unknown.
+func rangeText(ac *mode_s.Aircraft) string {
+	if !ac.Ranged {
+		return ""
+	}
+	return fmt.Sprintf(", %.1f km from the receiver at %.0f°", ac.DistanceKm, ac.Bearing)
+}
+
+// Close posts the queued alerts.
+func (s *WebhookSink) Close() error {
+	s.mux.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mux.Unlock()
+
+	<-s.done
+	return nil
+}
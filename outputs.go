@@ -0,0 +1,367 @@
+package main
+
+import (
+	"go1090/mode_s"
+	"go1090/output"
+	"strings"
+	"time"
+)
+
+// Rotation of the JSON lines files selected by the flags.
+func (s *settings) archivePolicy() output.ArchivePolicy {
+	return output.ArchivePolicy{
+		Rotate:   s.archRotate,
+		Compress: s.archCompress,
+		MaxAge:   s.archMaxAge,
+	}
+}
+
+// Units of an output: -metric unless set with its own flag.
+func (s *settings) outputUnits(name string) (mode_s.Units, error) {
+	if name == "" {
+		name = mode_s.UNITS_AVIATION.String()
+		if s.metric {
+			name = mode_s.UNITS_METRIC.String()
+		}
+	}
+	return mode_s.ParseUnits(name)
+}
+
+// Outputs that are reopened when the configuration is reloaded.
+type outputSet struct {
+	sinks  []output.Sink
+	names  []string /* of the sinks, for the admin API */
+	raw    []*output.RawServer
+	specs  []string /* of the raw servers, addr[?filter] */
+	feeds  []*output.BeastFeeder
+	events *output.EventLog
+	beast  *output.BeastFile
+	rawLog *output.RawLog
+	alerts *output.WebhookSink
+}
+
+// Open the reloadable outputs of the settings, hiding data of the public
+// ones with their privacy. The raw servers of old (nil if none) serving the
+// same addr[?filter] are taken over, their port being in use. On error,
+// the outputs opened are closed, old is left as it is.
+func openOutputs(s *settings, old *outputSet) (*outputSet, error) {
+	o := &outputSet{}
+	privacy := s.privacy
+
+	if s.cotAddr != "" {
+		sink, err := output.NewCoTSink(s.cotAddr, s.cotInterval)
+		if err != nil {
+			return nil, err
+		}
+		sink.SetPrivacy(privacy)
+		o.sinks = append(o.sinks, sink)
+		o.names = append(o.names, "cot")
+	}
+
+	if s.mqttBroker != "" {
+		units, err := s.outputUnits(s.mqttUnits)
+		if err != nil {
+			o.close(old)
+			return nil, err
+		}
+		sink, err := output.NewMQTTSink(output.MQTTOptions{
+			Broker:   s.mqttBroker,
+			Topic:    s.mqttTopic,
+			QoS:      s.mqttQoS,
+			Retain:   s.mqttRetain,
+			ClientID: s.mqttClientID,
+			Username: s.mqttUser,
+			Password: s.mqttPassword,
+			Interval: s.mqttInterval,
+			Units:    units,
+		})
+		if err != nil {
+			o.close(old)
+			return nil, err
+		}
+		sink.SetPrivacy(privacy)
+		o.sinks = append(o.sinks, sink)
+		o.names = append(o.names, "mqtt")
+	}
+
+	if s.aprsServer != "" {
+		sink, err := output.NewAPRSSink(output.APRSOptions{
+			Server:   s.aprsServer,
+			Callsign: s.aprsCall,
+			Passcode: s.aprsPasscode,
+			Interval: s.aprsInterval,
+			Rate:     s.aprsRate,
+		})
+		if err != nil {
+			o.close(old)
+			return nil, err
+		}
+		sink.SetPrivacy(privacy)
+		o.sinks = append(o.sinks, sink)
+		o.names = append(o.names, "aprs")
+	}
+
+	if s.csvFile != "" {
+		sink, err := output.NewCSVSink(s.csvFile, s.archivePolicy())
+		if err != nil {
+			o.close(old)
+			return nil, err
+		}
+		o.sinks = append(o.sinks, sink)
+		o.names = append(o.names, "csv")
+	}
+
+	if len(s.webhooks) > 0 {
+		units, err := s.outputUnits("")
+		if err != nil {
+			o.close(old)
+			return nil, err
+		}
+		sink, err := output.NewWebhookSink(output.WebhookOptions{
+			URLs:          s.webhooks,
+			LowAltitude:   s.alertAlt,
+			LowRadiusKm:   s.alertRadius,
+			RangeRecordKm: s.alertRecord,
+			Cooldown:      s.alertCool,
+			Units:         units,
+		})
+		if err != nil {
+			o.close(old)
+			return nil, err
+		}
+		sink.SetPrivacy(privacy)
+		sink.SetLogger(logOutput)
+		o.sinks = append(o.sinks, sink)
+		o.names = append(o.names, "webhook")
+		o.alerts = sink
+	}
+
+	reuse := make(map[string]*output.RawServer)
+	if old != nil {
+		for i, srv := range old.raw {
+			reuse[old.specs[i]] = srv
+		}
+	}
+	for _, spec := range s.rawOutputs {
+		addr, query := spec, ""
+		if i := strings.Index(spec, "?"); i >= 0 {
+			addr, query = spec[:i], spec[i+1:]
+		}
+
+		srv := reuse[spec]
+		delete(reuse, spec)
+		if srv == nil {
+			filter, err := output.ParseFrameFilter(query)
+			if err != nil {
+				o.close(old)
+				return nil, err
+			}
+			if srv, err = output.NewRawServer(addr, filter); err != nil {
+				o.close(old)
+				return nil, err
+			}
+		}
+		o.raw = append(o.raw, srv)
+		o.specs = append(o.specs, spec)
+	}
+
+	for _, addr := range s.beastFeeds {
+		f := output.NewBeastFeeder(addr)
+		f.SetPrivacy(privacy)
+		o.feeds = append(o.feeds, f)
+	}
+
+	if s.eventsFile != "" {
+		l, err := output.NewEventLog(s.eventsFile, s.archivePolicy())
+		if err != nil {
+			o.close(old)
+			return nil, err
+		}
+		o.events = l
+	}
+
+	if s.beastFile != "" {
+		b, err := output.NewBeastFile(s.beastFile, s.archivePolicy())
+		if err != nil {
+			o.close(old)
+			return nil, err
+		}
+		o.beast = b
+	}
+
+	if s.rawLogFile != "" {
+		l, err := output.NewRawLog(s.rawLogFile, s.archivePolicy())
+		if err != nil {
+			o.close(old)
+			return nil, err
+		}
+		o.rawLog = l
+	}
+
+	/* Reloading enables the raw servers taken over again. */
+	for _, srv := range o.raw {
+		srv.SetPrivacy(privacy)
+		srv.SetEnabled(true)
+	}
+	return o, nil
+}
+
+// Close the outputs, but the raw servers taken over by keep (nil to close
+// them all).
+func (o *outputSet) close(keep *outputSet) {
+	for _, s := range o.sinks {
+		s.Close()
+	}
+	for _, s := range o.raw {
+		if !keep.hasRaw(s) {
+			s.Close()
+		}
+	}
+	for _, f := range o.feeds {
+		f.Close()
+	}
+	if o.events != nil {
+		o.events.Close()
+	}
+	if o.beast != nil {
+		o.beast.Close()
+	}
+	if o.rawLog != nil {
+		o.rawLog.Close()
+	}
+}
+
+// The raw server is one of the set.
+func (o *outputSet) hasRaw(srv *output.RawServer) bool {
+	if o == nil {
+		return false
+	}
+	for _, s := range o.raw {
+		if s == srv {
+			return true
+		}
+	}
+	return false
+}
+
+// Register the output sinks selected on the command line.
+func (ctx *Context) initOutputs() error {
+	if *storeDir != "" {
+		s := ctx.currentSettings()
+		store, err := output.NewFileStore(*storeDir, s.archivePolicy())
+		if err != nil {
+			return err
+		}
+		store.SetMaxAge(time.Duration(s.aircraftTTL))
+		state, err := store.LoadState()
+		if err != nil {
+			return err
+		}
+		ctx.sky.Restore(state)
+		sink := output.NewStoreSink(store)
+		sink.SetMaxAge(time.Duration(s.aircraftTTL))
+		ctx.outputs.Add("store", sink)
+	}
+
+	ctx.sky.AddEventHandler(ctx.handleEvent)
+	return ctx.reloadOutputs(ctx.currentSettings())
+}
+
+// Open the reloadable outputs of the settings, then close the previous
+// ones. On error, the previous outputs are kept.
+func (ctx *Context) reloadOutputs(s *settings) error {
+	ctx.mux.RLock()
+	old := ctx.reloadable
+	ctx.mux.RUnlock()
+
+	o, err := openOutputs(s, old)
+	if err != nil {
+		return err
+	}
+
+	ctx.mux.Lock()
+	ctx.reloadable = o
+	ctx.mux.Unlock()
+
+	/* Out of the lock, as the dispatcher sends the queued updates of a
+	 * sink before removing it. */
+	if old != nil {
+		for _, sink := range old.sinks {
+			ctx.outputs.Remove(sink)
+		}
+		old.close(o)
+	}
+	for i, sink := range o.sinks {
+		ctx.outputs.Add(o.names[i], sink)
+	}
+	return nil
+}
+
+// Forward a decoded frame to the raw output servers, the feeders, the
+// Beast capture file and the raw log.
+func (ctx *Context) forwardRaw(msg *mode_s.ModeSMessage) {
+	ctx.mux.RLock()
+	defer ctx.mux.RUnlock()
+
+	if ctx.reloadable == nil {
+		return
+	}
+	for _, s := range ctx.reloadable.raw {
+		s.Forward(msg)
+	}
+	for _, f := range ctx.reloadable.feeds {
+		f.Forward(msg)
+	}
+	if ctx.reloadable.beast != nil {
+		if err := ctx.reloadable.beast.Forward(msg); err != nil {
+			logOutput.Error("beast capture stopped", "error", err)
+		}
+	}
+	if ctx.reloadable.rawLog != nil {
+		if err := ctx.reloadable.rawLog.Forward(msg); err != nil {
+			logOutput.Error("raw log stopped", "error", err)
+		}
+	}
+}
+
+// Connections of the feeders.
+func (ctx *Context) feederStatus() []output.FeederStatus {
+	ctx.mux.RLock()
+	defer ctx.mux.RUnlock()
+
+	list := []output.FeederStatus{}
+	if ctx.reloadable != nil {
+		for _, f := range ctx.reloadable.feeds {
+			list = append(list, f.Status())
+		}
+	}
+	return list
+}
+
+// Log the events of the sky and raise their webhook alerts.
+func (ctx *Context) handleEvent(e mode_s.Event) {
+	ctx.mux.RLock()
+	defer ctx.mux.RUnlock()
+
+	if ctx.reloadable != nil && ctx.reloadable.events != nil {
+		ctx.reloadable.events.Handle(e)
+	}
+	if ctx.reloadable != nil && ctx.reloadable.alerts != nil {
+		ctx.reloadable.alerts.Handle(e)
+	}
+}
+
+// Close the output sinks and servers.
+func (ctx *Context) closeOutputs() {
+	ctx.mux.Lock()
+	defer ctx.mux.Unlock()
+
+	if ctx.reloadable != nil {
+		for _, s := range ctx.reloadable.sinks {
+			ctx.outputs.Remove(s)
+		}
+		ctx.reloadable.close(nil)
+		ctx.reloadable = nil
+	}
+	ctx.outputs.Close()
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"go1090/i18n"
+	"go1090/mode_s"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/awesome-gocui/gocui"
+	. "github.com/logrusorgru/aurora"
+)
+
+// Smallest radar panel, columns, and the share of the terminal it takes
+// at most.
+const (
+	radarMinWidth = 21
+	radarMaxShare = 0.5
+)
+
+// Ranges of the radar when it fits the farthest aircraft, km.
+var radarRanges = []float64{10, 25, 50, 100, 150, 200, 250, 300, 400, 500}
+
+func (ctx *Context) toggleRadar(g *gocui.Gui) error {
+	ctx.list.radar = !ctx.list.radar
+	if err := ctx.layout(g); err != nil {
+		return err
+	}
+	return ctx.update(g)
+}
+
+// Left column of the radar panel, maxX when it is not shown. The panel is
+// about twice as wide as high, the cells of terminals being about twice
+// as high as wide, so that the rings are round.
+func (ctx *Context) radarLeft(maxX, maxY int) int {
+	if !ctx.list.radar {
+		return maxX
+	}
+	w := 2*(maxY-4) + 3
+	if max := int(float64(maxX) * radarMaxShare); w > max {
+		w = max
+	}
+	if w < radarMinWidth {
+		return maxX
+	}
+	return maxX - w
+}
+
+// Draw the aircraft around the receiver in the radar view, if shown.
+func (ctx *Context) updateRadar(g *gocui.Gui, aircrafts map[uint32]*mode_s.Aircraft) error {
+	v, err := g.View("radar")
+	if err == gocui.ErrUnknownView {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	v.Clear()
+
+	if _, _, ok := ctx.sky.ReceiverLocation(); !ok {
+		v.Title = i18n.S("ui.radar.title")
+		fmt.Fprintln(v, i18n.S("ui.radar.no_location"))
+		return nil
+	}
+
+	rangeKm := *radarRange
+	if rangeKm <= 0 {
+		rangeKm = radarFit(aircrafts)
+	}
+	v.Title = i18n.T("ui.radar.title_range", map[string]interface{}{"Range": rangeKm})
+
+	w, h := v.Size()
+	for _, line := range ctx.radarLines(aircrafts, w, h, rangeKm) {
+		fmt.Fprintln(v, line)
+	}
+	return nil
+}
+
+// Smallest of radarRanges fitting the farthest aircraft.
+func radarFit(aircrafts map[uint32]*mode_s.Aircraft) float64 {
+	farthest := 0.0
+	for _, ac := range aircrafts {
+		if ac.Ranged && ac.DistanceKm > farthest {
+			farthest = ac.DistanceKm
+		}
+	}
+	for _, r := range radarRanges {
+		if farthest <= r {
+			return r
+		}
+	}
+	return radarRanges[len(radarRanges)-1]
+}
+
+// Cells of the radar, a string of one column each, colored.
+type radarGrid struct {
+	cells          [][]string
+	cx, cy, rx, ry int // Center and radius of the outer ring, in cells.
+}
+
+func newRadarGrid(w, h int) *radarGrid {
+	r := &radarGrid{cells: make([][]string, h)}
+	for y := range r.cells {
+		r.cells[y] = make([]string, w)
+		for x := range r.cells[y] {
+			r.cells[y][x] = " "
+		}
+	}
+	r.cx, r.cy = (w-1)/2, (h-1)/2
+	r.ry = r.cy
+	r.rx = 2 * r.ry
+	if r.rx > r.cx {
+		r.rx = r.cx
+	}
+	return r
+}
+
+func (r *radarGrid) set(x, y int, s string) {
+	if y >= 0 && y < len(r.cells) && x >= 0 && x < len(r.cells[y]) {
+		r.cells[y][x] = s
+	}
+}
+
+// Cell of a bearing and a distance, a fraction of the outer ring.
+func (r *radarGrid) at(bearing, dist float64) (x, y int) {
+	a := bearing * math.Pi / 180
+	return r.cx + int(math.Round(dist*math.Sin(a)*float64(r.rx))),
+		r.cy - int(math.Round(dist*math.Cos(a)*float64(r.ry)))
+}
+
+// Lines of a radar of w by h cells: the receiver at the center, rings at
+// half and full range, and the aircraft by bearing and distance, labelled
+// with their flight.
+func (ctx *Context) radarLines(aircrafts map[uint32]*mode_s.Aircraft, w, h int, rangeKm float64) []string {
+	if w < 1 || h < 1 {
+		return nil
+	}
+	r := newRadarGrid(w, h)
+
+	for _, ring := range []float64{0.5, 1} {
+		for deg := 0.0; deg < 360; deg += 2 {
+			x, y := r.at(deg, ring)
+			r.set(x, y, Faint(".").String())
+		}
+	}
+	x, y := r.at(0, 1)
+	r.set(x, y, Bold("N").String())
+	r.set(r.cx, r.cy, Bold("+").String())
+
+	// The farthest first, so that the nearest are drawn over them.
+	var shown []*mode_s.Aircraft
+	for _, ac := range aircrafts {
+		if ac.Ranged && ac.DistanceKm <= rangeKm {
+			shown = append(shown, ac)
+		}
+	}
+	sort.Slice(shown, func(i, j int) bool { return shown[i].DistanceKm > shown[j].DistanceKm })
+
+	for _, ac := range shown {
+		x, y := r.at(ac.Bearing, ac.DistanceKm/rangeKm)
+		label := strings.TrimRight(ac.Flight, " \x00")
+		if label == "" {
+			label = ac.HexAddr
+		}
+		if x+1+len(label) >= w {
+			x -= len(label) + 1
+		}
+		for i, c := range label {
+			r.set(x+1+i, y, radarCell(ctx, ac, string(c)))
+		}
+	}
+	for _, ac := range shown {
+		x, y := r.at(ac.Bearing, ac.DistanceKm/rangeKm)
+		r.set(x, y, radarCell(ctx, ac, radarSymbol(ac)))
+	}
+
+	lines := make([]string, h)
+	for y, row := range r.cells {
+		lines[y] = strings.Join(row, "")
+	}
+	return lines
+}
+
+// Color of an aircraft as in the list, reversed when selected.
+func radarCell(ctx *Context, ac *mode_s.Aircraft, s string) string {
+	v := rowFormat(ac, s)
+	if ctx.list.hasSel && ac.Addr == ctx.list.selected {
+		v = v.Reverse()
+	}
+	return v.String()
+}
+
+// Symbol of an aircraft pointing to its track, a star when unknown.
+func radarSymbol(ac *mode_s.Aircraft) string {
+	if ac.Speed == 0 {
+		return "*"
+	}
+	return [...]string{"^", ">", "v", "<"}[((ac.Track%360+360+45)%360)/90]
+}
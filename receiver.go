@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"go1090/i18n"
+	"go1090/rtl_adsb"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/logrusorgru/aurora"
+)
+
+// Command-line arguments of rtl_adsb (source A).
+func receiverArgs() []string {
+	opts := rtl_adsb.ReceiverOptions{Device: *rtlDevice, Gain: *rtlGain, PPM: *rtlPPM}
+	return append(opts.Args(), strings.Fields(*rtlArgs)...)
+}
+
+// Status handler of a supervised rtl_adsb: log its exits and restarts,
+// and keep its state for the status bar and the health checks.
+func (ctx *Context) watchReceiver(source string) func(rtl_adsb.ReceiverStatus) {
+	return func(st rtl_adsb.ReceiverStatus) {
+		if st.Running && st.Restarts > 0 {
+			logReceiver.Info("rtl_adsb restarted", "source", source, "restarts", st.Restarts)
+		} else if !st.Running {
+			logReceiver.Warn("rtl_adsb stopped", "source", source, "error", st.Err,
+				"retry_in", time.Until(st.Retry).Round(time.Second))
+		}
+
+		ctx.mux.Lock()
+		if ctx.receivers == nil {
+			ctx.receivers = make(map[string]rtl_adsb.ReceiverStatus)
+		}
+		ctx.receivers[source] = st
+		ctx.mux.Unlock()
+
+		ctx.redraw()
+	}
+}
+
+// State of the supervised rtl_adsb processes, by source.
+func (ctx *Context) receiverStates() map[string]rtl_adsb.ReceiverStatus {
+	ctx.mux.RLock()
+	defer ctx.mux.RUnlock()
+
+	states := make(map[string]rtl_adsb.ReceiverStatus, len(ctx.receivers))
+	for source, st := range ctx.receivers {
+		states[source] = st
+	}
+	return states
+}
+
+// Stopped or restarted rtl_adsb processes, for the status bar.
+func (ctx *Context) receiverStatus() string {
+	states := ctx.receiverStates()
+	sources := make([]string, 0, len(states))
+	for source := range states {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	line := ""
+	for _, source := range sources {
+		st := states[source]
+		if !st.Running {
+			retry := time.Until(st.Retry)
+			if retry < 0 {
+				retry = 0
+			}
+			line += i18n.T("ui.status.receiver_down", map[string]interface{}{
+				"Source": source,
+				"Error":  Red(st.Err.Error()),
+				"Retry":  Red(fmt.Sprintf("%.0f", retry.Seconds())),
+			})
+		} else if st.Restarts > 0 {
+			line += i18n.T("ui.status.receiver_restarts", map[string]interface{}{
+				"Source": source,
+				"Count":  Yellow(st.Restarts),
+			})
+		}
+	}
+	return line
+}
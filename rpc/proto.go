@@ -0,0 +1,281 @@
+package rpc
+
+import (
+	"errors"
+	"go1090/mode_s"
+	"math"
+	"strings"
+)
+
+// Protocol buffers encoding of the messages of go1090.proto. The schema is
+// small and only streamed out, so it is encoded by hand rather than with
+// generated code.
+
+// Wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errMalformed = errors.New("malformed protocol buffer")
+
+type protoBuffer []byte
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+func (b *protoBuffer) tag(field, wire int) {
+	b.varint(uint64(field)<<3 | uint64(wire))
+}
+
+// Fields with the default value are not encoded, unless optional.
+
+func (b *protoBuffer) uint(field int, v uint64) {
+	if v != 0 {
+		b.optionalUint(field, v)
+	}
+}
+
+func (b *protoBuffer) optionalUint(field int, v uint64) {
+	b.tag(field, wireVarint)
+	b.varint(v)
+}
+
+func (b *protoBuffer) int(field int, v int64) {
+	b.uint(field, uint64(v))
+}
+
+func (b *protoBuffer) optionalInt(field int, v int64) {
+	b.optionalUint(field, uint64(v))
+}
+
+func (b *protoBuffer) bool(field int, v bool) {
+	if v {
+		b.uint(field, 1)
+	}
+}
+
+func (b *protoBuffer) optionalDouble(field int, v float64) {
+	b.tag(field, wireFixed64)
+	bits := math.Float64bits(v)
+	for i := uint(0); i < 64; i += 8 {
+		*b = append(*b, byte(bits>>i))
+	}
+}
+
+func (b *protoBuffer) bytes(field int, v []byte) {
+	if len(v) != 0 {
+		b.optionalBytes(field, v)
+	}
+}
+
+func (b *protoBuffer) optionalBytes(field int, v []byte) {
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *protoBuffer) string(field int, v string) {
+	b.bytes(field, []byte(v))
+}
+
+// Decode the fields of a message, calling f with the varint value or the
+// bytes of every field. Fixed size fields are skipped.
+func decodeFields(data []byte, f func(field, wire int, v uint64, bytes []byte) error) error {
+	for len(data) > 0 {
+		key, n := decodeVarint(data)
+		if n == 0 {
+			return errMalformed
+		}
+		data = data[n:]
+		field, wire := int(key>>3), int(key&7)
+
+		var v uint64
+		var bytes []byte
+		switch wire {
+		case wireVarint:
+			if v, n = decodeVarint(data); n == 0 {
+				return errMalformed
+			}
+		case wireFixed64:
+			n = 8
+		case wireFixed32:
+			n = 4
+		case wireBytes:
+			l, m := decodeVarint(data)
+			if m == 0 || uint64(len(data)-m) < l {
+				return errMalformed
+			}
+			bytes, n = data[m:m+int(l)], m+int(l)
+		default:
+			return errMalformed
+		}
+		if len(data) < n {
+			return errMalformed
+		}
+		data = data[n:]
+
+		if err := f(field, wire, v, bytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode a varint, n = 0 if it is truncated or too long.
+func decodeVarint(data []byte) (v uint64, n int) {
+	for i := 0; i < len(data) && i < 10; i++ {
+		v |= uint64(data[i]&0x7f) << (7 * uint(i))
+		if data[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// Decode a repeated uint32 field, packed or not.
+func appendUint32s(list []uint32, wire int, v uint64, bytes []byte) ([]uint32, error) {
+	if wire == wireVarint {
+		return append(list, uint32(v)), nil
+	}
+	for len(bytes) > 0 {
+		v, n := decodeVarint(bytes)
+		if n == 0 {
+			return nil, errMalformed
+		}
+		list = append(list, uint32(v))
+		bytes = bytes[n:]
+	}
+	return list, nil
+}
+
+// StreamMessagesRequest message.
+type StreamMessagesRequest struct {
+	DownlinkFormats []uint32
+	IncludeBadCRC   bool
+}
+
+func (r *StreamMessagesRequest) unmarshal(data []byte) error {
+	return decodeFields(data, func(field, wire int, v uint64, bytes []byte) (err error) {
+		switch field {
+		case 1:
+			r.DownlinkFormats, err = appendUint32s(r.DownlinkFormats, wire, v, bytes)
+		case 2:
+			r.IncludeBadCRC = v != 0
+		}
+		return err
+	})
+}
+
+func (r *StreamMessagesRequest) match(mm *mode_s.ModeSMessage) bool {
+	if !mm.CRCOk() && mm.DF() != mode_s.MODES_AC_MSGTYPE && !r.IncludeBadCRC {
+		return false
+	}
+	if len(r.DownlinkFormats) == 0 {
+		return true
+	}
+	for _, df := range r.DownlinkFormats {
+		if int(df) == mm.DF() {
+			return true
+		}
+	}
+	return false
+}
+
+// StreamAircraftRequest message.
+type StreamAircraftRequest struct {
+	ICAO []string
+}
+
+func (r *StreamAircraftRequest) unmarshal(data []byte) error {
+	return decodeFields(data, func(field, wire int, v uint64, bytes []byte) error {
+		if field == 1 && wire == wireBytes {
+			r.ICAO = append(r.ICAO, strings.ToLower(string(bytes)))
+		}
+		return nil
+	})
+}
+
+func (r *StreamAircraftRequest) match(ac *mode_s.Aircraft) bool {
+	if len(r.ICAO) == 0 {
+		return true
+	}
+	hex := strings.ToLower(ac.HexAddr)
+	for _, icao := range r.ICAO {
+		if icao == hex {
+			return true
+		}
+	}
+	return false
+}
+
+// Encode a decoded message as a Message.
+func marshalMessage(mm *mode_s.ModeSMessage) []byte {
+	p := mm.Provenance()
+	var b protoBuffer
+	if !p.Received.IsZero() {
+		b.int(1, p.Received.UnixNano())
+	}
+	b.string(2, p.Source)
+	b.bytes(3, mm.Bytes())
+	b.uint(4, uint64(mm.DF()))
+	b.uint(5, uint64(mm.ICAO()))
+	b.bool(6, mm.CRCOk())
+	b.uint(7, uint64(mm.CorrectedBits()))
+	if mm.IsExtendedSquitter() {
+		b.uint(8, uint64(mm.TypeCode()))
+	}
+	if alt, ok := mm.Altitude(); ok {
+		b.optionalInt(9, int64(alt))
+	}
+	if squawk, ok := mm.Squawk(); ok {
+		b.optionalUint(10, uint64(squawk))
+	}
+	if callsign, ok := mm.Callsign(); ok {
+		b.optionalBytes(11, []byte(callsign))
+	}
+	if speed, track, ok := mm.Velocity(); ok {
+		b.optionalInt(12, int64(speed))
+		b.optionalInt(13, int64(track))
+	}
+	if rate, ok := mm.VerticalRate(); ok {
+		b.optionalInt(14, int64(rate))
+	}
+	return b
+}
+
+// Encode an aircraft as an Aircraft message.
+func marshalAircraft(ac *mode_s.Aircraft) []byte {
+	var b protoBuffer
+	b.uint(1, uint64(ac.Addr))
+	b.string(2, strings.ToLower(ac.HexAddr))
+	b.string(3, strings.TrimRight(ac.Flight, " \x00"))
+	if ac.Altitude != 0 {
+		b.optionalInt(4, int64(ac.Altitude))
+	}
+	if ac.Speed != 0 {
+		b.optionalInt(5, int64(ac.Speed))
+		b.optionalInt(6, int64(ac.Track))
+	}
+	if ac.VertRateValid {
+		b.optionalInt(7, int64(ac.VertRate))
+	}
+	if ac.Trail.Len() > 0 {
+		b.optionalDouble(8, ac.Latitude)
+		b.optionalDouble(9, ac.Longitude)
+	}
+	if ac.Squawk != 0 {
+		b.optionalUint(10, uint64(ac.Squawk))
+	}
+	b.bool(11, ac.Emergency)
+	b.int(12, ac.Seen.UnixNano())
+	b.int(13, ac.Messages)
+	return b
+}
@@ -0,0 +1,303 @@
+// Package rpc serves decoded messages and aircraft updates as gRPC
+// streams, see go1090.proto.
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"go1090/mode_s"
+	"go1090/output"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of messages queued for a stream before messages are dropped.
+const streamQueueLen = 256
+
+// Time given to the streams to end when the server is closed.
+const shutdownTimeout = time.Second
+
+// Largest request message accepted.
+const maxRequestLen = 64 * 1024
+
+// gRPC status codes.
+const (
+	statusOK              = 0
+	statusInvalidArgument = 3
+	statusUnimplemented   = 12
+	statusUnavailable     = 14
+)
+
+// Server is the gRPC server, speaking HTTP/2 without TLS like the
+// "insecure" credentials of the gRPC clients, e.g.
+//
+//	grpcurl -plaintext -proto rpc/go1090.proto localhost:50051 go1090.Go1090/StreamAircraft
+//
+// It is an output.Sink for the aircraft updates, and Forward receives
+// the decoded messages.
+type Server struct {
+	listener net.Listener
+	http     *http.Server
+	messages map[*stream]*StreamMessagesRequest
+	aircraft map[*stream]*StreamAircraftRequest
+	privacy  *output.Privacy
+	closed   bool
+
+	mux sync.Mutex
+}
+
+// A response stream, closed when the server is closed.
+type stream struct {
+	queue  chan []byte
+	done   chan struct{}
+	remove func() // Unregister the stream, under the Server lock.
+}
+
+// NewServer function.
+// Listen on addr, e.g. ":50051".
+func NewServer(addr string) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("gRPC error: %s", err.Error())
+	}
+
+	s := &Server{
+		listener: l,
+		messages: make(map[*stream]*StreamMessagesRequest),
+		aircraft: make(map[*stream]*StreamAircraftRequest),
+	}
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	s.http = &http.Server{Handler: s, Protocols: &protocols}
+	go s.http.Serve(l)
+
+	return s, nil
+}
+
+// Addr returns the listening address.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// SetPrivacy hides the positions and the blocked aircraft. The messages
+// of the blocked aircraft are dropped, the positions of the messages are
+// not changed.
+func (s *Server) SetPrivacy(p *output.Privacy) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.privacy = p
+}
+
+// Forward a decoded message to the StreamMessages streams. Slow clients
+// lose messages rather than blocking the decoder.
+func (s *Server) Forward(mm *mode_s.ModeSMessage) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(s.messages) == 0 || s.privacy.Blocked(mm.ICAO()) {
+		return
+	}
+
+	var data []byte
+	for st, req := range s.messages {
+		if !req.match(mm) {
+			continue
+		}
+		if data == nil {
+			data = frame(marshalMessage(mm))
+		}
+		st.send(data)
+	}
+}
+
+// Update sends an aircraft to the StreamAircraft streams.
+func (s *Server) Update(ac *mode_s.Aircraft) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if len(s.aircraft) == 0 {
+		return nil
+	}
+	if ac = s.privacy.Apply(ac); ac == nil {
+		return nil
+	}
+
+	var data []byte
+	for st, req := range s.aircraft {
+		if !req.match(ac) {
+			continue
+		}
+		if data == nil {
+			data = frame(marshalAircraft(ac))
+		}
+		st.send(data)
+	}
+	return nil
+}
+
+// Close stops listening and ends the streams.
+func (s *Server) Close() error {
+	s.mux.Lock()
+	if !s.closed {
+		s.closed = true
+		for st := range s.messages {
+			close(st.done)
+		}
+		for st := range s.aircraft {
+			close(st.done)
+		}
+	}
+	s.mux.Unlock()
+
+	/* Let the streams send their status before closing the connections. */
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.http.Shutdown(ctx); err != nil {
+		return s.http.Close()
+	}
+	return nil
+}
+
+func (st *stream) send(data []byte) {
+	select {
+	case st.queue <- data:
+	default:
+	}
+}
+
+// ServeHTTP function.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc")
+
+	body, err := readRequest(r.Body)
+	if err != nil {
+		writeStatus(w, statusInvalidArgument, err.Error())
+		return
+	}
+
+	st := &stream{
+		queue: make(chan []byte, streamQueueLen),
+		done:  make(chan struct{}),
+	}
+	var add func()
+	switch r.URL.Path {
+	case "/go1090.Go1090/StreamMessages":
+		req := &StreamMessagesRequest{}
+		if err := req.unmarshal(body); err != nil {
+			writeStatus(w, statusInvalidArgument, err.Error())
+			return
+		}
+		add = func() { s.messages[st] = req }
+		st.remove = func() { delete(s.messages, st) }
+	case "/go1090.Go1090/StreamAircraft":
+		req := &StreamAircraftRequest{}
+		if err := req.unmarshal(body); err != nil {
+			writeStatus(w, statusInvalidArgument, err.Error())
+			return
+		}
+		add = func() { s.aircraft[st] = req }
+		st.remove = func() { delete(s.aircraft, st) }
+	default:
+		writeStatus(w, statusUnimplemented, "unknown method "+r.URL.Path)
+		return
+	}
+	if !s.subscribe(add) {
+		writeStatus(w, statusUnavailable, "server closed")
+		return
+	}
+	defer s.unsubscribe(st)
+
+	/* The status is sent in trailers once the stream started. */
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case data := <-st.queue:
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-st.done:
+			writeStatus(w, statusOK, "")
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Register a stream with add, false if the server is closed.
+func (s *Server) subscribe(add func()) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return false
+	}
+	add()
+	return true
+}
+
+func (s *Server) unsubscribe(st *stream) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	st.remove()
+}
+
+// Read the request message of a call.
+func readRequest(body io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxRequestLen+5))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if len(data) < 5 {
+		return nil, errMalformed
+	}
+	if data[0] != 0 {
+		return nil, fmt.Errorf("compressed requests are not supported")
+	}
+	n := binary.BigEndian.Uint32(data[1:5])
+	if n > maxRequestLen || int(n) > len(data)-5 {
+		return nil, errMalformed
+	}
+	return data[5 : 5+n], nil
+}
+
+// Prefix a message with the uncompressed flag and its length.
+func frame(msg []byte) []byte {
+	data := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(data[1:], uint32(len(msg)))
+	return append(data, msg...)
+}
+
+// Set the status trailers, or headers if nothing was sent.
+func writeStatus(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Grpc-Status", fmt.Sprint(code))
+	if msg != "" {
+		w.Header().Set("Grpc-Message", msg)
+	}
+}
@@ -0,0 +1,160 @@
+package rtl_adsb
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// MLATClockRate is the frequency of the Beast/AVR MLAT timestamp counter.
+const MLATClockRate = 12e6
+
+const (
+	clockWindow     = 600   // Samples used for the estimation.
+	clockMinSamples = 30    // Samples needed before reporting.
+	clockMaxDrift   = 100.0 // ppm, beyond that timestamps are not usable.
+	clockMaxJitter  = 0.050 // s, residual jitter beyond that is unusable.
+)
+
+// ClockReport is the result of a clock drift estimation.
+type ClockReport struct {
+	Samples  int     // Number of samples in the estimation window.
+	DriftPPM float64 // Source clock rate error, parts per million.
+	Jitter   float64 // Residual standard deviation, seconds.
+	Resets   int     // Number of times the counter went backwards.
+	Usable   bool    // Timestamps look good enough for MLAT.
+	Reason   string  // Why the timestamps are not usable.
+}
+
+type clockSample struct {
+	local  float64 // Local monotonic time, seconds.
+	source float64 // Source time, seconds.
+}
+
+// ClockDrift estimates the drift of a source's MLAT clock relative to the
+// local monotonic clock, by linear regression over the last samples.
+type ClockDrift struct {
+	start    time.Time
+	first    uint64
+	last     uint64
+	samples  []clockSample
+	next     int
+	resets   int
+	constant int
+	total    int
+
+	mux sync.Mutex
+}
+
+// NewClockDrift function.
+func NewClockDrift() *ClockDrift {
+	return &ClockDrift{
+		samples: make([]clockSample, 0, clockWindow),
+	}
+}
+
+// Observe adds a frame to the estimation. Frames without a timestamp are
+// ignored.
+func (c *ClockDrift) Observe(f Frame) {
+	if f.Timestamp == 0 {
+		return
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.total++
+
+	if c.start.IsZero() || f.Timestamp < c.last {
+		/* First sample, or the counter was reset: restart the window. */
+		if !c.start.IsZero() {
+			c.resets++
+		}
+		c.start = f.Received
+		c.first = f.Timestamp
+		c.samples = c.samples[:0]
+		c.next = 0
+	} else if f.Timestamp == c.last {
+		c.constant++
+	}
+	c.last = f.Timestamp
+
+	s := clockSample{
+		local:  f.Received.Sub(c.start).Seconds(),
+		source: float64(f.Timestamp-c.first) / MLATClockRate,
+	}
+
+	if len(c.samples) < clockWindow {
+		c.samples = append(c.samples, s)
+	} else {
+		c.samples[c.next] = s
+		c.next = (c.next + 1) % clockWindow
+	}
+}
+
+// Seen returns true if at least one timestamped frame was observed.
+func (c *ClockDrift) Seen() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.total > 0
+}
+
+// Report returns the current estimation.
+func (c *ClockDrift) Report() ClockReport {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	r := ClockReport{
+		Samples: len(c.samples),
+		Resets:  c.resets,
+	}
+
+	if len(c.samples) < clockMinSamples {
+		r.Reason = "not enough samples"
+		return r
+	}
+
+	/* Least squares fit of source time against local time. */
+	var sx, sy, sxx, sxy float64
+	n := float64(len(c.samples))
+	for _, s := range c.samples {
+		sx += s.local
+		sy += s.source
+		sxx += s.local * s.local
+		sxy += s.local * s.source
+	}
+
+	den := n*sxx - sx*sx
+	if den == 0 {
+		r.Reason = "no time spread"
+		return r
+	}
+
+	slope := (n*sxy - sx*sy) / den
+	offset := (sy - slope*sx) / n
+
+	var ss float64
+	for _, s := range c.samples {
+		d := s.source - (slope*s.local + offset)
+		ss += d * d
+	}
+
+	r.DriftPPM = (slope - 1) * 1e6
+	r.Jitter = math.Sqrt(ss / n)
+
+	switch {
+	case c.constant*2 > c.total:
+		r.Reason = "timestamps do not advance"
+	case c.resets*10 > c.total:
+		r.Reason = "timestamps go backwards"
+	case math.Abs(r.DriftPPM) > clockMaxDrift:
+		r.Reason = "drift too large"
+	case r.Jitter > clockMaxJitter:
+		r.Reason = "jitter too large"
+	default:
+		r.Usable = true
+	}
+
+	return r
+}
@@ -0,0 +1,145 @@
+package rtl_adsb
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Frames received by several sources within this window are considered
+// the same transmission.
+const compareWindow = 500 * time.Millisecond
+
+// SourceStats are the comparison counters of one source.
+type SourceStats struct {
+	Frames    int64 `json:"frames"`    // Frames received.
+	First     int64 `json:"first"`     // Frames this source received before the others.
+	Exclusive int64 `json:"exclusive"` // Frames received by this source only.
+}
+
+// CompareReport is a snapshot of a comparison.
+type CompareReport struct {
+	Sources map[string]SourceStats `json:"sources"`
+	Common  int64                  `json:"common"` // Frames received by more than one source.
+}
+
+type compareEntry struct {
+	first    string
+	received time.Time
+	seenBy   map[string]bool
+}
+
+// Comparator compares the reception of several sources (antennas) of the
+// same traffic: which source first receives each frame, and how many frames
+// only one source receives.
+type Comparator struct {
+	pending    map[ADSBMsg]*compareEntry
+	stats      map[string]*SourceStats
+	common     int64
+	lastExpire time.Time
+
+	mux sync.Mutex
+}
+
+// NewComparator function.
+func NewComparator(sources ...string) *Comparator {
+	c := &Comparator{
+		pending: make(map[ADSBMsg]*compareEntry),
+		stats:   make(map[string]*SourceStats),
+	}
+	for _, s := range sources {
+		c.stats[s] = &SourceStats{}
+	}
+	return c
+}
+
+// Observe a frame tagged with its Source. Returns true if this is the
+// first copy of the frame, which should be decoded; later copies from
+// other sources are duplicates.
+func (c *Comparator) Observe(f Frame) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	now := f.Received
+	if now.Sub(c.lastExpire) >= compareWindow {
+		c.expire(now)
+		c.lastExpire = now
+	}
+
+	st := c.stats[f.Source]
+	if st == nil {
+		st = &SourceStats{}
+		c.stats[f.Source] = st
+	}
+	st.Frames++
+
+	e := c.pending[f.Msg]
+	if e != nil && now.Sub(e.received) <= compareWindow {
+		if !e.seenBy[f.Source] {
+			if len(e.seenBy) == 1 {
+				c.common++
+			}
+			e.seenBy[f.Source] = true
+		}
+		return false
+	}
+
+	if e != nil {
+		c.settle(e)
+	}
+	c.pending[f.Msg] = &compareEntry{
+		first:    f.Source,
+		received: now,
+		seenBy:   map[string]bool{f.Source: true},
+	}
+	return true
+}
+
+// Account a frame whose window is over.
+func (c *Comparator) settle(e *compareEntry) {
+	st := c.stats[e.first]
+	if len(e.seenBy) == 1 {
+		st.Exclusive++
+	} else {
+		st.First++
+	}
+}
+
+func (c *Comparator) expire(now time.Time) {
+	for msg, e := range c.pending {
+		if now.Sub(e.received) > compareWindow {
+			c.settle(e)
+			delete(c.pending, msg)
+		}
+	}
+}
+
+// Sources returns the names of the compared sources.
+func (c *Comparator) Sources() []string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	names := make([]string, 0, len(c.stats))
+	for name := range c.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Report returns the counters of frames whose comparison window is over.
+func (c *Comparator) Report() CompareReport {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.expire(time.Now())
+
+	r := CompareReport{
+		Sources: make(map[string]SourceStats, len(c.stats)),
+		Common:  c.common,
+	}
+	for name, st := range c.stats {
+		r.Sources[name] = *st
+	}
+	return r
+}
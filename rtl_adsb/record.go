@@ -0,0 +1,150 @@
+package rtl_adsb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Recorder writes received frames to a log replayable with StartReplay:
+//   <RFC 3339 time> *112233445566778899AABBCCDDEE;
+// with "@...;" lines for frames with an MLAT timestamp and "*7700;" for
+// Mode A/C replies. A path ending with ".gz" or ".zst" is compressed with
+// gzip or zstd.
+type Recorder struct {
+	file *os.File
+	comp io.WriteCloser // Compressor, nil for a plain log.
+	w    *bufio.Writer
+
+	mux sync.Mutex
+}
+
+// Magic numbers of the compressed logs.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// NewRecorder function.
+// Frames are appended to an existing log: compressed logs then have
+// several streams, which StartReplay reads one after the other.
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("record error: %s", err.Error())
+	}
+
+	r := &Recorder{file: f}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz":
+		r.comp = gzip.NewWriter(f)
+	case ".zst":
+		if r.comp, err = zstd.NewWriter(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("record error: %s", err.Error())
+		}
+	}
+
+	if r.comp != nil {
+		r.w = bufio.NewWriter(r.comp)
+	} else {
+		r.w = bufio.NewWriter(f)
+	}
+	return r, nil
+}
+
+// Record function.
+func (r *Recorder) Record(f Frame) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.w == nil {
+		return fmt.Errorf("record error: closed")
+	}
+
+	r.w.WriteString(f.Received.UTC().Format(time.RFC3339Nano))
+	r.w.WriteByte(' ')
+	switch {
+	case f.ModeAC:
+		fmt.Fprintf(r.w, "*%02X%02X;", f.Msg[0], f.Msg[1])
+	case f.Timestamp != 0:
+		fmt.Fprintf(r.w, "@%012X%X;", f.Timestamp&0xffffffffffff, f.Msg[:])
+	default:
+		fmt.Fprintf(r.w, "*%X;", f.Msg[:])
+	}
+	if err := r.w.WriteByte('\n'); err != nil {
+		return fmt.Errorf("record error: %s", err.Error())
+	}
+	return nil
+}
+
+// Close flushes the log.
+func (r *Recorder) Close() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.w == nil {
+		return nil
+	}
+	err := r.w.Flush()
+	r.w = nil
+	if r.comp != nil {
+		if e := r.comp.Close(); err == nil {
+			err = e
+		}
+	}
+	if e := r.file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return fmt.Errorf("record error: %s", err.Error())
+	}
+	return nil
+}
+
+// A replay log, decompressed when it starts with the magic number of gzip
+// or zstd.
+type replayFile struct {
+	io.Reader
+	file  *os.File
+	close func() // Of the decompressor, nil for a plain log.
+}
+
+func newReplayFile(f *os.File) (*replayFile, error) {
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(len(zstdMagic))
+
+	r := &replayFile{Reader: br, file: f}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		r.Reader, r.close = zr, func() { zr.Close() }
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		r.Reader, r.close = zr, zr.Close
+	}
+	return r, nil
+}
+
+// Close function.
+func (r *replayFile) Close() error {
+	if r.close != nil {
+		r.close()
+	}
+	return r.file.Close()
+}
@@ -3,38 +3,77 @@ package rtl_adsb
 import (
 	"bufio"
 	"fmt"
+	"go1090/mode_s"
+	"io"
 	"os/exec"
 	"strconv"
+	"time"
 )
 
 type ADSBMsg [14]byte
 
+// Frame is a received message with its reception metadata, as decoded by
+// the mode_s package.
+type Frame = mode_s.Frame
+
 // MessageHandler is function for handling ADS-B Message.
 type MessageHandler func(ADSBMsg)
 
+// FrameHandler is function for handling received frames.
+type FrameHandler func(Frame)
+
+// ReceiverOptions are the tuning options of rtl_adsb. The zero value
+// keeps the defaults of rtl_adsb.
+type ReceiverOptions struct {
+	Device int     // Device index.
+	Gain   float64 // Tuner gain in dB, 0 = automatic.
+	PPM    int     // Frequency correction in ppm.
+}
+
+// Args returns the rtl_adsb command-line arguments of the options.
+func (o ReceiverOptions) Args() []string {
+	var args []string
+	if o.Device != 0 {
+		args = append(args, "-d", strconv.Itoa(o.Device))
+	}
+	if o.Gain != 0 {
+		args = append(args, "-g", strconv.FormatFloat(o.Gain, 'f', -1, 64))
+	}
+	if o.PPM != 0 {
+		args = append(args, "-p", strconv.Itoa(o.PPM))
+	}
+	return args
+}
+
 // StartReceive function.
 func StartReceive(execPath string, handler MessageHandler) (func(), error) {
-	cmd := exec.Command(execPath)
-	stdout, err := cmd.StdoutPipe()
+	return StartReceiveWithArgs(execPath, nil, handler)
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("RTL-ADSB error: %s", err.Error())
-	}
+// StartReceiveWithArgs function.
+// Like StartReceive, running rtl_adsb with arguments, e.g.
+// []string{"-g", "42", "-d", "1"}.
+func StartReceiveWithArgs(execPath string, args []string, handler MessageHandler) (func(), error) {
+	return StartReceiveFramesWithArgs(execPath, args, func(f Frame) {
+		handler(f.Msg)
+	})
+}
 
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("RTL-ADSB error: %s", err.Error())
+// StartReceiveFrames function.
+// Like StartReceive, but the handler also gets the reception metadata.
+func StartReceiveFrames(execPath string, handler FrameHandler) (func(), error) {
+	return StartReceiveFramesWithArgs(execPath, nil, handler)
+}
+
+// StartReceiveFramesWithArgs function.
+func StartReceiveFramesWithArgs(execPath string, args []string, handler FrameHandler) (func(), error) {
+	cmd, stdout, err := startCommand(execPath, args)
+	if err != nil {
+		return nil, err
 	}
 
 	go func() {
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			line := scanner.Text()
-
-			m := parseADSB(line)
-			if m != nil {
-				handler(*m)
-			}
-		}
+		ScanFrames(stdout, handler)
 		cmd.Wait()
 	}()
 	return func() {
@@ -42,6 +81,63 @@ func StartReceive(execPath string, handler MessageHandler) (func(), error) {
 	}, nil
 }
 
+// StartReaderFrames function.
+// Reads "*...;" frames from r in the background, e.g. os.Stdin at the end
+// of a shell pipeline. The stop function closes r if it is an io.Closer.
+func StartReaderFrames(r io.Reader, handler FrameHandler) (func(), error) {
+	go ScanFrames(r, handler)
+	return func() {
+		if c, ok := r.(io.Closer); ok {
+			c.Close()
+		}
+	}, nil
+}
+
+// Start rtl_adsb, returning its standard output.
+func startCommand(execPath string, args []string) (*exec.Cmd, io.Reader, error) {
+	cmd := exec.Command(execPath, args...)
+	stdout, err := cmd.StdoutPipe()
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("RTL-ADSB error: %s", err.Error())
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("RTL-ADSB error: %s", err.Error())
+	}
+	return cmd, stdout, nil
+}
+
+// ScanFrames reads "*...;" (and MLAT "@...;") frames line by line from r
+// until EOF, e.g. from a TCP connection to a raw output port.
+func ScanFrames(r io.Reader, handler FrameHandler) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if f := parseFrame(scanner.Text()); f != nil {
+			handler(*f)
+		}
+	}
+}
+
+// Parse a "*...;" or MLAT timestamped "@...;" line.
+func parseFrame(line string) *Frame {
+	received := time.Now()
+
+	if m := parseADSB(line); m != nil {
+		return &Frame{Msg: *m, Received: received}
+	}
+
+	if m, ts := parseTimestampedADSB(line); m != nil {
+		return &Frame{Msg: *m, Received: received, Timestamp: ts}
+	}
+
+	if m := parseModeAC(line); m != nil {
+		return &Frame{Msg: *m, Received: received, ModeAC: true}
+	}
+
+	return nil
+}
+
 // Parse ADS-B data.
 // See: https://mode-s.org/decode/adsb/introduction.html
 func parseADSB(hexstr string) *ADSBMsg {
@@ -68,6 +164,42 @@ func parseADSB(hexstr string) *ADSBMsg {
 	return nil
 }
 
+// Parse AVR data with MLAT timestamp.
+// message format (dump1090/readsb AVR-MLAT output):
+//   @0123456789AB112233445566778899AABBCCDDEE;
+func parseTimestampedADSB(hexstr string) (*ADSBMsg, uint64) {
+	if len(hexstr) != 42 || hexstr[0] != '@' || hexstr[41] != ';' {
+		return nil, 0
+	}
+
+	ts, err := strconv.ParseUint(hexstr[1:13], 16, 64)
+	if err != nil {
+		return nil, 0
+	}
+
+	m := parseADSB("*" + hexstr[13:])
+	return m, ts
+}
+
+// Parse a Mode A/C reply.
+// message format (dump1090/readsb AVR output with Mode A/C enabled):
+//   *7700;
+func parseModeAC(hexstr string) *ADSBMsg {
+	if len(hexstr) != 6 || hexstr[0] != '*' || hexstr[5] != ';' {
+		return nil
+	}
+
+	n, err := strconv.ParseUint(hexstr[1:5], 16, 16)
+	if err != nil {
+		return nil
+	}
+
+	var bin ADSBMsg
+	bin[0] = uint8(n >> 8)
+	bin[1] = uint8(n)
+	return &bin
+}
+
 func parseHex(hexstr string) uint8 {
 	n, _ := strconv.ParseUint(hexstr, 16, 8)
 	return uint8(n)
@@ -0,0 +1,102 @@
+package rtl_adsb
+
+import (
+	"context"
+	"io"
+)
+
+// Source produces received frames on a channel, an alternative to the
+// Start functions and their handlers for composing stages with channels.
+// The channel is closed at the end of the input or when ctx is canceled.
+// It is unbuffered: the source waits for the reader of the channel.
+type Source interface {
+	Frames(ctx context.Context) (<-chan Frame, error)
+}
+
+// ReceiverSource runs rtl_adsb, which is killed when ctx is canceled.
+type ReceiverSource struct {
+	ExecPath string
+	Args     []string // e.g. ReceiverOptions.Args()
+	Name     string   // Frame.Source of the frames.
+}
+
+// ReplaySource replays a log of timestamped frames, see StartReplay.
+type ReplaySource struct {
+	Path  string
+	Speed float64
+	Name  string // Frame.Source of the frames.
+}
+
+// ReaderSource reads "*...;" frames from a reader, e.g. a TCP connection
+// to a raw output port. Canceling ctx does not interrupt a blocked Read:
+// close the reader to stop it.
+type ReaderSource struct {
+	Reader io.Reader
+	Name   string // Frame.Source of the frames.
+}
+
+// Frames function.
+func (s ReceiverSource) Frames(ctx context.Context) (<-chan Frame, error) {
+	cmd, stdout, err := startCommand(s.ExecPath, s.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Frame)
+	go func() {
+		defer close(ch)
+		defer cmd.Wait()
+
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-ctx.Done():
+				cmd.Process.Kill()
+			case <-stop:
+			}
+		}()
+
+		ScanFrames(stdout, sendFrame(ctx, ch, s.Name))
+	}()
+	return ch, nil
+}
+
+// Frames function.
+func (s ReplaySource) Frames(ctx context.Context) (<-chan Frame, error) {
+	f, err := openReplay(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Frame)
+	go func() {
+		defer close(ch)
+		defer f.Close()
+
+		replayFrames(f, s.Speed, ctx.Done(), sendFrame(ctx, ch, s.Name))
+	}()
+	return ch, nil
+}
+
+// Frames function.
+func (s ReaderSource) Frames(ctx context.Context) (<-chan Frame, error) {
+	ch := make(chan Frame)
+	go func() {
+		defer close(ch)
+
+		ScanFrames(s.Reader, sendFrame(ctx, ch, s.Name))
+	}()
+	return ch, nil
+}
+
+// A handler sending the frames to ch until ctx is canceled.
+func sendFrame(ctx context.Context, ch chan<- Frame, name string) FrameHandler {
+	return func(f Frame) {
+		f.Source = name
+		select {
+		case ch <- f:
+		case <-ctx.Done():
+		}
+	}
+}
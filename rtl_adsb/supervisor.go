@@ -0,0 +1,108 @@
+package rtl_adsb
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Delays before restarting a supervised rtl_adsb: the delay doubles at
+// every failure, and starts again from RestartMinDelay once a process ran
+// for restartStableRun.
+const (
+	RestartMinDelay  = time.Second
+	RestartMaxDelay  = time.Minute
+	restartStableRun = time.Minute
+)
+
+// ReceiverStatus is the state of a supervised rtl_adsb.
+type ReceiverStatus struct {
+	Running  bool
+	Restarts int       // Processes started after the first one.
+	Err      error     // Why the process exited or failed to start, nil while running.
+	Retry    time.Time // When the process is restarted, while not running.
+}
+
+// StartSupervisedFrames function.
+// Like StartReceiveFramesWithArgs, but rtl_adsb is restarted whenever it exits,
+// e.g. when it crashed or the dongle was unplugged. status, if not nil, is
+// called when the process starts and when it exits. Only a failure of the
+// first start is returned.
+func StartSupervisedFrames(execPath string, args []string, handler FrameHandler, status func(ReceiverStatus)) (func(), error) {
+	cmd, stdout, err := startCommand(execPath, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == nil {
+		status = func(ReceiverStatus) {}
+	}
+
+	stop := make(chan struct{})
+	var mux sync.Mutex
+	current := cmd
+	stopped := false
+
+	go func() {
+		delay := RestartMinDelay
+		restarts := 0
+
+		for {
+			status(ReceiverStatus{Running: true, Restarts: restarts})
+			started := time.Now()
+
+			ScanFrames(stdout, handler)
+			err := cmd.Wait()
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err == nil {
+				err = errors.New("RTL-ADSB error: exited")
+			}
+			if time.Since(started) >= restartStableRun {
+				delay = RestartMinDelay
+			}
+
+			for {
+				status(ReceiverStatus{Restarts: restarts, Err: err, Retry: time.Now().Add(delay)})
+				select {
+				case <-time.After(delay):
+				case <-stop:
+					return
+				}
+
+				if delay *= 2; delay > RestartMaxDelay {
+					delay = RestartMaxDelay
+				}
+				restarts++
+
+				var next *exec.Cmd
+				if next, stdout, err = startCommand(execPath, args); err == nil {
+					cmd = next
+					break
+				}
+			}
+
+			mux.Lock()
+			current = cmd
+			if stopped {
+				cmd.Process.Kill()
+			}
+			mux.Unlock()
+		}
+	}()
+
+	return func() {
+		mux.Lock()
+		defer mux.Unlock()
+
+		if !stopped {
+			stopped = true
+			close(stop)
+			current.Process.Kill()
+		}
+	}, nil
+}
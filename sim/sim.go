@@ -0,0 +1,194 @@
+// Package sim generates synthetic Mode S traffic, used to benchmark and
+// exercise the decoder without a radio attached.
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"go1090/mode_s"
+)
+
+const earthRadiusNM = 3440.065
+
+// Flight is one simulated aircraft flying a straight line.
+type Flight struct {
+	Addr      uint32
+	Callsign  string
+	Latitude  float64
+	Longitude float64
+	Altitude  int     // ft
+	Speed     float64 // kt
+	Track     float64 // degrees
+	VertRate  int     // ft/min
+
+	next int // Next message kind to transmit.
+}
+
+// Generator produces frames for a set of simulated flights.
+type Generator struct {
+	Flights []*Flight
+
+	rnd   *rand.Rand
+	turn  int
+	noise Noise
+	stats NoiseStats
+}
+
+// Noise corrupts the generated frames like a marginal reception, to
+// exercise the error correction of the decoder. Every field is the
+// probability that a frame is affected.
+type Noise struct {
+	SingleBit float64 // One bit flipped.
+	TwoBits   float64 // Two bits flipped.
+	Truncate  float64 // Frame cut short, the missing bits read as zero.
+}
+
+// NoiseStats counts the corrupted frames.
+type NoiseStats struct {
+	Frames    int
+	SingleBit int
+	TwoBits   int
+	Truncated int
+}
+
+// NewGenerator function.
+// Creates 'count' flights scattered within ~100 NM of lat/lon. The same
+// seed always produces the same traffic.
+func NewGenerator(count int, lat, lon float64, seed int64) *Generator {
+	g := &Generator{
+		rnd: rand.New(rand.NewSource(seed)),
+	}
+
+	for i := 0; i < count; i++ {
+		f := &Flight{
+			Addr:      uint32(0x700000 + g.rnd.Intn(0x0fffff)),
+			Callsign:  fmt.Sprintf("SIM%04d", i),
+			Latitude:  lat + (g.rnd.Float64()-0.5)*3,
+			Longitude: lon + (g.rnd.Float64()-0.5)*3,
+			Altitude:  1000 + g.rnd.Intn(40000),
+			Speed:     150 + g.rnd.Float64()*350,
+			Track:     g.rnd.Float64() * 360,
+			VertRate:  (g.rnd.Intn(5) - 2) * 1024,
+		}
+		g.Flights = append(g.Flights, f)
+	}
+
+	return g
+}
+
+// SetNoise selects the corruption of the next frames.
+func (g *Generator) SetNoise(n Noise) {
+	g.noise = n
+}
+
+// NoiseStats returns the number of frames generated and corrupted.
+func (g *Generator) NoiseStats() NoiseStats {
+	return g.stats
+}
+
+// Move every flight forward by 'seconds'.
+func (g *Generator) Advance(seconds float64) {
+	for _, f := range g.Flights {
+		dist := f.Speed * seconds / 3600 / earthRadiusNM /* radians */
+		rad := f.Track * math.Pi / 180
+
+		f.Latitude += dist * math.Cos(rad) * 180 / math.Pi
+		f.Longitude += dist * math.Sin(rad) * 180 / math.Pi / math.Cos(f.Latitude*math.Pi/180)
+		f.Altitude += int(float64(f.VertRate) * seconds / 60)
+
+		if f.Altitude < 0 || f.Altitude > 45000 {
+			f.VertRate = -f.VertRate
+		}
+		if f.Longitude > 180 {
+			f.Longitude -= 360
+		} else if f.Longitude < -180 {
+			f.Longitude += 360
+		}
+	}
+}
+
+// Next returns the next frame, cycling over the flights and message kinds
+// (all-call reply, identification, even and odd position, velocity). The
+// frame is padded with zeros to a long message, as the noise may turn the
+// DF of a short frame into the one of a long message.
+func (g *Generator) Next() []byte {
+	if len(g.Flights) == 0 {
+		return nil
+	}
+
+	f := g.Flights[g.turn]
+	g.turn = (g.turn + 1) % len(g.Flights)
+
+	kind := f.next
+	f.next = (f.next + 1) % 5
+
+	var msg []byte
+	switch kind {
+	case 0:
+		msg = mode_s.EncodeAllCallReply(f.Addr)
+	case 1:
+		msg = mode_s.EncodeIdentification(f.Addr, f.Callsign)
+	case 2:
+		msg = mode_s.EncodeAirbornePosition(f.Addr, f.Latitude, f.Longitude, f.Altitude, 0)
+	case 3:
+		msg = mode_s.EncodeAirbornePosition(f.Addr, f.Latitude, f.Longitude, f.Altitude, 1)
+	default:
+		msg = mode_s.EncodeVelocity(f.Addr, f.Speed, f.Track, f.VertRate)
+	}
+
+	g.corrupt(msg)
+
+	frame := make([]byte, mode_s.MODES_LONG_MSG_BYTES)
+	copy(frame, msg)
+	return frame
+}
+
+// Apply the noise to a frame.
+func (g *Generator) corrupt(msg []byte) {
+	g.stats.Frames++
+	bits := len(msg) * 8
+
+	flip := func() int {
+		j := g.rnd.Intn(bits)
+		msg[j/8] ^= 1 << (7 - uint(j%8))
+		return j
+	}
+
+	switch p := g.rnd.Float64(); {
+	case p < g.noise.SingleBit:
+		flip()
+		g.stats.SingleBit++
+	case p < g.noise.SingleBit+g.noise.TwoBits:
+		first := flip()
+		for {
+			j := g.rnd.Intn(bits)
+			if j != first {
+				msg[j/8] ^= 1 << (7 - uint(j%8))
+				break
+			}
+		}
+		g.stats.TwoBits++
+	}
+
+	if g.noise.Truncate > 0 && g.rnd.Float64() < g.noise.Truncate {
+		/* Keep at least the DF, so the frame length is known. */
+		for i := 1 + g.rnd.Intn(len(msg)-1); i < len(msg); i++ {
+			msg[i] = 0
+		}
+		g.stats.Truncated++
+	}
+}
+
+// Corpus returns 'n' frames, padded as by Next.
+func (g *Generator) Corpus(n int) [][]byte {
+	frames := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		frames = append(frames, g.Next())
+		if g.turn == 0 {
+			g.Advance(1)
+		}
+	}
+	return frames
+}
@@ -0,0 +1,160 @@
+package stats
+
+import (
+	"fmt"
+	"go1090/mode_s"
+	"io"
+	"strings"
+	"time"
+)
+
+// Counters are the message counters of a period.
+type Counters struct {
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"` /* zero while counting */
+	Messages       int64     `json:"messages"`
+	BadCRC         int64     `json:"bad_crc"` /* included in messages */
+	SingleBitFixed int64     `json:"single_bit_fixed"`
+	TwoBitsFixed   int64     `json:"two_bits_fixed"`
+	PhaseCorrected int64     `json:"phase_corrected"`
+	ModeAC         int64     `json:"mode_ac"`
+	DF             [32]int64 `json:"df"` /* by downlink format */
+	TC             [32]int64 `json:"tc"` /* extended squitters by type code */
+	II             [16]int64 `json:"ii"` /* DF11 replies by interrogator II code */
+	SI             [64]int64 `json:"si"` /* DF11 replies by interrogator SI code */
+	UniqueAircraft int       `json:"unique_aircraft"`
+
+	aircraft map[uint32]bool
+}
+
+func newCounters(start time.Time) *Counters {
+	return &Counters{Start: start, aircraft: make(map[uint32]bool)}
+}
+
+// Count a message.
+func (c *Counters) count(mm *mode_s.ModeSMessage) {
+	c.Messages++
+	if mm.DF() == mode_s.MODES_AC_MSGTYPE {
+		c.ModeAC++
+		return
+	}
+
+	c.DF[mm.DF()&31]++
+	if mm.IsExtendedSquitter() {
+		c.TC[mm.TypeCode()&31]++
+	}
+	if mm.PhaseCorrected() {
+		c.PhaseCorrected++
+	}
+
+	if !mm.CRCOk() {
+		c.BadCRC++
+		return
+	}
+	switch mm.CorrectedBits() {
+	case 1:
+		c.SingleBitFixed++
+	case 2:
+		c.TwoBitsFixed++
+	}
+	if code, si, ok := mm.InterrogatorCode(); ok && si {
+		c.SI[code]++
+	} else if ok {
+		c.II[code]++
+	}
+
+	/* The address of a message with a bad CRC may be noise. */
+	if addr := mm.ICAO(); !c.aircraft[addr] {
+		c.aircraft[addr] = true
+		c.UniqueAircraft++
+	}
+}
+
+// Return a copy not sharing the set of aircraft, ended at end if it is
+// not zero.
+func (c *Counters) snapshot(end time.Time) Counters {
+	s := *c
+	s.aircraft = nil
+	if !end.IsZero() {
+		s.End = end
+	}
+	return s
+}
+
+// Rate returns the message rate of the period, in messages per second.
+func (c Counters) Rate() float64 {
+	end := c.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	secs := end.Sub(c.Start).Seconds()
+	if secs <= 0 {
+		return 0
+	}
+	return float64(c.Messages) / secs
+}
+
+// WriteTo writes the counters as a statistics block, like dump1090.
+func (c Counters) WriteTo(w io.Writer) (int64, error) {
+	p := &printer{w: w}
+
+	end := c.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	p.printf("Statistics: %s - %s\n", c.Start.Format(time.ANSIC), end.Format(time.ANSIC))
+	p.printf("Messages:\n")
+	p.printf("  %d total messages (%.1f/s)\n", c.Messages, c.Rate())
+	p.printf("  %d accepted with correct CRC\n", c.Messages-c.BadCRC-c.ModeAC)
+	p.printf("    %d with 1-bit error repaired\n", c.SingleBitFixed)
+	p.printf("    %d with 2-bit errors repaired\n", c.TwoBitsFixed)
+	p.printf("  %d with bad CRC\n", c.BadCRC)
+	p.printf("  %d decoded after phase correction\n", c.PhaseCorrected)
+	p.printf("  %d Mode A/C replies\n", c.ModeAC)
+	p.printf("  %d unique aircraft\n", c.UniqueAircraft)
+
+	p.printf("Downlink formats:\n")
+	p.histogram("DF", c.DF[:])
+	p.printf("Extended squitter type codes:\n")
+	p.histogram("TC", c.TC[:])
+	p.printf("DF11 replies by interrogator:\n")
+	p.histogram("II", c.II[:])
+	p.histogram("SI", c.SI[:])
+
+	return p.n, p.err
+}
+
+// Writer remembering the first error, for WriteTo.
+type printer struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (p *printer) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.n += int64(n)
+	p.err = err
+}
+
+// Print the non zero counts, 8 per line.
+func (p *printer) histogram(name string, counts []int64) {
+	line := ""
+	n := 0
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		line += fmt.Sprintf(" %s%-2d %-8d", name, i, count)
+		if n++; n%8 == 0 {
+			p.printf(" %s\n", strings.TrimRight(line, " "))
+			line = ""
+		}
+	}
+	if line != "" {
+		p.printf(" %s\n", strings.TrimRight(line, " "))
+	}
+}
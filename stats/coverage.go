@@ -0,0 +1,100 @@
+package stats
+
+import (
+	"go1090/mode_s"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CoverageSectors is the number of bearing sectors of the coverage, 10
+// degrees each.
+const CoverageSectors = 36
+
+// RangeRecord is the farthest position decoded in a sector.
+type RangeRecord struct {
+	DistanceKm float64   `json:"distance_km"`
+	Bearing    float64   `json:"bearing"`
+	Latitude   float64   `json:"lat"`
+	Longitude  float64   `json:"lon"`
+	Altitude   int       `json:"alt_baro"` /* feet, 0 if unknown */
+	Hex        string    `json:"hex"`
+	Time       time.Time `json:"time"`
+}
+
+// Coverage keeps the farthest position decoded in every sector of
+// bearing from the receiver, to evaluate the placement of the antenna.
+type Coverage struct {
+	sectors [CoverageSectors]RangeRecord
+	mux     sync.Mutex
+}
+
+// CoverageSnapshot is a copy of the coverage, as served in stats.json.
+type CoverageSnapshot struct {
+	MaxRange *RangeRecord   `json:"max_range,omitempty"` /* nil before the first position */
+	Sectors  []SectorRecord `json:"sectors"`             /* the sectors with a position */
+}
+
+// SectorRecord is the farthest position of the sector starting at
+// Sector degrees.
+type SectorRecord struct {
+	Sector int `json:"sector"`
+	RangeRecord
+}
+
+// Observe records the position of the aircraft if it is the farthest
+// of its sector. Aircraft without a range from the receiver are ignored.
+func (c *Coverage) Observe(ac *mode_s.Aircraft) {
+	if !ac.Ranged || ac.Trail.Len() == 0 {
+		return
+	}
+
+	i := int(math.Floor(ac.Bearing/(360/CoverageSectors))) % CoverageSectors
+	if i < 0 {
+		i += CoverageSectors
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if ac.DistanceKm <= c.sectors[i].DistanceKm {
+		return
+	}
+	c.sectors[i] = RangeRecord{
+		DistanceKm: ac.DistanceKm,
+		Bearing:    ac.Bearing,
+		Latitude:   ac.Latitude,
+		Longitude:  ac.Longitude,
+		Altitude:   ac.Altitude,
+		Hex:        strings.ToLower(ac.HexAddr),
+		Time:       ac.Seen,
+	}
+}
+
+// Reset forgets the positions.
+func (c *Coverage) Reset() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.sectors = [CoverageSectors]RangeRecord{}
+}
+
+// Snapshot function.
+func (c *Coverage) Snapshot() CoverageSnapshot {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	s := CoverageSnapshot{Sectors: []SectorRecord{}}
+	for i, r := range c.sectors {
+		if r.DistanceKm == 0 {
+			continue
+		}
+		s.Sectors = append(s.Sectors, SectorRecord{Sector: i * 360 / CoverageSectors, RangeRecord: r})
+		if s.MaxRange == nil || r.DistanceKm > s.MaxRange.DistanceKm {
+			max := r
+			s.MaxRange = &max
+		}
+	}
+	return s
+}
@@ -0,0 +1,47 @@
+package stats
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline renders values as a line of at most width block characters.
+// When there are more values than width, consecutive values are merged
+// keeping their maximum; the last values are always rendered.
+func Sparkline(values []int, width int) string {
+	if len(values) == 0 || width <= 0 {
+		return ""
+	}
+
+	group := (len(values) + width - 1) / width
+	var merged []int
+	/* Group from the end so the latest value has its own column when
+	 * possible. */
+	for end := len(values); end > 0; end -= group {
+		start := end - group
+		if start < 0 {
+			start = 0
+		}
+		peak := values[start]
+		for _, v := range values[start:end] {
+			if v > peak {
+				peak = v
+			}
+		}
+		merged = append([]int{peak}, merged...)
+	}
+
+	top := 0
+	for _, v := range merged {
+		if v > top {
+			top = v
+		}
+	}
+
+	line := make([]rune, len(merged))
+	for i, v := range merged {
+		level := 0
+		if top > 0 && v > 0 {
+			level = v * (len(sparkBlocks) - 1) / top
+		}
+		line[i] = sparkBlocks[level]
+	}
+	return string(line)
+}
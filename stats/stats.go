@@ -0,0 +1,247 @@
+// Package stats keeps reception statistics.
+package stats
+
+import (
+	"go1090/mode_s"
+	"sync"
+	"time"
+)
+
+// Stats are the reception statistics of a receiver: cumulative counters,
+// the counters of the current and last minute, and of a period ended by
+// TakePeriod (e.g. for a periodic report).
+type Stats struct {
+	started    time.Time
+	total      *Counters
+	minute     *Counters
+	lastMinute *Counters /* nil during the first minute */
+	period     *Counters
+	mux        sync.Mutex
+
+	history  *History
+	coverage Coverage
+}
+
+// Snapshot is a copy of the statistics, as served in stats.json.
+type Snapshot struct {
+	Now        float64          `json:"now"`
+	Uptime     float64          `json:"uptime"` /* seconds */
+	Messages   int64            `json:"messages"`
+	BadCRC     int64            `json:"bad_crc"` /* included in messages */
+	Total      Counters         `json:"total"`
+	LastMinute *Counters        `json:"last_minute,omitempty"`
+	History    []Sample         `json:"history"` /* oldest first, last hour */
+	Coverage   CoverageSnapshot `json:"coverage"`
+}
+
+// New function.
+func New() *Stats {
+	now := time.Now()
+	return &Stats{
+		started: now,
+		total:   newCounters(now),
+		minute:  newCounters(now.Truncate(time.Minute)),
+		period:  newCounters(now),
+		history: NewHistory(now),
+	}
+}
+
+// Count records the reception of a message.
+func (s *Stats) Count(mm *mode_s.ModeSMessage) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.total.count(mm)
+	s.minute.count(mm)
+	s.period.count(mm)
+}
+
+// Messages returns the number of messages received.
+func (s *Stats) Messages() int64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.total.Messages
+}
+
+// Reset clears the counters, the history and the coverage.
+func (s *Stats) Reset() {
+	now := time.Now()
+
+	s.mux.Lock()
+	s.total = newCounters(now)
+	s.minute = newCounters(now.Truncate(time.Minute))
+	s.lastMinute = nil
+	s.period = newCounters(now)
+	s.mux.Unlock()
+
+	s.history.Reset(now)
+	s.coverage.Reset()
+}
+
+// Tick samples the aircraft count and starts the counters of a new
+// minute. Call it regularly (every second).
+func (s *Stats) Tick(now time.Time, aircraft int) {
+	s.mux.Lock()
+	if end := s.minute.Start.Add(time.Minute); !now.Before(end) {
+		last := s.minute.snapshot(end)
+		s.lastMinute = &last
+		s.minute = newCounters(now.Truncate(time.Minute))
+	}
+	messages := s.total.Messages
+	s.mux.Unlock()
+
+	s.history.Observe(now, aircraft, messages)
+}
+
+// TakePeriod returns the counters since the last call (or the start), and
+// starts a new period.
+func (s *Stats) TakePeriod() Counters {
+	now := time.Now()
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	c := s.period.snapshot(now)
+	s.period = newCounters(now)
+	return c
+}
+
+// Total returns the cumulative counters.
+func (s *Stats) Total() Counters {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.total.snapshot(time.Time{})
+}
+
+// Coverage returns the farthest positions by bearing.
+func (s *Stats) Coverage() *Coverage {
+	return &s.coverage
+}
+
+// History returns the per minute history.
+func (s *Stats) History() *History {
+	return s.history
+}
+
+// Snapshot function.
+func (s *Stats) Snapshot() Snapshot {
+	now := time.Now()
+
+	s.mux.Lock()
+	total := s.total.snapshot(time.Time{})
+	var last *Counters
+	if s.lastMinute != nil {
+		c := *s.lastMinute
+		last = &c
+	}
+	s.mux.Unlock()
+
+	return Snapshot{
+		Now:        float64(now.UnixNano()) / 1e9,
+		Uptime:     now.Sub(s.started).Seconds(),
+		Messages:   total.Messages,
+		BadCRC:     total.BadCRC,
+		Total:      total,
+		LastMinute: last,
+		History:    s.history.Samples(),
+		Coverage:   s.coverage.Snapshot(),
+	}
+}
+
+// HistoryLen is the number of samples of History: one hour.
+const HistoryLen = 60
+
+// Sample is the statistics of one minute.
+type Sample struct {
+	Start    time.Time `json:"start"`
+	Aircraft int       `json:"aircraft"` /* peak aircraft count */
+	Messages int64     `json:"messages"`
+}
+
+// Rate returns the message rate of the sample, in messages per second.
+func (s Sample) Rate() float64 {
+	return float64(s.Messages) / time.Minute.Seconds()
+}
+
+// History is a ring of the last HistoryLen minutes.
+type History struct {
+	ring [HistoryLen]Sample
+	next int /* next slot of ring */
+	n    int /* number of completed samples */
+
+	current      Sample
+	lastMessages int64 /* message counter at the start of current */
+
+	mux sync.Mutex
+}
+
+// NewHistory function.
+func NewHistory(now time.Time) *History {
+	return &History{
+		current: Sample{Start: now.Truncate(time.Minute)},
+	}
+}
+
+// Reset drops the samples, starting a new history at now.
+func (h *History) Reset(now time.Time) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	h.next, h.n = 0, 0
+	h.current = Sample{Start: now.Truncate(time.Minute)}
+	h.lastMessages = 0
+}
+
+// Observe the aircraft count and the (cumulative) message counter.
+func (h *History) Observe(now time.Time, aircraft int, messages int64) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	for !now.Before(h.current.Start.Add(time.Minute)) {
+		h.push(h.current)
+		h.current = Sample{Start: h.current.Start.Add(time.Minute)}
+		h.lastMessages = messages
+	}
+
+	h.current.Messages = messages - h.lastMessages
+	if aircraft > h.current.Aircraft {
+		h.current.Aircraft = aircraft
+	}
+}
+
+func (h *History) push(s Sample) {
+	h.ring[h.next] = s
+	h.next = (h.next + 1) % HistoryLen
+	if h.n < HistoryLen {
+		h.n++
+	}
+}
+
+// Samples returns the completed minutes, oldest first.
+func (h *History) Samples() []Sample {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	samples := make([]Sample, 0, h.n)
+	for i := h.n; i > 0; i-- {
+		samples = append(samples, h.ring[(h.next-i+HistoryLen)%HistoryLen])
+	}
+	return samples
+}
+
+// AircraftCounts returns the peak aircraft counts of the completed
+// minutes and of the current one, oldest first.
+func (h *History) AircraftCounts() []int {
+	samples := h.Samples()
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	counts := make([]int, 0, len(samples)+1)
+	for _, s := range samples {
+		counts = append(counts, s.Aircraft)
+	}
+	return append(counts, h.current.Aircraft)
+}
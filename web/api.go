@@ -0,0 +1,309 @@
+package web
+
+import (
+	"errors"
+	"go1090/mode_s"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The REST API, for programs querying go1090:
+//
+//	GET /api/aircraft                 aircraft list, see aircraftFilter
+//	GET /api/aircraft/{icao}          full record of one aircraft
+//	GET /api/aircraft/{icao}/track    positions, ?since=<unix time>
+//	GET /api/zones                    zones and their aircraft, see zoneJSON
+//	GET /api/zones/{name}             one zone and its aircraft
+//	GET /api/stats                    registered by the caller with Handle
+//
+// The aircraft are in the schema of mode_s.Aircraft.MarshalJSON, shared
+// with the outputs; the /data files keep the one of dump1090 for the map.
+// Altitudes, speeds and vertical rates are in the units of SetUnits, or
+// of ?units=aviation|metric, stated by the "units" of the responses.
+// Errors are answered as {"error": "..."} with the HTTP status.
+
+// Filter of /api/aircraft, from the query parameters:
+// bbox=<min lat>,<min lon>,<max lat>,<max lon> keeps the aircraft with a
+// position in the box, min_alt and max_alt (in the units of the request)
+// the aircraft with a known altitude in the range.
+type aircraftFilter struct {
+	bbox           bool
+	minLat, minLon float64
+	maxLat, maxLon float64
+	minAlt, maxAlt *int
+}
+
+func parseAircraftFilter(q url.Values) (aircraftFilter, error) {
+	f := aircraftFilter{}
+
+	if v := q.Get("bbox"); v != "" {
+		parts := strings.Split(v, ",")
+		if len(parts) != 4 {
+			return f, errors.New("invalid bbox")
+		}
+		var c [4]float64
+		for i, p := range parts {
+			n, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				return f, errors.New("invalid bbox")
+			}
+			c[i] = n
+		}
+		if c[0] > c[2] || c[1] > c[3] {
+			return f, errors.New("invalid bbox")
+		}
+		f.bbox = true
+		f.minLat, f.minLon, f.maxLat, f.maxLon = c[0], c[1], c[2], c[3]
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **int
+	}{{"min_alt", &f.minAlt}, {"max_alt", &f.maxAlt}} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return f, errors.New("invalid " + p.name)
+			}
+			*p.dst = &n
+		}
+	}
+	return f, nil
+}
+
+func (f aircraftFilter) match(ac *mode_s.Aircraft, units mode_s.Units) bool {
+	if f.bbox {
+		if ac.Trail.Len() == 0 ||
+			ac.Latitude < f.minLat || ac.Latitude > f.maxLat ||
+			ac.Longitude < f.minLon || ac.Longitude > f.maxLon {
+			return false
+		}
+	}
+	if f.minAlt != nil || f.maxAlt != nil {
+		if ac.Altitude == 0 {
+			return false
+		}
+		alt := ac.AltitudeIn(units)
+		if f.minAlt != nil && alt < *f.minAlt {
+			return false
+		}
+		if f.maxAlt != nil && alt > *f.maxAlt {
+			return false
+		}
+	}
+	return true
+}
+
+// Position of /api/aircraft/{icao}/track. Unknown values are omitted.
+type trackPointJSON struct {
+	Time      float64 `json:"time"` /* unix time */
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+	Altitude  *int    `json:"alt_baro,omitempty"`
+	Speed     *int    `json:"gs,omitempty"`
+}
+
+// Full record of /api/aircraft/{icao}: the aircraft with the state of its
+// decoding and its trail, in the units of the request.
+type apiAircraftJSON struct {
+	mode_s.AircraftJSON
+	Provenance mode_s.Provenance    `json:"provenance"` /* of the last message */
+	EHS        mode_s.EHSData       `json:"ehs"`
+	ADSB       mode_s.ADSBQuality   `json:"adsb"`
+	Anomalies  []mode_s.Anomaly     `json:"anomalies,omitempty"`
+	Rejected   int64                `json:"positions_rejected"`
+	MsgCounts  mode_s.MessageCounts `json:"message_counts"`
+	Trail      [][5]float64         `json:"trail"` /* see trailJSON */
+}
+
+func (s *Server) registerAPI() {
+	s.mux.HandleFunc("/api/aircraft", s.handleAPIAircraftList)
+	s.mux.HandleFunc("/api/aircraft/", s.handleAPIAircraft)
+	s.mux.HandleFunc("/api/zones", s.handleAPIZones)
+	s.mux.HandleFunc("/api/zones/", s.handleAPIZones)
+}
+
+// GET /api/aircraft
+func (s *Server) handleAPIAircraftList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+
+	filter, err := parseAircraftFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	units, err := s.requestUnits(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now()
+	list := []mode_s.AircraftJSON{}
+	for _, ac := range s.publicAircrafts() {
+		if filter.match(ac, units) {
+			list = append(list, ac.JSONIn(units))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Hex < list[j].Hex })
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"now":      float64(now.UnixNano()) / 1e9,
+		"units":    units.String(),
+		"aircraft": list,
+	})
+}
+
+// GET /api/aircraft/{icao} and /api/aircraft/{icao}/track
+func (s *Server) handleAPIAircraft(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/aircraft/")
+	name, track := path, false
+	if i := strings.Index(path, "/"); i >= 0 {
+		if path[i:] != "/track" {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		name, track = path[:i], true
+	}
+
+	addr, ok := parseICAO(strings.ToLower(name))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid ICAO address")
+		return
+	}
+	ac := s.publicAircraft(addr)
+	if ac == nil {
+		writeError(w, http.StatusNotFound, "aircraft not tracked")
+		return
+	}
+	units, err := s.requestUnits(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !track {
+		j := apiAircraftJSON{
+			AircraftJSON: ac.JSONIn(units),
+			Provenance:   ac.Provenance,
+			EHS:          ac.EHS,
+			ADSB:         ac.ADSB,
+			Anomalies:    ac.Anomalies,
+			Rejected:     ac.PositionsRejected,
+			MsgCounts:    ac.MsgCounts,
+			Trail:        trailJSON(ac, time.Time{}, units),
+		}
+		j.Units = units.String()
+		writeJSON(w, http.StatusOK, j)
+		return
+	}
+
+	since, ok := parseSince(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid since")
+		return
+	}
+
+	points := ac.Trail.Since(since)
+	list := make([]trackPointJSON, 0, len(points))
+	for _, p := range points {
+		j := trackPointJSON{
+			Time:      float64(p.Time.UnixNano()) / 1e9,
+			Latitude:  p.Latitude,
+			Longitude: p.Longitude,
+		}
+		if p.Altitude != 0 {
+			alt := units.Altitude(p.Altitude)
+			j.Altitude = &alt
+		}
+		if p.Speed != 0 {
+			gs := units.Speed(p.Speed)
+			j.Speed = &gs
+		}
+		list = append(list, j)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"hex":   strings.ToLower(ac.HexAddr),
+		"units": units.String(),
+		"track": list,
+	})
+}
+
+// Zone of /api/zones: its definition (see mode_s.Zone) and the aircraft
+// in it, as in /api/aircraft.
+type zoneJSON struct {
+	mode_s.Zone
+	Aircraft []mode_s.AircraftJSON `json:"aircraft"`
+}
+
+// GET /api/zones and /api/zones/{name}
+func (s *Server) handleAPIZones(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+	units, err := s.requestUnits(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name, one := strings.CutPrefix(r.URL.Path, "/api/zones/")
+	zones := []zoneJSON{}
+	for _, z := range s.sky.Zones() {
+		if !one || z.Name == name {
+			zones = append(zones, zoneJSON{Zone: z, Aircraft: []mode_s.AircraftJSON{}})
+		}
+	}
+	if one && len(zones) == 0 {
+		writeError(w, http.StatusNotFound, "unknown zone")
+		return
+	}
+
+	now := time.Now()
+	for _, ac := range s.publicAircrafts() {
+		for i := range zones {
+			if containsZone(ac.Zones, zones[i].Name) {
+				zones[i].Aircraft = append(zones[i].Aircraft, ac.JSONIn(units))
+			}
+		}
+	}
+	for _, z := range zones {
+		sort.Slice(z.Aircraft, func(i, j int) bool { return z.Aircraft[i].Hex < z.Aircraft[j].Hex })
+	}
+
+	if one {
+		writeJSON(w, http.StatusOK, struct {
+			zoneJSON
+			Units string `json:"units"`
+		}{zones[0], units.String()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"now":   float64(now.UnixNano()) / 1e9,
+		"units": units.String(),
+		"zones": zones,
+	})
+}
+
+func containsZone(zones []string, name string) bool {
+	for _, z := range zones {
+		if z == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package web
+
+import (
+	"encoding/json"
+	"go1090/output"
+	"net/http"
+)
+
+// GET /data/aircraft.geojson, ?since=<unix time> to limit the trails to
+// the positions decoded after it.
+func (s *Server) handleGeoJSON(w http.ResponseWriter, r *http.Request) {
+	since, ok := parseSince(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid since")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	json.NewEncoder(w).Encode(output.GeoJSON(s.publicAircrafts(), since))
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"net/http"
+	"sync"
+)
+
+// HealthCheck reports the state of one component: whether it is healthy,
+// and details shown in the /healthz response.
+type HealthCheck func() (ok bool, detail map[string]interface{})
+
+type namedCheck struct {
+	name  string
+	check HealthCheck
+}
+
+type healthChecks struct {
+	checks []namedCheck
+
+	mux sync.Mutex
+}
+
+// AddHealthCheck registers a component reported by /healthz.
+func (s *Server) AddHealthCheck(name string, check HealthCheck) {
+	s.health.mux.Lock()
+	defer s.health.mux.Unlock()
+
+	s.health.checks = append(s.health.checks, namedCheck{name, check})
+}
+
+// GET /healthz
+// Responds 200 when every component is healthy, 503 otherwise, so
+// orchestrators and uptime monitors can detect a dead receiver.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.health.mux.Lock()
+	checks := append([]namedCheck(nil), s.health.checks...)
+	s.health.mux.Unlock()
+
+	healthy := true
+	result := make(map[string]interface{}, len(checks))
+	for _, c := range checks {
+		ok, detail := c.check()
+		if detail == nil {
+			detail = make(map[string]interface{})
+		}
+		detail["ok"] = ok
+		result[c.name] = detail
+
+		healthy = healthy && ok
+	}
+
+	status, code := "ok", http.StatusOK
+	if !healthy {
+		status, code = "unhealthy", http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, code, map[string]interface{}{
+		"status": status,
+		"checks": result,
+	})
+}
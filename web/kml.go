@@ -0,0 +1,46 @@
+package web
+
+import (
+	"go1090/output"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default refresh interval of the network link of /data/live.kml.
+const kmlRefresh = 5 * time.Second
+
+// GET /data/aircraft.kml or /data/aircraft.kmz (zipped)
+func (s *Server) handleKML(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, ".kmz") {
+		w.Header().Set("Content-Type", "application/vnd.google-earth.kmz")
+		output.WriteKMZ(w, s.publicAircrafts())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.google-earth.kml+xml")
+	output.WriteKML(w, s.publicAircrafts())
+}
+
+// GET /data/live.kml, ?refresh=<seconds>: open it in Google Earth to
+// follow the traffic, reloaded from /data/aircraft.kmz.
+func (s *Server) handleKMLNetworkLink(w http.ResponseWriter, r *http.Request) {
+	refresh := kmlRefresh
+	if v := r.URL.Query().Get("refresh"); v != "" {
+		secs, err := strconv.ParseFloat(v, 64)
+		if err != nil || secs <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid refresh")
+			return
+		}
+		refresh = time.Duration(secs * float64(time.Second))
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.google-earth.kml+xml")
+	output.WriteKMLNetworkLink(w, scheme+"://"+r.Host+"/data/aircraft.kmz", refresh)
+}
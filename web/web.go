@@ -0,0 +1,596 @@
+// Package web serves the aircraft state as JSON over HTTP.
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"go1090/mode_s"
+	"go1090/output"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is the HTTP server of go1090.
+type Server struct {
+	sky     *mode_s.Sky
+	mux     *http.ServeMux
+	health  healthChecks
+	heading output.HeadingFormat
+	admin   string /* bearer token of the admin API */
+	privacy *output.Privacy
+	units   mode_s.Units  /* of the REST API without ?units= */
+	reckon  time.Duration /* positions extrapolated for at most, 0 = never */
+
+	settingsMux sync.Mutex
+}
+
+// NewServer function.
+func NewServer(sky *mode_s.Sky) *Server {
+	s := &Server{
+		sky: sky,
+		mux: http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/data/aircraft.json", s.handleAircraftList)
+	s.mux.HandleFunc("/data/aircraft/", s.handleAircraft)
+	s.mux.HandleFunc("/data/trails.json", s.handleTrails)
+	s.mux.HandleFunc("/data/aircraft.geojson", s.handleGeoJSON)
+	s.mux.HandleFunc("/data/aircraft.kml", s.handleKML)
+	s.mux.HandleFunc("/data/aircraft.kmz", s.handleKML)
+	s.mux.HandleFunc("/data/live.kml", s.handleKMLNetworkLink)
+	s.mux.HandleFunc("/data/modeac.json", s.handleModeAC)
+	s.mux.HandleFunc("/data/receiver.json", s.handleReceiver)
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	s.registerAPI()
+	return s
+}
+
+// SetHeadingFormat selects the north reference of "track" and whether
+// "track_cardinal" is included.
+func (s *Server) SetHeadingFormat(h output.HeadingFormat) {
+	s.settingsMux.Lock()
+	defer s.settingsMux.Unlock()
+
+	s.heading = h
+}
+
+// SetPrivacy hides the positions and the blocked aircraft.
+func (s *Server) SetPrivacy(p *output.Privacy) {
+	s.settingsMux.Lock()
+	defer s.settingsMux.Unlock()
+
+	s.privacy = p
+}
+
+func (s *Server) privacyFilter() *output.Privacy {
+	s.settingsMux.Lock()
+	defer s.settingsMux.Unlock()
+
+	return s.privacy
+}
+
+// SetUnits selects the units of the altitudes, speeds and vertical rates
+// of the REST API, unless requested with ?units=. The /data files are
+// always in aviation units, as dump1090.
+func (s *Server) SetUnits(u mode_s.Units) {
+	s.settingsMux.Lock()
+	defer s.settingsMux.Unlock()
+
+	s.units = u
+}
+
+// Units of a REST API request.
+func (s *Server) requestUnits(r *http.Request) (mode_s.Units, error) {
+	if name := r.URL.Query().Get("units"); name != "" {
+		return mode_s.ParseUnits(name)
+	}
+
+	s.settingsMux.Lock()
+	defer s.settingsMux.Unlock()
+
+	return s.units, nil
+}
+
+func (s *Server) headingFormat() output.HeadingFormat {
+	s.settingsMux.Lock()
+	defer s.settingsMux.Unlock()
+
+	return s.heading
+}
+
+// SetDeadReckoning extrapolates the positions not updated from the speed
+// and track, for maxAge at most (0 = never). They are flagged "estimated",
+// "seen_pos" being the age of the last decoded position.
+func (s *Server) SetDeadReckoning(maxAge time.Duration) {
+	s.settingsMux.Lock()
+	defer s.settingsMux.Unlock()
+
+	s.reckon = maxAge
+}
+
+func (s *Server) deadReckoning() time.Duration {
+	s.settingsMux.Lock()
+	defer s.settingsMux.Unlock()
+
+	return s.reckon
+}
+
+// Handle registers an additional handler.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// ServeHTTP function.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Start listening on addr. Returns a function stopping the server.
+func (s *Server) Start(addr string) (func(), error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP error: %s", err.Error())
+	}
+
+	srv := &http.Server{Handler: s}
+	go srv.Serve(l)
+
+	return func() {
+		srv.Close()
+	}, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// SetAdminToken sets the bearer token required by the handlers registered
+// with HandleAdmin. Without a token, they are refused.
+func (s *Server) SetAdminToken(token string) {
+	s.settingsMux.Lock()
+	defer s.settingsMux.Unlock()
+
+	s.admin = token
+}
+
+func (s *Server) adminToken() string {
+	s.settingsMux.Lock()
+	defer s.settingsMux.Unlock()
+
+	return s.admin
+}
+
+// HandleAdmin registers a handler of the admin API, requiring the
+// "Authorization: Bearer <token>" header.
+func (s *Server) HandleAdmin(pattern string, handler http.Handler) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		token := s.adminToken()
+		auth := r.Header.Get("Authorization")
+		if token == "" || !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// ActionHandler is a POST endpoint running action, answering
+// {"status": "ok"} or the error.
+func ActionHandler(action func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "POST required")
+			return
+		}
+		if err := action(); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+// JSONHandler serves the value returned by f as JSON.
+func JSONHandler(f func() interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, f())
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// Summary of an aircraft, as in the aircraft.json list of dump1090.
+type aircraftJSON struct {
+	Hex           string   `json:"hex"`
+	Type          string   `json:"type"`
+	Category      string   `json:"category,omitempty"`
+	Flight        string   `json:"flight,omitempty"`
+	Registration  string   `json:"r,omitempty"`
+	TypeCode      string   `json:"t,omitempty"`
+	Operator      string   `json:"ownOp,omitempty"`
+	Country       string   `json:"country,omitempty"`
+	CountryCode   string   `json:"country_code,omitempty"`
+	Altitude      int      `json:"alt_baro"`
+	AltitudeGeom  *int     `json:"alt_geom,omitempty"`
+	GNSSBaroDiff  *int     `json:"gnss_baro_diff,omitempty"` /* alt_geom - alt_baro reported */
+	VertRate      *int     `json:"vert_rate,omitempty"`      /* ft/min */
+	Speed         int      `json:"gs"`
+	Track         int      `json:"track"`
+	TrackRef      string   `json:"track_ref"`
+	TrackCardinal string   `json:"track_cardinal,omitempty"`
+	TrackSource   string   `json:"track_source,omitempty"` /* "" if track is unknown */
+	Heading       *int     `json:"heading,omitempty"`      /* in the track_ref reference */
+	TrueHeading   *int     `json:"true_heading,omitempty"` /* as reported */
+	MagHeading    *int     `json:"mag_heading,omitempty"`  /* as reported */
+	HeadingSource string   `json:"heading_source,omitempty"`
+	Latitude      *float64 `json:"lat,omitempty"`
+	Longitude     *float64 `json:"lon,omitempty"`
+	Estimated     bool     `json:"estimated,omitempty"` /* lat and lon extrapolated */
+	SeenPos       *float64 `json:"seen_pos,omitempty"`  /* age of the last decoded position */
+	NIC           *int     `json:"nic,omitempty"`       /* integrity of the last position message */
+	Rc            *float64 `json:"rc,omitempty"`        /* its containment radius, meters, 0 if unknown */
+	DistanceKm    *float64 `json:"distance_km,omitempty"`
+	Bearing       *float64 `json:"bearing,omitempty"`
+	Squawk        string   `json:"squawk,omitempty"`
+	SquawkMeaning string   `json:"squawk_meaning,omitempty"`
+	Emergency     bool     `json:"emergency,omitempty"`
+	EmergencyDesc string   `json:"emergency_state,omitempty"`
+	OnGround      bool     `json:"ground,omitempty"`
+	Suspect       bool     `json:"suspect,omitempty"`
+	Watched       bool     `json:"watched,omitempty"`
+	Zones         []string `json:"zones,omitempty"`
+	Seen          float64  `json:"seen"`
+	Messages      int64    `json:"messages"`
+	MsgRate       float64  `json:"msg_rate"`         /* messages per second, see mode_s.MODES_RATE_WINDOW */
+	RSSI          *float64 `json:"rssi,omitempty"`   /* dBFS */
+	ModeA         int64    `json:"mode_a,omitempty"` /* correlated Mode A replies */
+	ModeC         int64    `json:"mode_c,omitempty"` /* correlated Mode C replies */
+}
+
+// Full record of one aircraft.
+type aircraftDetailJSON struct {
+	aircraftJSON
+	SeenAt     time.Time            `json:"seen_at"`
+	Provenance mode_s.Provenance    `json:"provenance"` /* of the last message */
+	EHS        mode_s.EHSData       `json:"ehs"`
+	ADSB       mode_s.ADSBQuality   `json:"adsb"`
+	LastRA     *mode_s.ACASRA       `json:"last_ra,omitempty"`
+	ACAS       *mode_s.ACASStatus   `json:"acas,omitempty"`          /* of the last DF0 or DF16 reply */
+	FS         *int                 `json:"flight_status,omitempty"` /* of the last DF4, 5, 20 or 21 reply */
+	Anomalies  []mode_s.Anomaly     `json:"anomalies,omitempty"`
+	Rejected   int64                `json:"positions_rejected"`
+	MsgCounts  mode_s.MessageCounts `json:"message_counts"`
+	Links      map[string]string    `json:"links,omitempty"`
+	Trail      [][5]float64         `json:"trail"` /* see trailJSON */
+}
+
+// Positions of a trail decoded after since, oldest first, as
+// [lat, lon, unix time, altitude, ground speed] in units.
+func trailJSON(ac *mode_s.Aircraft, since time.Time, units mode_s.Units) [][5]float64 {
+	points := ac.Trail.Since(since)
+	trail := make([][5]float64, 0, len(points))
+	for _, p := range points {
+		trail = append(trail, [5]float64{
+			p.Latitude,
+			p.Longitude,
+			float64(p.Time.UnixNano()) / 1e9,
+			float64(units.Altitude(p.Altitude)),
+			float64(units.Speed(p.Speed)),
+		})
+	}
+	return trail
+}
+
+func flightString(ac *mode_s.Aircraft) string {
+	return strings.TrimRight(ac.Flight, " \x00")
+}
+
+func newAircraftJSON(ac *mode_s.Aircraft, h output.HeadingFormat, reckon time.Duration, now time.Time) aircraftJSON {
+	j := aircraftJSON{
+		Hex:          strings.ToLower(ac.HexAddr),
+		Type:         ac.Source,
+		Category:     ac.Category,
+		Flight:       flightString(ac),
+		Registration: ac.Registration,
+		TypeCode:     ac.TypeCode,
+		Operator:     ac.Operator,
+		Country:      ac.Country,
+		CountryCode:  ac.CountryCode,
+		Altitude:     ac.Altitude,
+		Speed:        ac.Speed,
+		Track:        h.Degrees(ac.Track),
+		TrackRef:     h.Reference(),
+		Seen:         now.Sub(ac.Seen).Seconds(),
+		Messages:     ac.Messages,
+		MsgRate:      math.Round(ac.MessageRate(now)*10) / 10,
+		ModeA:        ac.ModeACount,
+		ModeC:        ac.ModeCCount,
+
+		TrackCardinal: h.CardinalOf(ac.Track),
+		SquawkMeaning: ac.SquawkMeaning,
+		Emergency:     ac.Emergency,
+		OnGround:      ac.OnGround,
+		Suspect:       ac.Suspect,
+		Watched:       ac.Watched,
+		Zones:         ac.Zones,
+	}
+
+	if ac.EmergencyState != 0 {
+		j.EmergencyDesc = ac.EmergencyStateDesc
+	}
+
+	if ac.TrackValid {
+		j.TrackSource = ac.TrackSource
+	}
+	if ac.HeadingValid {
+		magnetic := ac.HeadingRef == mode_s.NORTH_MAGNETIC
+		heading, reported := h.DegreesFrom(ac.Heading, magnetic), ac.Heading
+		j.Heading, j.HeadingSource = &heading, ac.HeadingSource
+		if magnetic {
+			j.MagHeading = &reported
+		} else {
+			j.TrueHeading = &reported
+		}
+	}
+
+	if ac.VertRateValid {
+		rate := ac.VertRate
+		j.VertRate = &rate
+	}
+
+	if ac.AltitudeGeomValid {
+		alt := ac.AltitudeGeom
+		j.AltitudeGeom = &alt
+	}
+	if ac.GNSSBaroDiffValid {
+		diff := ac.GNSSBaroDiff
+		j.GNSSBaroDiff = &diff
+	}
+
+	if ac.Squawk != 0 {
+		j.Squawk = fmt.Sprintf("%04d", ac.Squawk)
+	}
+
+	if ac.RSSIValid {
+		rssi := math.Round(ac.RSSI*10) / 10
+		j.RSSI = &rssi
+	}
+
+	if lat, lon, estimated, ok := ac.EstimatedPosition(now, reckon); ok {
+		last, _ := ac.Trail.Last()
+		seenPos := now.Sub(last.Time).Seconds()
+		j.Latitude, j.Longitude, j.SeenPos = &lat, &lon, &seenPos
+		j.Estimated = estimated
+		if ac.ADSB.NIC >= 0 {
+			nic, rc := ac.ADSB.NIC, ac.ADSB.Rc
+			j.NIC, j.Rc = &nic, &rc
+		}
+	}
+
+	if ac.Ranged {
+		dist, brg := ac.DistanceKm, ac.Bearing
+		j.DistanceKm, j.Bearing = &dist, &brg
+	}
+	return j
+}
+
+// GET /data/aircraft.json
+func (s *Server) handleAircraftList(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	heading := s.headingFormat()
+	reckon := s.deadReckoning()
+	privacy := s.privacyFilter()
+
+	list := make([]aircraftJSON, 0, s.sky.AircraftCount())
+	s.sky.ForEach(func(ac *mode_s.Aircraft) bool {
+		if ac = privacy.Apply(ac); ac != nil {
+			list = append(list, newAircraftJSON(ac, heading, reckon, now))
+		}
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Hex < list[j].Hex })
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"now":      float64(now.UnixNano()) / 1e9,
+		"aircraft": list,
+	})
+}
+
+// GET /data/trails.json, ?since=<unix time> for the positions decoded
+// after it only.
+func (s *Server) handleTrails(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	since, ok := parseSince(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid since")
+		return
+	}
+
+	privacy := s.privacyFilter()
+	trails := make(map[string][][5]float64)
+	s.sky.ForEach(func(ac *mode_s.Aircraft) bool {
+		if ac = privacy.Apply(ac); ac != nil && ac.Trail.Len() > 0 {
+			trails[strings.ToLower(ac.HexAddr)] = trailJSON(ac, since, mode_s.UNITS_AVIATION)
+		}
+		return true
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"now":    float64(now.UnixNano()) / 1e9,
+		"trails": trails,
+	})
+}
+
+// The aircraft as shown by the public endpoints: with privacy applied,
+// without the blocked ones.
+func (s *Server) publicAircrafts() []*mode_s.Aircraft {
+	privacy := s.privacyFilter()
+	var list []*mode_s.Aircraft
+	for _, ac := range s.sky.Aircrafts() {
+		if ac = privacy.Apply(ac); ac != nil {
+			list = append(list, ac)
+		}
+	}
+	return list
+}
+
+// Parse the ?since=<unix time> parameter, the zero time if absent.
+func parseSince(r *http.Request) (time.Time, bool) {
+	v := r.URL.Query().Get("since")
+	if v == "" {
+		return time.Time{}, true
+	}
+	secs, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(secs*1e9)), true
+}
+
+// Parse an address as printed in "hex", '~' marking non-ICAO addresses.
+func parseICAO(s string) (uint32, bool) {
+	var flag uint32
+	if strings.HasPrefix(s, "~") {
+		s, flag = s[1:], mode_s.MODES_NON_ICAO_ADDRESS
+	}
+	if len(s) != 6 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(n) | flag, true
+}
+
+// GET /data/aircraft/{icao}.json
+func (s *Server) handleAircraft(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/data/aircraft/")
+	if !strings.HasSuffix(name, ".json") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	addr, ok := parseICAO(strings.TrimSuffix(name, ".json"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid ICAO address")
+		return
+	}
+
+	ac := s.publicAircraft(addr)
+	if ac == nil {
+		writeError(w, http.StatusNotFound, "aircraft not tracked")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.aircraftDetail(ac))
+}
+
+// An aircraft as shown by the public endpoints, nil if it is not tracked
+// or blocked.
+func (s *Server) publicAircraft(addr uint32) *mode_s.Aircraft {
+	ac := s.sky.Aircraft(addr)
+	if ac == nil {
+		return nil
+	}
+	return s.privacyFilter().Apply(ac)
+}
+
+func (s *Server) aircraftDetail(ac *mode_s.Aircraft) aircraftDetailJSON {
+	d := aircraftDetailJSON{
+		aircraftJSON: newAircraftJSON(ac, s.headingFormat(), s.deadReckoning(), time.Now()),
+		SeenAt:       ac.Seen,
+		Provenance:   ac.Provenance,
+		EHS:          ac.EHS,
+		ADSB:         ac.ADSB,
+		Links:        ac.Links,
+		Anomalies:    ac.Anomalies,
+		Rejected:     ac.PositionsRejected,
+		MsgCounts:    ac.MsgCounts,
+		Trail:        trailJSON(ac, time.Time{}, mode_s.UNITS_AVIATION),
+	}
+	if !ac.LastRA.Time.IsZero() {
+		d.LastRA = &ac.LastRA
+	}
+	if ac.ACASValid {
+		d.ACAS = &ac.ACAS
+	}
+	if ac.FlightStatusValid {
+		d.FS = &ac.FlightStatus
+	}
+	return d
+}
+
+// Mode A/C reply code.
+type modeACJSON struct {
+	Squawk   string  `json:"squawk"`
+	Altitude *int    `json:"alt_baro,omitempty"` /* if a valid Mode C code */
+	SPI      bool    `json:"spi,omitempty"`
+	Seen     float64 `json:"seen"`
+	Messages int64   `json:"messages"`
+	ModeA    string  `json:"mode_a_hex,omitempty"` /* aircraft with this squawk */
+	ModeC    string  `json:"mode_c_hex,omitempty"` /* aircraft at this altitude */
+}
+
+func correlatedHex(addr uint32) string {
+	if addr == 0 {
+		return ""
+	}
+	if addr&mode_s.MODES_NON_ICAO_ADDRESS != 0 {
+		return fmt.Sprintf("~%06x", addr&0xffffff)
+	}
+	return fmt.Sprintf("%06x", addr)
+}
+
+// GET /data/modeac.json
+func (s *Server) handleModeAC(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	targets := s.sky.ModeACTargets()
+
+	list := make([]modeACJSON, 0, len(targets))
+	for _, t := range targets {
+		j := modeACJSON{
+			Squawk:   fmt.Sprintf("%04d", t.Code),
+			SPI:      t.SPI,
+			Seen:     now.Sub(t.Seen).Seconds(),
+			Messages: t.Messages,
+			ModeA:    correlatedHex(t.ModeAAddr),
+			ModeC:    correlatedHex(t.ModeCAddr),
+		}
+		if t.Altitude != mode_s.MODES_AC_INVALID_ALTITUDE {
+			alt := t.Altitude
+			j.Altitude = &alt
+		}
+		list = append(list, j)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"now":     float64(now.UnixNano()) / 1e9,
+		"replies": list,
+	})
+}
+
+// GET /data/receiver.json
+func (s *Server) handleReceiver(w http.ResponseWriter, r *http.Request) {
+	v := map[string]interface{}{
+		"version": "go1090",
+		"refresh": 1000,
+	}
+	if lat, lon, ok := s.sky.ReceiverLocation(); ok {
+		p := s.privacyFilter()
+		v["lat"], v["lon"] = p.Round(lat), p.Round(lon)
+	}
+	writeJSON(w, http.StatusOK, v)
+}